@@ -265,7 +265,6 @@ var _ = Describe("bosh-init", func() {
 			println("#################################################")
 			stdout := deploy()
 			outputLines := strings.Split(stdout, "\n")
-			numOutputLines := len(outputLines)
 
 			doneIndex := 0
 			stepIndex := -1
@@ -312,7 +311,8 @@ var _ = Describe("bosh-init", func() {
 			Expect(deployingSteps[numDeployingSteps-2]).To(MatchRegexp("^  Updating instance 'dummy_job/0'" + stageFinishedPattern))
 			Expect(deployingSteps[numDeployingSteps-1]).To(MatchRegexp("^  Waiting for instance 'dummy_job/0' to be running" + stageFinishedPattern))
 
-			Expect(outputLines[numOutputLines-2]).To(MatchRegexp("^Cleaning up rendered CPI jobs" + stageFinishedPattern))
+			// the installed CPI job is kept for the next deploy to reuse
+			Expect(stdout).ToNot(ContainSubstring("Cleaning up rendered CPI jobs"))
 
 			println("#################################################")
 			println("it sets the ssh password")
@@ -338,6 +338,9 @@ var _ = Describe("bosh-init", func() {
 
 			stdout = deploy()
 
+			Expect(stdout).To(MatchRegexp("(?m)^  Installing job 'cpi'\\.\\.\\. Skipped \\[Already installed\\]"))
+			Expect(stdout).ToNot(ContainSubstring("Cleaning up rendered CPI jobs"))
+
 			Expect(stdout).To(ContainSubstring("Deleting VM"))
 			Expect(stdout).To(ContainSubstring("Stopping jobs on instance 'unknown/0'"))
 			Expect(stdout).To(ContainSubstring("Unmounting disk"))
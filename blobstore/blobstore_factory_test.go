@@ -63,5 +63,20 @@ var _ = Describe("BlobstoreFactory", func() {
 				Expect(blobstore).To(Equal(expectedBlobstore))
 			})
 		})
+
+		Context("when the mbus host is an IPv6 address", func() {
+			It("keeps the brackets around the host in the endpoint", func() {
+				davClient := boshdavcli.NewClient(boshdavcliconf.Config{
+					Endpoint: "https://[2001:db8::1]:6868/blobs",
+					User:     "fake-user",
+					Password: "fake-password",
+				}, &httpClient)
+				expectedBlobstore := NewBlobstore(davClient, fakeUUIDGenerator, fs, logger)
+
+				blobstore, err := blobstoreFactory.Create("https://fake-user:fake-password@[2001:db8::1]:6868")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(blobstore).To(Equal(expectedBlobstore))
+			})
+		})
 	})
 })
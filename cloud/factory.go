@@ -9,6 +9,7 @@ import (
 
 type Factory interface {
 	NewCloud(installation biinstall.Installation, directorID string) (Cloud, error)
+	NewCPICmdRunner(installation biinstall.Installation) (CPICmdRunner, error)
 }
 
 type factory struct {
@@ -30,6 +31,16 @@ func NewFactory(
 }
 
 func (f *factory) NewCloud(installation biinstall.Installation, directorID string) (Cloud, error) {
+	cpiCmdRunner, err := f.NewCPICmdRunner(installation)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCloud(cpiCmdRunner, directorID, f.logger), nil
+}
+
+// NewCPICmdRunner returns a runner of the commands of the installed CPI, to call its methods directly
+func (f *factory) NewCPICmdRunner(installation biinstall.Installation) (CPICmdRunner, error) {
 	cpiJob := installation.Job()
 	target := installation.Target()
 	cpi := CPI{
@@ -43,6 +54,5 @@ func (f *factory) NewCloud(installation biinstall.Installation, directorID strin
 		return nil, bosherr.Errorf("Installed CPI job '%s' does not contain the required executable '%s'", cpiJob.Name, cmdPath)
 	}
 
-	cpiCmdRunner := NewCPICmdRunner(f.cmdRunner, cpi, f.logger)
-	return NewCloud(cpiCmdRunner, directorID, f.logger), nil
+	return NewCPICmdRunner(f.cmdRunner, cpi, f.logger), nil
 }
@@ -176,3 +176,14 @@ func (_m *MockFactory) NewCloud(_param0 installation.Installation, _param1 strin
 func (_mr *_MockFactoryRecorder) NewCloud(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewCloud", arg0, arg1)
 }
+
+func (_m *MockFactory) NewCPICmdRunner(_param0 installation.Installation) (cloud.CPICmdRunner, error) {
+	ret := _m.ctrl.Call(_m, "NewCPICmdRunner", _param0)
+	ret0, _ := ret[0].(cloud.CPICmdRunner)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockFactoryRecorder) NewCPICmdRunner(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewCPICmdRunner", arg0)
+}
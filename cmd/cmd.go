@@ -16,6 +16,9 @@ type Meta struct {
 	Synopsis string
 	Usage    string
 	Env      map[string]MetaEnv
+
+	// Subcommands maps the usage of each subcommand to its synopsis
+	Subcommands map[string]string
 }
 
 type MetaEnv struct {
@@ -37,3 +40,41 @@ var genericEnv = map[string]MetaEnv{
 		Description: "The path where logs will be written",
 	},
 }
+
+var remoteStateEnv = map[string]MetaEnv{
+	"AWS_ACCESS_KEY_ID": MetaEnv{
+		Description: "The access key id of a deployment state stored in an S3 bucket (s3://<bucket>/<key>?region=<region>&endpoint=<url>)",
+	},
+	"AWS_SECRET_ACCESS_KEY": MetaEnv{
+		Description: "The secret access key of a deployment state stored in an S3 bucket",
+	},
+}
+
+var stateKeyEnv = map[string]MetaEnv{
+	"BOSH_INIT_STATE_KEY": MetaEnv{
+		Example:     "$(openssl rand -base64 32)",
+		Description: "The base64 encoded 32 bytes key encrypting the deployment state & its history",
+	},
+	"BOSH_INIT_STATE_KEY_FILE": MetaEnv{
+		Example:     "/path/to/state.key",
+		Description: "The path of a file holding the base64 encoded key encrypting the deployment state, unless BOSH_INIT_STATE_KEY is set",
+	},
+}
+
+var compiledPackageCacheEnv = map[string]MetaEnv{
+	"BOSH_INIT_COMPILED_PACKAGE_CACHE": MetaEnv{
+		Example:     "true",
+		Default:     "false",
+		Description: "Share compiled packages between deployments using the same stemcell, via a cache in ~/.bosh_init/compiled_packages",
+	},
+}
+
+func mergeEnv(envs ...map[string]MetaEnv) map[string]MetaEnv {
+	merged := map[string]MetaEnv{}
+	for _, env := range envs {
+		for name, metaEnv := range env {
+			merged[name] = metaEnv
+		}
+	}
+	return merged
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
+	biinstall "github.com/cloudfoundry/bosh-init/installation"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// CpiCaller calls a method of the CPI of a deployment directly, to debug the CPI without deploying
+type CpiCaller interface {
+	Call(method string, args []interface{}, withRegistry bool, stage biui.Stage) (bicloud.CmdOutput, error)
+}
+
+func NewCpiCaller(
+	ui biui.UI,
+	logTag string,
+	logger boshlog.Logger,
+	deploymentStateService biconfig.DeploymentStateService,
+	releaseManager birel.Manager,
+	cloudFactory bicloud.Factory,
+	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
+	cpiInstaller bicpirel.CpiInstaller,
+	releaseFetcher birel.Fetcher,
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser,
+) CpiCaller {
+	return &cpiCaller{
+		ui:                                      ui,
+		logTag:                                  logTag,
+		logger:                                  logger,
+		deploymentStateService:                  deploymentStateService,
+		releaseManager:                          releaseManager,
+		cloudFactory:                            cloudFactory,
+		deploymentManifestPath:                  deploymentManifestPath,
+		deploymentVars:                          deploymentVars,
+		deploymentOps:                           deploymentOps,
+		cpiInstaller:                            cpiInstaller,
+		releaseFetcher:                          releaseFetcher,
+		releaseSetAndInstallationManifestParser: releaseSetAndInstallationManifestParser,
+	}
+}
+
+type cpiCaller struct {
+	ui                                      biui.UI
+	logTag                                  string
+	logger                                  boshlog.Logger
+	deploymentStateService                  biconfig.DeploymentStateService
+	releaseManager                          birel.Manager
+	cloudFactory                            bicloud.Factory
+	deploymentManifestPath                  string
+	deploymentVars                          bivars.Variables
+	deploymentOps                           bipatch.Ops
+	cpiInstaller                            bicpirel.CpiInstaller
+	releaseFetcher                          birel.Fetcher
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser
+}
+
+// Call installs the CPI & runs the method with the director_uuid of the deployment state in its context.
+// Without a deployment state the context has an empty director_uuid, as the state is not created just for debugging.
+func (c *cpiCaller) Call(method string, args []interface{}, withRegistry bool, stage biui.Stage) (bicloud.CmdOutput, error) {
+	c.ui.PrintLinef("Deployment state: '%s'", c.deploymentStateService.Path())
+
+	var directorID string
+	if c.deploymentStateService.Exists() {
+		deploymentState, err := c.deploymentStateService.Load()
+		if err != nil {
+			return bicloud.CmdOutput{}, bosherr.WrapError(err, "Loading deployment state")
+		}
+		directorID = deploymentState.DirectorID
+	} else {
+		c.ui.PrintLinef("No deployment state file found, calling the CPI with an empty director_uuid.")
+	}
+
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	var installationManifest biinstallmanifest.Manifest
+	err := stage.PerformComplex("validating", func(stage biui.Stage) error {
+		var releaseSetManifest birelsetmanifest.Manifest
+		var err error
+		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps)
+		if err != nil {
+			return err
+		}
+
+		cpiReleaseName := installationManifest.Template.Release
+		cpiReleaseRef, found := releaseSetManifest.FindByName(cpiReleaseName)
+		if !found {
+			return bosherr.Errorf("installation release '%s' must refer to a release in releases", cpiReleaseName)
+		}
+
+		err = c.releaseFetcher.DownloadAndExtract(cpiReleaseRef, stage)
+		if err != nil {
+			return err
+		}
+
+		return c.cpiInstaller.ValidateCpiRelease(installationManifest, stage)
+	})
+	if err != nil {
+		return bicloud.CmdOutput{}, err
+	}
+
+	var cmdOutput bicloud.CmdOutput
+	err = c.cpiInstaller.WithInstalledCpiRelease(installationManifest, stage, func(installation biinstall.Installation) error {
+		cpiCmdRunner, err := c.cloudFactory.NewCPICmdRunner(installation)
+		if err != nil {
+			return bosherr.WrapError(err, "Creating CPI command runner from CPI installation")
+		}
+
+		run := func() error {
+			cmdOutput, err = cpiCmdRunner.Run(bicloud.CmdContext{DirectorID: directorID}, method, args...)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Calling CPI method '%s'", method)
+			}
+			return nil
+		}
+
+		if withRegistry {
+			return installation.WithRunningRegistry(c.logger, stage, run)
+		}
+		return run()
+	})
+
+	return cmdOutput, err
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type cpiCmd struct {
+	cpiCallerProvider func(deploymentManifestPath string, deploymentStatePath string, raw bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (CpiCaller, error)
+	ui                biui.UI
+	fs                boshsys.FileSystem
+	logger            boshlog.Logger
+	logTag            string
+}
+
+func NewCpiCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	cpiCallerProvider func(deploymentManifestPath string, deploymentStatePath string, raw bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (CpiCaller, error),
+) Cmd {
+	return &cpiCmd{
+		ui:                ui,
+		fs:                fs,
+		cpiCallerProvider: cpiCallerProvider,
+		logger:            logger,
+		logTag:            "cpiCmd",
+	}
+}
+
+type cpiCmdInputs struct {
+	deploymentManifestPath string
+	method                 string
+	args                   []interface{}
+	withRegistry           bool
+	raw                    bool
+}
+
+func (c *cpiCmd) Name() string {
+	return "cpi"
+}
+
+func (c *cpiCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Call a method of the CPI of a deployment directly, with its arguments as a JSON array",
+		Usage:    "<deployment_manifest_path> <method> [<json_arguments>] [--registry] [--raw] " + stateOptionsUsage + " " + manifestOptionsUsage,
+		Env:      mergeEnv(genericEnv, remoteStateEnv, stateKeyEnv),
+	}
+}
+
+func (c *cpiCmd) Run(stage biui.Stage, args []string) error {
+	inputs, deploymentStateOptions, deploymentManifestOptions, err := c.parseCmdInputs(args)
+	if err != nil {
+		return err
+	}
+
+	manifestAbsFilePath, err := filepath.Abs(inputs.deploymentManifestPath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", inputs.deploymentManifestPath)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", inputs.deploymentManifestPath)
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+
+	stateAbsFilePath, err := deploymentStateAbsPath(deploymentStateOptions.path, manifestAbsFilePath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+	}
+
+	deploymentOps, err := deploymentManifestOptions.Ops(c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest ops files")
+		return bosherr.WrapError(err, "Loading deployment manifest ops files")
+	}
+
+	deploymentVars, err := deploymentManifestOptions.Variables(manifestAbsFilePath, deploymentOps, c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest variables")
+		return bosherr.WrapError(err, "Loading deployment manifest variables")
+	}
+
+	cpiCaller, err := c.cpiCallerProvider(manifestAbsFilePath, stateAbsFilePath, inputs.raw, deploymentVars, deploymentOps)
+	if err != nil {
+		return err
+	}
+
+	cmdOutput, err := cpiCaller.Call(inputs.method, inputs.args, inputs.withRegistry, stage)
+	if err != nil {
+		return err
+	}
+
+	return c.printCmdOutput(inputs.method, cmdOutput)
+}
+
+// printCmdOutput pretty-prints the result, error & log of the CPI method, returning an error if it failed
+func (c *cpiCmd) printCmdOutput(method string, cmdOutput bicloud.CmdOutput) error {
+	result, err := json.MarshalIndent(cmdOutput.Result, "", "  ")
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshalling the result of CPI method '%s'", method)
+	}
+
+	c.ui.PrintLinef("")
+	c.ui.PrintLinef("Result:")
+	c.ui.PrintLinef("%s", result)
+
+	if cmdOutput.Error != nil {
+		c.ui.PrintLinef("")
+		c.ui.PrintLinef("Error:")
+		c.ui.PrintLinef("  Type: %s", cmdOutput.Error.Type)
+		c.ui.PrintLinef("  Message: %s", cmdOutput.Error.Message)
+		c.ui.PrintLinef("  OK to retry: %t", cmdOutput.Error.OkToRetry)
+	}
+
+	if cmdOutput.Log != "" {
+		c.ui.PrintLinef("")
+		c.ui.PrintLinef("Log:")
+		c.ui.PrintLinef("%s", cmdOutput.Log)
+	}
+
+	if cmdOutput.Error != nil {
+		return bosherr.Errorf("CPI method '%s' returned an error: %s", method, cmdOutput.Error.Message)
+	}
+
+	return nil
+}
+
+func (c *cpiCmd) parseCmdInputs(args []string) (cpiCmdInputs, stateOptions, manifestOptions, error) {
+	inputs := cpiCmdInputs{}
+
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return inputs, deploymentStateOptions, manifestOptions{}, err
+	}
+
+	deploymentManifestOptions, remainingArgs, err := extractManifestOptions(remainingArgs)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return inputs, deploymentStateOptions, deploymentManifestOptions, err
+	}
+
+	positionalArgs := []string{}
+	for _, arg := range remainingArgs {
+		switch arg {
+		case "--registry":
+			inputs.withRegistry = true
+		case "--raw":
+			inputs.raw = true
+		default:
+			positionalArgs = append(positionalArgs, arg)
+		}
+	}
+
+	if len(positionalArgs) < 2 || len(positionalArgs) > 3 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return inputs, deploymentStateOptions, deploymentManifestOptions, errors.New("Invalid usage - cpi command requires a deployment manifest, a method & optionally its JSON arguments")
+	}
+
+	inputs.deploymentManifestPath = positionalArgs[0]
+	inputs.method = positionalArgs[1]
+	inputs.args = []interface{}{}
+
+	if len(positionalArgs) == 3 {
+		err = json.Unmarshal([]byte(positionalArgs[2]), &inputs.args)
+		if err != nil {
+			c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+			return inputs, deploymentStateOptions, deploymentManifestOptions, bosherr.WrapError(err, "Invalid usage - cpi command arguments must be a JSON array")
+		}
+	}
+
+	return inputs, deploymentStateOptions, deploymentManifestOptions, nil
+}
@@ -0,0 +1,140 @@
+package cmd_test
+
+import (
+	bicmd "github.com/cloudfoundry/bosh-init/cmd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	mock_cmd "github.com/cloudfoundry/bosh-init/cmd/mocks"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("CpiCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("Run", func() {
+		var (
+			mockCpiCaller *mock_cmd.MockCpiCaller
+			fs            *fakesys.FakeFileSystem
+			logger        boshlog.Logger
+
+			fakeUI                 *fakebiui.FakeUI
+			fakeStage              *fakebiui.FakeStage
+			deploymentManifestPath = "/deployment-dir/fake-deployment-manifest.yml"
+			receivedStatePath      string
+			receivedRaw            bool
+		)
+
+		var newCpiCmd = func() bicmd.Cmd {
+			doGetFunc := func(manifestPath string, statePath string, raw bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.CpiCaller, error) {
+				Expect(manifestPath).To(Equal(deploymentManifestPath))
+				receivedStatePath = statePath
+				receivedRaw = raw
+				return mockCpiCaller, nil
+			}
+
+			return bicmd.NewCpiCmd(fakeUI, fs, logger, doGetFunc)
+		}
+
+		BeforeEach(func() {
+			mockCpiCaller = mock_cmd.NewMockCpiCaller(mockCtrl)
+			fs = fakesys.NewFakeFileSystem()
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fakeUI = &fakebiui.FakeUI{}
+			fakeStage = fakebiui.NewFakeStage()
+			fs.WriteFileString(deploymentManifestPath, `---manifest-content`)
+		})
+
+		It("calls the CPI method with its JSON arguments & pretty-prints the result & the log", func() {
+			mockCpiCaller.EXPECT().Call("create_disk", []interface{}{float64(1024), map[string]interface{}{"type": "gp2"}, "fake-vm-cid"}, false, fakeStage).Return(bicloud.CmdOutput{
+				Result: "fake-disk-cid",
+				Log:    "fake-cpi-log",
+			}, nil)
+
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath, "create_disk", `[1024, {"type": "gp2"}, "fake-vm-cid"]`})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(receivedStatePath).To(Equal("/deployment-dir/fake-deployment-manifest-state.json"))
+			Expect(receivedRaw).To(BeFalse())
+			Expect(fakeUI.Said).To(ContainElement("Result:"))
+			Expect(fakeUI.Said).To(ContainElement(`"fake-disk-cid"`))
+			Expect(fakeUI.Said).To(ContainElement("fake-cpi-log"))
+		})
+
+		It("calls the CPI method without arguments unless given", func() {
+			mockCpiCaller.EXPECT().Call("info", []interface{}{}, false, fakeStage).Return(bicloud.CmdOutput{}, nil)
+
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath, "info"})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("passes --registry, --raw & --state on", func() {
+			mockCpiCaller.EXPECT().Call("create_vm", []interface{}{}, true, fakeStage).Return(bicloud.CmdOutput{}, nil)
+
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath, "create_vm", "[]", "--registry", "--raw", "--state", "/fake-state.json"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(receivedRaw).To(BeTrue())
+			Expect(receivedStatePath).To(Equal("/fake-state.json"))
+		})
+
+		It("prints the error returned by the CPI & returns it", func() {
+			mockCpiCaller.EXPECT().Call("delete_vm", []interface{}{"fake-vm-cid"}, false, fakeStage).Return(bicloud.CmdOutput{
+				Error: &bicloud.CmdError{
+					Type:      "Bosh::Clouds::VMNotFound",
+					Message:   "fake-cpi-error",
+					OkToRetry: true,
+				},
+			}, nil)
+
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath, "delete_vm", `["fake-vm-cid"]`})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CPI method 'delete_vm' returned an error: fake-cpi-error"))
+
+			Expect(fakeUI.Said).To(ContainElement("  Type: Bosh::Clouds::VMNotFound"))
+			Expect(fakeUI.Said).To(ContainElement("  OK to retry: true"))
+		})
+
+		It("returns the error of the CPI caller", func() {
+			mockCpiCaller.EXPECT().Call("info", []interface{}{}, false, fakeStage).Return(bicloud.CmdOutput{}, bosherr.Error("fake-call-error"))
+
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath, "info"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-call-error"))
+		})
+
+		It("returns an error when the arguments are not a JSON array", func() {
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath, "create_vm", `{"not": "an array"}`})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("cpi command arguments must be a JSON array"))
+		})
+
+		It("returns an error unless a deployment manifest & a method are given", func() {
+			err := newCpiCmd().Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage - cpi command requires a deployment manifest, a method"))
+		})
+
+		It("returns an error when the deployment manifest does not exist", func() {
+			err := newCpiCmd().Run(fakeStage, []string{"/garbage", "info"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment manifest does not exist"))
+		})
+	})
+})
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"path/filepath"
 
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -11,7 +13,7 @@ import (
 )
 
 type deleteCmd struct {
-	deploymentDeleterProvider func(deploymentManifestString string) (DeploymentDeleter, error)
+	deploymentDeleterProvider func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (DeploymentDeleter, error)
 	ui                        biui.UI
 	fs                        boshsys.FileSystem
 	logger                    boshlog.Logger
@@ -22,7 +24,7 @@ func NewDeleteCmd(
 	ui biui.UI,
 	fs boshsys.FileSystem,
 	logger boshlog.Logger,
-	deploymentDeleterProvider func(deploymentManifestString string) (DeploymentDeleter, error),
+	deploymentDeleterProvider func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (DeploymentDeleter, error),
 ) Cmd {
 	return &deleteCmd{
 		ui: ui,
@@ -40,31 +42,62 @@ func (c *deleteCmd) Name() string {
 func (c *deleteCmd) Meta() Meta {
 	return Meta{
 		Synopsis: "Delete existing deployment",
-		Usage:    "<deployment_manifest_path>",
-		Env:      genericEnv,
+		Usage:    "<deployment_manifest_path>... " + stateOptionsUsage + " " + manifestOptionsUsage,
+		Env:      mergeEnv(genericEnv, remoteStateEnv, stateKeyEnv),
 	}
 }
 
 func (c *deleteCmd) Run(stage biui.Stage, args []string) error {
-	deploymentManifestPath, err := c.parseCmdInputs(args)
+	deploymentManifestPaths, deploymentStateOptions, deploymentManifestOptions, err := c.parseCmdInputs(args)
 	if err != nil {
 		return err
 	}
 
-	manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+	manifestAbsFilePaths := make([]string, len(deploymentManifestPaths))
+	for i, deploymentManifestPath := range deploymentManifestPaths {
+		manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+		if err != nil {
+			c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
+			return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+		}
+
+		if !c.fs.FileExists(manifestAbsFilePath) {
+			c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+			return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+		}
+
+		c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+		manifestAbsFilePaths[i] = manifestAbsFilePath
+	}
+
+	stateAbsFilePath, err := deploymentStateAbsPath(deploymentStateOptions.path, manifestAbsFilePaths[0])
 	if err != nil {
-		c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
-		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+		c.ui.ErrorLinef("Failed getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
 	}
 
-	if !c.fs.FileExists(manifestAbsFilePath) {
-		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
-		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	// the other manifests are merged into the first one, before the ops files are applied.
+	// Relative paths in them resolve relative to the first one.
+	deploymentOps, err := bipatch.LoadMergeOps(manifestAbsFilePaths[1:], c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifests")
+		return bosherr.WrapError(err, "Loading deployment manifests")
 	}
 
-	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+	opsFilesOps, err := deploymentManifestOptions.Ops(c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest ops files")
+		return bosherr.WrapError(err, "Loading deployment manifest ops files")
+	}
+	deploymentOps = append(deploymentOps, opsFilesOps...)
+
+	deploymentVars, err := deploymentManifestOptions.Variables(manifestAbsFilePaths[0], deploymentOps, c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest variables")
+		return bosherr.WrapError(err, "Loading deployment manifest variables")
+	}
 
-	deploymentDeleter, err := c.deploymentDeleterProvider(manifestAbsFilePath)
+	deploymentDeleter, err := c.deploymentDeleterProvider(manifestAbsFilePaths[0], stateAbsFilePath, deploymentStateOptions.forceUnlock, deploymentVars, deploymentOps)
 	if err != nil {
 		return err
 	}
@@ -72,10 +105,22 @@ func (c *deleteCmd) Run(stage biui.Stage, args []string) error {
 	return deploymentDeleter.DeleteDeployment(stage)
 }
 
-func (c *deleteCmd) parseCmdInputs(args []string) (string, error) {
-	if len(args) != 1 {
+func (c *deleteCmd) parseCmdInputs(args []string) ([]string, stateOptions, manifestOptions, error) {
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, deploymentStateOptions, manifestOptions{}, err
+	}
+
+	deploymentManifestOptions, remainingArgs, err := extractManifestOptions(remainingArgs)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, deploymentStateOptions, deploymentManifestOptions, err
+	}
+
+	if len(remainingArgs) == 0 {
 		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
-		return "", errors.New("Invalid usage - delete command requires exactly 1 argument")
+		return nil, deploymentStateOptions, deploymentManifestOptions, errors.New("Invalid usage - delete command requires at least 1 argument")
 	}
-	return args[0], nil
+	return remainingArgs, deploymentStateOptions, deploymentManifestOptions, nil
 }
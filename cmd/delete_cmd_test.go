@@ -7,6 +7,8 @@ import (
 
 	"code.google.com/p/gomock/gomock"
 	mock_cmd "github.com/cloudfoundry/bosh-init/cmd/mocks"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -36,11 +38,18 @@ var _ = Describe("DeleteCmd", func() {
 			fakeUI                 *fakeui.FakeUI
 			fakeStage              *fakebiui.FakeStage
 			deploymentManifestPath = "/deployment-dir/fake-deployment-manifest.yml"
+
+			receivedDeploymentStatePath string
+			receivedForceUnlock         bool
+			receivedDeploymentOps       bipatch.Ops
 		)
 
 		var newDeleteCmd = func() bicmd.Cmd {
-			doGetFunc := func(deploymentManifestPath string) (bicmd.DeploymentDeleter, error) {
+			doGetFunc := func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.DeploymentDeleter, error) {
 				Expect(deploymentManifestPath).To(Equal(deploymentManifestPath))
+				receivedDeploymentStatePath = deploymentStatePath
+				receivedForceUnlock = forceUnlock
+				receivedDeploymentOps = deploymentOps
 				return mockDeploymentDeleter, nil
 			}
 
@@ -74,6 +83,48 @@ var _ = Describe("DeleteCmd", func() {
 				newDeleteCmd().Run(fakeStage, []string{deploymentManifestPath})
 			})
 
+			It("uses the deployment state file next to the deployment manifest by default", func() {
+				mockDeploymentDeleter.EXPECT().DeleteDeployment(fakeStage).Return(nil)
+				err := newDeleteCmd().Run(fakeStage, []string{deploymentManifestPath})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentStatePath).To(Equal("/deployment-dir/fake-deployment-manifest-state.json"))
+			})
+
+			It("uses the deployment state file given with --state", func() {
+				mockDeploymentDeleter.EXPECT().DeleteDeployment(fakeStage).Return(nil)
+				err := newDeleteCmd().Run(fakeStage, []string{"--state", "/other-dir/state.json", deploymentManifestPath})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentStatePath).To(Equal("/other-dir/state.json"))
+			})
+
+			It("passes the URL of a remote deployment state given with --state as is", func() {
+				mockDeploymentDeleter.EXPECT().DeleteDeployment(fakeStage).Return(nil)
+				err := newDeleteCmd().Run(fakeStage, []string{"--state", "s3://fake-bucket/state.json", deploymentManifestPath})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentStatePath).To(Equal("s3://fake-bucket/state.json"))
+			})
+
+			It("forces the unlock of the deployment state with --force-unlock", func() {
+				mockDeploymentDeleter.EXPECT().DeleteDeployment(fakeStage).Return(nil).Times(2)
+				err := newDeleteCmd().Run(fakeStage, []string{deploymentManifestPath})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedForceUnlock).To(BeFalse())
+
+				err = newDeleteCmd().Run(fakeStage, []string{deploymentManifestPath, "--force-unlock"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedForceUnlock).To(BeTrue())
+			})
+
+			It("merges the other deployment manifests into the first one", func() {
+				fs.WriteFileString("/deployment-dir/cpi.yml", "---\ncloud_provider: {}\n")
+
+				mockDeploymentDeleter.EXPECT().DeleteDeployment(fakeStage).Return(nil)
+				err := newDeleteCmd().Run(fakeStage, []string{deploymentManifestPath, "/deployment-dir/cpi.yml"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentOps).To(HaveLen(1))
+				Expect(receivedDeploymentOps[0].String()).To(Equal("merge '/deployment-dir/cpi.yml'"))
+			})
+
 			Context("when the deployment deleter returns an error", func() {
 				It("sends the manifest on to the deleter", func() {
 					err := bosherr.Error("boom")
@@ -84,14 +135,14 @@ var _ = Describe("DeleteCmd", func() {
 			})
 		})
 
-		It("returns err unless exactly 1 arguments is given", func() {
+		It("returns err unless at least 1 argument is given", func() {
 			command := newDeleteCmd()
 
 			err := command.Run(fakeStage, []string{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
 
-			err = command.Run(fakeStage, []string{"1", "2"})
+			err = command.Run(fakeStage, []string{"--state"})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
 		})
@@ -22,7 +22,10 @@ import (
 	mock_stemcell "github.com/cloudfoundry/bosh-init/stemcell/mocks"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
@@ -279,7 +282,8 @@ func rootDesc() {
 			fakeCPIRelease.ReleaseVersion = "1.0"
 			fakeCPIRelease.ReleaseJobs = []bireljob.Job{
 				{
-					Name: "fake-cpi-release-job-name",
+					Name:        "fake-cpi-release-job-name",
+					Fingerprint: "fake-cpi-release-job-fingerprint",
 					Templates: map[string]string{
 						"templates/cpi.erb": "bin/cpi",
 					},
@@ -293,12 +297,12 @@ func rootDesc() {
 
 		JustBeforeEach(func() {
 
-			doGet := func(deploymentManifestPath string) (bicmd.DeploymentPreparer, error) {
-				deploymentStateService := biconfig.NewFileSystemDeploymentStateService(fakeFs, configUUIDGenerator, logger, biconfig.DeploymentStatePath(deploymentManifestPath))
+			doGet := func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.DeploymentPreparer, error) {
+				deploymentStateService := biconfig.NewFileSystemDeploymentStateService(fakeFs, configUUIDGenerator, logger, deploymentStatePath)
 				deploymentRepo := biconfig.NewDeploymentRepo(deploymentStateService)
 				releaseRepo := biconfig.NewReleaseRepo(deploymentStateService, fakeUUIDGenerator)
 				stemcellRepo := biconfig.NewStemcellRepo(deploymentStateService, fakeUUIDGenerator)
-				deploymentRecord := deployment.NewRecord(deploymentRepo, releaseRepo, stemcellRepo, sha1Calculator)
+				deploymentRecord := deployment.NewRecord(deploymentRepo, releaseRepo, stemcellRepo, fakeFs)
 
 				fakeHTTPClient := fakebihttpclient.NewFakeHTTPClient()
 				tarballCache := bitarball.NewCache("fake-base-path", fakeFs, logger)
@@ -323,6 +327,7 @@ func rootDesc() {
 					DeploymentParser:    fakeDeploymentParser,
 					DeploymentValidator: fakeDeploymentValidator,
 					ReleaseManager:      releaseManager,
+					UI:                  userInterface,
 				}
 
 				return bicmd.NewDeploymentPreparer(
@@ -330,6 +335,7 @@ func rootDesc() {
 					logger,
 					"deployCmd",
 					deploymentStateService,
+					forceUnlock,
 					mockLegacyDeploymentStateMigrator,
 					releaseManager,
 					deploymentRecord,
@@ -340,6 +346,8 @@ func rootDesc() {
 					mockBlobstoreFactory,
 					mockDeployer,
 					deploymentManifestPath,
+					deploymentVars,
+					deploymentOps,
 					cpiInstaller,
 					releaseFetcher,
 					stemcellFetcher,
@@ -379,7 +387,6 @@ func rootDesc() {
 			expectInstall = mockInstaller.EXPECT().InstallPackagesAndJobs(installationManifest, gomock.Any()).Do(func(_ interface{}, stage biui.Stage) {
 				Expect(fakeStage.SubStages).To(ContainElement(stage))
 			}).Return(installation, nil).AnyTimes()
-			mockInstaller.EXPECT().Cleanup(installation).AnyTimes()
 
 			mockDeployment := mock_deployment.NewMockDeployment(mockCtrl)
 
@@ -387,11 +394,12 @@ func rootDesc() {
 				cloud,
 				boshDeploymentManifest,
 				cloudStemcell,
+				extractedStemcell,
 				installationManifest.Registry,
 				fakeVMManager,
 				mockBlobstore,
 				gomock.Any(),
-			).Do(func(_, _, _, _, _, _ interface{}, stage biui.Stage) {
+			).Do(func(_, _, _, _, _, _, _ interface{}, stage biui.Stage) {
 				Expect(fakeStage.SubStages).To(ContainElement(stage))
 			}).Return(mockDeployment, nil).AnyTimes()
 
@@ -408,6 +416,92 @@ func rootDesc() {
 			Expect(stdOut).To(gbytes.Say("Deployment state: '/path/to/manifest-state.json'"))
 		})
 
+		It("prints the warnings of the deployment jobs validation", func() {
+			fakeDeploymentValidator.SetValidateReleaseJobsBehavior([]fakebideplval.ValidateReleaseJobsOutput{
+				{Warnings: []bivalidation.Problem{bivalidation.Warningf("/properties/fake-prop-key", "fake-warning")}},
+			})
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdOut).To(gbytes.Say("Warning: fake-warning"))
+		})
+
+		It("uses the deployment state file given with --state", func() {
+			err := command.Run(fakeStage, []string{deploymentManifestPath, "--state", "/path/to/other-state.json"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdOut).To(gbytes.Say("Deployment state: '/path/to/other-state.json'"))
+			Expect(fakeFs.FileExists("/path/to/other-state.json")).To(BeTrue())
+		})
+
+		It("locks the deployment state during the deploy", func() {
+			expectDeploy.Do(func(_, _, _, _, _, _, _, _ interface{}) {
+				Expect(fakeFs.FileExists("/path/to/manifest-state.json.lock")).To(BeTrue())
+			}).Times(1)
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeFs.FileExists("/path/to/manifest-state.json.lock")).To(BeFalse())
+		})
+
+		It("records the deployment state written by the deploy in its history", func() {
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).NotTo(HaveOccurred())
+
+			versions, err := setupDeploymentStateService.History()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(HaveLen(1))
+			Expect(versions[0].Command).To(Equal("deploy"))
+			Expect(versions[0].State.DirectorID).To(Equal(directorID))
+		})
+
+		Context("when another run holds the lock of the deployment state", func() {
+			BeforeEach(func() {
+				fakeFs.WriteFileString("/path/to/manifest-state.json.lock", `{"pid": 1234, "host": "fake-other-host", "user": "fake-user"}`)
+			})
+
+			It("returns an error without deploying", func() {
+				expectDeploy.Times(0)
+
+				err := command.Run(fakeStage, []string{deploymentManifestPath})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("is locked by pid 1234 of user 'fake-user' on host 'fake-other-host'"))
+				Expect(fakeFs.FileExists("/path/to/manifest-state.json.lock")).To(BeTrue())
+			})
+
+			It("deploys with --force-unlock", func() {
+				expectDeploy.Times(1)
+
+				err := command.Run(fakeStage, []string{deploymentManifestPath, "--force-unlock"})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		It("merges the other deployment manifests into the first one, before applying the ops files", func() {
+			fakeFs.WriteFileString("/path/to/cpi.yml", "---\ncloud_provider: {template: {name: cpi}}\n")
+			fakeFs.WriteFileString("/path/to/ops.yml", "- type: replace\n  path: /name?\n  value: fake-name\n")
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath, "/path/to/cpi.yml", "--ops-file", "/path/to/ops.yml"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stdOut).To(gbytes.Say("Deployment manifest: '/path/to/manifest.yml'"))
+			Expect(stdOut).To(gbytes.Say("Deployment manifest: '/path/to/cpi.yml'"))
+			Expect(stdOut).To(gbytes.Say("Deployment state: '/path/to/manifest-state.json'"))
+
+			Expect(fakeInstallationParser.ParsePath).To(Equal(deploymentManifestPath))
+			Expect(fakeInstallationParser.ParseOps).To(HaveLen(2))
+			Expect(fakeInstallationParser.ParseOps[0].String()).To(Equal("merge '/path/to/cpi.yml'"))
+			Expect(fakeInstallationParser.ParseOps[1].String()).To(Equal("replace '/name?'"))
+			Expect(fakeDeploymentParser.ParseOps).To(Equal(fakeInstallationParser.ParseOps))
+		})
+
+		It("returns an error when one of the deployment manifests does not exist", func() {
+			err := command.Run(fakeStage, []string{deploymentManifestPath, "/path/to/missing.yml"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment manifest does not exist at '/path/to/missing.yml'"))
+		})
+
 		It("does not migrate the legacy bosh-deployments.yml if manifest-state.json exists", func() {
 			err := fakeFs.WriteFileString(deploymentStatePath, "{}")
 			Expect(err).ToNot(HaveOccurred())
@@ -446,6 +540,25 @@ func rootDesc() {
 			Expect(fakeDeploymentParser.ParsePath).To(Equal(deploymentManifestPath))
 		})
 
+		It("parses the manifests with the variables of the --var options", func() {
+			err := command.Run(fakeStage, []string{deploymentManifestPath, "--var", "password=fake-password"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeInstallationParser.ParseVariables).To(Equal(bivars.Variables{"password": "fake-password"}))
+			Expect(fakeDeploymentParser.ParseVariables).To(Equal(bivars.Variables{"password": "fake-password"}))
+		})
+
+		It("parses the manifests with the ops of the --ops-file options", func() {
+			opsYAML := "- type: replace\n  path: /properties?/password\n  value: fake-password\n"
+			fakeFs.WriteFileString("/path/to/ops.yml", opsYAML)
+			expectedOps, err := bipatch.ParseOps([]byte(opsYAML))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = command.Run(fakeStage, []string{deploymentManifestPath, "--ops-file", "/path/to/ops.yml"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeInstallationParser.ParseOps).To(Equal(expectedOps))
+			Expect(fakeDeploymentParser.ParseOps).To(Equal(expectedOps))
+		})
+
 		It("validates bosh deployment manifest", func() {
 			err := command.Run(fakeStage, []string{deploymentManifestPath})
 			Expect(err).NotTo(HaveOccurred())
@@ -504,6 +617,15 @@ func rootDesc() {
 			}))
 		})
 
+		It("keeps the installed CPI for the next deploy to reuse", func() {
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeStage.PerformCalls).ToNot(ContainElement(&fakebiui.PerformCall{
+				Name: "Cleaning up rendered CPI jobs",
+			}))
+		})
+
 		It("adds a new 'Starting registry' event logger stage", func() {
 			err := command.Run(fakeStage, []string{deploymentManifestPath})
 			Expect(err).NotTo(HaveOccurred())
@@ -583,6 +705,9 @@ func rootDesc() {
 					ID:      "fake-uuid-0",
 					Name:    fakeCPIRelease.Name(),
 					Version: fakeCPIRelease.Version(),
+					Jobs: []biconfig.FingerprintRecord{
+						{Name: "fake-cpi-release-job-name", Fingerprint: "fake-cpi-release-job-fingerprint"},
+					},
 				},
 			}))
 		})
@@ -603,6 +728,9 @@ func rootDesc() {
 						ID:      "my-release-id-1",
 						Name:    fakeCPIRelease.Name(),
 						Version: fakeCPIRelease.Version(),
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "fake-cpi-release-job-name", Fingerprint: "fake-cpi-release-job-fingerprint"},
+						},
 					}},
 					CurrentStemcellID: "my-stemcellRecordID",
 					Stemcells: []biconfig.StemcellRecord{{
@@ -669,7 +797,7 @@ func rootDesc() {
 				fakeFs.WriteFileString(otherReleaseTarballPath, "")
 
 				fakeOtherRelease = fakebirel.New("other-release", "1234")
-				fakeOtherRelease.ReleaseJobs = []bireljob.Job{{Name: "not-cpi"}}
+				fakeOtherRelease.ReleaseJobs = []bireljob.Job{{Name: "not-cpi", Fingerprint: "not-cpi-fingerprint"}}
 
 				expectOtherReleaseExtract = mockReleaseExtractor.EXPECT().Extract(
 					otherReleaseTarballPath,
@@ -718,11 +846,17 @@ func rootDesc() {
 						ID:      "fake-uuid-0",
 						Name:    fakeCPIRelease.Name(),
 						Version: fakeCPIRelease.Version(),
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "fake-cpi-release-job-name", Fingerprint: "fake-cpi-release-job-fingerprint"},
+						},
 					},
 					{
 						ID:      "fake-uuid-1",
 						Name:    fakeOtherRelease.Name(),
 						Version: fakeOtherRelease.Version(),
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "not-cpi", Fingerprint: "not-cpi-fingerprint"},
+						},
 					},
 				}))
 			})
@@ -739,11 +873,17 @@ func rootDesc() {
 								ID:      "existing-release-id-1",
 								Name:    fakeCPIRelease.Name(),
 								Version: fakeCPIRelease.Version(),
+								Jobs: []biconfig.FingerprintRecord{
+									{Name: "fake-cpi-release-job-name", Fingerprint: "fake-cpi-release-job-fingerprint"},
+								},
 							},
 							{
 								ID:      "existing-release-id-2",
 								Name:    fakeOtherRelease.Name(),
 								Version: olderReleaseVersion,
+								Jobs: []biconfig.FingerprintRecord{
+									{Name: "not-cpi", Fingerprint: "older-not-cpi-fingerprint"},
+								},
 							},
 						},
 						CurrentStemcellID: "my-stemcellRecordID",
@@ -791,11 +931,17 @@ func rootDesc() {
 								ID:      "my-release-id-1",
 								Name:    fakeCPIRelease.Name(),
 								Version: fakeCPIRelease.Version(),
+								Jobs: []biconfig.FingerprintRecord{
+									{Name: "fake-cpi-release-job-name", Fingerprint: "fake-cpi-release-job-fingerprint"},
+								},
 							},
 							{
 								ID:      "my-release-id-2",
 								Name:    fakeOtherRelease.Name(),
 								Version: fakeOtherRelease.Version(),
+								Jobs: []biconfig.FingerprintRecord{
+									{Name: "not-cpi", Fingerprint: "not-cpi-fingerprint"},
+								},
 							},
 						},
 						CurrentStemcellID: "my-stemcellRecordID",
@@ -838,6 +984,24 @@ func rootDesc() {
 			})
 		})
 
+		Context("when release version does not match the version in release tarball", func() {
+			BeforeEach(func() {
+				releaseSetManifest.Releases = []birelmanifest.ReleaseRef{
+					{
+						Name:    "fake-cpi-release-name",
+						Version: "2.0",
+						URL:     "file://" + cpiReleaseTarballPath,
+					},
+				}
+			})
+
+			It("returns an error", func() {
+				err := command.Run(fakeStage, []string{deploymentManifestPath})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Release version '2.0' of release 'fake-cpi-release-name' does not match the version in release tarball '1.0'"))
+			})
+		})
+
 		Context("When the stemcell tarball does not exist", func() {
 			JustBeforeEach(func() {
 				fakeStemcellExtractor.SetExtractBehavior(stemcellTarballPath, extractedStemcell, errors.New("no-stemcell-there"))
@@ -941,12 +1105,12 @@ func rootDesc() {
 			})
 		})
 
-		It("returns err when number of arguments is not equal 1", func() {
+		It("returns err when no deployment manifest is given", func() {
 			err := command.Run(fakeStage, []string{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
 
-			err = command.Run(fakeStage, []string{"1", "2"})
+			err = command.Run(fakeStage, []string{"--state", "/path/to/state.json"})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
 		})
@@ -969,6 +1133,7 @@ func rootDesc() {
 					cloud,
 					boshDeploymentManifest,
 					cloudStemcell,
+					extractedStemcell,
 					installationManifest.Registry,
 					fakeVMManager,
 					mockBlobstore,
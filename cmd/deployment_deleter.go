@@ -3,6 +3,8 @@ package cmd
 import (
 	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
@@ -25,12 +27,15 @@ func NewDeploymentDeleter(
 	logTag string,
 	logger boshlog.Logger,
 	deploymentStateService biconfig.DeploymentStateService,
+	forceUnlock bool,
 	releaseManager birel.Manager,
 	cloudFactory bicloud.Factory,
 	agentClientFactory bihttpagent.AgentClientFactory,
 	blobstoreFactory biblobstore.Factory,
 	deploymentManagerFactory bidepl.ManagerFactory,
 	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
 	cpiInstaller bicpirel.CpiInstaller,
 	cpiUninstaller biinstall.Uninstaller,
 	releaseFetcher birel.Fetcher,
@@ -41,12 +46,15 @@ func NewDeploymentDeleter(
 		logTag:                                  logTag,
 		logger:                                  logger,
 		deploymentStateService:                  deploymentStateService,
+		forceUnlock:                             forceUnlock,
 		releaseManager:                          releaseManager,
 		cloudFactory:                            cloudFactory,
 		agentClientFactory:                      agentClientFactory,
 		blobstoreFactory:                        blobstoreFactory,
 		deploymentManagerFactory:                deploymentManagerFactory,
 		deploymentManifestPath:                  deploymentManifestPath,
+		deploymentVars:                          deploymentVars,
+		deploymentOps:                           deploymentOps,
 		cpiInstaller:                            cpiInstaller,
 		cpiUninstaller:                          cpiUninstaller,
 		releaseFetcher:                          releaseFetcher,
@@ -59,12 +67,15 @@ type deploymentDeleter struct {
 	logTag                                  string
 	logger                                  boshlog.Logger
 	deploymentStateService                  biconfig.DeploymentStateService
+	forceUnlock                             bool
 	releaseManager                          birel.Manager
 	cloudFactory                            bicloud.Factory
 	agentClientFactory                      bihttpagent.AgentClientFactory
 	blobstoreFactory                        biblobstore.Factory
 	deploymentManagerFactory                bidepl.ManagerFactory
 	deploymentManifestPath                  string
+	deploymentVars                          bivars.Variables
+	deploymentOps                           bipatch.Ops
 	cpiInstaller                            bicpirel.CpiInstaller
 	cpiUninstaller                          biinstall.Uninstaller
 	releaseFetcher                          birel.Fetcher
@@ -79,6 +90,22 @@ func (c *deploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 		return nil
 	}
 
+	err = c.deploymentStateService.Lock(c.forceUnlock)
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+	defer func() {
+		err := c.deploymentStateService.RecordVersion("delete")
+		if err != nil {
+			c.logger.Warn(c.logTag, "Recording deployment state version: %s", err.Error())
+		}
+
+		err = c.deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
 	deploymentState, err := c.deploymentStateService.Load()
 	if err != nil {
 		return bosherr.WrapError(err, "Loading deployment state")
@@ -93,7 +120,7 @@ func (c *deploymentDeleter) DeleteDeployment(stage biui.Stage) (err error) {
 	var installationManifest biinstallmanifest.Manifest
 	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
 		var releaseSetManifest birelsetmanifest.Manifest
-		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath)
+		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps)
 		if err != nil {
 			return err
 		}
@@ -16,7 +16,9 @@ import (
 	mock_install "github.com/cloudfoundry/bosh-init/installation/mocks"
 	mock_release "github.com/cloudfoundry/bosh-init/release/mocks"
 
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
@@ -150,7 +152,6 @@ cloud_provider:
 			expectCPIInstall = mockCpiInstaller.EXPECT().InstallPackagesAndJobs(installationManifest, gomock.Any()).Do(func(_ biinstallmanifest.Manifest, stage biui.Stage) {
 				Expect(fakeStage.SubStages).To(ContainElement(stage))
 			}).Return(fakeInstallation, nil).AnyTimes()
-			mockCpiInstaller.EXPECT().Cleanup(fakeInstallation).AnyTimes()
 
 			expectNewCloud = mockCloudFactory.EXPECT().NewCloud(fakeInstallation, directorID).Return(mockCloud, nil).AnyTimes()
 		}
@@ -182,12 +183,15 @@ cloud_provider:
 				"deleteCmd",
 				logger,
 				deploymentStateService,
+				false,
 				releaseManager,
 				mockCloudFactory,
 				mockAgentClientFactory,
 				mockBlobstoreFactory,
 				mockDeploymentManagerFactory,
 				deploymentManifestPath,
+				bivars.Variables{},
+				bipatch.Ops{},
 				cpiInstaller,
 				mockCpiUninstaller,
 				releaseFetcher,
@@ -246,9 +250,6 @@ cloud_provider:
 				{
 					Name: "Uninstalling local artifacts for CPI and deployment",
 				},
-				{
-					Name: "Cleaning up rendered CPI jobs",
-				},
 				// mock deployment manager cleanup doesn't add sub-stages
 			}))
 
@@ -364,9 +365,9 @@ cloud_provider:
 					Expect(fakeUI.Errors).To(BeEmpty())
 				})
 
-				It("deletes the local CPI installation", func() {
+				It("deletes the local CPI installation, including the CPI job kept for reuse", func() {
 					expectDeleteAndCleanup(false)
-					mockCpiUninstaller.EXPECT().Uninstall(gomock.Any()).Return(nil)
+					mockCpiUninstaller.EXPECT().Uninstall(fakeInstallation.Target()).Return(nil)
 
 					err := newDeploymentDeleter().DeleteDeployment(fakeStage)
 					Expect(err).ToNot(HaveOccurred())
@@ -388,8 +389,17 @@ cloud_provider:
 					Expect(err).ToNot(HaveOccurred())
 
 					Expect(fs.FileExists(deploymentStatePath)).To(BeFalse())
+					Expect(fs.FileExists(biconfig.DeploymentStateLockPath(deploymentStatePath))).To(BeFalse())
 				})
 
+				It("returns an error without deleting anything when another run holds the lock of the deployment state", func() {
+					fs.WriteFileString(biconfig.DeploymentStateLockPath(deploymentStatePath), `{"pid": 1234, "host": "fake-other-host", "user": "fake-user"}`)
+
+					err := newDeploymentDeleter().DeleteDeployment(fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("is locked by pid 1234 of user 'fake-user' on host 'fake-other-host'"))
+					Expect(fs.FileExists(deploymentStatePath)).To(BeTrue())
+				})
 			})
 
 			Context("when nothing has been deployed", func() {
@@ -426,7 +436,6 @@ cloud_provider:
 				expectCPIInstall = mockCpiInstaller.EXPECT().InstallPackagesAndJobs(installationManifest, gomock.Any()).Do(func(_ biinstallmanifest.Manifest, stage biui.Stage) {
 					Expect(fakeStage.SubStages).To(ContainElement(stage))
 				}).Return(fakeInstallation, nil).AnyTimes()
-				mockCpiInstaller.EXPECT().Cleanup(fakeInstallation).AnyTimes()
 
 				expectNewCloud = mockCloudFactory.EXPECT().NewCloud(fakeInstallation, directorID).Return(mockCloud, nil).AnyTimes()
 			})
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
 	birel "github.com/cloudfoundry/bosh-init/release"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
@@ -12,13 +15,15 @@ type DeploymentManifestParser struct {
 	DeploymentParser    bideplmanifest.Parser
 	DeploymentValidator bideplmanifest.Validator
 	ReleaseManager      birel.Manager
+	UI                  biui.UI
 }
 
-func (y DeploymentManifestParser) GetDeploymentManifest(deploymentManifestPath string, releaseSetManifest birelsetmanifest.Manifest, stage biui.Stage) (bideplmanifest.Manifest, error) {
+func (y DeploymentManifestParser) GetDeploymentManifest(deploymentManifestPath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops, releaseSetManifest birelsetmanifest.Manifest, stage biui.Stage) (bideplmanifest.Manifest, error) {
 	var deploymentManifest bideplmanifest.Manifest
+	var warnings []bivalidation.Problem
 	err := stage.Perform("Validating deployment manifest", func() error {
 		var err error
-		deploymentManifest, err = y.DeploymentParser.Parse(deploymentManifestPath)
+		deploymentManifest, err = y.DeploymentParser.Parse(deploymentManifestPath, deploymentVars, deploymentOps)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Parsing deployment manifest '%s'", deploymentManifestPath)
 		}
@@ -28,7 +33,7 @@ func (y DeploymentManifestParser) GetDeploymentManifest(deploymentManifestPath s
 			return bosherr.WrapError(err, "Validating deployment manifest")
 		}
 
-		err = y.DeploymentValidator.ValidateReleaseJobs(deploymentManifest, y.ReleaseManager)
+		warnings, err = y.DeploymentValidator.ValidateReleaseJobs(deploymentManifest, y.ReleaseManager)
 		if err != nil {
 			return bosherr.WrapError(err, "Validating deployment jobs refer to jobs in release")
 		}
@@ -39,5 +44,9 @@ func (y DeploymentManifestParser) GetDeploymentManifest(deploymentManifestPath s
 		return bideplmanifest.Manifest{}, err
 	}
 
+	for _, warning := range warnings {
+		y.UI.PrintLinef("Warning: %s", warning.Message)
+	}
+
 	return deploymentManifest, nil
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
@@ -24,6 +26,7 @@ func NewDeploymentPreparer(
 	logger boshlog.Logger,
 	logTag string,
 	deploymentStateService biconfig.DeploymentStateService,
+	forceUnlock bool,
 	legacyDeploymentStateMigrator biconfig.LegacyDeploymentStateMigrator,
 	releaseManager birel.Manager,
 	deploymentRecord bidepl.Record,
@@ -34,6 +37,8 @@ func NewDeploymentPreparer(
 	blobstoreFactory biblobstore.Factory,
 	deployer bidepl.Deployer,
 	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
 	cpiInstaller bicpirel.CpiInstaller,
 	releaseFetcher birel.Fetcher,
 	stemcellFetcher bistemcell.Fetcher,
@@ -46,6 +51,7 @@ func NewDeploymentPreparer(
 		logger:                                  logger,
 		logTag:                                  logTag,
 		deploymentStateService:                  deploymentStateService,
+		forceUnlock:                             forceUnlock,
 		legacyDeploymentStateMigrator:           legacyDeploymentStateMigrator,
 		releaseManager:                          releaseManager,
 		deploymentRecord:                        deploymentRecord,
@@ -56,6 +62,8 @@ func NewDeploymentPreparer(
 		blobstoreFactory:                        blobstoreFactory,
 		deployer:                                deployer,
 		deploymentManifestPath:                  deploymentManifestPath,
+		deploymentVars:                          deploymentVars,
+		deploymentOps:                           deploymentOps,
 		cpiInstaller:                            cpiInstaller,
 		releaseFetcher:                          releaseFetcher,
 		stemcellFetcher:                         stemcellFetcher,
@@ -69,6 +77,7 @@ type DeploymentPreparer struct {
 	logger                                  boshlog.Logger
 	logTag                                  string
 	deploymentStateService                  biconfig.DeploymentStateService
+	forceUnlock                             bool
 	legacyDeploymentStateMigrator           biconfig.LegacyDeploymentStateMigrator
 	releaseManager                          birel.Manager
 	deploymentRecord                        bidepl.Record
@@ -79,6 +88,8 @@ type DeploymentPreparer struct {
 	blobstoreFactory                        biblobstore.Factory
 	deployer                                bidepl.Deployer
 	deploymentManifestPath                  string
+	deploymentVars                          bivars.Variables
+	deploymentOps                           bipatch.Ops
 	cpiInstaller                            bicpirel.CpiInstaller
 	releaseFetcher                          birel.Fetcher
 	stemcellFetcher                         bistemcell.Fetcher
@@ -89,6 +100,22 @@ type DeploymentPreparer struct {
 func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 	c.ui.PrintLinef("Deployment state: '%s'", c.deploymentStateService.Path())
 
+	err = c.deploymentStateService.Lock(c.forceUnlock)
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+	defer func() {
+		err := c.deploymentStateService.RecordVersion("deploy")
+		if err != nil {
+			c.logger.Warn(c.logTag, "Recording deployment state version: %s", err.Error())
+		}
+
+		err = c.deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
 	if !c.deploymentStateService.Exists() {
 		migrated, err := c.legacyDeploymentStateMigrator.MigrateIfExists(biconfig.LegacyDeploymentStatePath(c.deploymentManifestPath))
 		if err != nil {
@@ -118,7 +145,7 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 	)
 	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
 		var releaseSetManifest birelsetmanifest.Manifest
-		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath)
+		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps)
 		if err != nil {
 			return err
 		}
@@ -135,7 +162,7 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 			return err
 		}
 
-		deploymentManifest, err = c.deploymentManifestParser.GetDeploymentManifest(c.deploymentManifestPath, releaseSetManifest, stage)
+		deploymentManifest, err = c.deploymentManifestParser.GetDeploymentManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps, releaseSetManifest, stage)
 		if err != nil {
 			return err
 		}
@@ -153,7 +180,7 @@ func (c *DeploymentPreparer) PrepareDeployment(stage biui.Stage) (err error) {
 		}
 	}()
 
-	isDeployed, err := c.deploymentRecord.IsDeployed(c.deploymentManifestPath, c.releaseManager.List(), extractedStemcell)
+	isDeployed, err := c.deploymentRecord.IsDeployed(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps, c.releaseManager.List(), extractedStemcell)
 	if err != nil {
 		return bosherr.WrapError(err, "Checking if deployment has changed")
 	}
@@ -217,6 +244,7 @@ func (c *DeploymentPreparer) deploy(
 			cloud,
 			deploymentManifest,
 			cloudStemcell,
+			extractedStemcell,
 			installationManifest.Registry,
 			vmManager,
 			blobstore,
@@ -226,7 +254,7 @@ func (c *DeploymentPreparer) deploy(
 			return bosherr.WrapError(err, "Deploying")
 		}
 
-		err = c.deploymentRecord.Update(c.deploymentManifestPath, c.releaseManager.List())
+		err = c.deploymentRecord.Update(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps, c.releaseManager.List())
 		if err != nil {
 			return bosherr.WrapError(err, "Updating deployment record")
 		}
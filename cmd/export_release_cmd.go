@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type exportReleaseCmd struct {
+	releaseExporterProvider func(deploymentManifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ReleaseExporter, error)
+	ui                      biui.UI
+	fs                      boshsys.FileSystem
+	logger                  boshlog.Logger
+	logTag                  string
+}
+
+func NewExportReleaseCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	releaseExporterProvider func(deploymentManifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ReleaseExporter, error),
+) Cmd {
+	return &exportReleaseCmd{
+		ui:                      ui,
+		fs:                      fs,
+		releaseExporterProvider: releaseExporterProvider,
+		logger:                  logger,
+		logTag:                  "exportReleaseCmd",
+	}
+}
+
+func (c *exportReleaseCmd) Name() string {
+	return "export-release"
+}
+
+func (c *exportReleaseCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Export a release of the existing deployment as a compiled release",
+		Usage:    "<deployment_manifest_path>... <release_name> " + stateOptionsUsage + " " + manifestOptionsUsage,
+		Env:      mergeEnv(genericEnv, remoteStateEnv, stateKeyEnv),
+	}
+}
+
+func (c *exportReleaseCmd) Run(stage biui.Stage, args []string) error {
+	deploymentManifestPaths, releaseName, deploymentStateOptions, deploymentManifestOptions, err := c.parseCmdInputs(args)
+	if err != nil {
+		return err
+	}
+
+	manifestAbsFilePaths := make([]string, len(deploymentManifestPaths))
+	for i, deploymentManifestPath := range deploymentManifestPaths {
+		manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+		if err != nil {
+			c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
+			return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+		}
+
+		if !c.fs.FileExists(manifestAbsFilePath) {
+			c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+			return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+		}
+
+		c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+		manifestAbsFilePaths[i] = manifestAbsFilePath
+	}
+
+	stateAbsFilePath, err := deploymentStateAbsPath(deploymentStateOptions.path, manifestAbsFilePaths[0])
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+	}
+
+	// the other manifests are merged into the first one, before the ops files are applied.
+	// Relative paths in them resolve relative to the first one.
+	deploymentOps, err := bipatch.LoadMergeOps(manifestAbsFilePaths[1:], c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifests")
+		return bosherr.WrapError(err, "Loading deployment manifests")
+	}
+
+	opsFilesOps, err := deploymentManifestOptions.Ops(c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest ops files")
+		return bosherr.WrapError(err, "Loading deployment manifest ops files")
+	}
+	deploymentOps = append(deploymentOps, opsFilesOps...)
+
+	deploymentVars, err := deploymentManifestOptions.Variables(manifestAbsFilePaths[0], deploymentOps, c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest variables")
+		return bosherr.WrapError(err, "Loading deployment manifest variables")
+	}
+
+	releaseExporter, err := c.releaseExporterProvider(manifestAbsFilePaths[0], stateAbsFilePath, deploymentVars, deploymentOps)
+	if err != nil {
+		return err
+	}
+
+	return releaseExporter.ExportRelease(releaseName, stage)
+}
+
+// parseCmdInputs returns the deployment manifests, which are all the arguments but the last one, & the release name
+func (c *exportReleaseCmd) parseCmdInputs(args []string) ([]string, string, stateOptions, manifestOptions, error) {
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, "", deploymentStateOptions, manifestOptions{}, err
+	}
+
+	deploymentManifestOptions, remainingArgs, err := extractManifestOptions(remainingArgs)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, "", deploymentStateOptions, deploymentManifestOptions, err
+	}
+
+	if len(remainingArgs) < 2 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, "", deploymentStateOptions, deploymentManifestOptions, errors.New("Invalid usage - export-release command requires at least 1 deployment manifest & a release name")
+	}
+	return remainingArgs[:len(remainingArgs)-1], remainingArgs[len(remainingArgs)-1], deploymentStateOptions, deploymentManifestOptions, nil
+}
@@ -0,0 +1,126 @@
+package cmd_test
+
+import (
+	bicmd "github.com/cloudfoundry/bosh-init/cmd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	mock_cmd "github.com/cloudfoundry/bosh-init/cmd/mocks"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+	fakeui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("ExportReleaseCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("Run", func() {
+		var (
+			mockReleaseExporter *mock_cmd.MockReleaseExporter
+			fs                  boshsys.FileSystem
+			logger              boshlog.Logger
+
+			fakeUI                 *fakeui.FakeUI
+			fakeStage              *fakebiui.FakeStage
+			deploymentManifestPath = "/deployment-dir/fake-deployment-manifest.yml"
+
+			receivedStatePath     string
+			receivedDeploymentOps bipatch.Ops
+		)
+
+		var newExportReleaseCmd = func() bicmd.Cmd {
+			doGetFunc := func(manifestPath string, statePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.ReleaseExporter, error) {
+				Expect(manifestPath).To(Equal(deploymentManifestPath))
+				receivedStatePath = statePath
+				receivedDeploymentOps = deploymentOps
+				return mockReleaseExporter, nil
+			}
+
+			return bicmd.NewExportReleaseCmd(fakeUI, fs, logger, doGetFunc)
+		}
+
+		BeforeEach(func() {
+			mockReleaseExporter = mock_cmd.NewMockReleaseExporter(mockCtrl)
+			fs = fakesys.NewFakeFileSystem()
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fakeUI = &fakeui.FakeUI{}
+			fakeStage = fakebiui.NewFakeStage()
+			fs.WriteFileString(deploymentManifestPath, `---manifest-content`)
+		})
+
+		Context("when the deployment manifest does not exist", func() {
+			It("returns an error", func() {
+				err := newExportReleaseCmd().Run(fakeStage, []string{"/garbage", "fake-release"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Deployment manifest does not exist at '/garbage'"))
+				Expect(fakeUI.Errors).To(ContainElement("Deployment '/garbage' does not exist"))
+			})
+		})
+
+		Context("when the deployment manifest exists", func() {
+			It("exports the named release", func() {
+				mockReleaseExporter.EXPECT().ExportRelease("fake-release", fakeStage).Return(nil)
+				err := newExportReleaseCmd().Run(fakeStage, []string{deploymentManifestPath, "fake-release"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedStatePath).To(Equal("/deployment-dir/fake-deployment-manifest-state.json"))
+			})
+
+			It("exports the release of the deployment with the state given with --state", func() {
+				mockReleaseExporter.EXPECT().ExportRelease("fake-release", fakeStage).Return(nil).Times(2)
+				err := newExportReleaseCmd().Run(fakeStage, []string{deploymentManifestPath, "fake-release", "--state", "/fake-state.json"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedStatePath).To(Equal("/fake-state.json"))
+
+				err = newExportReleaseCmd().Run(fakeStage, []string{"--state", "s3://fake-bucket/fake-state.json", deploymentManifestPath, "fake-release"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedStatePath).To(Equal("s3://fake-bucket/fake-state.json"))
+			})
+
+			It("merges the other deployment manifests into the first one", func() {
+				fs.WriteFileString("/deployment-dir/cpi.yml", "---\ncloud_provider: {}\n")
+
+				mockReleaseExporter.EXPECT().ExportRelease("fake-release", fakeStage).Return(nil)
+				err := newExportReleaseCmd().Run(fakeStage, []string{deploymentManifestPath, "/deployment-dir/cpi.yml", "fake-release"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentOps).To(HaveLen(1))
+				Expect(receivedDeploymentOps[0].String()).To(Equal("merge '/deployment-dir/cpi.yml'"))
+			})
+
+			Context("when the release exporter returns an error", func() {
+				It("returns the error", func() {
+					err := bosherr.Error("boom")
+					mockReleaseExporter.EXPECT().ExportRelease("fake-release", fakeStage).Return(err)
+					returnedErr := newExportReleaseCmd().Run(fakeStage, []string{deploymentManifestPath, "fake-release"})
+					Expect(returnedErr).To(Equal(err))
+				})
+			})
+		})
+
+		It("returns err unless a deployment manifest & a release name are given", func() {
+			command := newExportReleaseCmd()
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+
+			err = command.Run(fakeStage, []string{deploymentManifestPath, "fake-release", "--state"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+		})
+	})
+})
@@ -6,6 +6,8 @@ import (
 
 	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
@@ -44,37 +46,38 @@ type Factory interface {
 }
 
 type factory struct {
-	commands               CommandList
-	fs                     boshsys.FileSystem
-	ui                     biui.UI
-	timeService            clock.Clock
-	logger                 boshlog.Logger
-	uuidGenerator          boshuuid.Generator
-	workspaceRootPath      string
-	runner                 boshsys.CmdRunner
-	compressor             boshcmd.Compressor
-	agentClientFactory     bihttpagent.AgentClientFactory
-	registryServerManager  biregistry.ServerManager
-	sshTunnelFactory       bisshtunnel.Factory
-	instanceFactory        biinstance.Factory
-	instanceManagerFactory biinstance.ManagerFactory
-	deploymentFactory      bidepl.Factory
-	blobstoreFactory       biblobstore.Factory
-	eventLogger            biui.Stage
-	releaseExtractor       birel.Extractor
-	releaseManager         birel.Manager
-	releaseSetParser       birelsetmanifest.Parser
-	releaseJobResolver     bideplrel.JobResolver
-	installationParser     biinstallmanifest.Parser
-	deploymentParser       bideplmanifest.Parser
-	releaseSetValidator    birelsetmanifest.Validator
-	installationValidator  biinstallmanifest.Validator
-	deploymentValidator    bideplmanifest.Validator
-	cloudFactory           bicloud.Factory
-	stateBuilderFactory    biinstancestate.BuilderFactory
-	compiledPackageRepo    bistatepkg.CompiledPackageRepo
-	tarballProvider        bitarball.Provider
-	cpiReleaseValidator    *bicpirel.Validator
+	commands              CommandList
+	fs                    boshsys.FileSystem
+	ui                    biui.UI
+	timeService           clock.Clock
+	logger                boshlog.Logger
+	uuidGenerator         boshuuid.Generator
+	workspaceRootPath     string
+	usePackageCache       bool
+	runner                boshsys.CmdRunner
+	compressor            boshcmd.Compressor
+	agentClientFactory    bihttpagent.AgentClientFactory
+	registryServerManager biregistry.ServerManager
+	sshTunnelFactory      bisshtunnel.Factory
+	deploymentFactory     bidepl.Factory
+	blobstoreFactory      biblobstore.Factory
+	eventLogger           biui.Stage
+	releaseExtractor      birel.Extractor
+	releaseManager        birel.Manager
+	releaseSetParser      birelsetmanifest.Parser
+	releaseJobResolver    bideplrel.JobResolver
+	installationParser    biinstallmanifest.Parser
+	deploymentParser      bideplmanifest.Parser
+	releaseSetValidator   birelsetmanifest.Validator
+	installationValidator biinstallmanifest.Validator
+	deploymentValidator   bideplmanifest.Validator
+	cloudFactory          bicloud.Factory
+	jobListRenderer       bitemplate.JobListRenderer
+	renderedJobListDiffer bitemplate.RenderedJobListDiffer
+	compiledPackageCache  bistatepkg.CompiledPackageCache
+	tarballCache          bitarball.Cache
+	tarballProvider       bitarball.Provider
+	cpiReleaseValidator   *bicpirel.Validator
 }
 
 func NewFactory(
@@ -84,6 +87,7 @@ func NewFactory(
 	logger boshlog.Logger,
 	uuidGenerator boshuuid.Generator,
 	workspaceRootPath string,
+	usePackageCache bool,
 ) Factory {
 	f := &factory{
 		fs:                fs,
@@ -92,12 +96,18 @@ func NewFactory(
 		logger:            logger,
 		uuidGenerator:     uuidGenerator,
 		workspaceRootPath: workspaceRootPath,
+		usePackageCache:   usePackageCache,
 	}
 	f.commands = CommandList{
-		"deploy":  f.createDeployCmd,
-		"delete":  f.createDeleteCmd,
-		"help":    f.createHelpCmd,
-		"version": f.createVersionCmd,
+		"cpi":            f.createCpiCmd,
+		"deploy":         f.createDeployCmd,
+		"delete":         f.createDeleteCmd,
+		"export-release": f.createExportReleaseCmd,
+		"help":           f.createHelpCmd,
+		"render":         f.createRenderCmd,
+		"state":          f.createStateCmd,
+		"validate":       f.createValidateCmd,
+		"version":        f.createVersionCmd,
 	}
 	return f
 }
@@ -117,8 +127,13 @@ func (f *factory) CreateCommand(name string) (Cmd, error) {
 }
 
 func (f *factory) createDeployCmd() (Cmd, error) {
-	getter := func(deploymentManifestPath string) (DeploymentPreparer, error) {
-		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath}
+	getter := func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (DeploymentPreparer, error) {
+		deploymentStateService, err := biconfig.NewDeploymentStateService(deploymentStatePath, f.fs, f.uuidGenerator, f.logger)
+		if err != nil {
+			return DeploymentPreparer{}, err
+		}
+
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, deploymentStatePath: deploymentStatePath, deploymentStateService: deploymentStateService, forceUnlock: forceUnlock, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
 		deploymentPreparer, err := f.loadDeploymentPreparer()
 		if err != nil {
 			return deploymentPreparer, err
@@ -130,8 +145,13 @@ func (f *factory) createDeployCmd() (Cmd, error) {
 }
 
 func (f *factory) createDeleteCmd() (Cmd, error) {
-	getter := func(deploymentManifestPath string) (DeploymentDeleter, error) {
-		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath}
+	getter := func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (DeploymentDeleter, error) {
+		deploymentStateService, err := biconfig.NewDeploymentStateService(deploymentStatePath, f.fs, f.uuidGenerator, f.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, deploymentStatePath: deploymentStatePath, deploymentStateService: deploymentStateService, forceUnlock: forceUnlock, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
 		deploymentDeleter, err := f.loadDeploymentDeleter()
 		if err != nil {
 			return deploymentDeleter, err
@@ -142,6 +162,65 @@ func (f *factory) createDeleteCmd() (Cmd, error) {
 	return NewDeleteCmd(f.ui, f.fs, f.logger, getter), nil
 }
 
+func (f *factory) createCpiCmd() (Cmd, error) {
+	getter := func(deploymentManifestPath string, deploymentStatePath string, raw bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (CpiCaller, error) {
+		deploymentStateService, err := biconfig.NewDeploymentStateService(deploymentStatePath, f.fs, f.uuidGenerator, f.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, deploymentStatePath: deploymentStatePath, deploymentStateService: deploymentStateService, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
+		return f.loadCpiCaller(raw)
+	}
+
+	return NewCpiCmd(f.ui, f.fs, f.logger, getter), nil
+}
+
+func (f *factory) createExportReleaseCmd() (Cmd, error) {
+	getter := func(deploymentManifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ReleaseExporter, error) {
+		deploymentStateService, err := biconfig.NewDeploymentStateService(deploymentStatePath, f.fs, f.uuidGenerator, f.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, deploymentStatePath: deploymentStatePath, deploymentStateService: deploymentStateService, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
+		return f.loadReleaseExporter(), nil
+	}
+
+	return NewExportReleaseCmd(f.ui, f.fs, f.logger, getter), nil
+}
+
+func (f *factory) createRenderCmd() (Cmd, error) {
+	getter := func(deploymentManifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (TemplatesRenderer, error) {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, deploymentStatePath: deploymentStatePath, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
+		return f.loadTemplatesRenderer(), nil
+	}
+
+	return NewRenderCmd(f.ui, f.fs, f.logger, getter), nil
+}
+
+func (f *factory) createValidateCmd() (Cmd, error) {
+	getter := func(deploymentManifestPath string, strict bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ManifestValidator, error) {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, strictProperties: strict, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
+		return f.loadManifestValidator(), nil
+	}
+
+	return NewValidateCmd(f.ui, f.fs, f.logger, getter), nil
+}
+
+func (f *factory) createStateCmd() (Cmd, error) {
+	getter := func(deploymentStatePath string) (biconfig.DeploymentStateService, error) {
+		return biconfig.NewDeploymentStateService(deploymentStatePath, f.fs, f.uuidGenerator, f.logger)
+	}
+
+	verifierGetter := func(deploymentManifestPath string, deploymentStateService biconfig.DeploymentStateService, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ResourceVerifier, error) {
+		f := &deploymentManagerFactory2{f: f, deploymentManifestPath: deploymentManifestPath, deploymentStatePath: deploymentStateService.Path(), deploymentStateService: deploymentStateService, deploymentVars: deploymentVars, deploymentOps: deploymentOps}
+		return f.loadResourceVerifier()
+	}
+
+	return NewStateCmd(f.ui, f.fs, f.uuidGenerator, f.logger, getter, verifierGetter), nil
+}
+
 func (f *factory) createHelpCmd() (Cmd, error) {
 	return NewHelpCmd(f.ui, f.commands), nil
 }
@@ -166,14 +245,19 @@ func (f *factory) loadCompressor() boshcmd.Compressor {
 	return f.compressor
 }
 
-func (f *factory) loadCompiledPackageRepo() bistatepkg.CompiledPackageRepo {
-	if f.compiledPackageRepo != nil {
-		return f.compiledPackageRepo
+// loadCompiledPackageCache returns nil unless the shared compiled package cache is enabled
+func (f *factory) loadCompiledPackageCache() bistatepkg.CompiledPackageCache {
+	if !f.usePackageCache {
+		return nil
+	}
+	if f.compiledPackageCache != nil {
+		return f.compiledPackageCache
 	}
 
-	index := biindex.NewInMemoryIndex()
-	f.compiledPackageRepo = bistatepkg.NewCompiledPackageRepo(index)
-	return f.compiledPackageRepo
+	cacheBasePath := filepath.Join(f.workspaceRootPath, "compiled_packages")
+	index := biindex.NewFileIndex(filepath.Join(cacheBasePath, "index.json"), f.fs)
+	f.compiledPackageCache = bistatepkg.NewCompiledPackageCache(cacheBasePath, index, bicrypto.NewSha1Calculator(f.fs), f.fs, f.logger)
+	return f.compiledPackageCache
 }
 
 func (f *factory) loadRegistryServerManager() biregistry.ServerManager {
@@ -194,30 +278,6 @@ func (f *factory) loadSSHTunnelFactory() bisshtunnel.Factory {
 	return f.sshTunnelFactory
 }
 
-func (f *factory) loadInstanceManagerFactory() biinstance.ManagerFactory {
-	if f.instanceManagerFactory != nil {
-		return f.instanceManagerFactory
-	}
-
-	f.instanceManagerFactory = biinstance.NewManagerFactory(
-		f.loadSSHTunnelFactory(),
-		f.loadInstanceFactory(),
-		f.logger,
-	)
-	return f.instanceManagerFactory
-}
-
-func (f *factory) loadInstanceFactory() biinstance.Factory {
-	if f.instanceFactory != nil {
-		return f.instanceFactory
-	}
-
-	f.instanceFactory = biinstance.NewFactory(
-		f.loadBuilderFactory(),
-	)
-	return f.instanceFactory
-}
-
 func (f *factory) loadReleaseJobResolver() bideplrel.JobResolver {
 	if f.releaseJobResolver != nil {
 		return f.releaseJobResolver
@@ -227,32 +287,24 @@ func (f *factory) loadReleaseJobResolver() bideplrel.JobResolver {
 	return f.releaseJobResolver
 }
 
-func (f *factory) loadBuilderFactory() biinstancestate.BuilderFactory {
-	if f.stateBuilderFactory != nil {
-		return f.stateBuilderFactory
+func (f *factory) loadJobListRenderer() bitemplate.JobListRenderer {
+	if f.jobListRenderer != nil {
+		return f.jobListRenderer
 	}
 
 	erbRenderer := bitemplateerb.NewERBRenderer(f.fs, f.loadCMDRunner(), f.logger)
 	jobRenderer := bitemplate.NewJobRenderer(erbRenderer, f.fs, f.logger)
-	jobListRenderer := bitemplate.NewJobListRenderer(jobRenderer, f.logger)
-
-	sha1Calculator := bicrypto.NewSha1Calculator(f.fs)
+	f.jobListRenderer = bitemplate.NewJobListRenderer(jobRenderer, f.logger)
+	return f.jobListRenderer
+}
 
-	renderedJobListCompressor := bitemplate.NewRenderedJobListCompressor(
-		f.fs,
-		f.loadCompressor(),
-		sha1Calculator,
-		f.logger,
-	)
+func (f *factory) loadRenderedJobListDiffer() bitemplate.RenderedJobListDiffer {
+	if f.renderedJobListDiffer != nil {
+		return f.renderedJobListDiffer
+	}
 
-	f.stateBuilderFactory = biinstancestate.NewBuilderFactory(
-		f.loadCompiledPackageRepo(),
-		f.loadReleaseJobResolver(),
-		jobListRenderer,
-		renderedJobListCompressor,
-		f.logger,
-	)
-	return f.stateBuilderFactory
+	f.renderedJobListDiffer = bitemplate.NewRenderedJobListDiffer(f.fs, f.loadCompressor(), f.logger)
+	return f.renderedJobListDiffer
 }
 
 func (f *factory) loadDeploymentFactory() bidepl.Factory {
@@ -302,20 +354,29 @@ func (f *factory) loadReleaseExtractor() birel.Extractor {
 	}
 
 	releaseValidator := birel.NewValidator(f.fs)
-	f.releaseExtractor = birel.NewExtractor(f.fs, f.loadCompressor(), releaseValidator, f.logger)
+	devReleaseBuilder := birel.NewDevReleaseBuilder(f.fs, f.loadCompressor(), bicrypto.NewSha1Calculator(f.fs), birel.NewFileModeReader(), f.logger)
+	f.releaseExtractor = birel.NewExtractor(f.fs, f.loadCompressor(), releaseValidator, devReleaseBuilder, f.logger)
 	return f.releaseExtractor
 }
 
+func (f *factory) loadTarballCache() bitarball.Cache {
+	if f.tarballCache != nil {
+		return f.tarballCache
+	}
+
+	tarballCacheBasePath := filepath.Join(f.workspaceRootPath, "downloads")
+	f.tarballCache = bitarball.NewCache(tarballCacheBasePath, f.fs, f.logger)
+	return f.tarballCache
+}
+
 func (f *factory) loadTarballProvider() bitarball.Provider {
 	if f.tarballProvider != nil {
 		return f.tarballProvider
 	}
 
-	tarballCacheBasePath := filepath.Join(f.workspaceRootPath, "downloads")
-	tarballCache := bitarball.NewCache(tarballCacheBasePath, f.fs, f.logger)
 	httpClient := bihttpclient.NewHTTPClient(bitarball.HTTPClient, f.logger)
 	sha1Calculator := bicrypto.NewSha1Calculator(f.fs)
-	f.tarballProvider = bitarball.NewProvider(tarballCache, f.fs, httpClient, sha1Calculator, 3, 500*time.Millisecond, f.logger)
+	f.tarballProvider = bitarball.NewProvider(f.loadTarballCache(), f.fs, httpClient, sha1Calculator, 3, 500*time.Millisecond, f.logger)
 	return f.tarballProvider
 }
 
@@ -395,6 +456,11 @@ func (f *factory) loadCloudFactory() bicloud.Factory {
 type deploymentManagerFactory2 struct {
 	f                             *factory
 	deploymentManifestPath        string
+	deploymentStatePath           string
+	forceUnlock                   bool
+	strictProperties              bool
+	deploymentVars                bivars.Variables
+	deploymentOps                 bipatch.Ops
 	deploymentStateService        biconfig.DeploymentStateService
 	legacyDeploymentStateMigrator biconfig.LegacyDeploymentStateMigrator
 	vmRepo                        biconfig.VMRepo
@@ -407,13 +473,17 @@ type deploymentManagerFactory2 struct {
 	stemcellManagerFactory        bistemcell.ManagerFactory
 	installerFactory              biinstall.InstallerFactory
 	deployer                      bidepl.Deployer
+	instanceManagerFactory        biinstance.ManagerFactory
+	instanceFactory               biinstance.Factory
+	stateBuilderFactory           biinstancestate.BuilderFactory
+	renderedJobListRepo           bitemplate.RenderedJobListArchiveRepo
+	compiledPackageRepo           bistatepkg.CompiledPackageRepo
 }
 
 func (d *deploymentManagerFactory2) loadDeploymentPreparer() (DeploymentPreparer, error) {
 	deploymentRepo := biconfig.NewDeploymentRepo(d.loadDeploymentStateService())
 	releaseRepo := biconfig.NewReleaseRepo(d.loadDeploymentStateService(), d.f.uuidGenerator)
-	sha1Calculator := bicrypto.NewSha1Calculator(d.f.fs)
-	deploymentRecord := bidepl.NewRecord(deploymentRepo, releaseRepo, d.loadStemcellRepo(), sha1Calculator)
+	deploymentRecord := bidepl.NewRecord(deploymentRepo, releaseRepo, d.loadStemcellRepo(), d.f.fs)
 	cpiInstaller, err := d.loadCpiInstaller()
 	if err != nil {
 		return DeploymentPreparer{}, err
@@ -424,6 +494,7 @@ func (d *deploymentManagerFactory2) loadDeploymentPreparer() (DeploymentPreparer
 		d.f.logger,
 		"DeploymentPreparer",
 		d.loadDeploymentStateService(),
+		d.forceUnlock,
 		d.loadLegacyDeploymentStateMigrator(),
 		d.f.loadReleaseManager(),
 		deploymentRecord,
@@ -434,6 +505,8 @@ func (d *deploymentManagerFactory2) loadDeploymentPreparer() (DeploymentPreparer
 		d.f.loadBlobstoreFactory(),
 		d.loadDeployer(),
 		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
 		cpiInstaller,
 		d.loadReleaseFetcher(),
 		d.loadStemcellFetcher(),
@@ -452,12 +525,15 @@ func (d *deploymentManagerFactory2) loadDeploymentDeleter() (DeploymentDeleter,
 		"DeploymentDeleter",
 		d.f.logger,
 		d.loadDeploymentStateService(),
+		d.forceUnlock,
 		d.f.loadReleaseManager(),
 		d.f.loadCloudFactory(),
 		d.f.loadAgentClientFactory(),
 		d.f.loadBlobstoreFactory(),
 		d.loadDeploymentManagerFactory(),
 		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
 		cpiInstaller,
 		d.loadCpiUninstaller(),
 		d.loadReleaseFetcher(),
@@ -465,6 +541,118 @@ func (d *deploymentManagerFactory2) loadDeploymentDeleter() (DeploymentDeleter,
 	), nil
 }
 
+func (d *deploymentManagerFactory2) loadResourceVerifier() (ResourceVerifier, error) {
+	cpiInstaller, err := d.loadCpiInstaller()
+	if err != nil {
+		return nil, err
+	}
+	return NewResourceVerifier(
+		"ResourceVerifier",
+		d.f.logger,
+		d.loadDeploymentStateService(),
+		d.f.loadReleaseManager(),
+		d.f.loadCloudFactory(),
+		d.f.loadAgentClientFactory(),
+		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
+		cpiInstaller,
+		d.loadReleaseFetcher(),
+		d.loadReleaseSetAndInstallationManifestParser(),
+	), nil
+}
+
+// loadCpiCaller calls the CPI through a command runner printing its exact stdin & stdout when raw
+func (d *deploymentManagerFactory2) loadCpiCaller(raw bool) (CpiCaller, error) {
+	cpiInstaller, err := d.loadCpiInstaller()
+	if err != nil {
+		return nil, err
+	}
+
+	cloudFactory := d.f.loadCloudFactory()
+	if raw {
+		cloudFactory = bicloud.NewFactory(d.f.fs, newRawCmdRunner(d.f.loadCMDRunner(), d.f.ui), d.f.logger)
+	}
+
+	return NewCpiCaller(
+		d.f.ui,
+		"CpiCaller",
+		d.f.logger,
+		d.loadDeploymentStateService(),
+		d.f.loadReleaseManager(),
+		cloudFactory,
+		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
+		cpiInstaller,
+		d.loadReleaseFetcher(),
+		d.loadReleaseSetAndInstallationManifestParser(),
+	), nil
+}
+
+func (d *deploymentManagerFactory2) loadReleaseExporter() ReleaseExporter {
+	return NewReleaseExporter(
+		d.f.ui,
+		"ReleaseExporter",
+		d.f.logger,
+		d.loadDeploymentStateService(),
+		d.f.loadReleaseManager(),
+		d.f.loadBlobstoreFactory(),
+		d.loadCompiledPackageRepo(),
+		birel.NewCompiledReleaseWriter(d.f.fs, d.f.loadCompressor(), d.f.logger),
+		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
+		d.loadReleaseFetcher(),
+		d.loadStemcellFetcher(),
+		d.loadReleaseSetAndInstallationManifestParser(),
+		d.loadDeploymentManifestParser(),
+	)
+}
+
+func (d *deploymentManagerFactory2) loadTemplatesRenderer() TemplatesRenderer {
+	return NewTemplatesRenderer(
+		d.f.ui,
+		d.f.fs,
+		"TemplatesRenderer",
+		d.f.logger,
+		d.f.loadReleaseManager(),
+		d.f.loadReleaseJobResolver(),
+		d.f.loadJobListRenderer(),
+		d.loadRenderedJobListArchiveRepo(),
+		d.f.loadRenderedJobListDiffer(),
+		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
+		d.loadReleaseFetcher(),
+		d.f.loadReleaseSetParser(),
+		d.loadDeploymentManifestParser(),
+	)
+}
+
+func (d *deploymentManagerFactory2) loadManifestValidator() ManifestValidator {
+	deploymentValidator := d.f.loadDeploymentValidator()
+	if d.strictProperties {
+		deploymentValidator = bideplmanifest.NewStrictValidator(d.f.logger)
+	}
+
+	return NewManifestValidator(
+		d.f.fs,
+		"ManifestValidator",
+		d.f.logger,
+		d.f.loadReleaseExtractor(),
+		d.f.loadReleaseManager(),
+		d.f.loadTarballCache(),
+		d.deploymentManifestPath,
+		d.deploymentVars,
+		d.deploymentOps,
+		d.f.loadReleaseSetParser(),
+		d.f.loadInstallationParser(),
+		d.f.loadDeploymentParser(),
+		deploymentValidator,
+	)
+}
+
 func (d *deploymentManagerFactory2) loadDeploymentStateService() biconfig.DeploymentStateService {
 	if d.deploymentStateService != nil {
 		return d.deploymentStateService
@@ -474,7 +662,7 @@ func (d *deploymentManagerFactory2) loadDeploymentStateService() biconfig.Deploy
 		d.f.fs,
 		d.f.uuidGenerator,
 		d.f.logger,
-		biconfig.DeploymentStatePath(d.deploymentManifestPath),
+		d.deploymentStatePath,
 	)
 	return d.deploymentStateService
 }
@@ -542,7 +730,7 @@ func (d *deploymentManagerFactory2) loadDeploymentManagerFactory() bidepl.Manage
 
 	d.deploymentManagerFactory = bidepl.NewManagerFactory(
 		d.loadVMManagerFactory(),
-		d.f.loadInstanceManagerFactory(),
+		d.loadInstanceManagerFactory(),
 		d.loadDiskManagerFactory(),
 		d.loadStemcellManagerFactory(),
 		d.f.loadDeploymentFactory(),
@@ -582,7 +770,7 @@ func (d *deploymentManagerFactory2) loadDeployer() bidepl.Deployer {
 
 	d.deployer = bidepl.NewDeployer(
 		d.loadVMManagerFactory(),
-		d.f.loadInstanceManagerFactory(),
+		d.loadInstanceManagerFactory(),
 		d.f.loadDeploymentFactory(),
 		d.f.logger,
 	)
@@ -661,5 +849,87 @@ func (d *deploymentManagerFactory2) loadDeploymentManifestParser() DeploymentMan
 		DeploymentParser:    d.f.loadDeploymentParser(),
 		DeploymentValidator: d.f.loadDeploymentValidator(),
 		ReleaseManager:      d.f.loadReleaseManager(),
+		UI:                  d.f.ui,
+	}
+}
+
+func (d *deploymentManagerFactory2) loadInstanceManagerFactory() biinstance.ManagerFactory {
+	if d.instanceManagerFactory != nil {
+		return d.instanceManagerFactory
+	}
+
+	d.instanceManagerFactory = biinstance.NewManagerFactory(
+		d.f.loadSSHTunnelFactory(),
+		d.loadInstanceFactory(),
+		d.f.logger,
+	)
+	return d.instanceManagerFactory
+}
+
+func (d *deploymentManagerFactory2) loadInstanceFactory() biinstance.Factory {
+	if d.instanceFactory != nil {
+		return d.instanceFactory
+	}
+
+	d.instanceFactory = biinstance.NewFactory(
+		d.loadBuilderFactory(),
+		d.loadRenderedJobListArchiveRepo(),
+		d.f.ui,
+	)
+	return d.instanceFactory
+}
+
+func (d *deploymentManagerFactory2) loadBuilderFactory() biinstancestate.BuilderFactory {
+	if d.stateBuilderFactory != nil {
+		return d.stateBuilderFactory
+	}
+
+	sha1Calculator := bicrypto.NewSha1Calculator(d.f.fs)
+
+	renderedJobListCompressor := bitemplate.NewRenderedJobListCompressor(
+		d.f.fs,
+		d.f.loadCompressor(),
+		sha1Calculator,
+		d.f.logger,
+	)
+
+	d.stateBuilderFactory = biinstancestate.NewBuilderFactory(
+		d.loadCompiledPackageRepo(),
+		d.f.loadCompiledPackageCache(),
+		sha1Calculator,
+		d.f.loadReleaseJobResolver(),
+		d.f.loadJobListRenderer(),
+		renderedJobListCompressor,
+		d.loadRenderedJobListArchiveRepo(),
+		d.f.loadRenderedJobListDiffer(),
+		d.f.logger,
+	)
+	return d.stateBuilderFactory
+}
+
+func (d *deploymentManagerFactory2) loadRenderedJobListArchiveRepo() bitemplate.RenderedJobListArchiveRepo {
+	if d.renderedJobListRepo != nil {
+		return d.renderedJobListRepo
+	}
+
+	// a few applied archives are kept, to be able to look at the recent changes
+	path := biconfig.RenderedTemplatesPath(d.f.workspaceRootPath, d.deploymentStatePath)
+	d.renderedJobListRepo = bitemplate.NewRenderedJobListArchiveRepo(path, 5, d.f.fs, d.f.logger)
+	return d.renderedJobListRepo
+}
+
+// loadCompiledPackageRepo returns the records of the packages compiled on the VM of the deployment, for export-release to export them.
+// They are kept next to a local deployment state, or in the workspace for a remote one.
+func (d *deploymentManagerFactory2) loadCompiledPackageRepo() bistatepkg.CompiledPackageRepo {
+	if d.compiledPackageRepo != nil {
+		return d.compiledPackageRepo
 	}
+
+	indexPath := biconfig.CompiledPackagesIndexPath(d.deploymentStatePath)
+	if biconfig.IsDeploymentStateURL(d.deploymentStatePath) {
+		indexPath = filepath.Join(d.f.workspaceRootPath, "compiled_packages", biconfig.DeploymentStateKey(d.deploymentStatePath)+".json")
+	}
+
+	d.compiledPackageRepo = bistatepkg.NewCompiledPackageRepo(biindex.NewFileIndex(indexPath, d.f.fs))
+	return d.compiledPackageRepo
 }
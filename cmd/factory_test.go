@@ -37,6 +37,7 @@ var _ = Describe("cmd.Factory", func() {
 			logger,
 			uuidGenerator,
 			"/fake-path",
+			false,
 		)
 	})
 
@@ -60,6 +61,38 @@ var _ = Describe("cmd.Factory", func() {
 				Expect(cmd.Name()).To(Equal("delete"))
 			})
 		})
+
+		Describe("cpi command", func() {
+			It("returns cpi command", func() {
+				cmd, err := factory.CreateCommand("cpi")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("cpi"))
+			})
+		})
+
+		Describe("export-release command", func() {
+			It("returns export-release command", func() {
+				cmd, err := factory.CreateCommand("export-release")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("export-release"))
+			})
+		})
+
+		Describe("render command", func() {
+			It("returns render command", func() {
+				cmd, err := factory.CreateCommand("render")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("render"))
+			})
+		})
+
+		Describe("state command", func() {
+			It("returns state command", func() {
+				cmd, err := factory.CreateCommand("state")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cmd.Name()).To(Equal("state"))
+			})
+		})
 	})
 
 	Context("unknown command name", func() {
@@ -66,6 +66,7 @@ func (h *helpCmd) printCommand(name string, meta Meta) {
 		IsSubcommand: true,
 		Synopsis:     meta.Synopsis,
 		Usage:        meta.Usage,
+		Commands:     sortedPairs(meta.Subcommands),
 		Envs:         sortedEnvs(meta.Env),
 	}
 
@@ -42,6 +42,17 @@ var _ = Describe("HelpCmd", func() {
 				}
 				return fakecmd.NewFakeCommand("complex", meta), nil
 			},
+			"nested": func() (Cmd, error) {
+				meta := Meta{
+					Synopsis: "Nested command... has subcommands",
+					Usage:    "<subcommand>",
+					Subcommands: map[string]string{
+						"show <path>":    "Show something",
+						"restore <path>": "Restore something",
+					},
+				}
+				return fakecmd.NewFakeCommand("nested", meta), nil
+			},
 			"help": func() (Cmd, error) {
 				return NewHelpCmd(ui, commands), nil
 			},
@@ -71,6 +82,7 @@ USAGE:
 COMMANDS:
     complex    Complex command... has usage and env
     help       Show help message
+    nested     Nested command... has subcommands
     simple     Simple command... sorted to the end of the list
 
 GLOBAL OPTIONS:
@@ -123,6 +135,28 @@ GLOBAL OPTIONS:
 			})
 		})
 
+		Context("given a command with subcommands", func() {
+			It("prints requested command help with its subcommands", func() {
+				err := help.Run(fakeui.NewFakeStage(), []string{"nested"})
+				Expect(err).ToNot(HaveOccurred())
+				expectedOutput := `NAME:
+    nested - Nested command... has subcommands
+
+USAGE:
+    bosh-init [global options] nested <subcommand>
+
+COMMANDS:
+    restore <path>    Restore something
+    show <path>       Show something
+
+GLOBAL OPTIONS:
+    --help, -h       Show help message
+    --version, -v    Show version`
+
+				Expect(ui.Said).To(Equal([]string{expectedOutput}))
+			})
+		})
+
 		Context("when non-existing command name passed in as first argument", func() {
 			It("prints an error", func() {
 				err := help.Run(fakeui.NewFakeStage(), []string{"foo"})
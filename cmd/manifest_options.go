@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"os"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const manifestOptionsUsage = "[--var <name>=<value>]... [--vars-file <path>]... [--vars-env <prefix>]... [--vars-store <path>] [--ops-file <path>]..."
+
+// manifestOptions are the options providing the ops applied to the deployment manifest
+// and the values of its ((name)) variables
+type manifestOptions struct {
+	keyValues   []string
+	files       []string
+	envPrefixes []string
+	store       string
+	opsFiles    []string
+}
+
+// extractManifestOptions removes the --var, --vars-file, --vars-env, --vars-store & --ops-file options from the args, returning the remaining args
+func extractManifestOptions(args []string) (manifestOptions, []string, error) {
+	options := manifestOptions{}
+	remainingArgs := []string{}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--var", "--vars-file", "--vars-env", "--vars-store", "--ops-file":
+			if i+1 == len(args) {
+				return manifestOptions{}, nil, bosherr.Errorf("Invalid usage - option '%s' requires a value", args[i])
+			}
+			switch args[i] {
+			case "--var":
+				options.keyValues = append(options.keyValues, args[i+1])
+			case "--vars-file":
+				options.files = append(options.files, args[i+1])
+			case "--vars-store":
+				if options.store != "" {
+					return manifestOptions{}, nil, bosherr.Errorf("Invalid usage - option '%s' can only be given once", args[i])
+				}
+				options.store = args[i+1]
+			case "--ops-file":
+				options.opsFiles = append(options.opsFiles, args[i+1])
+			default:
+				options.envPrefixes = append(options.envPrefixes, args[i+1])
+			}
+			i++
+		default:
+			remainingArgs = append(remainingArgs, args[i])
+		}
+	}
+
+	return options, remainingArgs, nil
+}
+
+// Variables loads the variables of the options.
+// The --var values override the --vars-file values, which override the --vars-env values;
+// for a repeated option, the later values override the earlier ones.
+// With --vars-store, the variables defined in the variables section of the deployment manifest (after applying the ops)
+// that are not given are generated & saved to the store, and the stored variables are used for the missing values.
+func (o manifestOptions) Variables(deploymentManifestPath string, deploymentOps bipatch.Ops, fs boshsys.FileSystem) (bivars.Variables, error) {
+	variables := bivars.Variables{}
+
+	for _, prefix := range o.envPrefixes {
+		envVariables, err := bivars.LoadEnv(prefix, os.Environ())
+		if err != nil {
+			return bivars.Variables{}, bosherr.WrapErrorf(err, "Loading variables from environment with prefix '%s'", prefix)
+		}
+		variables = variables.Merge(envVariables)
+	}
+
+	for _, path := range o.files {
+		fileVariables, err := bivars.LoadFile(path, fs)
+		if err != nil {
+			return bivars.Variables{}, err
+		}
+		variables = variables.Merge(fileVariables)
+	}
+
+	for _, keyValue := range o.keyValues {
+		keyValueVariables, err := bivars.ParseKeyValue(keyValue)
+		if err != nil {
+			return bivars.Variables{}, err
+		}
+		variables = variables.Merge(keyValueVariables)
+	}
+
+	if o.store == "" {
+		return variables, nil
+	}
+
+	contents, err := fs.ReadFile(deploymentManifestPath)
+	if err != nil {
+		return bivars.Variables{}, bosherr.WrapErrorf(err, "Reading deployment manifest '%s'", deploymentManifestPath)
+	}
+
+	contents, err = deploymentOps.Apply(contents)
+	if err != nil {
+		return bivars.Variables{}, bosherr.WrapErrorf(err, "Applying ops to deployment manifest '%s'", deploymentManifestPath)
+	}
+
+	definitions, err := bivars.ParseDefinitions(contents)
+	if err != nil {
+		return bivars.Variables{}, bosherr.WrapErrorf(err, "Parsing variable definitions of deployment manifest '%s'", deploymentManifestPath)
+	}
+
+	return bivars.NewFileStore(o.store, fs, bivars.NewGenerator()).Generate(definitions, variables)
+}
+
+// Ops loads the ops files of the options, keeping the order of the files & of the operations within each file
+func (o manifestOptions) Ops(fs boshsys.FileSystem) (bipatch.Ops, error) {
+	ops := bipatch.Ops{}
+
+	for _, path := range o.opsFiles {
+		fileOps, err := bipatch.LoadFile(path, fs)
+		if err != nil {
+			return bipatch.Ops{}, err
+		}
+		ops = append(ops, fileOps...)
+	}
+
+	return ops, nil
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	ErrorSeverity   = string(bivalidation.ErrorSeverity)
+	WarningSeverity = string(bivalidation.WarningSeverity)
+)
+
+// ManifestProblem is a problem of the deployment manifest, with the YAML path of the value causing it (e.g. /jobs/0/name)
+type ManifestProblem struct {
+	Path     string `json:"path"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type ManifestValidator interface {
+	// Validate returns the problems of the deployment manifest, without downloading or installing anything.
+	// The deployment jobs are only checked against the release jobs when all the release tarballs are available locally.
+	Validate() []ManifestProblem
+}
+
+func NewManifestValidator(
+	fs boshsys.FileSystem,
+	logTag string,
+	logger boshlog.Logger,
+	releaseExtractor birel.Extractor,
+	releaseManager birel.Manager,
+	tarballCache bitarball.Cache,
+	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
+	releaseSetParser birelsetmanifest.Parser,
+	installationParser biinstallmanifest.Parser,
+	deploymentParser bideplmanifest.Parser,
+	deploymentValidator bideplmanifest.Validator,
+) ManifestValidator {
+	return &manifestValidator{
+		fs:                     fs,
+		logTag:                 logTag,
+		logger:                 logger,
+		releaseExtractor:       releaseExtractor,
+		releaseManager:         releaseManager,
+		tarballCache:           tarballCache,
+		deploymentManifestPath: deploymentManifestPath,
+		deploymentVars:         deploymentVars,
+		deploymentOps:          deploymentOps,
+		releaseSetParser:       releaseSetParser,
+		installationParser:     installationParser,
+		deploymentParser:       deploymentParser,
+		deploymentValidator:    deploymentValidator,
+	}
+}
+
+type manifestValidator struct {
+	fs                     boshsys.FileSystem
+	logTag                 string
+	logger                 boshlog.Logger
+	releaseExtractor       birel.Extractor
+	releaseManager         birel.Manager
+	tarballCache           bitarball.Cache
+	deploymentManifestPath string
+	deploymentVars         bivars.Variables
+	deploymentOps          bipatch.Ops
+	releaseSetParser       birelsetmanifest.Parser
+	installationParser     biinstallmanifest.Parser
+	deploymentParser       bideplmanifest.Parser
+	deploymentValidator    bideplmanifest.Validator
+}
+
+func (v *manifestValidator) Validate() []ManifestProblem {
+	defer func() {
+		err := v.releaseManager.DeleteAll()
+		if err != nil {
+			v.logger.Warn(v.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	problems := []ManifestProblem{}
+
+	// the release set parser & the installation parser also validate their manifests
+	releaseSetManifest, err := v.releaseSetParser.Parse(v.deploymentManifestPath, v.deploymentVars, v.deploymentOps)
+	if err != nil {
+		return append(problems, v.problemsOf(err)...)
+	}
+
+	_, err = v.installationParser.Parse(v.deploymentManifestPath, v.deploymentVars, v.deploymentOps, releaseSetManifest)
+	if err != nil {
+		problems = append(problems, v.problemsOf(err)...)
+	}
+
+	deploymentManifest, err := v.deploymentParser.Parse(v.deploymentManifestPath, v.deploymentVars, v.deploymentOps)
+	if err != nil {
+		return append(problems, v.problemsOf(err)...)
+	}
+
+	err = v.deploymentValidator.Validate(deploymentManifest, releaseSetManifest)
+	if err != nil {
+		problems = append(problems, v.problemsOf(err)...)
+	}
+
+	allReleasesExtracted := true
+	for releaseIdx, releaseRef := range releaseSetManifest.Releases {
+		releasePath, found := v.localTarballPath(releaseRef)
+		if !found {
+			problems = append(problems, ManifestProblem{
+				Path:     fmt.Sprintf("/releases/%d/url", releaseIdx),
+				Severity: WarningSeverity,
+				Message:  fmt.Sprintf("release '%s' is not available locally, so the jobs are not checked against the release jobs", releaseRef.Name),
+			})
+			allReleasesExtracted = false
+			continue
+		}
+
+		release, err := v.releaseExtractor.Extract(releasePath)
+		if err != nil {
+			problems = append(problems, ManifestProblem{
+				Path:     fmt.Sprintf("/releases/%d/url", releaseIdx),
+				Severity: ErrorSeverity,
+				Message:  bosherr.WrapErrorf(err, "Extracting release '%s'", releasePath).Error(),
+			})
+			allReleasesExtracted = false
+			continue
+		}
+		v.releaseManager.Add(release)
+	}
+
+	if allReleasesExtracted {
+		warnings, err := v.deploymentValidator.ValidateReleaseJobs(deploymentManifest, v.releaseManager)
+		if err != nil {
+			problems = append(problems, v.problemsOf(err)...)
+		}
+
+		for _, warning := range warnings {
+			problems = append(problems, v.manifestProblem(warning))
+		}
+	}
+
+	return problems
+}
+
+// localTarballPath returns the path of a file:// release tarball, or of an http(s):// release tarball already downloaded
+func (v *manifestValidator) localTarballPath(releaseRef birelmanifest.ReleaseRef) (string, bool) {
+	if strings.HasPrefix(releaseRef.URL, "file://") {
+		path, err := v.fs.ExpandPath(strings.TrimPrefix(releaseRef.URL, "file://"))
+		if err != nil || !v.fs.FileExists(path) {
+			return "", false
+		}
+		return path, true
+	}
+
+	if strings.HasPrefix(releaseRef.URL, "http") {
+		return v.tarballCache.Get(releaseRef)
+	}
+
+	return "", false
+}
+
+// problemsOf returns a problem per validation problem of the error, unwrapping the error to the multiple errors of a validator if any.
+// Errors that are not validation problems (e.g. a YAML syntax error) are problems of the whole manifest.
+func (v *manifestValidator) problemsOf(err error) []ManifestProblem {
+	cause := err
+	for {
+		if complexErr, ok := cause.(bosherr.ComplexError); ok {
+			cause = complexErr.Cause
+			continue
+		}
+		break
+	}
+
+	multiErr, ok := cause.(bosherr.MultiError)
+	if !ok {
+		return []ManifestProblem{{Path: "/", Severity: ErrorSeverity, Message: err.Error()}}
+	}
+
+	problems := make([]ManifestProblem, len(multiErr.Errors))
+	for i, validationErr := range multiErr.Errors {
+		problem, ok := validationErr.(bivalidation.Problem)
+		if !ok {
+			problem = bivalidation.Errorf("/", validationErr.Error())
+		}
+		problems[i] = v.manifestProblem(problem)
+	}
+	return problems
+}
+
+func (v *manifestValidator) manifestProblem(problem bivalidation.Problem) ManifestProblem {
+	return ManifestProblem{
+		Path:     problem.Path,
+		Severity: string(problem.Severity),
+		Message:  problem.Message,
+	}
+}
@@ -1,10 +1,12 @@
 // Automatically generated by MockGen. DO NOT EDIT!
-// Source: github.com/cloudfoundry/bosh-init/cmd (interfaces: DeploymentDeleter)
+// Source: github.com/cloudfoundry/bosh-init/cmd (interfaces: DeploymentDeleter,ReleaseExporter,TemplatesRenderer,ManifestValidator,ResourceVerifier,CpiCaller)
 
 package mocks
 
 import (
 	gomock "code.google.com/p/gomock/gomock"
+	cloud "github.com/cloudfoundry/bosh-init/cloud"
+	cmd "github.com/cloudfoundry/bosh-init/cmd"
 	ui "github.com/cloudfoundry/bosh-init/ui"
 )
 
@@ -38,3 +40,169 @@ func (_m *MockDeploymentDeleter) DeleteDeployment(_param0 ui.Stage) error {
 func (_mr *_MockDeploymentDeleterRecorder) DeleteDeployment(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteDeployment", arg0)
 }
+
+// Mock of ReleaseExporter interface
+type MockReleaseExporter struct {
+	ctrl     *gomock.Controller
+	recorder *_MockReleaseExporterRecorder
+}
+
+// Recorder for MockReleaseExporter (not exported)
+type _MockReleaseExporterRecorder struct {
+	mock *MockReleaseExporter
+}
+
+func NewMockReleaseExporter(ctrl *gomock.Controller) *MockReleaseExporter {
+	mock := &MockReleaseExporter{ctrl: ctrl}
+	mock.recorder = &_MockReleaseExporterRecorder{mock}
+	return mock
+}
+
+func (_m *MockReleaseExporter) EXPECT() *_MockReleaseExporterRecorder {
+	return _m.recorder
+}
+
+func (_m *MockReleaseExporter) ExportRelease(_param0 string, _param1 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "ExportRelease", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockReleaseExporterRecorder) ExportRelease(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ExportRelease", arg0, arg1)
+}
+
+// Mock of TemplatesRenderer interface
+type MockTemplatesRenderer struct {
+	ctrl     *gomock.Controller
+	recorder *_MockTemplatesRendererRecorder
+}
+
+// Recorder for MockTemplatesRenderer (not exported)
+type _MockTemplatesRendererRecorder struct {
+	mock *MockTemplatesRenderer
+}
+
+func NewMockTemplatesRenderer(ctrl *gomock.Controller) *MockTemplatesRenderer {
+	mock := &MockTemplatesRenderer{ctrl: ctrl}
+	mock.recorder = &_MockTemplatesRendererRecorder{mock}
+	return mock
+}
+
+func (_m *MockTemplatesRenderer) EXPECT() *_MockTemplatesRendererRecorder {
+	return _m.recorder
+}
+
+func (_m *MockTemplatesRenderer) Render(_param0 string, _param1 string, _param2 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "Render", _param0, _param1, _param2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockTemplatesRendererRecorder) Render(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Render", arg0, arg1, arg2)
+}
+
+// Mock of ManifestValidator interface
+type MockManifestValidator struct {
+	ctrl     *gomock.Controller
+	recorder *_MockManifestValidatorRecorder
+}
+
+// Recorder for MockManifestValidator (not exported)
+type _MockManifestValidatorRecorder struct {
+	mock *MockManifestValidator
+}
+
+func NewMockManifestValidator(ctrl *gomock.Controller) *MockManifestValidator {
+	mock := &MockManifestValidator{ctrl: ctrl}
+	mock.recorder = &_MockManifestValidatorRecorder{mock}
+	return mock
+}
+
+func (_m *MockManifestValidator) EXPECT() *_MockManifestValidatorRecorder {
+	return _m.recorder
+}
+
+func (_m *MockManifestValidator) Validate() []cmd.ManifestProblem {
+	ret := _m.ctrl.Call(_m, "Validate")
+	ret0, _ := ret[0].([]cmd.ManifestProblem)
+	return ret0
+}
+
+func (_mr *_MockManifestValidatorRecorder) Validate() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Validate")
+}
+
+// Mock of ResourceVerifier interface
+type MockResourceVerifier struct {
+	ctrl     *gomock.Controller
+	recorder *_MockResourceVerifierRecorder
+}
+
+// Recorder for MockResourceVerifier (not exported)
+type _MockResourceVerifierRecorder struct {
+	mock *MockResourceVerifier
+}
+
+func NewMockResourceVerifier(ctrl *gomock.Controller) *MockResourceVerifier {
+	mock := &MockResourceVerifier{ctrl: ctrl}
+	mock.recorder = &_MockResourceVerifierRecorder{mock}
+	return mock
+}
+
+func (_m *MockResourceVerifier) EXPECT() *_MockResourceVerifierRecorder {
+	return _m.recorder
+}
+
+func (_m *MockResourceVerifier) VerifyVM(_param0 string, _param1 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "VerifyVM", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockResourceVerifierRecorder) VerifyVM(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "VerifyVM", arg0, arg1)
+}
+
+func (_m *MockResourceVerifier) VerifyDisk(_param0 string, _param1 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "VerifyDisk", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockResourceVerifierRecorder) VerifyDisk(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "VerifyDisk", arg0, arg1)
+}
+
+// Mock of CpiCaller interface
+type MockCpiCaller struct {
+	ctrl     *gomock.Controller
+	recorder *_MockCpiCallerRecorder
+}
+
+// Recorder for MockCpiCaller (not exported)
+type _MockCpiCallerRecorder struct {
+	mock *MockCpiCaller
+}
+
+func NewMockCpiCaller(ctrl *gomock.Controller) *MockCpiCaller {
+	mock := &MockCpiCaller{ctrl: ctrl}
+	mock.recorder = &_MockCpiCallerRecorder{mock}
+	return mock
+}
+
+func (_m *MockCpiCaller) EXPECT() *_MockCpiCallerRecorder {
+	return _m.recorder
+}
+
+func (_m *MockCpiCaller) Call(_param0 string, _param1 []interface{}, _param2 bool, _param3 ui.Stage) (cloud.CmdOutput, error) {
+	ret := _m.ctrl.Call(_m, "Call", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(cloud.CmdOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockCpiCallerRecorder) Call(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Call", arg0, arg1, arg2, arg3)
+}
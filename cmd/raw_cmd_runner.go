@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// rawCmdRunner prints the exact stdin, stdout & stderr of the complex commands it runs,
+// which is how the CPI is called, for the --raw option of the cpi command
+type rawCmdRunner struct {
+	boshsys.CmdRunner
+	ui biui.UI
+}
+
+func newRawCmdRunner(cmdRunner boshsys.CmdRunner, ui biui.UI) boshsys.CmdRunner {
+	return rawCmdRunner{
+		CmdRunner: cmdRunner,
+		ui:        ui,
+	}
+}
+
+func (r rawCmdRunner) RunComplexCommand(cmd boshsys.Command) (string, string, int, error) {
+	if cmd.Stdin != nil {
+		stdin, err := ioutil.ReadAll(cmd.Stdin)
+		if err != nil {
+			return "", "", -1, bosherr.WrapErrorf(err, "Reading stdin of command '%s'", cmd.Name)
+		}
+		cmd.Stdin = bytes.NewReader(stdin)
+
+		r.ui.PrintLinef("STDIN:\n%s", stdin)
+	}
+
+	stdout, stderr, exitStatus, err := r.CmdRunner.RunComplexCommand(cmd)
+
+	r.ui.PrintLinef("STDOUT:\n%s", stdout)
+	r.ui.PrintLinef("STDERR:\n%s", stderr)
+	r.ui.PrintLinef("Exit status: %d", exitStatus)
+
+	return stdout, stderr, exitStatus, err
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
+	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+type ReleaseExporter interface {
+	ExportRelease(releaseName string, stage biui.Stage) error
+}
+
+func NewReleaseExporter(
+	ui biui.UI,
+	logTag string,
+	logger boshlog.Logger,
+	deploymentStateService biconfig.DeploymentStateService,
+	releaseManager birel.Manager,
+	blobstoreFactory biblobstore.Factory,
+	compiledPackageRepo bistatepkg.CompiledPackageRepo,
+	compiledReleaseWriter birel.CompiledReleaseWriter,
+	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
+	releaseFetcher birel.Fetcher,
+	stemcellFetcher bistemcell.Fetcher,
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser,
+	deploymentManifestParser DeploymentManifestParser,
+) ReleaseExporter {
+	return &releaseExporter{
+		ui:                                      ui,
+		logTag:                                  logTag,
+		logger:                                  logger,
+		deploymentStateService:                  deploymentStateService,
+		releaseManager:                          releaseManager,
+		blobstoreFactory:                        blobstoreFactory,
+		compiledPackageRepo:                     compiledPackageRepo,
+		compiledReleaseWriter:                   compiledReleaseWriter,
+		deploymentManifestPath:                  deploymentManifestPath,
+		deploymentVars:                          deploymentVars,
+		deploymentOps:                           deploymentOps,
+		releaseFetcher:                          releaseFetcher,
+		stemcellFetcher:                         stemcellFetcher,
+		releaseSetAndInstallationManifestParser: releaseSetAndInstallationManifestParser,
+		deploymentManifestParser:                deploymentManifestParser,
+	}
+}
+
+type releaseExporter struct {
+	ui                                      biui.UI
+	logTag                                  string
+	logger                                  boshlog.Logger
+	deploymentStateService                  biconfig.DeploymentStateService
+	releaseManager                          birel.Manager
+	blobstoreFactory                        biblobstore.Factory
+	compiledPackageRepo                     bistatepkg.CompiledPackageRepo
+	compiledReleaseWriter                   birel.CompiledReleaseWriter
+	deploymentManifestPath                  string
+	deploymentVars                          bivars.Variables
+	deploymentOps                           bipatch.Ops
+	releaseFetcher                          birel.Fetcher
+	stemcellFetcher                         bistemcell.Fetcher
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser
+	deploymentManifestParser                DeploymentManifestParser
+}
+
+// ExportRelease downloads the packages of the named release compiled by the last deploy from the agent blobstore,
+// and writes a compiled release tarball for the deployed stemcell into the current directory.
+// Nothing is compiled: every package used by the jobs of the release must have been compiled by the deployment.
+func (c *releaseExporter) ExportRelease(releaseName string, stage biui.Stage) error {
+	c.ui.PrintLinef("Deployment state: '%s'", c.deploymentStateService.Path())
+
+	if !c.deploymentStateService.Exists() {
+		return bosherr.Errorf("Deployment state does not exist at '%s'. Deploy before exporting releases", c.deploymentStateService.Path())
+	}
+
+	deploymentState, err := c.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	if deploymentState.CurrentVMCID == "" {
+		return bosherr.Error("No deployed VM found. Deploy before exporting releases")
+	}
+
+	defer func() {
+		err := c.releaseManager.DeleteAll()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	var (
+		release              birel.Release
+		extractedStemcell    bistemcell.ExtractedStemcell
+		installationManifest biinstallmanifest.Manifest
+	)
+	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
+		var releaseSetManifest birelsetmanifest.Manifest
+		releaseSetManifest, installationManifest, err = c.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps)
+		if err != nil {
+			return err
+		}
+
+		if _, found := releaseSetManifest.FindByName(releaseName); !found {
+			return bosherr.Errorf("Release '%s' is not in the deployment manifest releases", releaseName)
+		}
+
+		for _, releaseRef := range releaseSetManifest.Releases {
+			err = c.releaseFetcher.DownloadAndExtract(releaseRef, stage)
+			if err != nil {
+				return err
+			}
+		}
+
+		release, _ = c.releaseManager.Find(releaseName)
+
+		var deploymentManifest bideplmanifest.Manifest
+		deploymentManifest, err = c.deploymentManifestParser.GetDeploymentManifest(c.deploymentManifestPath, c.deploymentVars, c.deploymentOps, releaseSetManifest, stage)
+		if err != nil {
+			return err
+		}
+
+		extractedStemcell, err = c.stemcellFetcher.GetStemcell(deploymentManifest, stage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		deleteErr := extractedStemcell.Delete()
+		if deleteErr != nil {
+			c.logger.Warn(c.logTag, "Failed to delete extracted stemcell: %s", deleteErr.Error())
+		}
+	}()
+
+	stemcellManifest := extractedStemcell.Manifest()
+	if stemcellManifest.OS == "" {
+		return bosherr.Errorf("Stemcell '%s/%s' does not specify an operating system, which compiled releases require", stemcellManifest.Name, stemcellManifest.Version)
+	}
+
+	compiledPackageStemcell := bistatepkg.Stemcell{
+		Name:    stemcellManifest.Name,
+		Version: stemcellManifest.Version,
+		OS:      stemcellManifest.OS,
+	}
+
+	compiledPackageRefs, err := c.findCompiledPackages(release, compiledPackageStemcell)
+	if err != nil {
+		return err
+	}
+
+	blobstore, err := c.blobstoreFactory.Create(installationManifest.Mbus)
+	if err != nil {
+		return bosherr.WrapError(err, "Creating blobstore client")
+	}
+
+	tarballName := fmt.Sprintf("%s-%s-%s-%s.tgz", release.Name(), release.Version(), stemcellManifest.OS, stemcellManifest.Version)
+	tarballPath, err := filepath.Abs(tarballName)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to compiled release '%s'", tarballName)
+	}
+
+	err = stage.PerformComplex("exporting release", func(stage biui.Stage) error {
+		return c.exportRelease(release, compiledPackageStemcell.OsAndVersion(), compiledPackageRefs, blobstore, tarballPath, stage)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.ui.PrintLinef("Exported compiled release: '%s'", tarballPath)
+	return nil
+}
+
+func (c *releaseExporter) exportRelease(
+	release birel.Release,
+	stemcell string,
+	compiledPackageRefs []bistatejob.CompiledPackageRef,
+	blobstore biblobstore.Blobstore,
+	tarballPath string,
+	stage biui.Stage,
+) error {
+	compiledPackages := make([]birel.CompiledPackage, 0, len(compiledPackageRefs))
+
+	localBlobs := []biblobstore.LocalBlob{}
+	defer func() {
+		for _, localBlob := range localBlobs {
+			localBlob.DeleteSilently()
+		}
+	}()
+
+	for _, compiledPackageRef := range compiledPackageRefs {
+		pkg, found := c.findPackage(release, compiledPackageRef.Name)
+		if !found {
+			return bosherr.Errorf("Compiled package '%s' is not in release '%s'", compiledPackageRef.Name, release.Name())
+		}
+
+		stepName := fmt.Sprintf("Downloading compiled package '%s/%s'", compiledPackageRef.Name, compiledPackageRef.Version)
+		err := stage.Perform(stepName, func() error {
+			localBlob, err := blobstore.Get(compiledPackageRef.BlobstoreID)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Downloading compiled package '%s' from blobstore", compiledPackageRef.Name)
+			}
+			localBlobs = append(localBlobs, localBlob)
+
+			compiledPackages = append(compiledPackages, birel.CompiledPackage{
+				Package:     pkg,
+				ArchivePath: localBlob.Path(),
+				SHA1:        compiledPackageRef.SHA1,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return stage.Perform(fmt.Sprintf("Writing compiled release '%s'", tarballPath), func() error {
+		return c.compiledReleaseWriter.Write(release, stemcell, compiledPackages, tarballPath)
+	})
+}
+
+// findCompiledPackages returns the records of the packages used by the jobs of the release, compiled by the deployment for the stemcell
+func (c *releaseExporter) findCompiledPackages(release birel.Release, stemcell bistatepkg.Stemcell) ([]bistatejob.CompiledPackageRef, error) {
+	usedPackageNames := map[string]bool{}
+	for _, job := range release.Jobs() {
+		for _, pkg := range job.Packages {
+			usedPackageNames[pkg.Name] = true
+			for _, dependency := range bistatepkg.ResolveDependencies(pkg) {
+				usedPackageNames[dependency.Name] = true
+			}
+		}
+	}
+
+	compiledPackageRefs := []bistatejob.CompiledPackageRef{}
+	for _, pkg := range release.Packages() {
+		if !usedPackageNames[pkg.Name] {
+			continue
+		}
+
+		record, found, err := c.compiledPackageRepo.Find(*pkg, stemcell)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Finding compiled package '%s/%s'", pkg.Name, pkg.Fingerprint)
+		}
+		if !found {
+			return nil, bosherr.Errorf("Package '%s/%s' of release '%s' was not compiled for stemcell '%s/%s' by the deployment. "+
+				"Deploy the jobs using it before exporting the release", pkg.Name, pkg.Fingerprint, release.Name(), stemcell.Name, stemcell.Version)
+		}
+
+		compiledPackageRefs = append(compiledPackageRefs, bistatejob.CompiledPackageRef{
+			Name:        pkg.Name,
+			Version:     pkg.Fingerprint,
+			BlobstoreID: record.BlobID,
+			SHA1:        record.BlobSHA1,
+		})
+	}
+
+	return compiledPackageRefs, nil
+}
+
+func (c *releaseExporter) findPackage(release birel.Release, pkgName string) (*birelpkg.Package, bool) {
+	for _, pkg := range release.Packages() {
+		if pkg.Name == pkgName {
+			return pkg, true
+		}
+	}
+	return nil, false
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -11,13 +13,13 @@ type ReleaseSetAndInstallationManifestParser struct {
 	InstallationParser biinstallmanifest.Parser
 }
 
-func (y ReleaseSetAndInstallationManifestParser) ReleaseSetAndInstallationManifest(deploymentManifestPath string) (birelsetmanifest.Manifest, biinstallmanifest.Manifest, error) {
-	releaseSetManifest, err := y.ReleaseSetParser.Parse(deploymentManifestPath)
+func (y ReleaseSetAndInstallationManifestParser) ReleaseSetAndInstallationManifest(deploymentManifestPath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (birelsetmanifest.Manifest, biinstallmanifest.Manifest, error) {
+	releaseSetManifest, err := y.ReleaseSetParser.Parse(deploymentManifestPath, deploymentVars, deploymentOps)
 	if err != nil {
 		return birelsetmanifest.Manifest{}, biinstallmanifest.Manifest{}, bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", deploymentManifestPath)
 	}
 
-	installationManifest, err := y.InstallationParser.Parse(deploymentManifestPath, releaseSetManifest)
+	installationManifest, err := y.InstallationParser.Parse(deploymentManifestPath, deploymentVars, deploymentOps, releaseSetManifest)
 	if err != nil {
 		return birelsetmanifest.Manifest{}, biinstallmanifest.Manifest{}, bosherr.WrapErrorf(err, "Parsing installation manifest '%s'", deploymentManifestPath)
 	}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type renderCmd struct {
+	templatesRendererProvider func(deploymentManifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (TemplatesRenderer, error)
+	ui                        biui.UI
+	fs                        boshsys.FileSystem
+	logger                    boshlog.Logger
+	logTag                    string
+}
+
+func NewRenderCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	templatesRendererProvider func(deploymentManifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (TemplatesRenderer, error),
+) Cmd {
+	return &renderCmd{
+		ui:                        ui,
+		fs:                        fs,
+		templatesRendererProvider: templatesRendererProvider,
+		logger:                    logger,
+		logTag:                    "renderCmd",
+	}
+}
+
+func (c *renderCmd) Name() string {
+	return "render"
+}
+
+func (c *renderCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Render the job templates of a deployment locally, without deploying, or only those of the release job (template) given with --job",
+		Usage:    "<deployment_manifest_path> [--job <release_job_name>] --output <dir> " + stateOptionsUsage + " " + manifestOptionsUsage,
+		Env:      genericEnv,
+	}
+}
+
+func (c *renderCmd) Run(stage biui.Stage, args []string) error {
+	deploymentManifestPath, releaseJobName, outputPath, deploymentStateOptions, deploymentManifestOptions, err := c.parseCmdInputs(args)
+	if err != nil {
+		return err
+	}
+
+	manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	outputAbsPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting absolute path to output dir '%s'", outputPath)
+	}
+
+	c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+
+	// the last rendered templates are kept by deployment state, so the same state as deploy is needed to compare with them
+	stateAbsFilePath, err := deploymentStateAbsPath(deploymentStateOptions.path, manifestAbsFilePath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+		return bosherr.WrapErrorf(err, "Getting absolute path to deployment state file '%s'", deploymentStateOptions.path)
+	}
+
+	deploymentOps, err := deploymentManifestOptions.Ops(c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest ops files")
+		return bosherr.WrapError(err, "Loading deployment manifest ops files")
+	}
+
+	deploymentVars, err := deploymentManifestOptions.Variables(manifestAbsFilePath, deploymentOps, c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest variables")
+		return bosherr.WrapError(err, "Loading deployment manifest variables")
+	}
+
+	templatesRenderer, err := c.templatesRendererProvider(manifestAbsFilePath, stateAbsFilePath, deploymentVars, deploymentOps)
+	if err != nil {
+		return err
+	}
+
+	return templatesRenderer.Render(releaseJobName, outputAbsPath, stage)
+}
+
+func (c *renderCmd) parseCmdInputs(args []string) (string, string, string, stateOptions, manifestOptions, error) {
+	var deploymentManifestPath, releaseJobName, outputPath string
+
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", "", "", deploymentStateOptions, manifestOptions{}, err
+	}
+
+	deploymentManifestOptions, remainingArgs, err := extractManifestOptions(remainingArgs)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", "", "", deploymentStateOptions, deploymentManifestOptions, err
+	}
+
+	for i := 0; i < len(remainingArgs); i++ {
+		switch remainingArgs[i] {
+		case "--job", "--output":
+			if i+1 == len(remainingArgs) {
+				c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+				return "", "", "", deploymentStateOptions, deploymentManifestOptions, bosherr.Errorf("Invalid usage - render command option '%s' requires a value", remainingArgs[i])
+			}
+			if remainingArgs[i] == "--job" {
+				releaseJobName = remainingArgs[i+1]
+			} else {
+				outputPath = remainingArgs[i+1]
+			}
+			i++
+		default:
+			if deploymentManifestPath != "" {
+				c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+				return "", "", "", deploymentStateOptions, deploymentManifestOptions, errors.New("Invalid usage - render command requires exactly 1 deployment manifest argument")
+			}
+			deploymentManifestPath = remainingArgs[i]
+		}
+	}
+
+	if deploymentManifestPath == "" {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", "", "", deploymentStateOptions, deploymentManifestOptions, errors.New("Invalid usage - render command requires exactly 1 deployment manifest argument")
+	}
+
+	if outputPath == "" {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return "", "", "", deploymentStateOptions, deploymentManifestOptions, errors.New("Invalid usage - render command requires the --output option")
+	}
+
+	return deploymentManifestPath, releaseJobName, outputPath, deploymentStateOptions, deploymentManifestOptions, nil
+}
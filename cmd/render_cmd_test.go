@@ -0,0 +1,284 @@
+package cmd_test
+
+import (
+	"os"
+
+	bicmd "github.com/cloudfoundry/bosh-init/cmd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	mock_cmd "github.com/cloudfoundry/bosh-init/cmd/mocks"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+	fakeui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("RenderCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("Run", func() {
+		var (
+			mockTemplatesRenderer *mock_cmd.MockTemplatesRenderer
+			fs                    boshsys.FileSystem
+			logger                boshlog.Logger
+
+			fakeUI                      *fakeui.FakeUI
+			fakeStage                   *fakebiui.FakeStage
+			deploymentManifestPath      = "/deployment-dir/fake-deployment-manifest.yml"
+			receivedDeploymentStatePath string
+			receivedDeploymentVars      bivars.Variables
+			receivedDeploymentOps       bipatch.Ops
+		)
+
+		var newRenderCmd = func() bicmd.Cmd {
+			doGetFunc := func(manifestPath string, deploymentStatePath string, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.TemplatesRenderer, error) {
+				Expect(manifestPath).To(Equal(deploymentManifestPath))
+				receivedDeploymentStatePath = deploymentStatePath
+				receivedDeploymentVars = deploymentVars
+				receivedDeploymentOps = deploymentOps
+				return mockTemplatesRenderer, nil
+			}
+
+			return bicmd.NewRenderCmd(fakeUI, fs, logger, doGetFunc)
+		}
+
+		BeforeEach(func() {
+			mockTemplatesRenderer = mock_cmd.NewMockTemplatesRenderer(mockCtrl)
+			fs = fakesys.NewFakeFileSystem()
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fakeUI = &fakeui.FakeUI{}
+			fakeStage = fakebiui.NewFakeStage()
+			fs.WriteFileString(deploymentManifestPath, `---manifest-content`)
+		})
+
+		Context("when the deployment manifest does not exist", func() {
+			It("returns an error", func() {
+				err := newRenderCmd().Run(fakeStage, []string{"/garbage", "--output", "/fake-output"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Deployment manifest does not exist at '/garbage'"))
+				Expect(fakeUI.Errors).To(ContainElement("Deployment '/garbage' does not exist"))
+			})
+		})
+
+		Context("when the deployment manifest exists", func() {
+			It("renders all the jobs into the output dir", func() {
+				mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil)
+				err := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output"})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("uses the deployment state next to the deployment manifest", func() {
+				mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil)
+				err := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentStatePath).To(Equal("/deployment-dir/fake-deployment-manifest-state.json"))
+			})
+
+			It("uses the deployment state given with --state", func() {
+				mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil).Times(2)
+				err := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--state", "/other-dir/state.json"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentStatePath).To(Equal("/other-dir/state.json"))
+
+				err = newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--state", "s3://fake-bucket/state.json"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(receivedDeploymentStatePath).To(Equal("s3://fake-bucket/state.json"))
+			})
+
+			It("finds the job templates that deploy recorded with the same deployment state", func() {
+				workspacePath := "/fake-workspace"
+				logger := boshlog.NewLogger(boshlog.LevelNone)
+
+				mockArchive := mock_template.NewMockRenderedJobListArchive(mockCtrl)
+				mockArchive.EXPECT().Path().Return("/fake-archive-path").AnyTimes()
+				fs.WriteFileString("/fake-archive-path", "fake-archive-content")
+
+				deployProvider := func(manifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.DeploymentPreparer, error) {
+					archiveRepo := bitemplate.NewRenderedJobListArchiveRepo(biconfig.RenderedTemplatesPath(workspacePath, deploymentStatePath), 5, fs, logger)
+					Expect(archiveRepo.SavePending(mockArchive, []string{})).To(Succeed())
+					Expect(archiveRepo.CommitPending()).To(Succeed())
+					return bicmd.DeploymentPreparer{}, bosherr.Error("fake-stop-deploy")
+				}
+				err := bicmd.NewDeployCmd(fakeUI, fs, logger, deployProvider).Run(fakeStage, []string{deploymentManifestPath, "--state", "/other-dir/state.json"})
+				Expect(err).To(MatchError("fake-stop-deploy"))
+
+				mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil)
+				err = newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--state", "/other-dir/state.json"})
+				Expect(err).ToNot(HaveOccurred())
+
+				archiveRepo := bitemplate.NewRenderedJobListArchiveRepo(biconfig.RenderedTemplatesPath(workspacePath, receivedDeploymentStatePath), 5, fs, logger)
+				_, found := archiveRepo.FindLast()
+				Expect(found).To(BeTrue())
+			})
+
+			It("renders the job selected with --job", func() {
+				mockTemplatesRenderer.EXPECT().Render("fake-job", "/fake-output", fakeStage).Return(nil)
+				err := newRenderCmd().Run(fakeStage, []string{"--job", "fake-job", deploymentManifestPath, "--output", "/fake-output"})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when manifest variables are given", func() {
+				BeforeEach(func() {
+					fs.WriteFileString("/fake-vars-1.yml", "from_file: file-1\nfrom_files: file-1\nfrom_var: file-1\n")
+					fs.WriteFileString("/fake-vars-2.yml", "from_files: file-2\n")
+					os.Setenv("FAKE_VARS_from_env", "env")
+					os.Setenv("FAKE_VARS_from_file", "env")
+				})
+
+				AfterEach(func() {
+					os.Unsetenv("FAKE_VARS_from_env")
+					os.Unsetenv("FAKE_VARS_from_file")
+				})
+
+				It("passes the variables to the templates renderer, --var overriding --vars-file overriding --vars-env", func() {
+					mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil)
+					err := newRenderCmd().Run(fakeStage, []string{
+						deploymentManifestPath, "--output", "/fake-output",
+						"--var", "from_var=var",
+						"--vars-file", "/fake-vars-1.yml",
+						"--vars-file", "/fake-vars-2.yml",
+						"--vars-env", "FAKE_VARS",
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(receivedDeploymentVars).To(Equal(bivars.Variables{
+						"from_env":   "env",
+						"from_file":  "file-1",
+						"from_files": "file-2",
+						"from_var":   "var",
+					}))
+				})
+
+				It("returns an error when a vars file cannot be read", func() {
+					err := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--vars-file", "/missing.yml"})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Reading variables file '/missing.yml'"))
+					Expect(fakeUI.Errors).To(ContainElement("Failed loading deployment manifest variables"))
+				})
+			})
+
+			Context("when a vars store is given", func() {
+				BeforeEach(func() {
+					fs.WriteFileString(deploymentManifestPath, "---\nvariables:\n- name: nats_password\n  type: password\n- name: registry_password\n  type: password\n")
+					fs.WriteFileString("/fake-ops.yml", "- type: replace\n  path: /variables/-\n  value: {name: jumpbox_ssh, type: ssh}\n")
+				})
+
+				It("generates the defined variables that are not given, saving them to the store", func() {
+					mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil).Times(2)
+					args := []string{
+						deploymentManifestPath, "--output", "/fake-output",
+						"--vars-store", "/fake-creds.yml",
+						"--ops-file", "/fake-ops.yml",
+						"--var", "registry_password=given-password",
+					}
+
+					err := newRenderCmd().Run(fakeStage, args)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(receivedDeploymentVars["nats_password"]).To(MatchRegexp("^[a-z0-9]{20}$"))
+					Expect(receivedDeploymentVars["registry_password"]).To(Equal("given-password"))
+					Expect(receivedDeploymentVars).To(HaveKey("jumpbox_ssh"))
+
+					storedVars, err := bivars.LoadFile("/fake-creds.yml", fs)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(storedVars).To(HaveKey("nats_password"))
+					Expect(storedVars).To(HaveKey("jumpbox_ssh"))
+					Expect(storedVars).ToNot(HaveKey("registry_password"))
+
+					firstDeploymentVars := receivedDeploymentVars
+					err = newRenderCmd().Run(fakeStage, args)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(receivedDeploymentVars).To(Equal(firstDeploymentVars))
+				})
+
+				It("returns an error when the vars store is given twice", func() {
+					err := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--vars-store", "/a.yml", "--vars-store", "/b.yml"})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("Invalid usage - option '--vars-store' can only be given once"))
+				})
+			})
+
+			Context("when ops files are given", func() {
+				BeforeEach(func() {
+					fs.WriteFileString("/fake-ops-1.yml", "- type: replace\n  path: /name\n  value: fake-name\n")
+					fs.WriteFileString("/fake-ops-2.yml", "- type: remove\n  path: /jobs/name=fake-job\n")
+				})
+
+				It("passes the operations of all the ops files, in order, to the templates renderer", func() {
+					mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(nil)
+					err := newRenderCmd().Run(fakeStage, []string{
+						deploymentManifestPath, "--output", "/fake-output",
+						"--ops-file", "/fake-ops-1.yml",
+						"--ops-file", "/fake-ops-2.yml",
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(receivedDeploymentOps).To(HaveLen(2))
+					Expect(receivedDeploymentOps[0].String()).To(Equal("replace '/name'"))
+					Expect(receivedDeploymentOps[1].String()).To(Equal("remove '/jobs/name=fake-job'"))
+				})
+
+				It("returns an error naming the invalid operation", func() {
+					fs.WriteFileString("/fake-ops-2.yml", "- type: remove\n  path: /jobs/name=fake-job\n  value: x\n")
+
+					err := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--ops-file", "/fake-ops-2.yml"})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Parsing ops file '/fake-ops-2.yml': Operation 0"))
+					Expect(fakeUI.Errors).To(ContainElement("Failed loading deployment manifest ops files"))
+				})
+			})
+
+			Context("when the templates renderer returns an error", func() {
+				It("returns the error", func() {
+					err := bosherr.Error("boom")
+					mockTemplatesRenderer.EXPECT().Render("", "/fake-output", fakeStage).Return(err)
+					returnedErr := newRenderCmd().Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output"})
+					Expect(returnedErr).To(Equal(err))
+				})
+			})
+		})
+
+		It("returns err unless a deployment manifest and an output dir are given", func() {
+			command := newRenderCmd()
+
+			err := command.Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("requires the --output option"))
+
+			err = command.Run(fakeStage, []string{"--output", "/fake-output"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+
+			err = command.Run(fakeStage, []string{deploymentManifestPath, "other", "--output", "/fake-output"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+
+			err = command.Run(fakeStage, []string{deploymentManifestPath, "--job"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("option '--job' requires a value"))
+
+			err = command.Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--var"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("option '--var' requires a value"))
+
+			err = command.Run(fakeStage, []string{deploymentManifestPath, "--output", "/fake-output", "--var", "no-value"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected variable 'no-value' to be in the format 'name=value'"))
+		})
+	})
+})
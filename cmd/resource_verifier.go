@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+
+	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
+	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
+	bihttpagent "github.com/cloudfoundry/bosh-init/deployment/agentclient/http"
+	biinstall "github.com/cloudfoundry/bosh-init/installation"
+	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// ResourceVerifier checks with the CPI & the agent of a deployment that resources created outside of bosh-init exist,
+// before they are imported into its deployment state
+type ResourceVerifier interface {
+	VerifyVM(vmCID string, stage biui.Stage) error
+	VerifyDisk(diskCID string, stage biui.Stage) error
+}
+
+func NewResourceVerifier(
+	logTag string,
+	logger boshlog.Logger,
+	deploymentStateService biconfig.DeploymentStateService,
+	releaseManager birel.Manager,
+	cloudFactory bicloud.Factory,
+	agentClientFactory bihttpagent.AgentClientFactory,
+	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
+	cpiInstaller bicpirel.CpiInstaller,
+	releaseFetcher birel.Fetcher,
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser,
+) ResourceVerifier {
+	return &resourceVerifier{
+		logTag:                                  logTag,
+		logger:                                  logger,
+		deploymentStateService:                  deploymentStateService,
+		releaseManager:                          releaseManager,
+		cloudFactory:                            cloudFactory,
+		agentClientFactory:                      agentClientFactory,
+		deploymentManifestPath:                  deploymentManifestPath,
+		deploymentVars:                          deploymentVars,
+		deploymentOps:                           deploymentOps,
+		cpiInstaller:                            cpiInstaller,
+		releaseFetcher:                          releaseFetcher,
+		releaseSetAndInstallationManifestParser: releaseSetAndInstallationManifestParser,
+	}
+}
+
+type resourceVerifier struct {
+	logTag                                  string
+	logger                                  boshlog.Logger
+	deploymentStateService                  biconfig.DeploymentStateService
+	releaseManager                          birel.Manager
+	cloudFactory                            bicloud.Factory
+	agentClientFactory                      bihttpagent.AgentClientFactory
+	deploymentManifestPath                  string
+	deploymentVars                          bivars.Variables
+	deploymentOps                           bipatch.Ops
+	cpiInstaller                            bicpirel.CpiInstaller
+	releaseFetcher                          birel.Fetcher
+	releaseSetAndInstallationManifestParser ReleaseSetAndInstallationManifestParser
+}
+
+// VerifyVM checks that the CPI finds the VM & that the agent of the deployment responds
+func (v *resourceVerifier) VerifyVM(vmCID string, stage biui.Stage) error {
+	return v.withCloudAndAgent(stage, func(cloud bicloud.Cloud, agentClient biagentclient.AgentClient) error {
+		return stage.Perform(fmt.Sprintf("Verifying VM '%s'", vmCID), func() error {
+			found, err := cloud.HasVM(vmCID)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Checking existence of VM '%s'", vmCID)
+			}
+			if !found {
+				return bosherr.Errorf("VM '%s' does not exist in the cloud", vmCID)
+			}
+
+			_, err = agentClient.Ping()
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Pinging the agent of VM '%s'", vmCID)
+			}
+
+			return nil
+		})
+	})
+}
+
+// VerifyDisk checks that the agent of the deployment lists the disk as attached to its VM
+func (v *resourceVerifier) VerifyDisk(diskCID string, stage biui.Stage) error {
+	return v.withCloudAndAgent(stage, func(_ bicloud.Cloud, agentClient biagentclient.AgentClient) error {
+		return stage.Perform(fmt.Sprintf("Verifying disk '%s'", diskCID), func() error {
+			diskCIDs, err := agentClient.ListDisk()
+			if err != nil {
+				return bosherr.WrapError(err, "Listing the disks of the agent")
+			}
+
+			for _, attachedDiskCID := range diskCIDs {
+				if attachedDiskCID == diskCID {
+					return nil
+				}
+			}
+
+			return bosherr.Errorf("Disk '%s' is not attached to the VM of the deployment", diskCID)
+		})
+	})
+}
+
+func (v *resourceVerifier) withCloudAndAgent(stage biui.Stage, fn func(bicloud.Cloud, biagentclient.AgentClient) error) error {
+	deploymentState, err := v.deploymentStateService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+	defer func() {
+		err := v.releaseManager.DeleteAll()
+		if err != nil {
+			v.logger.Warn(v.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	var installationManifest biinstallmanifest.Manifest
+	err = stage.PerformComplex("validating", func(stage biui.Stage) error {
+		var releaseSetManifest birelsetmanifest.Manifest
+		releaseSetManifest, installationManifest, err = v.releaseSetAndInstallationManifestParser.ReleaseSetAndInstallationManifest(v.deploymentManifestPath, v.deploymentVars, v.deploymentOps)
+		if err != nil {
+			return err
+		}
+
+		cpiReleaseName := installationManifest.Template.Release
+		cpiReleaseRef, found := releaseSetManifest.FindByName(cpiReleaseName)
+		if !found {
+			return bosherr.Errorf("installation release '%s' must refer to a release in releases", cpiReleaseName)
+		}
+
+		err = v.releaseFetcher.DownloadAndExtract(cpiReleaseRef, stage)
+		if err != nil {
+			return err
+		}
+
+		return v.cpiInstaller.ValidateCpiRelease(installationManifest, stage)
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.cpiInstaller.WithInstalledCpiRelease(installationManifest, stage, func(installation biinstall.Installation) error {
+		cloud, err := v.cloudFactory.NewCloud(installation, deploymentState.DirectorID)
+		if err != nil {
+			return bosherr.WrapError(err, "Creating CPI client from CPI installation")
+		}
+
+		agentClient := v.agentClientFactory.NewAgentClient(deploymentState.DirectorID, installationManifest.Mbus)
+		return fn(cloud, agentClient)
+	})
+}
@@ -0,0 +1,834 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+type stateCmd struct {
+	ui                             biui.UI
+	fs                             boshsys.FileSystem
+	uuidGenerator                  boshuuid.Generator
+	deploymentStateServiceProvider func(deploymentStatePath string) (biconfig.DeploymentStateService, error)
+	resourceVerifierProvider       func(deploymentManifestPath string, deploymentStateService biconfig.DeploymentStateService, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ResourceVerifier, error)
+	logger                         boshlog.Logger
+	logTag                         string
+}
+
+func NewStateCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	uuidGenerator boshuuid.Generator,
+	logger boshlog.Logger,
+	deploymentStateServiceProvider func(deploymentStatePath string) (biconfig.DeploymentStateService, error),
+	resourceVerifierProvider func(deploymentManifestPath string, deploymentStateService biconfig.DeploymentStateService, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ResourceVerifier, error),
+) Cmd {
+	return &stateCmd{
+		ui:                             ui,
+		fs:                             fs,
+		uuidGenerator:                  uuidGenerator,
+		deploymentStateServiceProvider: deploymentStateServiceProvider,
+		resourceVerifierProvider:       resourceVerifierProvider,
+		logger:                         logger,
+		logTag:                         "stateCmd",
+	}
+}
+
+func (c *stateCmd) Name() string {
+	return "state"
+}
+
+func (c *stateCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Inspect and recover a deployment state file",
+		Usage:    "<subcommand> <deployment_state_path> [arguments...]",
+		Subcommands: map[string]string{
+			"history <deployment_state_path>":                             "List the recorded versions of the deployment state, the most recent first",
+			"restore <deployment_state_path> <n> [--force-unlock]":        "Restore the deployment state to the version <n> of its history",
+			"migrate <deployment_state_path> --to <url> [--force-unlock]": "Move a local deployment state to a WebDAV server (http(s)://...) or an S3 bucket (s3://<bucket>/<key>)",
+			"encrypt <deployment_state_path> [--force-unlock]":            "Encrypt the deployment state & its history with the key of BOSH_INIT_STATE_KEY or BOSH_INIT_STATE_KEY_FILE",
+			"decrypt <deployment_state_path> [--force-unlock]":            "Decrypt the deployment state & its history with the key of BOSH_INIT_STATE_KEY or BOSH_INIT_STATE_KEY_FILE",
+			"show <deployment_state_path> [--json]":                       "Show the VM, disks, stemcells & releases recorded in the deployment state",
+			"import vm <deployment_state_path> <cid> [--manifest <deployment_manifest_path>] [--force-unlock]": "Record a VM created outside of bosh-init as the VM of the deployment. " +
+				"With the deployment manifest (& the variables & ops options of deploy), the CPI & the agent check that the VM exists",
+			"import disk <deployment_state_path> <cid> --size <mb> [--cloud-properties <json>] [--manifest <deployment_manifest_path>] [--force-unlock]": "Record a disk created outside of bosh-init as the disk of the deployment. " +
+				"With the deployment manifest (& the variables & ops options of deploy), the agent checks that the disk is attached to the VM",
+			"import stemcell <deployment_state_path> <cid> --name <name> --version <version> [--force-unlock]": "Record a stemcell uploaded outside of bosh-init",
+			"forget vm|disk|stemcell <deployment_state_path> <cid> [--force-unlock]":                           "Remove the record of a VM, disk or stemcell from the deployment state, without deleting it from the cloud",
+		},
+		Env: mergeEnv(genericEnv, remoteStateEnv, stateKeyEnv),
+	}
+}
+
+func (c *stateCmd) Run(stage biui.Stage, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Invalid usage - state command requires a subcommand")
+	}
+
+	switch args[0] {
+	case "history":
+		return c.history(args[1:])
+	case "restore":
+		return c.restore(args[1:])
+	case "migrate":
+		return c.migrate(args[1:])
+	case "encrypt":
+		return c.reencrypt(args[1:], true)
+	case "decrypt":
+		return c.reencrypt(args[1:], false)
+	case "show":
+		return c.show(args[1:])
+	case "import":
+		return c.importResource(stage, args[1:])
+	case "forget":
+		return c.forget(args[1:])
+	default:
+		return bosherr.Errorf("Invalid usage - unknown state subcommand '%s'", args[0])
+	}
+}
+
+func (c *stateCmd) history(args []string) error {
+	if len(args) != 1 {
+		return errors.New("Invalid usage - state history subcommand requires exactly 1 argument")
+	}
+
+	deploymentStateService, err := c.loadDeploymentStateService(args[0])
+	if err != nil {
+		return err
+	}
+
+	versions, err := deploymentStateService.History()
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading the history of deployment state '%s'", deploymentStateService.Path())
+		return bosherr.WrapError(err, "Loading deployment state history")
+	}
+
+	if len(versions) == 0 {
+		c.ui.PrintLinef("No recorded versions of deployment state '%s'", deploymentStateService.Path())
+		return nil
+	}
+
+	c.ui.PrintLinef("Versions of deployment state '%s':", deploymentStateService.Path())
+	for i, version := range versions {
+		c.ui.PrintLinef("%d  %s  %s", i, version.CreatedAt.Format(time.RFC3339), version.Command)
+	}
+
+	return nil
+}
+
+func (c *stateCmd) restore(args []string) error {
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args)
+	if err != nil {
+		return err
+	}
+
+	if deploymentStateOptions.path != "" || len(remainingArgs) != 2 {
+		return errors.New("Invalid usage - state restore subcommand requires exactly 2 arguments")
+	}
+
+	index, err := strconv.Atoi(remainingArgs[1])
+	if err != nil {
+		return bosherr.Errorf("Invalid usage - version '%s' is not a number", remainingArgs[1])
+	}
+
+	deploymentStateService, err := c.loadDeploymentStateService(remainingArgs[0])
+	if err != nil {
+		return err
+	}
+
+	err = deploymentStateService.Lock(deploymentStateOptions.forceUnlock)
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+	defer func() {
+		err := deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
+	versions, err := deploymentStateService.History()
+	if err != nil {
+		c.ui.ErrorLinef("Failed restoring deployment state '%s'", deploymentStateService.Path())
+		return bosherr.WrapError(err, "Loading deployment state history")
+	}
+
+	if index < 0 || index >= len(versions) {
+		return bosherr.Errorf("Deployment state version %d does not exist, the history of '%s' has %d version(s)", index, deploymentStateService.Path(), len(versions))
+	}
+
+	// the version is resolved before recording the state being replaced, which shifts the indexes of the history
+	// and may prune its oldest version
+	version := versions[index]
+	err = deploymentStateService.RecordVersion("before state restore")
+	if err != nil {
+		c.ui.ErrorLinef("Failed restoring deployment state '%s'", deploymentStateService.Path())
+		return bosherr.WrapError(err, "Recording deployment state version before restoring it")
+	}
+
+	err = deploymentStateService.Restore(version)
+	if err != nil {
+		c.ui.ErrorLinef("Failed restoring deployment state '%s'", deploymentStateService.Path())
+		return bosherr.WrapError(err, "Restoring deployment state")
+	}
+
+	// the restored state becomes the most recent version, so that the restore itself can be undone
+	err = deploymentStateService.RecordVersion("state restore")
+	if err != nil {
+		c.logger.Warn(c.logTag, "Recording deployment state version: %s", err.Error())
+	}
+
+	c.ui.PrintLinef("Restored deployment state '%s' to version %d", deploymentStateService.Path(), index)
+	return nil
+}
+
+func (c *stateCmd) migrate(args []string) error {
+	var targetURL string
+	remainingArgs := []string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--to" {
+			remainingArgs = append(remainingArgs, args[i])
+			continue
+		}
+		if i+1 == len(args) {
+			return bosherr.Errorf("Invalid usage - option '%s' requires a value", args[i])
+		}
+		targetURL = args[i+1]
+		i++
+	}
+
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(remainingArgs)
+	if err != nil {
+		return err
+	}
+
+	if deploymentStateOptions.path != "" || len(remainingArgs) != 1 || targetURL == "" {
+		return errors.New("Invalid usage - state migrate subcommand requires exactly 1 argument & the --to option")
+	}
+
+	if biconfig.IsDeploymentStateURL(remainingArgs[0]) {
+		return bosherr.Errorf("Invalid usage - '%s' is not a local deployment state file", remainingArgs[0])
+	}
+	if !biconfig.IsDeploymentStateURL(targetURL) {
+		return bosherr.Errorf("Invalid usage - '%s' is not the URL of a WebDAV server or an S3 bucket", targetURL)
+	}
+
+	sourceService, err := c.loadExistingDeploymentStateService(remainingArgs[0])
+	if err != nil {
+		return err
+	}
+
+	targetService, err := c.loadDeploymentStateService(targetURL)
+	if err != nil {
+		return err
+	}
+
+	err = sourceService.Lock(deploymentStateOptions.forceUnlock)
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+	defer func() {
+		err := sourceService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
+	deploymentState, err := sourceService.Load()
+	if err != nil {
+		return bosherr.WrapError(err, "Loading deployment state")
+	}
+
+	// the target has no version yet, so the write fails if a deployment state is already stored there
+	err = targetService.Save(deploymentState)
+	if err != nil {
+		c.ui.ErrorLinef("Failed migrating deployment state to '%s'", targetService.Path())
+		return bosherr.WrapError(err, "Migrating deployment state")
+	}
+
+	// keep a local copy in the history, in case the remote state gets lost
+	err = sourceService.RecordVersion("state migrate")
+	if err != nil {
+		c.logger.Warn(c.logTag, "Recording deployment state version: %s", err.Error())
+	}
+
+	err = sourceService.Cleanup()
+	if err != nil {
+		return bosherr.WrapError(err, "Deleting migrated deployment state")
+	}
+
+	c.ui.PrintLinef("Migrated deployment state '%s' to '%s'", sourceService.Path(), targetService.Path())
+	c.ui.PrintLinef("Run the next deploy & delete commands with '--state %s'", targetService.Path())
+	return nil
+}
+
+func (c *stateCmd) reencrypt(args []string, encrypted bool) error {
+	subcommand := "decrypt"
+	if encrypted {
+		subcommand = "encrypt"
+	}
+
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args)
+	if err != nil {
+		return err
+	}
+
+	if deploymentStateOptions.path != "" || len(remainingArgs) != 1 {
+		return bosherr.Errorf("Invalid usage - state %s subcommand requires exactly 1 argument", subcommand)
+	}
+
+	deploymentStateService, err := c.loadExistingDeploymentStateService(remainingArgs[0])
+	if err != nil {
+		return err
+	}
+
+	err = deploymentStateService.Lock(deploymentStateOptions.forceUnlock)
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+	defer func() {
+		err := deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
+	err = deploymentStateService.Reencrypt(encrypted)
+	if err != nil {
+		c.ui.ErrorLinef("Failed to %s deployment state '%s'", subcommand, deploymentStateService.Path())
+		return bosherr.WrapErrorf(err, "Rewriting deployment state")
+	}
+
+	c.ui.PrintLinef("%sed deployment state '%s'", strings.Title(subcommand), deploymentStateService.Path())
+	return nil
+}
+
+func (c *stateCmd) loadDeploymentStateService(deploymentStatePath string) (biconfig.DeploymentStateService, error) {
+	deploymentStateAbsPath, err := deploymentStateAbsPath(deploymentStatePath, "")
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment state file '%s'", deploymentStatePath)
+		return nil, bosherr.WrapErrorf(err, "Getting absolute path to deployment state file '%s'", deploymentStatePath)
+	}
+
+	deploymentStateService, err := c.deploymentStateServiceProvider(deploymentStateAbsPath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment state '%s'", deploymentStatePath)
+		return nil, bosherr.WrapError(err, "Loading deployment state service")
+	}
+
+	return deploymentStateService, nil
+}
+
+// deploymentStateResources is the JSON output of the state show subcommand
+type deploymentStateResources struct {
+	VMCID     string                    `json:"vm_cid"`
+	Disks     []deploymentStateDisk     `json:"disks"`
+	Stemcells []deploymentStateStemcell `json:"stemcells"`
+	Releases  []deploymentStateRelease  `json:"releases"`
+}
+
+type deploymentStateDisk struct {
+	CID     string `json:"cid"`
+	Size    int    `json:"size"`
+	Current bool   `json:"current"`
+}
+
+type deploymentStateStemcell struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	CID     string `json:"cid"`
+	Current bool   `json:"current"`
+}
+
+type deploymentStateRelease struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (c *stateCmd) show(args []string) error {
+	jsonOutput := false
+	remainingArgs := []string{}
+	for _, arg := range args {
+		if arg == "--json" {
+			jsonOutput = true
+			continue
+		}
+		remainingArgs = append(remainingArgs, arg)
+	}
+
+	if len(remainingArgs) != 1 {
+		return errors.New("Invalid usage - state show subcommand requires exactly 1 argument")
+	}
+
+	deploymentStateService, err := c.loadExistingDeploymentStateService(remainingArgs[0])
+	if err != nil {
+		return err
+	}
+
+	resources, err := c.resources(deploymentStateService)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment state '%s'", deploymentStateService.Path())
+		return err
+	}
+
+	if jsonOutput {
+		jsonContent, err := json.MarshalIndent(resources, "", "  ")
+		if err != nil {
+			return bosherr.WrapError(err, "Marshalling deployment state resources into JSON")
+		}
+		c.ui.PrintLinef("%s", jsonContent)
+		return nil
+	}
+
+	c.ui.PrintLinef("Deployment state '%s'", deploymentStateService.Path())
+
+	if resources.VMCID == "" {
+		c.ui.PrintLinef("VM: none")
+	} else {
+		c.ui.PrintLinef("VM: %s", resources.VMCID)
+	}
+
+	c.ui.PrintLinef("Disks:")
+	for _, disk := range resources.Disks {
+		c.ui.PrintLinef("  %s  %d MB%s", disk.CID, disk.Size, currentSuffix(disk.Current))
+	}
+
+	c.ui.PrintLinef("Stemcells:")
+	for _, stemcell := range resources.Stemcells {
+		c.ui.PrintLinef("  %s/%s  %s%s", stemcell.Name, stemcell.Version, stemcell.CID, currentSuffix(stemcell.Current))
+	}
+
+	c.ui.PrintLinef("Releases:")
+	for _, release := range resources.Releases {
+		c.ui.PrintLinef("  %s/%s", release.Name, release.Version)
+	}
+
+	return nil
+}
+
+func currentSuffix(current bool) string {
+	if current {
+		return "  (current)"
+	}
+	return ""
+}
+
+func (c *stateCmd) resources(deploymentStateService biconfig.DeploymentStateService) (deploymentStateResources, error) {
+	resources := deploymentStateResources{
+		Disks:     []deploymentStateDisk{},
+		Stemcells: []deploymentStateStemcell{},
+		Releases:  []deploymentStateRelease{},
+	}
+
+	vmCID, _, err := biconfig.NewVMRepo(deploymentStateService).FindCurrent()
+	if err != nil {
+		return resources, bosherr.WrapError(err, "Finding current VM")
+	}
+	resources.VMCID = vmCID
+
+	diskRepo := biconfig.NewDiskRepo(deploymentStateService, c.uuidGenerator)
+	currentDisk, _, err := diskRepo.FindCurrent()
+	if err != nil {
+		return resources, bosherr.WrapError(err, "Finding current disk")
+	}
+	diskRecords, err := diskRepo.All()
+	if err != nil {
+		return resources, bosherr.WrapError(err, "Finding disks")
+	}
+	for _, diskRecord := range diskRecords {
+		resources.Disks = append(resources.Disks, deploymentStateDisk{
+			CID:     diskRecord.CID,
+			Size:    diskRecord.Size,
+			Current: diskRecord.ID == currentDisk.ID,
+		})
+	}
+
+	stemcellRepo := biconfig.NewStemcellRepo(deploymentStateService, c.uuidGenerator)
+	currentStemcell, _, err := stemcellRepo.FindCurrent()
+	if err != nil {
+		return resources, bosherr.WrapError(err, "Finding current stemcell")
+	}
+	stemcellRecords, err := stemcellRepo.All()
+	if err != nil {
+		return resources, bosherr.WrapError(err, "Finding stemcells")
+	}
+	for _, stemcellRecord := range stemcellRecords {
+		resources.Stemcells = append(resources.Stemcells, deploymentStateStemcell{
+			Name:    stemcellRecord.Name,
+			Version: stemcellRecord.Version,
+			CID:     stemcellRecord.CID,
+			Current: stemcellRecord.ID == currentStemcell.ID,
+		})
+	}
+
+	releaseRecords, err := biconfig.NewReleaseRepo(deploymentStateService, c.uuidGenerator).List()
+	if err != nil {
+		return resources, bosherr.WrapError(err, "Finding releases")
+	}
+	for _, releaseRecord := range releaseRecords {
+		resources.Releases = append(resources.Releases, deploymentStateRelease{
+			Name:    releaseRecord.Name,
+			Version: releaseRecord.Version,
+		})
+	}
+
+	return resources, nil
+}
+
+func (c *stateCmd) importResource(stage biui.Stage, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Invalid usage - state import subcommand requires a kind of resource: vm, disk or stemcell")
+	}
+	kind := args[0]
+
+	options, remainingArgs, err := extractValueOptions(args[1:], "--manifest", "--size", "--cloud-properties", "--name", "--version")
+	if err != nil {
+		return err
+	}
+
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(remainingArgs)
+	if err != nil {
+		return err
+	}
+
+	deploymentManifestOptions, remainingArgs, err := extractManifestOptions(remainingArgs)
+	if err != nil {
+		return err
+	}
+
+	if deploymentStateOptions.path != "" || len(remainingArgs) != 2 {
+		return errors.New("Invalid usage - state import subcommand requires exactly 2 arguments")
+	}
+	cid := remainingArgs[1]
+
+	var importFunc func(biconfig.DeploymentStateService) error
+	switch kind {
+	case "vm":
+		importFunc = func(deploymentStateService biconfig.DeploymentStateService) error {
+			return c.importVM(stage, deploymentStateService, cid, options["--manifest"], deploymentManifestOptions)
+		}
+	case "disk":
+		size, err := strconv.Atoi(options["--size"])
+		if err != nil || size <= 0 {
+			return errors.New("Invalid usage - state import disk subcommand requires the --size option in MB")
+		}
+
+		cloudProperties := biproperty.Map{}
+		if options["--cloud-properties"] != "" {
+			err = json.Unmarshal([]byte(options["--cloud-properties"]), &cloudProperties)
+			if err != nil {
+				return bosherr.WrapError(err, "Invalid usage - option '--cloud-properties' must be a JSON object")
+			}
+		}
+
+		importFunc = func(deploymentStateService biconfig.DeploymentStateService) error {
+			return c.importDisk(stage, deploymentStateService, cid, size, cloudProperties, options["--manifest"], deploymentManifestOptions)
+		}
+	case "stemcell":
+		if options["--name"] == "" || options["--version"] == "" {
+			return errors.New("Invalid usage - state import stemcell subcommand requires the --name & --version options")
+		}
+
+		importFunc = func(deploymentStateService biconfig.DeploymentStateService) error {
+			return c.importStemcell(deploymentStateService, cid, options["--name"], options["--version"])
+		}
+	default:
+		return bosherr.Errorf("Invalid usage - unknown kind of resource '%s', expected vm, disk or stemcell", kind)
+	}
+
+	return c.withLockedDeploymentState(remainingArgs[0], deploymentStateOptions.forceUnlock, "state import", importFunc)
+}
+
+func (c *stateCmd) importVM(stage biui.Stage, deploymentStateService biconfig.DeploymentStateService, cid string, deploymentManifestPath string, deploymentManifestOptions manifestOptions) error {
+	vmRepo := biconfig.NewVMRepo(deploymentStateService)
+	currentVMCID, found, err := vmRepo.FindCurrent()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding current VM")
+	}
+	if found {
+		c.ui.ErrorLinef("Deployment state '%s' already records VM '%s'", deploymentStateService.Path(), currentVMCID)
+		return bosherr.Errorf("Deployment state already records VM '%s': forget it first", currentVMCID)
+	}
+
+	if deploymentManifestPath == "" {
+		c.ui.PrintLinef("Not verifying VM '%s' with the CPI & the agent: no deployment manifest given", cid)
+	} else {
+		resourceVerifier, err := c.loadResourceVerifier(deploymentManifestPath, deploymentManifestOptions, deploymentStateService)
+		if err != nil {
+			return err
+		}
+
+		err = resourceVerifier.VerifyVM(cid, stage)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Verifying VM '%s'", cid)
+		}
+	}
+
+	err = vmRepo.UpdateCurrent(cid)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording VM")
+	}
+
+	c.ui.PrintLinef("Imported VM '%s' into deployment state '%s'", cid, deploymentStateService.Path())
+	return nil
+}
+
+func (c *stateCmd) importDisk(stage biui.Stage, deploymentStateService biconfig.DeploymentStateService, cid string, size int, cloudProperties biproperty.Map, deploymentManifestPath string, deploymentManifestOptions manifestOptions) error {
+	diskRepo := biconfig.NewDiskRepo(deploymentStateService, c.uuidGenerator)
+	_, found, err := diskRepo.Find(cid)
+	if err != nil {
+		return bosherr.WrapError(err, "Finding disk")
+	}
+	if found {
+		c.ui.ErrorLinef("Deployment state '%s' already records disk '%s'", deploymentStateService.Path(), cid)
+		return bosherr.Errorf("Deployment state already records disk '%s'", cid)
+	}
+
+	// unused disks are deleted by the next deploy, so the imported disk must become the current one
+	currentDisk, found, err := diskRepo.FindCurrent()
+	if err != nil {
+		return bosherr.WrapError(err, "Finding current disk")
+	}
+	if found {
+		c.ui.ErrorLinef("Deployment state '%s' already records disk '%s' as the disk of the deployment", deploymentStateService.Path(), currentDisk.CID)
+		return bosherr.Errorf("Deployment state already records disk '%s' as the disk of the deployment: forget it first", currentDisk.CID)
+	}
+
+	if deploymentManifestPath == "" {
+		c.ui.PrintLinef("Not verifying disk '%s' with the agent: no deployment manifest given", cid)
+	} else {
+		resourceVerifier, err := c.loadResourceVerifier(deploymentManifestPath, deploymentManifestOptions, deploymentStateService)
+		if err != nil {
+			return err
+		}
+
+		err = resourceVerifier.VerifyDisk(cid, stage)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Verifying disk '%s'", cid)
+		}
+	}
+
+	diskRecord, err := diskRepo.Save(cid, size, cloudProperties)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording disk")
+	}
+
+	err = diskRepo.UpdateCurrent(diskRecord.ID)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording disk as the disk of the deployment")
+	}
+
+	c.ui.PrintLinef("Imported disk '%s' into deployment state '%s'", cid, deploymentStateService.Path())
+	return nil
+}
+
+func (c *stateCmd) importStemcell(deploymentStateService biconfig.DeploymentStateService, cid, name, version string) error {
+	stemcellRepo := biconfig.NewStemcellRepo(deploymentStateService, c.uuidGenerator)
+	stemcellRecord, found, err := stemcellRepo.Find(name, version)
+	if err != nil {
+		return bosherr.WrapError(err, "Finding stemcell")
+	}
+	if found {
+		c.ui.ErrorLinef("Deployment state '%s' already records stemcell '%s/%s' as '%s'", deploymentStateService.Path(), name, version, stemcellRecord.CID)
+		return bosherr.Errorf("Deployment state already records stemcell '%s/%s'", name, version)
+	}
+
+	// the CPI cannot tell whether a stemcell exists
+	c.ui.PrintLinef("Not verifying stemcell '%s': the CPI cannot check stemcells", cid)
+
+	_, err = stemcellRepo.Save(name, version, cid)
+	if err != nil {
+		return bosherr.WrapError(err, "Recording stemcell")
+	}
+
+	c.ui.PrintLinef("Imported stemcell '%s/%s' ('%s') into deployment state '%s'", name, version, cid, deploymentStateService.Path())
+	return nil
+}
+
+func (c *stateCmd) forget(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Invalid usage - state forget subcommand requires a kind of resource: vm, disk or stemcell")
+	}
+	kind := args[0]
+
+	deploymentStateOptions, remainingArgs, err := extractStateOptions(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if deploymentStateOptions.path != "" || len(remainingArgs) != 2 {
+		return errors.New("Invalid usage - state forget subcommand requires exactly 2 arguments")
+	}
+	cid := remainingArgs[1]
+
+	var forgetFunc func(biconfig.DeploymentStateService) (bool, error)
+	switch kind {
+	case "vm":
+		forgetFunc = func(deploymentStateService biconfig.DeploymentStateService) (bool, error) {
+			vmRepo := biconfig.NewVMRepo(deploymentStateService)
+			currentVMCID, found, err := vmRepo.FindCurrent()
+			if err != nil || !found || currentVMCID != cid {
+				return false, err
+			}
+			return true, vmRepo.ClearCurrent()
+		}
+	case "disk":
+		forgetFunc = func(deploymentStateService biconfig.DeploymentStateService) (bool, error) {
+			diskRepo := biconfig.NewDiskRepo(deploymentStateService, c.uuidGenerator)
+			diskRecord, found, err := diskRepo.Find(cid)
+			if err != nil || !found {
+				return false, err
+			}
+			return true, diskRepo.Delete(diskRecord)
+		}
+	case "stemcell":
+		forgetFunc = func(deploymentStateService biconfig.DeploymentStateService) (bool, error) {
+			stemcellRepo := biconfig.NewStemcellRepo(deploymentStateService, c.uuidGenerator)
+			stemcellRecords, err := stemcellRepo.All()
+			if err != nil {
+				return false, err
+			}
+			for _, stemcellRecord := range stemcellRecords {
+				if stemcellRecord.CID == cid {
+					return true, stemcellRepo.Delete(stemcellRecord)
+				}
+			}
+			return false, nil
+		}
+	default:
+		return bosherr.Errorf("Invalid usage - unknown kind of resource '%s', expected vm, disk or stemcell", kind)
+	}
+
+	return c.withLockedDeploymentState(remainingArgs[0], deploymentStateOptions.forceUnlock, "state forget", func(deploymentStateService biconfig.DeploymentStateService) error {
+		found, err := forgetFunc(deploymentStateService)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Forgetting %s '%s'", kind, cid)
+		}
+		if !found {
+			c.ui.ErrorLinef("Deployment state '%s' does not record %s '%s'", deploymentStateService.Path(), kind, cid)
+			return bosherr.Errorf("Deployment state does not record %s '%s'", kind, cid)
+		}
+
+		c.ui.PrintLinef("Forgot %s '%s' in deployment state '%s'", kind, cid, deploymentStateService.Path())
+		return nil
+	})
+}
+
+// withLockedDeploymentState runs the function with the existing deployment state locked,
+// recording the resulting deployment state in its history when the function succeeds
+func (c *stateCmd) withLockedDeploymentState(deploymentStatePath string, forceUnlock bool, command string, fn func(biconfig.DeploymentStateService) error) error {
+	deploymentStateService, err := c.loadExistingDeploymentStateService(deploymentStatePath)
+	if err != nil {
+		return err
+	}
+
+	err = deploymentStateService.Lock(forceUnlock)
+	if err != nil {
+		return bosherr.WrapError(err, "Locking deployment state")
+	}
+	defer func() {
+		err := deploymentStateService.Unlock()
+		if err != nil {
+			c.logger.Warn(c.logTag, "Unlocking deployment state: %s", err.Error())
+		}
+	}()
+
+	err = fn(deploymentStateService)
+	if err != nil {
+		return err
+	}
+
+	err = deploymentStateService.RecordVersion(command)
+	if err != nil {
+		c.logger.Warn(c.logTag, "Recording deployment state version: %s", err.Error())
+	}
+
+	return nil
+}
+
+func (c *stateCmd) loadResourceVerifier(deploymentManifestPath string, deploymentManifestOptions manifestOptions, deploymentStateService biconfig.DeploymentStateService) (ResourceVerifier, error) {
+	manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+	if err != nil {
+		c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
+		return nil, bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+	}
+
+	if !c.fs.FileExists(manifestAbsFilePath) {
+		c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+		return nil, bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+	}
+
+	deploymentOps, err := deploymentManifestOptions.Ops(c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest ops files")
+		return nil, bosherr.WrapError(err, "Loading deployment manifest ops files")
+	}
+
+	deploymentVars, err := deploymentManifestOptions.Variables(manifestAbsFilePath, deploymentOps, c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest variables")
+		return nil, bosherr.WrapError(err, "Loading deployment manifest variables")
+	}
+
+	return c.resourceVerifierProvider(manifestAbsFilePath, deploymentStateService, deploymentVars, deploymentOps)
+}
+
+func (c *stateCmd) loadExistingDeploymentStateService(deploymentStatePath string) (biconfig.DeploymentStateService, error) {
+	deploymentStateService, err := c.loadDeploymentStateService(deploymentStatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deploymentStateService.Exists() {
+		c.ui.ErrorLinef("Deployment state '%s' does not exist", deploymentStateService.Path())
+		return nil, bosherr.Errorf("Deployment state does not exist at '%s'", deploymentStateService.Path())
+	}
+
+	return deploymentStateService, nil
+}
+
+// extractValueOptions removes the given options & their values from the args, returning their values & the remaining args
+func extractValueOptions(args []string, names ...string) (map[string]string, []string, error) {
+	values := map[string]string{}
+	remainingArgs := []string{}
+
+	for i := 0; i < len(args); i++ {
+		isOption := false
+		for _, name := range names {
+			if args[i] == name {
+				isOption = true
+			}
+		}
+
+		if !isOption {
+			remainingArgs = append(remainingArgs, args[i])
+			continue
+		}
+
+		if i+1 == len(args) {
+			return nil, nil, bosherr.Errorf("Invalid usage - option '%s' requires a value", args[i])
+		}
+		if _, given := values[args[i]]; given {
+			return nil, nil, bosherr.Errorf("Invalid usage - option '%s' can only be given once", args[i])
+		}
+
+		values[args[i]] = args[i+1]
+		i++
+	}
+
+	return values, remainingArgs, nil
+}
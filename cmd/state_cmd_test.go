@@ -0,0 +1,465 @@
+package cmd_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	bicmd "github.com/cloudfoundry/bosh-init/cmd"
+	mock_cmd "github.com/cloudfoundry/bosh-init/cmd/mocks"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	fakebiconfig "github.com/cloudfoundry/bosh-init/config/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
+
+	fakeui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("StateCmd", func() {
+	var (
+		command                bicmd.Cmd
+		fs                     *fakesys.FakeFileSystem
+		fakeUI                 *fakeui.FakeUI
+		fakeStage              *fakeui.FakeStage
+		deploymentStateService biconfig.DeploymentStateService
+		deploymentStatePath    = "/deployment-dir/fake-deployment-manifest-state.json"
+		receivedStatePath      string
+		mockCtrl               *gomock.Controller
+		mockResourceVerifier   *mock_cmd.MockResourceVerifier
+		receivedManifestPath   string
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockResourceVerifier = mock_cmd.NewMockResourceVerifier(mockCtrl)
+		fs = fakesys.NewFakeFileSystem()
+		fakeUI = &fakeui.FakeUI{}
+		fakeStage = fakeui.NewFakeStage()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		deploymentStateService = biconfig.NewFileSystemDeploymentStateService(fs, fakeuuid.NewFakeGenerator(), logger, deploymentStatePath)
+
+		getter := func(statePath string) (biconfig.DeploymentStateService, error) {
+			receivedStatePath = statePath
+			if statePath == deploymentStatePath {
+				return deploymentStateService, nil
+			}
+			return biconfig.NewDeploymentStateService(statePath, fs, fakeuuid.NewFakeGenerator(), logger)
+		}
+		verifierGetter := func(manifestPath string, _ biconfig.DeploymentStateService, _ bivars.Variables, _ bipatch.Ops) (bicmd.ResourceVerifier, error) {
+			receivedManifestPath = manifestPath
+			return mockResourceVerifier, nil
+		}
+		command = bicmd.NewStateCmd(fakeUI, fs, fakeuuid.NewFakeGenerator(), logger, getter, verifierGetter)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	var recordVersion = func(directorID string, command string) {
+		Expect(deploymentStateService.Save(biconfig.DeploymentState{DirectorID: directorID})).To(Succeed())
+		Expect(deploymentStateService.RecordVersion(command)).To(Succeed())
+	}
+
+	It("returns an error without a known subcommand", func() {
+		err := command.Run(fakeStage, []string{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Invalid usage - state command requires a subcommand"))
+
+		err = command.Run(fakeStage, []string{"bogus", deploymentStatePath})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Invalid usage - unknown state subcommand 'bogus'"))
+	})
+
+	Describe("history", func() {
+		It("lists the recorded versions, the most recent first", func() {
+			recordVersion("first-director-id", "deploy")
+			recordVersion("second-director-id", "delete")
+
+			err := command.Run(fakeStage, []string{"history", deploymentStatePath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(receivedStatePath).To(Equal(deploymentStatePath))
+
+			Expect(fakeUI.Said).To(HaveLen(3))
+			Expect(fakeUI.Said[0]).To(Equal("Versions of deployment state '/deployment-dir/fake-deployment-manifest-state.json':"))
+			Expect(fakeUI.Said[1]).To(MatchRegexp(`^0  \d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ  delete$`))
+			Expect(fakeUI.Said[2]).To(MatchRegexp(`^1  \d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ  deploy$`))
+		})
+
+		It("says when no versions were recorded", func() {
+			err := command.Run(fakeStage, []string{"history", deploymentStatePath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(Equal([]string{"No recorded versions of deployment state '/deployment-dir/fake-deployment-manifest-state.json'"}))
+		})
+
+		It("returns an error unless exactly 1 argument is given", func() {
+			err := command.Run(fakeStage, []string{"history"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+		})
+	})
+
+	Describe("restore", func() {
+		BeforeEach(func() {
+			recordVersion("first-director-id", "deploy")
+			recordVersion("second-director-id", "deploy")
+		})
+
+		It("records the replaced deployment state, restores it to the given version & records it as the most recent version", func() {
+			err := command.Run(fakeStage, []string{"restore", deploymentStatePath, "1"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(ContainElement("Restored deployment state '/deployment-dir/fake-deployment-manifest-state.json' to version 1"))
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.DirectorID).To(Equal("first-director-id"))
+
+			versions, err := deploymentStateService.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(HaveLen(4))
+			Expect(versions[0].Command).To(Equal("state restore"))
+			Expect(versions[0].State.DirectorID).To(Equal("first-director-id"))
+			Expect(versions[1].Command).To(Equal("before state restore"))
+			Expect(versions[1].State.DirectorID).To(Equal("second-director-id"))
+
+			Expect(fs.FileExists(biconfig.DeploymentStateLockPath(deploymentStatePath))).To(BeFalse())
+		})
+
+		It("returns an error when the version does not exist", func() {
+			err := command.Run(fakeStage, []string{"restore", deploymentStatePath, "5"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state version 5 does not exist, the history of '/deployment-dir/fake-deployment-manifest-state.json' has 2 version(s)"))
+
+			versions, err := deploymentStateService.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(HaveLen(2))
+		})
+
+		It("returns an error when the version is not a number", func() {
+			err := command.Run(fakeStage, []string{"restore", deploymentStatePath, "latest"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - version 'latest' is not a number"))
+		})
+
+		Context("when another run holds the lock of the deployment state", func() {
+			BeforeEach(func() {
+				fs.WriteFileString(biconfig.DeploymentStateLockPath(deploymentStatePath), `{"pid": 1234, "host": "fake-other-host", "user": "fake-user"}`)
+			})
+
+			It("returns an error without restoring", func() {
+				err := command.Run(fakeStage, []string{"restore", deploymentStatePath, "1"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("is locked by pid 1234"))
+
+				deploymentState, err := deploymentStateService.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.DirectorID).To(Equal("second-director-id"))
+			})
+
+			It("restores with --force-unlock", func() {
+				err := command.Run(fakeStage, []string{"restore", deploymentStatePath, "1", "--force-unlock"})
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("migrate", func() {
+		var (
+			server  *httptest.Server
+			fakeDAV *fakebiconfig.FakeDeploymentStateServer
+		)
+
+		BeforeEach(func() {
+			fakeDAV = fakebiconfig.NewFakeDeploymentStateServer()
+			server = httptest.NewServer(fakeDAV)
+			recordVersion("fake-director-id", "deploy")
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("moves the local deployment state to the URL, keeping a copy in the local history", func() {
+			err := command.Run(fakeStage, []string{"migrate", deploymentStatePath, "--to", server.URL + "/state.json"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(ContainElement(fmt.Sprintf("Migrated deployment state '%s' to '%s/state.json'", deploymentStatePath, server.URL)))
+
+			deploymentState := biconfig.DeploymentState{}
+			Expect(json.Unmarshal(fakeDAV.Objects["/state.json"], &deploymentState)).To(Succeed())
+			Expect(deploymentState.DirectorID).To(Equal("fake-director-id"))
+
+			Expect(fs.FileExists(deploymentStatePath)).To(BeFalse())
+			versions, err := deploymentStateService.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions[0].Command).To(Equal("state migrate"))
+		})
+
+		It("returns an error without deleting the local deployment state when a state is already stored at the URL", func() {
+			fakeDAV.SetObject("/state.json", []byte(`{"director_id":"other-director-id"}`))
+
+			err := command.Run(fakeStage, []string{"migrate", deploymentStatePath, "--to", server.URL + "/state.json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("was changed by another run since it was read"))
+			Expect(fs.FileExists(deploymentStatePath)).To(BeTrue())
+		})
+
+		It("returns an error when the local deployment state does not exist", func() {
+			err := command.Run(fakeStage, []string{"migrate", "/deployment-dir/missing-state.json", "--to", server.URL + "/state.json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state does not exist at '/deployment-dir/missing-state.json'"))
+		})
+
+		It("returns an error unless --to is the URL of a remote deployment state", func() {
+			err := command.Run(fakeStage, []string{"migrate", deploymentStatePath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Invalid usage"))
+
+			err = command.Run(fakeStage, []string{"migrate", deploymentStatePath, "--to", "/other-dir/state.json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - '/other-dir/state.json' is not the URL of a WebDAV server or an S3 bucket"))
+		})
+	})
+
+	Describe("encrypt & decrypt", func() {
+		var otherStatePath = "/deployment-dir/other-state.json"
+
+		BeforeEach(func() {
+			os.Setenv("BOSH_INIT_STATE_KEY", base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+			fs.WriteFileString(otherStatePath, `{"director_id":"fake-director-id"}`)
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("BOSH_INIT_STATE_KEY")
+		})
+
+		It("encrypts & decrypts the deployment state with the key of the environment", func() {
+			err := command.Run(fakeStage, []string{"encrypt", otherStatePath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(ContainElement("Encrypted deployment state '/deployment-dir/other-state.json'"))
+
+			contents, err := fs.ReadFile(otherStatePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(biconfig.IsEncryptedDeploymentState(contents)).To(BeTrue())
+
+			err = command.Run(fakeStage, []string{"decrypt", otherStatePath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeUI.Said).To(ContainElement("Decrypted deployment state '/deployment-dir/other-state.json'"))
+			Expect(fs.ReadFileString(otherStatePath)).To(Equal(`{"director_id":"fake-director-id"}`))
+		})
+
+		It("returns an error when encrypting without a key", func() {
+			os.Unsetenv("BOSH_INIT_STATE_KEY")
+
+			err := command.Run(fakeStage, []string{"encrypt", otherStatePath})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("requires BOSH_INIT_STATE_KEY or BOSH_INIT_STATE_KEY_FILE to be set"))
+		})
+
+		It("returns an error when the deployment state does not exist", func() {
+			err := command.Run(fakeStage, []string{"encrypt", "/deployment-dir/missing-state.json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state does not exist at '/deployment-dir/missing-state.json'"))
+		})
+	})
+
+	Describe("show", func() {
+		BeforeEach(func() {
+			Expect(deploymentStateService.Save(biconfig.DeploymentState{
+				DirectorID:        "fake-director-id",
+				CurrentVMCID:      "fake-vm-cid",
+				CurrentDiskID:     "fake-disk-id",
+				CurrentStemcellID: "fake-stemcell-id",
+				Disks: []biconfig.DiskRecord{
+					{ID: "fake-disk-id", CID: "fake-disk-cid", Size: 1024, CloudProperties: biproperty.Map{"secret": "fake-secret"}},
+					{ID: "old-disk-id", CID: "old-disk-cid", Size: 512},
+				},
+				Stemcells: []biconfig.StemcellRecord{
+					{ID: "fake-stemcell-id", Name: "fake-stemcell", Version: "2", CID: "fake-stemcell-cid"},
+				},
+				Releases: []biconfig.ReleaseRecord{
+					{ID: "fake-release-id", Name: "fake-release", Version: "1"},
+				},
+			})).To(Succeed())
+		})
+
+		It("shows the VM, disks, stemcells & releases of the deployment state", func() {
+			err := command.Run(fakeStage, []string{"show", deploymentStatePath})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeUI.Said).To(Equal([]string{
+				"Deployment state '/deployment-dir/fake-deployment-manifest-state.json'",
+				"VM: fake-vm-cid",
+				"Disks:",
+				"  fake-disk-cid  1024 MB  (current)",
+				"  old-disk-cid  512 MB",
+				"Stemcells:",
+				"  fake-stemcell/2  fake-stemcell-cid  (current)",
+				"Releases:",
+				"  fake-release/1",
+			}))
+		})
+
+		It("shows them as JSON with --json, without the cloud properties of the disks", func() {
+			err := command.Run(fakeStage, []string{"show", deploymentStatePath, "--json"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeUI.Said).To(HaveLen(1))
+			Expect(fakeUI.Said[0]).To(MatchJSON(`{
+				"vm_cid": "fake-vm-cid",
+				"disks": [
+					{"cid": "fake-disk-cid", "size": 1024, "current": true},
+					{"cid": "old-disk-cid", "size": 512, "current": false}
+				],
+				"stemcells": [{"name": "fake-stemcell", "version": "2", "cid": "fake-stemcell-cid", "current": true}],
+				"releases": [{"name": "fake-release", "version": "1"}]
+			}`))
+		})
+
+		It("returns an error when the deployment state does not exist", func() {
+			err := command.Run(fakeStage, []string{"show", "/deployment-dir/missing-state.json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state does not exist at '/deployment-dir/missing-state.json'"))
+		})
+	})
+
+	Describe("import", func() {
+		var loadState = func() biconfig.DeploymentState {
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			return deploymentState
+		}
+
+		BeforeEach(func() {
+			Expect(deploymentStateService.Save(biconfig.DeploymentState{DirectorID: "fake-director-id"})).To(Succeed())
+		})
+
+		It("records the VM as the VM of the deployment without verifying it when no manifest is given", func() {
+			err := command.Run(fakeStage, []string{"import", "vm", deploymentStatePath, "fake-vm-cid"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(loadState().CurrentVMCID).To(Equal("fake-vm-cid"))
+			Expect(fakeUI.Said).To(ContainElement("Not verifying VM 'fake-vm-cid' with the CPI & the agent: no deployment manifest given"))
+			Expect(fakeUI.Said).To(ContainElement("Imported VM 'fake-vm-cid' into deployment state '/deployment-dir/fake-deployment-manifest-state.json'"))
+
+			versions, err := deploymentStateService.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions[0].Command).To(Equal("state import"))
+		})
+
+		It("verifies the VM with the deployment manifest", func() {
+			fs.WriteFileString("/deployment-dir/manifest.yml", "---")
+			mockResourceVerifier.EXPECT().VerifyVM("fake-vm-cid", fakeStage)
+
+			err := command.Run(fakeStage, []string{"import", "vm", deploymentStatePath, "fake-vm-cid", "--manifest", "/deployment-dir/manifest.yml"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(receivedManifestPath).To(Equal("/deployment-dir/manifest.yml"))
+			Expect(loadState().CurrentVMCID).To(Equal("fake-vm-cid"))
+		})
+
+		It("does not record the VM when it cannot be verified", func() {
+			fs.WriteFileString("/deployment-dir/manifest.yml", "---")
+			mockResourceVerifier.EXPECT().VerifyVM("fake-vm-cid", fakeStage).Return(errors.New("fake-verify-error"))
+
+			err := command.Run(fakeStage, []string{"import", "vm", deploymentStatePath, "fake-vm-cid", "--manifest", "/deployment-dir/manifest.yml"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-verify-error"))
+			Expect(loadState().CurrentVMCID).To(BeEmpty())
+		})
+
+		It("returns an error when the deployment state already records a VM", func() {
+			Expect(deploymentStateService.Save(biconfig.DeploymentState{DirectorID: "fake-director-id", CurrentVMCID: "other-vm-cid"})).To(Succeed())
+
+			err := command.Run(fakeStage, []string{"import", "vm", deploymentStatePath, "fake-vm-cid"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state already records VM 'other-vm-cid': forget it first"))
+		})
+
+		It("records the disk as the disk of the deployment", func() {
+			fs.WriteFileString("/deployment-dir/manifest.yml", "---")
+			mockResourceVerifier.EXPECT().VerifyDisk("fake-disk-cid", fakeStage)
+
+			err := command.Run(fakeStage, []string{"import", "disk", deploymentStatePath, "fake-disk-cid", "--size", "1024",
+				"--cloud-properties", `{"type":"ssd"}`, "--manifest", "/deployment-dir/manifest.yml"})
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState := loadState()
+			Expect(deploymentState.Disks).To(HaveLen(1))
+			Expect(deploymentState.Disks[0].CID).To(Equal("fake-disk-cid"))
+			Expect(deploymentState.Disks[0].Size).To(Equal(1024))
+			Expect(deploymentState.Disks[0].CloudProperties).To(Equal(biproperty.Map{"type": "ssd"}))
+			Expect(deploymentState.CurrentDiskID).To(Equal(deploymentState.Disks[0].ID))
+		})
+
+		It("returns an error when importing a disk without its size", func() {
+			err := command.Run(fakeStage, []string{"import", "disk", deploymentStatePath, "fake-disk-cid"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - state import disk subcommand requires the --size option in MB"))
+		})
+
+		It("records the stemcell", func() {
+			err := command.Run(fakeStage, []string{"import", "stemcell", deploymentStatePath, "fake-stemcell-cid", "--name", "fake-stemcell", "--version", "2"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(loadState().Stemcells).To(HaveLen(1))
+			Expect(loadState().Stemcells[0].CID).To(Equal("fake-stemcell-cid"))
+			Expect(loadState().Stemcells[0].Name).To(Equal("fake-stemcell"))
+			Expect(loadState().Stemcells[0].Version).To(Equal("2"))
+		})
+
+		It("returns an error for an unknown kind of resource", func() {
+			err := command.Run(fakeStage, []string{"import", "network", deploymentStatePath, "fake-cid"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - unknown kind of resource 'network', expected vm, disk or stemcell"))
+		})
+	})
+
+	Describe("forget", func() {
+		BeforeEach(func() {
+			Expect(deploymentStateService.Save(biconfig.DeploymentState{
+				DirectorID:        "fake-director-id",
+				CurrentVMCID:      "fake-vm-cid",
+				CurrentDiskID:     "fake-disk-id",
+				CurrentStemcellID: "fake-stemcell-id",
+				Disks:             []biconfig.DiskRecord{{ID: "fake-disk-id", CID: "fake-disk-cid", Size: 1024}},
+				Stemcells:         []biconfig.StemcellRecord{{ID: "fake-stemcell-id", Name: "fake-stemcell", Version: "2", CID: "fake-stemcell-cid"}},
+			})).To(Succeed())
+		})
+
+		It("removes the records of the VM, disk & stemcell", func() {
+			Expect(command.Run(fakeStage, []string{"forget", "vm", deploymentStatePath, "fake-vm-cid"})).To(Succeed())
+			Expect(command.Run(fakeStage, []string{"forget", "disk", deploymentStatePath, "fake-disk-cid"})).To(Succeed())
+			Expect(command.Run(fakeStage, []string{"forget", "stemcell", deploymentStatePath, "fake-stemcell-cid"})).To(Succeed())
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentVMCID).To(BeEmpty())
+			Expect(deploymentState.CurrentDiskID).To(BeEmpty())
+			Expect(deploymentState.Disks).To(BeEmpty())
+			Expect(deploymentState.CurrentStemcellID).To(BeEmpty())
+			Expect(deploymentState.Stemcells).To(BeEmpty())
+
+			Expect(fakeUI.Said).To(ContainElement("Forgot disk 'fake-disk-cid' in deployment state '/deployment-dir/fake-deployment-manifest-state.json'"))
+		})
+
+		It("returns an error when the deployment state does not record the resource", func() {
+			err := command.Run(fakeStage, []string{"forget", "vm", deploymentStatePath, "other-vm-cid"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state does not record vm 'other-vm-cid'"))
+
+			deploymentState, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentVMCID).To(Equal("fake-vm-cid"))
+		})
+	})
+})
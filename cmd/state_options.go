@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	biconfig "github.com/cloudfoundry/bosh-init/config"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const stateOptionsUsage = "[--state <path_or_url>] [--force-unlock]"
+
+type stateOptions struct {
+	path        string
+	forceUnlock bool
+}
+
+// extractStateOptions removes the --state & --force-unlock options from the args, returning their values & the remaining args
+func extractStateOptions(args []string) (stateOptions, []string, error) {
+	options := stateOptions{}
+	remainingArgs := []string{}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--force-unlock":
+			options.forceUnlock = true
+
+		case "--state":
+			if i+1 == len(args) {
+				return stateOptions{}, nil, bosherr.Errorf("Invalid usage - option '%s' requires a value", args[i])
+			}
+
+			if options.path != "" {
+				return stateOptions{}, nil, bosherr.Errorf("Invalid usage - option '%s' can only be given once", args[i])
+			}
+
+			options.path = args[i+1]
+			i++
+
+		default:
+			remainingArgs = append(remainingArgs, args[i])
+		}
+	}
+
+	return options, remainingArgs, nil
+}
+
+// deploymentStateAbsPath returns the absolute path of the --state option, or its URL when the state is remote,
+// defaulting to the state file next to the (first) deployment manifest
+func deploymentStateAbsPath(statePath string, deploymentManifestAbsPath string) (string, error) {
+	if statePath == "" {
+		return biconfig.DeploymentStatePath(deploymentManifestAbsPath), nil
+	}
+	if biconfig.IsDeploymentStateURL(statePath) {
+		return statePath, nil
+	}
+	return filepath.Abs(statePath)
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type TemplatesRenderer interface {
+	// Render renders the templates of the deployment job into the output dir (one sub-dir per release job).
+	// If releaseJobName is not empty, only the templates of that release job are written.
+	Render(releaseJobName string, outputPath string, stage biui.Stage) error
+}
+
+func NewTemplatesRenderer(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logTag string,
+	logger boshlog.Logger,
+	releaseManager birel.Manager,
+	releaseJobResolver bideplrel.JobResolver,
+	jobListRenderer bitemplate.JobListRenderer,
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo,
+	renderedJobListDiffer bitemplate.RenderedJobListDiffer,
+	deploymentManifestPath string,
+	deploymentVars bivars.Variables,
+	deploymentOps bipatch.Ops,
+	releaseFetcher birel.Fetcher,
+	releaseSetParser birelsetmanifest.Parser,
+	deploymentManifestParser DeploymentManifestParser,
+) TemplatesRenderer {
+	return &templatesRenderer{
+		ui:                         ui,
+		fs:                         fs,
+		logTag:                     logTag,
+		logger:                     logger,
+		releaseManager:             releaseManager,
+		releaseJobResolver:         releaseJobResolver,
+		jobListRenderer:            jobListRenderer,
+		renderedJobListArchiveRepo: renderedJobListArchiveRepo,
+		renderedJobListDiffer:      renderedJobListDiffer,
+		deploymentManifestPath:     deploymentManifestPath,
+		deploymentVars:             deploymentVars,
+		deploymentOps:              deploymentOps,
+		releaseFetcher:             releaseFetcher,
+		releaseSetParser:           releaseSetParser,
+		deploymentManifestParser:   deploymentManifestParser,
+	}
+}
+
+type templatesRenderer struct {
+	ui                         biui.UI
+	fs                         boshsys.FileSystem
+	logTag                     string
+	logger                     boshlog.Logger
+	releaseManager             birel.Manager
+	releaseJobResolver         bideplrel.JobResolver
+	jobListRenderer            bitemplate.JobListRenderer
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo
+	renderedJobListDiffer      bitemplate.RenderedJobListDiffer
+	deploymentManifestPath     string
+	deploymentVars             bivars.Variables
+	deploymentOps              bipatch.Ops
+	releaseFetcher             birel.Fetcher
+	releaseSetParser           birelsetmanifest.Parser
+	deploymentManifestParser   DeploymentManifestParser
+}
+
+// Render renders the job templates locally, without installing the CPI or creating any VMs,
+// and prints the differences with the last rendered templates of the deployment.
+func (r *templatesRenderer) Render(releaseJobName string, outputPath string, stage biui.Stage) error {
+	defer func() {
+		err := r.releaseManager.DeleteAll()
+		if err != nil {
+			r.logger.Warn(r.logTag, "Deleting all extracted releases: %s", err.Error())
+		}
+	}()
+
+	var deploymentManifest bideplmanifest.Manifest
+	err := stage.PerformComplex("validating", func(stage biui.Stage) error {
+		releaseSetManifest, err := r.releaseSetParser.Parse(r.deploymentManifestPath, r.deploymentVars, r.deploymentOps)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Parsing release set manifest '%s'", r.deploymentManifestPath)
+		}
+
+		for _, releaseRef := range releaseSetManifest.Releases {
+			err = r.releaseFetcher.DownloadAndExtract(releaseRef, stage)
+			if err != nil {
+				return err
+			}
+		}
+
+		deploymentManifest, err = r.deploymentManifestParser.GetDeploymentManifest(r.deploymentManifestPath, r.deploymentVars, r.deploymentOps, releaseSetManifest, stage)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(deploymentManifest.Jobs) != 1 {
+		return bosherr.Errorf("Deployment manifest must have exactly 1 job to render its templates, but it has %d", len(deploymentManifest.Jobs))
+	}
+	deploymentJob := deploymentManifest.Jobs[0]
+
+	if releaseJobName != "" {
+		if _, found := r.findJobRef(deploymentJob, releaseJobName); !found {
+			return bosherr.Errorf("Release job '%s' is not a template of deployment job '%s'", releaseJobName, deploymentJob.Name)
+		}
+	}
+
+	releaseJobs := make([]bireljob.Job, 0, len(deploymentJob.Templates))
+	linkOverrides := map[string]bitemplate.LinkOverrides{}
+	for _, jobRef := range deploymentJob.Templates {
+		releaseJob, err := r.releaseJobResolver.Resolve(jobRef.Name, jobRef.Release)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Resolving job '%s' of release '%s'", jobRef.Name, jobRef.Release)
+		}
+		releaseJobs = append(releaseJobs, releaseJob)
+		linkOverrides[jobRef.Name] = bitemplate.LinkOverrides{
+			Consumes: jobRef.Consumes,
+			Provides: jobRef.Provides,
+		}
+	}
+
+	address, err := deploymentManifest.DefaultIP(deploymentJob.Name)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding the default IP of job '%s'", deploymentJob.Name)
+	}
+
+	// all the jobs are rendered, because they may provide links to the selected job
+	var renderedJobList bitemplate.RenderedJobList
+	err = stage.Perform("Rendering job templates", func() error {
+		renderedJobList, err = r.jobListRenderer.Render(releaseJobs, linkOverrides, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, address)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer renderedJobList.DeleteSilently()
+
+	renderedJobs := []bitemplate.RenderedJob{}
+	for _, renderedJob := range renderedJobList.All() {
+		if releaseJobName == "" || renderedJob.Job().Name == releaseJobName {
+			renderedJobs = append(renderedJobs, renderedJob)
+		}
+	}
+
+	err = stage.Perform("Writing rendered job templates", func() error {
+		return r.writeRenderedJobs(renderedJobs, outputPath)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.ui.PrintLinef("Rendered job templates: '%s'", outputPath)
+
+	return r.printDiff(renderedJobs, deploymentJob.Properties, deploymentManifest.Properties)
+}
+
+func (r *templatesRenderer) writeRenderedJobs(renderedJobs []bitemplate.RenderedJob, outputPath string) error {
+	err := r.fs.MkdirAll(outputPath, os.ModePerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating output dir '%s'", outputPath)
+	}
+
+	for _, renderedJob := range renderedJobs {
+		jobOutputPath := filepath.Join(outputPath, renderedJob.Job().Name)
+
+		err = r.fs.RemoveAll(jobOutputPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing previously rendered templates '%s'", jobOutputPath)
+		}
+
+		err = r.fs.CopyDir(renderedJob.Path(), jobOutputPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Writing rendered templates of job '%s' to '%s'", renderedJob.Job().Name, jobOutputPath)
+		}
+	}
+
+	return nil
+}
+
+func (r *templatesRenderer) printDiff(renderedJobs []bitemplate.RenderedJob, jobProperties, globalProperties biproperty.Map) error {
+	lastArchive, found := r.renderedJobListArchiveRepo.FindLast()
+	if !found {
+		r.logger.Debug(r.logTag, "No previously applied job templates found")
+		return nil
+	}
+
+	secretValues := r.renderedJobListDiffer.SecretValues(renderedJobs, jobProperties, globalProperties)
+	diff, err := r.renderedJobListDiffer.Diff(lastArchive, renderedJobs, secretValues)
+	if err != nil {
+		return bosherr.WrapError(err, "Comparing with the last deployed job templates")
+	}
+
+	if diff == "" {
+		r.ui.PrintLinef("No changes from the last deployed job templates")
+		return nil
+	}
+
+	r.ui.PrintLinef("Changes from the last deployed job templates:")
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		r.ui.PrintLinef("%s", line)
+	}
+	return nil
+}
+
+func (r *templatesRenderer) findJobRef(deploymentJob bideplmanifest.Job, jobName string) (bideplmanifest.ReleaseJobRef, bool) {
+	for _, jobRef := range deploymentJob.Templates {
+		if jobRef.Name == jobName {
+			return jobRef, true
+		}
+	}
+	return bideplmanifest.ReleaseJobRef{}, false
+}
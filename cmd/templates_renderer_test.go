@@ -0,0 +1,277 @@
+package cmd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bicmd "github.com/cloudfoundry/bosh-init/cmd"
+
+	"code.google.com/p/gomock/gomock"
+	mock_release "github.com/cloudfoundry/bosh-init/release/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
+	bitarball "github.com/cloudfoundry/bosh-init/installation/tarball"
+	birel "github.com/cloudfoundry/bosh-init/release"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
+	fakebihttpclient "github.com/cloudfoundry/bosh-init/deployment/httpclient/fakes"
+	fakebideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest/fakes"
+	fakebirel "github.com/cloudfoundry/bosh-init/release/fakes"
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("TemplatesRenderer", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("Render", func() {
+		var (
+			fs                      *fakesys.FakeFileSystem
+			logger                  boshlog.Logger
+			fakeUI                  *fakebiui.FakeUI
+			fakeStage               *fakebiui.FakeStage
+			fakeDeploymentParser    *fakebideplmanifest.FakeParser
+			fakeDeploymentValidator *fakebideplmanifest.FakeValidator
+			mockReleaseExtractor    *mock_release.MockExtractor
+			mockJobListRenderer     *mock_template.MockJobListRenderer
+			mockRenderedJobList     *mock_template.MockRenderedJobList
+			mockArchiveRepo         *mock_template.MockRenderedJobListArchiveRepo
+			mockDiffer              *mock_template.MockRenderedJobListDiffer
+
+			releaseJobs     []bireljob.Job
+			renderedJobs    []bitemplate.RenderedJob
+			expectFindLast  *gomock.Call
+			expectDiff      *gomock.Call
+			deploymentProps biproperty.Map
+
+			deploymentManifestPath = "/deployment-dir/fake-deployment-manifest.yml"
+			outputPath             = "/fake-output-dir"
+		)
+
+		var newTemplatesRenderer = func() bicmd.TemplatesRenderer {
+			releaseManager := birel.NewManager(logger)
+			releaseSetParser := birelsetmanifest.NewParser(fs, logger, birelsetmanifest.NewValidator(logger))
+			tarballCache := bitarball.NewCache("fake-base-path", fs, logger)
+			tarballProvider := bitarball.NewProvider(tarballCache, fs, fakebihttpclient.NewFakeHTTPClient(), fakebicrypto.NewFakeSha1Calculator(), 1, 0, logger)
+
+			return bicmd.NewTemplatesRenderer(
+				fakeUI,
+				fs,
+				"TemplatesRenderer",
+				logger,
+				releaseManager,
+				bideplrel.NewJobResolver(releaseManager),
+				mockJobListRenderer,
+				mockArchiveRepo,
+				mockDiffer,
+				deploymentManifestPath,
+				bivars.Variables{},
+				bipatch.Ops{},
+				birel.NewFetcher(tarballProvider, mockReleaseExtractor, releaseManager),
+				releaseSetParser,
+				bicmd.DeploymentManifestParser{
+					DeploymentParser:    fakeDeploymentParser,
+					DeploymentValidator: fakeDeploymentValidator,
+					ReleaseManager:      releaseManager,
+					UI:                  fakeUI,
+				},
+			)
+		}
+
+		var newRenderedJob = func(releaseJob bireljob.Job) bitemplate.RenderedJob {
+			renderedJobPath := "/fake-rendered-jobs/" + releaseJob.Name
+			fs.WriteFileString(renderedJobPath+"/monit", "fake-"+releaseJob.Name+"-monit")
+			fs.WriteFileString(renderedJobPath+"/bin/ctl", "fake-"+releaseJob.Name+"-ctl")
+			return bitemplate.NewRenderedJob(releaseJob, renderedJobPath, fs, logger)
+		}
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fakeUI = &fakebiui.FakeUI{}
+			fakeStage = fakebiui.NewFakeStage()
+			fakeDeploymentParser = fakebideplmanifest.NewFakeParser()
+			fakeDeploymentValidator = fakebideplmanifest.NewFakeValidator()
+			mockReleaseExtractor = mock_release.NewMockExtractor(mockCtrl)
+			mockJobListRenderer = mock_template.NewMockJobListRenderer(mockCtrl)
+			mockRenderedJobList = mock_template.NewMockRenderedJobList(mockCtrl)
+			mockArchiveRepo = mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+			mockDiffer = mock_template.NewMockRenderedJobListDiffer(mockCtrl)
+
+			fs.WriteFileString(deploymentManifestPath, `---
+name: fake-deployment-name
+releases:
+- name: fake-release-name
+  url: file:///fake-release.tgz
+`)
+
+			releaseJobs = []bireljob.Job{
+				{Name: "fake-job-1"},
+				{Name: "fake-job-2"},
+			}
+			fakeRelease := fakebirel.New("fake-release-name", "1.0")
+			fakeRelease.ReleaseJobs = releaseJobs
+			mockReleaseExtractor.EXPECT().Extract("/fake-release.tgz").Return(fakeRelease, nil)
+
+			deploymentProps = biproperty.Map{"fake-job-prop": "fake-job-value"}
+			fakeDeploymentParser.ParseManifest = bideplmanifest.Manifest{
+				Name: "fake-deployment-name",
+				Jobs: []bideplmanifest.Job{
+					{
+						Name: "fake-deployment-job",
+						Templates: []bideplmanifest.ReleaseJobRef{
+							{Name: "fake-job-1", Release: "fake-release-name"},
+							{Name: "fake-job-2", Release: "fake-release-name", Consumes: map[string]string{"db": "fake-db"}},
+						},
+						Networks:   []bideplmanifest.JobNetwork{{Name: "fake-network-name", StaticIPs: []string{"1.2.3.4"}}},
+						Properties: deploymentProps,
+					},
+				},
+				Networks:   []bideplmanifest.Network{{Name: "fake-network-name", Type: "vip"}},
+				Properties: biproperty.Map{},
+			}
+			fakeDeploymentValidator.SetValidateBehavior([]fakebideplmanifest.ValidateOutput{{Err: nil}})
+			fakeDeploymentValidator.SetValidateReleaseJobsBehavior([]fakebideplmanifest.ValidateReleaseJobsOutput{{Err: nil}})
+
+			renderedJobs = []bitemplate.RenderedJob{
+				newRenderedJob(releaseJobs[0]),
+				newRenderedJob(releaseJobs[1]),
+			}
+
+			expectedLinkOverrides := map[string]bitemplate.LinkOverrides{
+				"fake-job-1": {},
+				"fake-job-2": {Consumes: map[string]string{"db": "fake-db"}},
+			}
+			mockJobListRenderer.EXPECT().Render(releaseJobs, expectedLinkOverrides, deploymentProps, biproperty.Map{}, "fake-deployment-name", "1.2.3.4").Return(mockRenderedJobList, nil).AnyTimes()
+			mockRenderedJobList.EXPECT().All().Return(renderedJobs).AnyTimes()
+			mockRenderedJobList.EXPECT().DeleteSilently().AnyTimes()
+
+			lastArchive := bitemplate.AppliedRenderedJobListArchive{Path: "/fake-last-archive.tgz", SecretValuesPath: "/fake-last-archive-secrets.json"}
+			expectFindLast = mockArchiveRepo.EXPECT().FindLast().Return(lastArchive, true).AnyTimes()
+			mockDiffer.EXPECT().SecretValues(gomock.Any(), deploymentProps, biproperty.Map{}).Return([]string{"fake-secret-value"}).AnyTimes()
+			expectDiff = mockDiffer.EXPECT().Diff(lastArchive, gomock.Any(), []string{"fake-secret-value"}).Return("--- a/fake-job-1/monit\n+++ b/fake-job-1/monit\n", nil).AnyTimes()
+		})
+
+		It("writes the rendered templates of every job to the output dir", func() {
+			err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.ReadFileString("/fake-output-dir/fake-job-1/monit")).To(Equal("fake-fake-job-1-monit"))
+			Expect(fs.ReadFileString("/fake-output-dir/fake-job-1/bin/ctl")).To(Equal("fake-fake-job-1-ctl"))
+			Expect(fs.ReadFileString("/fake-output-dir/fake-job-2/monit")).To(Equal("fake-fake-job-2-monit"))
+			Expect(fakeUI.Said).To(ContainElement("Rendered job templates: '/fake-output-dir'"))
+		})
+
+		It("logs the rendering stages", func() {
+			err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeStage.PerformCalls).To(ContainElement(&fakebiui.PerformCall{Name: "Rendering job templates"}))
+			Expect(fakeStage.PerformCalls).To(ContainElement(&fakebiui.PerformCall{Name: "Writing rendered job templates"}))
+		})
+
+		It("prints the differences with the last rendered templates", func() {
+			err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeUI.Said).To(ContainElement("Changes from the last deployed job templates:"))
+			Expect(fakeUI.Said).To(ContainElement("--- a/fake-job-1/monit"))
+			Expect(fakeUI.Said).To(ContainElement("+++ b/fake-job-1/monit"))
+		})
+
+		Context("when the templates did not change", func() {
+			BeforeEach(func() {
+				expectDiff.Return("", nil)
+			})
+
+			It("prints that there are no changes", func() {
+				err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeUI.Said).To(ContainElement("No changes from the last deployed job templates"))
+			})
+		})
+
+		Context("when the deployment was never rendered", func() {
+			BeforeEach(func() {
+				expectFindLast.Return(bitemplate.AppliedRenderedJobListArchive{}, false)
+				expectDiff.Times(0)
+			})
+
+			It("does not print differences", func() {
+				err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeUI.Said).ToNot(ContainElement("Changes from the last deployed job templates:"))
+				Expect(fakeUI.Said).ToNot(ContainElement("No changes from the last deployed job templates"))
+			})
+		})
+
+		Context("when comparing with the last rendered templates fails", func() {
+			BeforeEach(func() {
+				expectDiff.Return("", bosherr.Error("fake-diff-error"))
+			})
+
+			It("returns an error", func() {
+				err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-diff-error"))
+			})
+		})
+
+		Context("when a job is selected", func() {
+			It("writes and compares only the rendered templates of that job", func() {
+				expectDiff.Do(func(_ bitemplate.AppliedRenderedJobListArchive, jobs []bitemplate.RenderedJob, _ []string) {
+					Expect(jobs).To(Equal([]bitemplate.RenderedJob{renderedJobs[1]}))
+				})
+
+				err := newTemplatesRenderer().Render("fake-job-2", outputPath, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fs.FileExists("/fake-output-dir/fake-job-1")).To(BeFalse())
+				Expect(fs.ReadFileString("/fake-output-dir/fake-job-2/monit")).To(Equal("fake-fake-job-2-monit"))
+			})
+
+			It("returns an error when the job is not a template of the deployment job", func() {
+				err := newTemplatesRenderer().Render("fake-missing-job", outputPath, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Release job 'fake-missing-job' is not a template of deployment job 'fake-deployment-job'"))
+			})
+		})
+
+		It("returns an error unless the deployment manifest has exactly 1 job", func() {
+			fakeDeploymentParser.ParseManifest.Jobs = []bideplmanifest.Job{}
+
+			err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment manifest must have exactly 1 job to render its templates, but it has 0"))
+		})
+
+		Context("when rendering fails", func() {
+			It("returns an error", func() {
+				mockJobListRenderer = mock_template.NewMockJobListRenderer(mockCtrl)
+				mockJobListRenderer.EXPECT().Render(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, bosherr.Error("fake-render-error"))
+
+				err := newTemplatesRenderer().Render("", outputPath, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-render-error"))
+			})
+		})
+	})
+})
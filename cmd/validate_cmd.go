@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	biui "github.com/cloudfoundry/bosh-init/ui"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+type validateCmd struct {
+	manifestValidatorProvider func(deploymentManifestPath string, strict bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ManifestValidator, error)
+	ui                        biui.UI
+	fs                        boshsys.FileSystem
+	logger                    boshlog.Logger
+	logTag                    string
+}
+
+type validateCmdOutput struct {
+	Valid    bool              `json:"valid"`
+	Problems []ManifestProblem `json:"problems"`
+}
+
+func NewValidateCmd(
+	ui biui.UI,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+	manifestValidatorProvider func(deploymentManifestPath string, strict bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (ManifestValidator, error),
+) Cmd {
+	return &validateCmd{
+		ui:                        ui,
+		fs:                        fs,
+		manifestValidatorProvider: manifestValidatorProvider,
+		logger:                    logger,
+		logTag:                    "validateCmd",
+	}
+}
+
+func (c *validateCmd) Name() string {
+	return "validate"
+}
+
+func (c *validateCmd) Meta() Meta {
+	return Meta{
+		Synopsis: "Validate a deployment manifest offline, without downloading or installing anything",
+		Usage:    "<deployment_manifest_path>... [--json] [--strict] " + manifestOptionsUsage,
+		Env:      genericEnv,
+	}
+}
+
+func (c *validateCmd) Run(stage biui.Stage, args []string) error {
+	deploymentManifestPaths, jsonOutput, strict, deploymentManifestOptions, err := c.parseCmdInputs(args)
+	if err != nil {
+		return err
+	}
+
+	manifestAbsFilePaths := make([]string, len(deploymentManifestPaths))
+	for i, deploymentManifestPath := range deploymentManifestPaths {
+		manifestAbsFilePath, err := filepath.Abs(deploymentManifestPath)
+		if err != nil {
+			c.ui.ErrorLinef("Failed getting absolute path to deployment file '%s'", deploymentManifestPath)
+			return bosherr.WrapErrorf(err, "Getting absolute path to deployment file '%s'", deploymentManifestPath)
+		}
+
+		if !c.fs.FileExists(manifestAbsFilePath) {
+			c.ui.ErrorLinef("Deployment '%s' does not exist", manifestAbsFilePath)
+			return bosherr.Errorf("Deployment manifest does not exist at '%s'", manifestAbsFilePath)
+		}
+
+		if !jsonOutput {
+			c.ui.PrintLinef("Deployment manifest: '%s'", manifestAbsFilePath)
+		}
+		manifestAbsFilePaths[i] = manifestAbsFilePath
+	}
+
+	// the other manifests are merged into the first one, before the ops files are applied.
+	// Relative paths in them resolve relative to the first one.
+	deploymentOps, err := bipatch.LoadMergeOps(manifestAbsFilePaths[1:], c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifests")
+		return bosherr.WrapError(err, "Loading deployment manifests")
+	}
+
+	opsFilesOps, err := deploymentManifestOptions.Ops(c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest ops files")
+		return bosherr.WrapError(err, "Loading deployment manifest ops files")
+	}
+	deploymentOps = append(deploymentOps, opsFilesOps...)
+
+	deploymentVars, err := deploymentManifestOptions.Variables(manifestAbsFilePaths[0], deploymentOps, c.fs)
+	if err != nil {
+		c.ui.ErrorLinef("Failed loading deployment manifest variables")
+		return bosherr.WrapError(err, "Loading deployment manifest variables")
+	}
+
+	manifestValidator, err := c.manifestValidatorProvider(manifestAbsFilePaths[0], strict, deploymentVars, deploymentOps)
+	if err != nil {
+		return err
+	}
+
+	problems := manifestValidator.Validate()
+
+	errorCount := 0
+	for _, problem := range problems {
+		if problem.Severity == ErrorSeverity {
+			errorCount++
+		}
+	}
+
+	if jsonOutput {
+		output, err := json.MarshalIndent(validateCmdOutput{Valid: errorCount == 0, Problems: problems}, "", "  ")
+		if err != nil {
+			return bosherr.WrapError(err, "Marshalling validation problems")
+		}
+		c.ui.PrintLinef("%s", output)
+	} else {
+		for _, problem := range problems {
+			c.ui.PrintLinef("%s at '%s': %s", problem.Severity, problem.Path, problem.Message)
+		}
+	}
+
+	if errorCount > 0 {
+		return bosherr.Errorf("Deployment manifest is invalid: %d error(s)", errorCount)
+	}
+
+	if !jsonOutput {
+		c.ui.PrintLinef("Deployment manifest is valid")
+	}
+	return nil
+}
+
+// parseCmdInputs returns the deployment manifests, whether to print the problems as JSON
+// & whether job properties not declared by any job template are errors (--strict) instead of warnings
+func (c *validateCmd) parseCmdInputs(args []string) ([]string, bool, bool, manifestOptions, error) {
+	deploymentManifestOptions, remainingArgs, err := extractManifestOptions(args)
+	if err != nil {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, false, false, deploymentManifestOptions, err
+	}
+
+	jsonOutput := false
+	strict := false
+	deploymentManifestPaths := []string{}
+	for _, arg := range remainingArgs {
+		switch arg {
+		case "--json":
+			jsonOutput = true
+		case "--strict":
+			strict = true
+		default:
+			deploymentManifestPaths = append(deploymentManifestPaths, arg)
+		}
+	}
+
+	if len(deploymentManifestPaths) == 0 {
+		c.logger.Error(c.logTag, "Invalid arguments: %#v", args)
+		return nil, false, false, deploymentManifestOptions, errors.New("Invalid usage - validate command requires at least 1 argument")
+	}
+	return deploymentManifestPaths, jsonOutput, strict, deploymentManifestOptions, nil
+}
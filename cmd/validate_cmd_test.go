@@ -0,0 +1,160 @@
+package cmd_test
+
+import (
+	"encoding/json"
+
+	bicmd "github.com/cloudfoundry/bosh-init/cmd"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	mock_cmd "github.com/cloudfoundry/bosh-init/cmd/mocks"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+	fakeui "github.com/cloudfoundry/bosh-init/ui/fakes"
+)
+
+var _ = Describe("ValidateCmd", func() {
+	var mockCtrl *gomock.Controller
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("Run", func() {
+		var (
+			mockManifestValidator *mock_cmd.MockManifestValidator
+			fs                    *fakesys.FakeFileSystem
+			logger                boshlog.Logger
+
+			fakeUI                 *fakeui.FakeUI
+			fakeStage              *fakebiui.FakeStage
+			deploymentManifestPath = "/deployment-dir/fake-deployment-manifest.yml"
+			receivedDeploymentOps  bipatch.Ops
+			receivedStrict         bool
+		)
+
+		var newValidateCmd = func() bicmd.Cmd {
+			doGetFunc := func(manifestPath string, strict bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (bicmd.ManifestValidator, error) {
+				Expect(manifestPath).To(Equal(deploymentManifestPath))
+				receivedStrict = strict
+				receivedDeploymentOps = deploymentOps
+				return mockManifestValidator, nil
+			}
+
+			return bicmd.NewValidateCmd(fakeUI, fs, logger, doGetFunc)
+		}
+
+		BeforeEach(func() {
+			mockManifestValidator = mock_cmd.NewMockManifestValidator(mockCtrl)
+			fs = fakesys.NewFakeFileSystem()
+			logger = boshlog.NewLogger(boshlog.LevelNone)
+			fakeUI = &fakeui.FakeUI{}
+			fakeStage = fakebiui.NewFakeStage()
+			fs.WriteFileString(deploymentManifestPath, `---manifest-content`)
+		})
+
+		It("returns an error when no deployment manifest is given", func() {
+			err := newValidateCmd().Run(fakeStage, []string{"--json"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Invalid usage - validate command requires at least 1 argument"))
+		})
+
+		It("returns an error when the deployment manifest does not exist", func() {
+			err := newValidateCmd().Run(fakeStage, []string{"/garbage"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment manifest does not exist at '/garbage'"))
+			Expect(fakeUI.Errors).To(ContainElement("Deployment '/garbage' does not exist"))
+		})
+
+		It("merges the other deployment manifests into the first one", func() {
+			fs.WriteFileString("/deployment-dir/fake-other-manifest.yml", "name: other\n")
+			mockManifestValidator.EXPECT().Validate().Return([]bicmd.ManifestProblem{})
+
+			err := newValidateCmd().Run(fakeStage, []string{deploymentManifestPath, "/deployment-dir/fake-other-manifest.yml"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(receivedDeploymentOps).To(HaveLen(1))
+			Expect(receivedDeploymentOps[0].String()).To(Equal("merge '/deployment-dir/fake-other-manifest.yml'"))
+		})
+
+		It("fails on job properties not declared by any job template with --strict", func() {
+			mockManifestValidator.EXPECT().Validate().Return([]bicmd.ManifestProblem{}).Times(2)
+
+			err := newValidateCmd().Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(receivedStrict).To(BeFalse())
+
+			err = newValidateCmd().Run(fakeStage, []string{deploymentManifestPath, "--strict"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(receivedStrict).To(BeTrue())
+		})
+
+		Context("when the deployment manifest is valid", func() {
+			BeforeEach(func() {
+				mockManifestValidator.EXPECT().Validate().Return([]bicmd.ManifestProblem{
+					{Path: "/releases/0/url", Severity: bicmd.WarningSeverity, Message: "fake-warning"},
+				})
+			})
+
+			It("prints the warnings and that the deployment manifest is valid", func() {
+				err := newValidateCmd().Run(fakeStage, []string{deploymentManifestPath})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeUI.Said).To(Equal([]string{
+					"Deployment manifest: '/deployment-dir/fake-deployment-manifest.yml'",
+					"warning at '/releases/0/url': fake-warning",
+					"Deployment manifest is valid",
+				}))
+			})
+
+			It("prints the problems as json with --json", func() {
+				err := newValidateCmd().Run(fakeStage, []string{deploymentManifestPath, "--json"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeUI.Said).To(HaveLen(1))
+
+				var output map[string]interface{}
+				Expect(json.Unmarshal([]byte(fakeUI.Said[0]), &output)).To(Succeed())
+				Expect(output).To(Equal(map[string]interface{}{
+					"valid": true,
+					"problems": []interface{}{
+						map[string]interface{}{"path": "/releases/0/url", "severity": "warning", "message": "fake-warning"},
+					},
+				}))
+			})
+		})
+
+		Context("when the deployment manifest is invalid", func() {
+			BeforeEach(func() {
+				mockManifestValidator.EXPECT().Validate().Return([]bicmd.ManifestProblem{
+					{Path: "/jobs/0/name", Severity: bicmd.ErrorSeverity, Message: "jobs[0].name must be provided"},
+					{Path: "/releases/0/url", Severity: bicmd.WarningSeverity, Message: "fake-warning"},
+				})
+			})
+
+			It("prints the problems and returns an error", func() {
+				err := newValidateCmd().Run(fakeStage, []string{deploymentManifestPath})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Deployment manifest is invalid: 1 error(s)"))
+				Expect(fakeUI.Said).To(ContainElement("error at '/jobs/0/name': jobs[0].name must be provided"))
+				Expect(fakeUI.Said).ToNot(ContainElement("Deployment manifest is valid"))
+			})
+
+			It("prints the problems as json with --json and returns an error", func() {
+				err := newValidateCmd().Run(fakeStage, []string{"--json", deploymentManifestPath})
+				Expect(err).To(HaveOccurred())
+
+				var output map[string]interface{}
+				Expect(json.Unmarshal([]byte(fakeUI.Said[0]), &output)).To(Succeed())
+				Expect(output["valid"]).To(BeFalse())
+				Expect(output["problems"]).To(HaveLen(2))
+			})
+		})
+	})
+})
@@ -0,0 +1,12 @@
+package diff_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Common Diff Suite")
+}
@@ -0,0 +1,184 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+	// oldIndex & newIndex are the positions of the line in the old & new text (or where it would be)
+	oldIndex int
+	newIndex int
+}
+
+// Unified returns the line differences between the old and new text in unified diff format,
+// or an empty string if the texts are the same.
+func Unified(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks(ops) {
+		writeHunk(&buffer, hunk)
+	}
+	return buffer.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns the edit script from the old lines to the new lines, based on their longest common subsequence
+func diffLines(oldLines, newLines []string) []op {
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	oldMiddle := oldLines[prefix : len(oldLines)-suffix]
+	newMiddle := newLines[prefix : len(newLines)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of oldMiddle[i:] and newMiddle[j:]
+	lcs := make([][]int, len(oldMiddle)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newMiddle)+1)
+	}
+	for i := len(oldMiddle) - 1; i >= 0; i-- {
+		for j := len(newMiddle) - 1; j >= 0; j-- {
+			if oldMiddle[i] == newMiddle[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]op, 0, len(oldLines)+len(newLines))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{kind: opEqual, line: oldLines[i], oldIndex: i, newIndex: i})
+	}
+
+	i, j := 0, 0
+	for i < len(oldMiddle) || j < len(newMiddle) {
+		switch {
+		case i < len(oldMiddle) && j < len(newMiddle) && oldMiddle[i] == newMiddle[j]:
+			ops = append(ops, op{kind: opEqual, line: oldMiddle[i], oldIndex: prefix + i, newIndex: prefix + j})
+			i++
+			j++
+		case j == len(newMiddle) || (i < len(oldMiddle) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{kind: opDelete, line: oldMiddle[i], oldIndex: prefix + i, newIndex: prefix + j})
+			i++
+		default:
+			ops = append(ops, op{kind: opInsert, line: newMiddle[j], oldIndex: prefix + i, newIndex: prefix + j})
+			j++
+		}
+	}
+
+	for k := 0; k < suffix; k++ {
+		ops = append(ops, op{
+			kind:     opEqual,
+			line:     oldLines[len(oldLines)-suffix+k],
+			oldIndex: len(oldLines) - suffix + k,
+			newIndex: len(newLines) - suffix + k,
+		})
+	}
+
+	return ops
+}
+
+// hunks groups the changes with their surrounding context, merging changes whose contexts overlap
+func hunks(ops []op) [][]op {
+	result := [][]op{}
+
+	start, end := -1, -1
+	for idx, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+
+		if start != -1 && idx-contextLines <= end {
+			end = minInt(idx+contextLines+1, len(ops))
+			continue
+		}
+
+		if start != -1 {
+			result = append(result, ops[start:end])
+		}
+		start = maxInt(idx-contextLines, 0)
+		end = minInt(idx+contextLines+1, len(ops))
+	}
+
+	if start != -1 {
+		result = append(result, ops[start:end])
+	}
+	return result
+}
+
+func writeHunk(buffer *bytes.Buffer, hunk []op) {
+	oldCount, newCount := 0, 0
+	for _, o := range hunk {
+		if o.kind != opInsert {
+			oldCount++
+		}
+		if o.kind != opDelete {
+			newCount++
+		}
+	}
+
+	oldStart := hunk[0].oldIndex + 1
+	if oldCount == 0 {
+		oldStart--
+	}
+	newStart := hunk[0].newIndex + 1
+	if newCount == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(buffer, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, o := range hunk {
+		fmt.Fprintf(buffer, "%c%s\n", o.kind, o.line)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package diff_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/common/diff"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unified", func() {
+	It("returns an empty string when the texts are the same", func() {
+		Expect(Unified("a/file", "b/file", "line-1\nline-2\n", "line-1\nline-2\n")).To(Equal(""))
+	})
+
+	It("returns the changed lines with their context", func() {
+		oldText := "line-1\nline-2\nline-3\nline-4\nline-5\nline-6\nline-7\nline-8\n"
+		newText := "line-1\nline-2\nline-3\nline-4\nchanged-line-5\nline-6\nline-7\nline-8\n"
+
+		Expect(Unified("a/file", "b/file", oldText, newText)).To(Equal(`--- a/file
++++ b/file
+@@ -2,7 +2,7 @@
+ line-2
+ line-3
+ line-4
+-line-5
++changed-line-5
+ line-6
+ line-7
+ line-8
+`))
+	})
+
+	It("separates changes that are far apart into hunks", func() {
+		oldText := "line-1\nline-2\nline-3\nline-4\nline-5\nline-6\nline-7\nline-8\nline-9\nline-10\n"
+		newText := "new-line\nline-1\nline-2\nline-3\nline-4\nline-5\nline-6\nline-7\nline-8\nline-9\n"
+
+		Expect(Unified("a/file", "b/file", oldText, newText)).To(Equal(`--- a/file
++++ b/file
+@@ -1,3 +1,4 @@
++new-line
+ line-1
+ line-2
+ line-3
+@@ -7,4 +8,3 @@
+ line-7
+ line-8
+ line-9
+-line-10
+`))
+	})
+
+	It("returns all the lines of new and deleted files", func() {
+		Expect(Unified("/dev/null", "b/file", "", "line-1\nline-2\n")).To(Equal(`--- /dev/null
++++ b/file
+@@ -0,0 +1,2 @@
++line-1
++line-2
+`))
+
+		Expect(Unified("a/file", "/dev/null", "line-1\n", "")).To(Equal(`--- a/file
++++ /dev/null
+@@ -1,1 +0,0 @@
+-line-1
+`))
+	})
+})
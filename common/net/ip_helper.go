@@ -2,6 +2,8 @@ package net
 
 import (
 	"net"
+	"strconv"
+	"strings"
 )
 
 func LastAddress(n *net.IPNet) net.IP {
@@ -23,3 +25,24 @@ func LastAddress(n *net.IPNet) net.IP {
 		ip[2]|^n.Mask[2],
 		ip[3]|^n.Mask[3])
 }
+
+// JoinHostPort joins a host, which may be an IPv6 address with or without brackets, and a port (e.g. [2001:db8::1]:6868)
+func JoinHostPort(host string, port int) string {
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), strconv.Itoa(port))
+}
+
+// Netmask returns the mask of the network, e.g. 255.255.255.0 for IPv4 networks,
+// or the prefix length for IPv6 networks (e.g. 64), as the agent expects it
+func Netmask(n *net.IPNet) string {
+	if IsIPv4(n) {
+		return net.IP(n.Mask).String()
+	}
+
+	prefixLength, _ := n.Mask.Size()
+	return strconv.Itoa(prefixLength)
+}
+
+// IsIPv4 returns true if the network is an IPv4 network
+func IsIPv4(n *net.IPNet) bool {
+	return n.IP.To4() != nil
+}
@@ -32,6 +32,29 @@ var _ = Describe("common.net ip helpers", func() {
 			).To(Equal(net.ParseIP("2001:db8:1234:ffff:ffff:ffff:ffff:ffff")))
 		})
 	})
+
+	Describe("JoinHostPort", func() {
+		It("joins IPv4 addresses & host names with the port", func() {
+			Expect(binet.JoinHostPort("10.0.0.5", 6868)).To(Equal("10.0.0.5:6868"))
+			Expect(binet.JoinHostPort("fake-host", 6868)).To(Equal("fake-host:6868"))
+		})
+
+		It("brackets IPv6 addresses, bracketed or not", func() {
+			Expect(binet.JoinHostPort("2001:db8::1", 6868)).To(Equal("[2001:db8::1]:6868"))
+			Expect(binet.JoinHostPort("[2001:db8::1]", 6868)).To(Equal("[2001:db8::1]:6868"))
+		})
+	})
+
+	Describe("Netmask", func() {
+		It("returns the mask of IPv4 networks", func() {
+			Expect(binet.Netmask(netFor("10.0.0.0/22"))).To(Equal("255.255.252.0"))
+		})
+
+		It("returns the prefix length of IPv6 networks", func() {
+			Expect(binet.Netmask(netFor("2001:db8:1234::/64"))).To(Equal("64"))
+			Expect(binet.Netmask(netFor("2001:db8:1234::/48"))).To(Equal("48"))
+		})
+	})
 })
 
 func netFor(ipNetString string) *net.IPNet {
@@ -39,3 +62,39 @@ func netFor(ipNetString string) *net.IPNet {
 	Expect(err).ToNot(HaveOccurred())
 	return ipNet
 }
+
+var _ = Describe("IPRange", func() {
+	Describe("ParseIPRange", func() {
+		It("parses a single IP", func() {
+			ipRange, err := binet.ParseIPRange("10.0.0.5")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ipRange.Contains(net.ParseIP("10.0.0.5"))).To(BeTrue())
+			Expect(ipRange.Contains(net.ParseIP("10.0.0.6"))).To(BeFalse())
+		})
+
+		It("parses an inclusive IP range", func() {
+			ipRange, err := binet.ParseIPRange("10.0.0.5 - 10.0.0.10")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ipRange.Contains(net.ParseIP("10.0.0.4"))).To(BeFalse())
+			Expect(ipRange.Contains(net.ParseIP("10.0.0.5"))).To(BeTrue())
+			Expect(ipRange.Contains(net.ParseIP("10.0.0.10"))).To(BeTrue())
+			Expect(ipRange.Contains(net.ParseIP("10.0.0.11"))).To(BeFalse())
+		})
+
+		It("returns an error when the range is not made of IPs in order", func() {
+			for _, invalidRange := range []string{"", "fake-ip", "10.0.0.10 - 10.0.0.5", "10.0.0.1 - 10.0.0.2 - 10.0.0.3"} {
+				_, err := binet.ParseIPRange(invalidRange)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Describe("Within", func() {
+		It("returns true when all the IPs are in the network", func() {
+			ipRange, err := binet.ParseIPRange("10.0.0.5 - 10.0.0.10")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ipRange.Within(netFor("10.0.0.0/24"))).To(BeTrue())
+			Expect(ipRange.Within(netFor("10.0.0.8/29"))).To(BeFalse())
+		})
+	})
+})
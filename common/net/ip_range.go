@@ -0,0 +1,51 @@
+package net
+
+import (
+	"bytes"
+	"net"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// IPRange is an inclusive range of IPs, such as the reserved & static ranges of a subnet
+type IPRange struct {
+	First net.IP
+	Last  net.IP
+}
+
+// ParseIPRange parses a single IP (e.g. 10.0.0.5) or an inclusive IP range (e.g. 10.0.0.5 - 10.0.0.10)
+func ParseIPRange(ipRange string) (IPRange, error) {
+	parts := strings.Split(ipRange, "-")
+	if len(parts) > 2 {
+		return IPRange{}, bosherr.Errorf("Invalid IP range '%s'", ipRange)
+	}
+
+	first := net.ParseIP(strings.TrimSpace(parts[0]))
+	if first == nil {
+		return IPRange{}, bosherr.Errorf("Invalid IP range '%s'", ipRange)
+	}
+
+	last := first
+	if len(parts) == 2 {
+		last = net.ParseIP(strings.TrimSpace(parts[1]))
+		if last == nil || compareIPs(first, last) > 0 {
+			return IPRange{}, bosherr.Errorf("Invalid IP range '%s'", ipRange)
+		}
+	}
+
+	return IPRange{First: first, Last: last}, nil
+}
+
+func (r IPRange) Contains(ip net.IP) bool {
+	return compareIPs(r.First, ip) <= 0 && compareIPs(ip, r.Last) <= 0
+}
+
+// Within returns true if all the IPs of the range are in the network
+func (r IPRange) Within(ipNet *net.IPNet) bool {
+	return ipNet.Contains(r.First) && ipNet.Contains(r.Last)
+}
+
+func compareIPs(ip1, ip2 net.IP) int {
+	return bytes.Compare(ip1.To16(), ip2.To16())
+}
@@ -0,0 +1,134 @@
+package patch
+
+import (
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+)
+
+// MergeOp deep-merges a YAML document, e.g. from an additional manifest file, into the document:
+// maps are merged key by key, arrays of maps with a name key (such as releases or jobs) are merged item by item
+// by name, appending the new items, and the other values are replaced.
+// The paths in the document, like file:// release URLs, are not rewritten: relative ones resolve
+// relative to the document merged into, i.e. the first deployment manifest.
+type MergeOp struct {
+	Source string
+	Value  interface{}
+}
+
+// LoadMergeOps reads the YAML documents of the files as merge operations, in order
+func LoadMergeOps(paths []string, fs boshsys.FileSystem) (Ops, error) {
+	ops := make(Ops, 0, len(paths))
+	for _, path := range paths {
+		contents, err := fs.ReadFile(path)
+		if err != nil {
+			return Ops{}, bosherr.WrapErrorf(err, "Reading manifest file '%s'", path)
+		}
+
+		var value interface{}
+		err = yaml.Unmarshal(contents, &value)
+		if err != nil {
+			return Ops{}, bosherr.WrapErrorf(err, "Unmarshalling manifest file '%s'", path)
+		}
+
+		ops = append(ops, MergeOp{Source: path, Value: value})
+	}
+	return ops, nil
+}
+
+func (op MergeOp) Apply(document interface{}) (interface{}, error) {
+	if op.Value == nil {
+		// an empty document changes nothing
+		return document, nil
+	}
+	// the operations are applied several times per command, so the document must not share the value
+	return merge(document, copyValue(op.Value)), nil
+}
+
+func (op MergeOp) String() string {
+	return fmt.Sprintf("merge '%s'", op.Source)
+}
+
+func merge(node interface{}, value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		nodeMap, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return value
+		}
+
+		for key, childValue := range typedValue {
+			if child, found := nodeMap[key]; found {
+				nodeMap[key] = merge(child, childValue)
+			} else {
+				nodeMap[key] = childValue
+			}
+		}
+		return nodeMap
+
+	case []interface{}:
+		nodeArray, ok := node.([]interface{})
+		if !ok || !isNamedArray(nodeArray) || !isNamedArray(typedValue) {
+			return value
+		}
+
+		for _, item := range typedValue {
+			itemName := fmt.Sprintf("%v", item.(map[interface{}]interface{})["name"])
+
+			merged := false
+			for i, nodeItem := range nodeArray {
+				if fmt.Sprintf("%v", nodeItem.(map[interface{}]interface{})["name"]) == itemName {
+					nodeArray[i] = merge(nodeItem, item)
+					merged = true
+					break
+				}
+			}
+
+			if !merged {
+				nodeArray = append(nodeArray, item)
+			}
+		}
+		return nodeArray
+
+	default:
+		return value
+	}
+}
+
+func isNamedArray(array []interface{}) bool {
+	for _, item := range array {
+		itemMap, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return false
+		}
+
+		if _, found := itemMap["name"]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// copyValue deep-copies the maps & arrays of a YAML value
+func copyValue(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[interface{}]interface{}:
+		valueMap := make(map[interface{}]interface{}, len(typedValue))
+		for key, childValue := range typedValue {
+			valueMap[key] = copyValue(childValue)
+		}
+		return valueMap
+
+	case []interface{}:
+		valueArray := make([]interface{}, len(typedValue))
+		for i, item := range typedValue {
+			valueArray[i] = copyValue(item)
+		}
+		return valueArray
+
+	default:
+		return value
+	}
+}
@@ -0,0 +1,132 @@
+package patch_test
+
+import (
+	"errors"
+
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/common/patch"
+)
+
+var _ = Describe("MergeOp", func() {
+	var fs *fakesys.FakeFileSystem
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+	})
+
+	var merge = func(document string, files ...string) (string, error) {
+		paths := []string{}
+		for i, file := range files {
+			path := []string{"/cpi.yml", "/director.yml"}[i]
+			fs.WriteFileString(path, file)
+			paths = append(paths, path)
+		}
+
+		ops, err := LoadMergeOps(paths, fs)
+		Expect(err).ToNot(HaveOccurred())
+
+		result, err := ops.Apply([]byte(document))
+		return string(result), err
+	}
+
+	It("merges maps deeply, arrays of named items by name & replaces the other values", func() {
+		result, err := merge(`---
+name: fake-deployment
+releases:
+- name: bosh
+  url: file://bosh.tgz
+networks:
+- name: default
+  subnets: [{range: 10.0.0.0/24}]
+tags: [a, b]
+`, `---
+releases:
+- name: bosh-aws-cpi
+  url: file://cpi.tgz
+cloud_provider:
+  properties: {aws: {region: us-east-1}}
+`, `---
+name: other-deployment
+releases:
+- name: bosh
+  sha1: fake-sha1
+networks:
+- name: default
+  type: manual
+tags: [c]
+cloud_provider:
+  properties: {aws: {default_key_name: bosh}}
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(`cloud_provider:
+  properties:
+    aws:
+      default_key_name: bosh
+      region: us-east-1
+name: other-deployment
+networks:
+- name: default
+  subnets:
+  - range: 10.0.0.0/24
+  type: manual
+releases:
+- name: bosh
+  sha1: fake-sha1
+  url: file://bosh.tgz
+- name: bosh-aws-cpi
+  url: file://cpi.tgz
+tags:
+- c
+`))
+	})
+
+	It("can be applied several times, even when a later operation changes the merged values", func() {
+		fs.WriteFileString("/cpi.yml", `---
+cloud_provider:
+  properties:
+    a: 1
+    b: 2
+`)
+		mergeOps, err := LoadMergeOps([]string{"/cpi.yml"}, fs)
+		Expect(err).ToNot(HaveOccurred())
+
+		removeOps, err := ParseOps([]byte(`
+- type: remove
+  path: /cloud_provider/properties/b
+`))
+		Expect(err).ToNot(HaveOccurred())
+		ops := append(mergeOps, removeOps...)
+
+		for i := 0; i < 3; i++ {
+			result, err := ops.Apply([]byte("---\nname: fake-deployment\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(result)).To(Equal(`cloud_provider:
+  properties:
+    a: 1
+name: fake-deployment
+`))
+		}
+	})
+
+	It("ignores empty files", func() {
+		result, err := merge("---\nname: fake-deployment\n", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal("name: fake-deployment\n"))
+	})
+
+	It("names the file in errors of the later operations", func() {
+		Expect(MergeOp{Source: "/cpi.yml"}.String()).To(Equal("merge '/cpi.yml'"))
+	})
+
+	It("returns an error when a file cannot be read", func() {
+		fs.WriteFileString("/cpi.yml", "")
+		fs.ReadFileError = errors.New("fake-read-error")
+
+		_, err := LoadMergeOps([]string{"/cpi.yml"}, fs)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Reading manifest file '/cpi.yml': fake-read-error"))
+	})
+})
@@ -0,0 +1,303 @@
+package patch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Op is an operation changing a YAML document, e.g. from an ops file
+type Op interface {
+	// Apply returns the changed document; the document may be changed in place
+	Apply(document interface{}) (interface{}, error)
+
+	String() string
+}
+
+// ReplaceOp replaces the node at the path with the value, creating the missing optional nodes
+type ReplaceOp struct {
+	Path  Pointer
+	Value interface{}
+}
+
+// RemoveOp removes the node at the path, unless the missing nodes are optional
+type RemoveOp struct {
+	Path Pointer
+}
+
+// Ops are operations applied in order
+type Ops []Op
+
+// Apply applies the operations to a YAML document.
+// The contents are returned unchanged if there are no operations.
+func (ops Ops) Apply(contents []byte) ([]byte, error) {
+	if len(ops) == 0 {
+		return contents, nil
+	}
+
+	var document interface{}
+	err := yaml.Unmarshal(contents, &document)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling YAML document")
+	}
+
+	for i, op := range ops {
+		document, err = op.Apply(document)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Applying operation %d (%s)", i, op)
+		}
+	}
+
+	patchedContents, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling patched YAML document")
+	}
+	return patchedContents, nil
+}
+
+func (op ReplaceOp) Apply(document interface{}) (interface{}, error) {
+	return op.replace(document, 0, false)
+}
+
+func (op ReplaceOp) String() string {
+	return fmt.Sprintf("replace '%s'", op.Path)
+}
+
+func (op ReplaceOp) replace(node interface{}, i int, optional bool) (interface{}, error) {
+	tokens := op.Path.tokens
+	if i == len(tokens) {
+		return copyValue(op.Value), nil
+	}
+	path := Pointer{tokens: tokens[:i+1]}
+
+	switch t := tokens[i].(type) {
+	case keyToken:
+		optional = optional || t.optional
+		if node == nil && optional {
+			node = map[interface{}]interface{}{}
+		}
+
+		nodeMap, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("a map", node, path)
+		}
+
+		child, found := nodeMap[t.key]
+		if !found && !optional {
+			return nil, newMissingKeyError(t.key, nodeMap, path)
+		}
+
+		newChild, err := op.replace(child, i+1, optional)
+		if err != nil {
+			return nil, err
+		}
+		nodeMap[t.key] = newChild
+		return nodeMap, nil
+
+	case indexToken:
+		nodeArray, ok := node.([]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("an array", node, path)
+		}
+
+		if t.index >= len(nodeArray) {
+			return nil, newIndexOutOfRangeError(t.index, nodeArray, path)
+		}
+
+		newChild, err := op.replace(nodeArray[t.index], i+1, optional)
+		if err != nil {
+			return nil, err
+		}
+		nodeArray[t.index] = newChild
+		return nodeArray, nil
+
+	case afterLastIndexToken:
+		if node == nil && optional {
+			node = []interface{}{}
+		}
+
+		nodeArray, ok := node.([]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("an array", node, path)
+		}
+
+		newChild, err := op.replace(nil, i+1, true)
+		if err != nil {
+			return nil, err
+		}
+		return append(nodeArray, newChild), nil
+
+	case matchingIndexToken:
+		optional = optional || t.optional
+		if node == nil && optional {
+			node = []interface{}{}
+		}
+
+		nodeArray, ok := node.([]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("an array", node, path)
+		}
+
+		indexes := t.matchingIndexes(nodeArray)
+		if len(indexes) == 0 && optional {
+			nodeArray = append(nodeArray, map[interface{}]interface{}{t.key: t.value})
+			indexes = []int{len(nodeArray) - 1}
+		}
+
+		if len(indexes) != 1 {
+			return nil, newMatchCountError(len(indexes), path)
+		}
+
+		newChild, err := op.replace(nodeArray[indexes[0]], i+1, optional)
+		if err != nil {
+			return nil, err
+		}
+		nodeArray[indexes[0]] = newChild
+		return nodeArray, nil
+
+	default:
+		return nil, bosherr.Errorf("Unknown path segment '%s'", t)
+	}
+}
+
+func (op RemoveOp) Apply(document interface{}) (interface{}, error) {
+	if len(op.Path.tokens) == 0 {
+		return nil, bosherr.Error("Expected not to remove the whole document")
+	}
+	return op.remove(document, 0, false)
+}
+
+func (op RemoveOp) String() string {
+	return fmt.Sprintf("remove '%s'", op.Path)
+}
+
+func (op RemoveOp) remove(node interface{}, i int, optional bool) (interface{}, error) {
+	tokens := op.Path.tokens
+	isLast := i == len(tokens)-1
+	path := Pointer{tokens: tokens[:i+1]}
+
+	switch t := tokens[i].(type) {
+	case keyToken:
+		optional = optional || t.optional
+		if node == nil && optional {
+			return node, nil
+		}
+
+		nodeMap, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("a map", node, path)
+		}
+
+		child, found := nodeMap[t.key]
+		if !found {
+			if optional {
+				return nodeMap, nil
+			}
+			return nil, newMissingKeyError(t.key, nodeMap, path)
+		}
+
+		if isLast {
+			delete(nodeMap, t.key)
+			return nodeMap, nil
+		}
+
+		newChild, err := op.remove(child, i+1, optional)
+		if err != nil {
+			return nil, err
+		}
+		nodeMap[t.key] = newChild
+		return nodeMap, nil
+
+	case indexToken:
+		nodeArray, ok := node.([]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("an array", node, path)
+		}
+
+		if t.index >= len(nodeArray) {
+			return nil, newIndexOutOfRangeError(t.index, nodeArray, path)
+		}
+
+		return op.removeFromArray(nodeArray, t.index, isLast, i, optional)
+
+	case matchingIndexToken:
+		optional = optional || t.optional
+		if node == nil && optional {
+			return node, nil
+		}
+
+		nodeArray, ok := node.([]interface{})
+		if !ok {
+			return nil, newTypeMismatchError("an array", node, path)
+		}
+
+		indexes := t.matchingIndexes(nodeArray)
+		if len(indexes) == 0 && optional {
+			return nodeArray, nil
+		}
+
+		if len(indexes) != 1 {
+			return nil, newMatchCountError(len(indexes), path)
+		}
+
+		return op.removeFromArray(nodeArray, indexes[0], isLast, i, optional)
+
+	default:
+		return nil, bosherr.Errorf("Expected path segment '%s' of '%s' to be a map key, an array index or a matching array item", t, op.Path)
+	}
+}
+
+func (op RemoveOp) removeFromArray(nodeArray []interface{}, index int, isLast bool, i int, optional bool) (interface{}, error) {
+	if isLast {
+		newArray := make([]interface{}, 0, len(nodeArray)-1)
+		newArray = append(newArray, nodeArray[:index]...)
+		return append(newArray, nodeArray[index+1:]...), nil
+	}
+
+	newChild, err := op.remove(nodeArray[index], i+1, optional)
+	if err != nil {
+		return nil, err
+	}
+	nodeArray[index] = newChild
+	return nodeArray, nil
+}
+
+func (t matchingIndexToken) matchingIndexes(nodeArray []interface{}) []int {
+	indexes := []int{}
+	for i, item := range nodeArray {
+		itemMap, ok := item.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+
+		value, found := itemMap[t.key]
+		if found && fmt.Sprintf("%v", value) == t.value {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func newTypeMismatchError(expected string, node interface{}, path Pointer) error {
+	return bosherr.Errorf("Expected to find %s at path '%s' but found '%T'", expected, path, node)
+}
+
+func newMissingKeyError(key string, nodeMap map[interface{}]interface{}, path Pointer) error {
+	keys := []string{}
+	for k := range nodeMap {
+		keys = append(keys, fmt.Sprintf("'%v'", k))
+	}
+	sort.Strings(keys)
+	return bosherr.Errorf("Expected to find a map key '%s' for path '%s' (found map keys: %s)", key, path, strings.Join(keys, ", "))
+}
+
+func newIndexOutOfRangeError(index int, nodeArray []interface{}, path Pointer) error {
+	return bosherr.Errorf("Expected to find array index %d for path '%s' but found an array of length %d", index, path, len(nodeArray))
+}
+
+func newMatchCountError(count int, path Pointer) error {
+	return bosherr.Errorf("Expected to find exactly one matching array item for path '%s' but found %d", path, count)
+}
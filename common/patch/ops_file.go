@@ -0,0 +1,77 @@
+package patch
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadFile reads the operations of an ops file
+func LoadFile(path string, fs boshsys.FileSystem) (Ops, error) {
+	contents, err := fs.ReadFile(path)
+	if err != nil {
+		return Ops{}, bosherr.WrapErrorf(err, "Reading ops file '%s'", path)
+	}
+
+	ops, err := ParseOps(contents)
+	if err != nil {
+		return Ops{}, bosherr.WrapErrorf(err, "Parsing ops file '%s'", path)
+	}
+	return ops, nil
+}
+
+// ParseOps parses a YAML list of operations, such as:
+//
+//   - type: replace
+//     path: /jobs/name=bosh/properties/director?/name
+//     value: my-director
+//   - type: remove
+//     path: /jobs/name=bosh/properties/hm
+func ParseOps(contents []byte) (Ops, error) {
+	definitions := []map[string]interface{}{}
+	err := yaml.Unmarshal(contents, &definitions)
+	if err != nil {
+		return Ops{}, bosherr.WrapError(err, "Unmarshalling operations")
+	}
+
+	ops := make(Ops, 0, len(definitions))
+	for i, definition := range definitions {
+		op, err := newOpFromDefinition(definition)
+		if err != nil {
+			return Ops{}, bosherr.WrapErrorf(err, "Operation %d", i)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func newOpFromDefinition(definition map[string]interface{}) (Op, error) {
+	path, ok := definition["path"].(string)
+	if !ok {
+		return nil, bosherr.Error("Expected a string path")
+	}
+
+	pointer, err := NewPointerFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, hasValue := definition["value"]
+
+	switch definition["type"] {
+	case "replace":
+		if !hasValue {
+			return nil, bosherr.Errorf("Expected replace operation for path '%s' to have a value", path)
+		}
+		return ReplaceOp{Path: pointer, Value: value}, nil
+
+	case "remove":
+		if hasValue {
+			return nil, bosherr.Errorf("Expected remove operation for path '%s' not to have a value", path)
+		}
+		return RemoveOp{Path: pointer}, nil
+
+	default:
+		return nil, bosherr.Errorf("Unknown operation type '%v' for path '%s', expected 'replace' or 'remove'", definition["type"], path)
+	}
+}
@@ -0,0 +1,195 @@
+package patch_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/common/patch"
+)
+
+var _ = Describe("Ops", func() {
+	var document = []byte(`---
+name: fake-deployment
+jobs:
+- name: bosh
+  templates:
+  - name: director
+  - name: nats
+  properties:
+    director:
+      name: fake-director
+- name: other
+`)
+
+	var apply = func(opsYAML string) (string, error) {
+		ops, err := ParseOps([]byte(opsYAML))
+		Expect(err).ToNot(HaveOccurred())
+
+		result, err := ops.Apply(document)
+		return string(result), err
+	}
+
+	It("returns the contents unchanged when there are no operations", func() {
+		result, err := Ops{}.Apply(document)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(document))
+	})
+
+	Describe("replace", func() {
+		It("replaces map keys & array items matched by name", func() {
+			result, err := apply(`
+- type: replace
+  path: /jobs/name=bosh/properties/director/name
+  value: other-director
+- type: replace
+  path: /jobs/0/templates/1
+  value: {name: registry}
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(`jobs:
+- name: bosh
+  properties:
+    director:
+      name: other-director
+  templates:
+  - name: director
+  - name: registry
+- name: other
+name: fake-deployment
+`))
+		})
+
+		It("creates the missing optional nodes", func() {
+			result, err := apply(`
+- type: replace
+  path: /jobs/name=other/properties?/nats/password
+  value: fake-password
+- type: replace
+  path: /jobs/name=extra?/instances
+  value: 1
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(ContainSubstring(`- name: other
+  properties:
+    nats:
+      password: fake-password
+- instances: 1
+  name: extra
+`))
+		})
+
+		It("appends to arrays with '-'", func() {
+			result, err := apply(`
+- type: replace
+  path: /jobs/name=bosh/templates/-
+  value: {name: registry}
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(ContainSubstring(`  templates:
+  - name: director
+  - name: nats
+  - name: registry
+`))
+		})
+
+		It("returns an error naming the operation when a map key is missing", func() {
+			_, err := apply(`
+- type: replace
+  path: /name
+  value: other-deployment
+- type: replace
+  path: /jobs/name=bosh/properties/nats/password
+  value: fake-password
+`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Applying operation 1 (replace '/jobs/name=bosh/properties/nats/password'): " +
+				"Expected to find a map key 'nats' for path '/jobs/name=bosh/properties/nats' (found map keys: 'director')"))
+		})
+
+		It("returns an error when no array item matches", func() {
+			_, err := apply(`
+- type: replace
+  path: /jobs/name=missing/instances
+  value: 1
+`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected to find exactly one matching array item for path '/jobs/name=missing' but found 0"))
+		})
+
+		It("returns an error when the node has another type", func() {
+			_, err := apply(`
+- type: replace
+  path: /name/0
+  value: x
+`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected to find an array at path '/name/0' but found 'string'"))
+		})
+	})
+
+	Describe("remove", func() {
+		It("removes map keys & array items", func() {
+			result, err := apply(`
+- type: remove
+  path: /jobs/name=bosh/templates/name=nats
+- type: remove
+  path: /jobs/name=bosh/properties
+- type: remove
+  path: /jobs/1
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(`jobs:
+- name: bosh
+  templates:
+  - name: director
+name: fake-deployment
+`))
+		})
+
+		It("ignores the missing optional nodes", func() {
+			result, err := apply(`
+- type: remove
+  path: /jobs/name=missing?/properties
+- type: remove
+  path: /update?/canaries
+`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(ContainSubstring("name: fake-deployment"))
+		})
+
+		It("returns an error when an array index is out of range", func() {
+			_, err := apply(`
+- type: remove
+  path: /jobs/5
+`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Applying operation 0 (remove '/jobs/5'): Expected to find array index 5 for path '/jobs/5' but found an array of length 2"))
+		})
+	})
+
+	Describe("ParseOps", func() {
+		It("returns an error naming the operation index when an operation is invalid", func() {
+			_, err := ParseOps([]byte(`
+- type: replace
+  path: /name
+  value: x
+- type: move
+  path: /name
+`))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Operation 1: Unknown operation type 'move' for path '/name', expected 'replace' or 'remove'"))
+		})
+
+		It("returns an error when a replace operation has no value", func() {
+			_, err := ParseOps([]byte("- type: replace\n  path: /name\n"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected replace operation for path '/name' to have a value"))
+		})
+
+		It("returns an error when a path is not absolute", func() {
+			_, err := ParseOps([]byte("- type: remove\n  path: name\n"))
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Expected path 'name' to start with '/'"))
+		})
+	})
+})
@@ -0,0 +1,12 @@
+package patch_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Common Patch Suite")
+}
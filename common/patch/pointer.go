@@ -0,0 +1,128 @@
+package patch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// Pointer is a path to a node of a YAML document, e.g. /jobs/name=bosh/properties/nats?/password
+//
+// Each segment is one of:
+//   - a map key
+//   - an array index, or '-' for after the last array item
+//   - 'key=value', the only array item that is a map with that key & value
+//
+// A segment suffixed with '?' is optional: when its node is missing, it is created by replace operations
+// and ignored by remove operations. The segments after an optional segment are optional too.
+// Within segments, '~1' stands for '/' and '~0' for '~'.
+type Pointer struct {
+	tokens []token
+}
+
+type token interface {
+	String() string
+}
+
+type keyToken struct {
+	key      string
+	optional bool
+}
+
+type indexToken struct {
+	index int
+}
+
+type afterLastIndexToken struct{}
+
+type matchingIndexToken struct {
+	key      string
+	value    string
+	optional bool
+}
+
+// NewPointerFromString parses a path such as /jobs/name=bosh/properties
+func NewPointerFromString(path string) (Pointer, error) {
+	if !strings.HasPrefix(path, "/") {
+		return Pointer{}, bosherr.Errorf("Expected path '%s' to start with '/'", path)
+	}
+
+	if path == "/" {
+		return Pointer{tokens: []token{}}, nil
+	}
+
+	tokens := []token{}
+	for _, segment := range strings.Split(path[1:], "/") {
+		optional := strings.HasSuffix(segment, "?")
+		segment = strings.TrimSuffix(segment, "?")
+		segment = strings.Replace(strings.Replace(segment, "~1", "/", -1), "~0", "~", -1)
+
+		if segment == "" {
+			return Pointer{}, bosherr.Errorf("Expected path '%s' to have no empty segments", path)
+		}
+
+		if segment == "-" {
+			tokens = append(tokens, afterLastIndexToken{})
+			continue
+		}
+
+		if index, err := strconv.Atoi(segment); err == nil {
+			if index < 0 {
+				return Pointer{}, bosherr.Errorf("Expected path '%s' to have no negative array indexes", path)
+			}
+			tokens = append(tokens, indexToken{index: index})
+			continue
+		}
+
+		if pieces := strings.SplitN(segment, "=", 2); len(pieces) == 2 {
+			tokens = append(tokens, matchingIndexToken{key: pieces[0], value: pieces[1], optional: optional})
+			continue
+		}
+
+		tokens = append(tokens, keyToken{key: segment, optional: optional})
+	}
+
+	return Pointer{tokens: tokens}, nil
+}
+
+// String returns the path of the pointer
+func (p Pointer) String() string {
+	if len(p.tokens) == 0 {
+		return "/"
+	}
+
+	segments := make([]string, len(p.tokens))
+	for i, t := range p.tokens {
+		segments[i] = t.String()
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func (t keyToken) String() string {
+	return escape(t.key) + optionalSuffix(t.optional)
+}
+
+func (t indexToken) String() string {
+	return strconv.Itoa(t.index)
+}
+
+func (t afterLastIndexToken) String() string {
+	return "-"
+}
+
+func (t matchingIndexToken) String() string {
+	return fmt.Sprintf("%s=%s%s", escape(t.key), escape(t.value), optionalSuffix(t.optional))
+}
+
+func escape(segment string) string {
+	return strings.Replace(strings.Replace(segment, "~", "~0", -1), "/", "~1", -1)
+}
+
+func optionalSuffix(optional bool) string {
+	if optional {
+		return "?"
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type Severity string
+
+const (
+	ErrorSeverity   Severity = "error"
+	WarningSeverity Severity = "warning"
+)
+
+// Problem is a problem of a manifest value, with the YAML path of the value (e.g. /jobs/0/name).
+// Error problems are returned as errors by the validators, usually in a bosherr.MultiError.
+type Problem struct {
+	Path     string
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) Error() string {
+	return p.Message
+}
+
+// Errorf returns an error problem of the value at the path
+func Errorf(path string, msg string, args ...interface{}) Problem {
+	return Problem{Path: path, Severity: ErrorSeverity, Message: fmt.Sprintf(msg, args...)}
+}
+
+// Warningf returns a warning problem of the value at the path
+func Warningf(path string, msg string, args ...interface{}) Problem {
+	return Problem{Path: path, Severity: WarningSeverity, Message: fmt.Sprintf(msg, args...)}
+}
+
+// Path returns the YAML path of the keys and indexes, e.g. Path("jobs", 0, "name") is /jobs/0/name.
+// A key may be a path itself, e.g. Path("/jobs/0", "name") is also /jobs/0/name.
+func Path(elements ...interface{}) string {
+	keys := make([]string, len(elements))
+	for i, element := range elements {
+		keys[i] = fmt.Sprintf("%v", element)
+	}
+	return path.Clean("/" + strings.Join(keys, "/"))
+}
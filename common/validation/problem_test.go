@@ -0,0 +1,32 @@
+package validation_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/common/validation"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+var _ = Describe("Problem", func() {
+	It("is an error with the message of the problem", func() {
+		err := bosherr.NewMultiError(Errorf("/jobs/0/name", "jobs[%d].name must be provided", 0))
+		Expect(err.Error()).To(Equal("jobs[0].name must be provided"))
+	})
+
+	It("has the severity of the problem", func() {
+		Expect(Errorf("/name", "fake-error")).To(Equal(Problem{Path: "/name", Severity: ErrorSeverity, Message: "fake-error"}))
+		Expect(Warningf("/name", "fake-%s", "warning")).To(Equal(Problem{Path: "/name", Severity: WarningSeverity, Message: "fake-warning"}))
+	})
+
+	Describe("Path", func() {
+		It("joins the keys and indexes", func() {
+			Expect(Path("jobs", 0, "templates", 1, "name")).To(Equal("/jobs/0/templates/1/name"))
+		})
+
+		It("joins paths", func() {
+			jobPath := Path("jobs", 0)
+			Expect(Path(jobPath, "name")).To(Equal("/jobs/0/name"))
+		})
+	})
+})
@@ -0,0 +1,78 @@
+package vars
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	PasswordType    = "password"
+	CertificateType = "certificate"
+	RSAType         = "rsa"
+	SSHType         = "ssh"
+)
+
+// Definition is a variable declared in the variables section of a manifest, which can be generated
+type Definition struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	Options DefinitionOptions `yaml:"options"`
+}
+
+// DefinitionOptions are the options of certificate definitions
+type DefinitionOptions struct {
+	IsCA             bool     `yaml:"is_ca"`
+	CA               string   `yaml:"ca"`
+	CommonName       string   `yaml:"common_name"`
+	AlternativeNames []string `yaml:"alternative_names"`
+}
+
+type definitionsManifest struct {
+	Variables []Definition `yaml:"variables"`
+}
+
+// ParseDefinitions parses the variables section of a manifest, such as:
+//
+//	variables:
+//	- name: default_ca
+//	  type: certificate
+//	  options: {is_ca: true, common_name: ca}
+//	- name: director_ssl
+//	  type: certificate
+//	  options: {ca: default_ca, common_name: 10.0.0.6, alternative_names: [10.0.0.6]}
+//	- name: nats_password
+//	  type: password
+//
+// A certificate is signed by the CA of another variable, e.g. an earlier definition, unless it is a CA itself.
+func ParseDefinitions(contents []byte) ([]Definition, error) {
+	manifest := definitionsManifest{}
+	err := yaml.Unmarshal(contents, &manifest)
+	if err != nil {
+		return []Definition{}, bosherr.WrapError(err, "Unmarshalling variable definitions")
+	}
+
+	names := map[string]bool{}
+	for i, definition := range manifest.Variables {
+		if definition.Name == "" {
+			return []Definition{}, bosherr.Errorf("Expected variables[%d].name to be provided", i)
+		}
+
+		if names[definition.Name] {
+			return []Definition{}, bosherr.Errorf("Expected variable '%s' to be defined only once", definition.Name)
+		}
+
+		switch definition.Type {
+		case PasswordType, RSAType, SSHType:
+		case CertificateType:
+			if !definition.Options.IsCA && definition.Options.CA == "" {
+				return []Definition{}, bosherr.Errorf("Expected certificate '%s' to be a CA or to have the name of its CA", definition.Name)
+			}
+		default:
+			return []Definition{}, bosherr.Errorf("Unknown type '%s' of variable '%s', expected 'password', 'certificate', 'rsa' or 'ssh'", definition.Type, definition.Name)
+		}
+
+		names[definition.Name] = true
+	}
+
+	return manifest.Variables, nil
+}
@@ -0,0 +1,62 @@
+package vars_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/common/vars"
+)
+
+var _ = Describe("ParseDefinitions", func() {
+	It("parses the variables section of the manifest", func() {
+		definitions, err := ParseDefinitions([]byte(`---
+name: fake-deployment
+variables:
+- name: default_ca
+  type: certificate
+  options: {is_ca: true, common_name: ca}
+- name: director_ssl
+  type: certificate
+  options:
+    ca: default_ca
+    common_name: 10.0.0.6
+    alternative_names: [10.0.0.6, director.example.com]
+- name: nats_password
+  type: password
+`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(definitions).To(Equal([]Definition{
+			{Name: "default_ca", Type: "certificate", Options: DefinitionOptions{IsCA: true, CommonName: "ca"}},
+			{Name: "director_ssl", Type: "certificate", Options: DefinitionOptions{
+				CA:               "default_ca",
+				CommonName:       "10.0.0.6",
+				AlternativeNames: []string{"10.0.0.6", "director.example.com"},
+			}},
+			{Name: "nats_password", Type: "password"},
+		}))
+	})
+
+	It("returns no definitions when the manifest has no variables section", func() {
+		definitions, err := ParseDefinitions([]byte("---\nname: fake-deployment\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(definitions).To(BeEmpty())
+	})
+
+	It("returns an error when the type is unknown", func() {
+		_, err := ParseDefinitions([]byte("variables:\n- {name: token, type: uuid}\n"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Unknown type 'uuid' of variable 'token', expected 'password', 'certificate', 'rsa' or 'ssh'"))
+	})
+
+	It("returns an error when a variable is defined twice", func() {
+		_, err := ParseDefinitions([]byte("variables:\n- {name: token, type: password}\n- {name: token, type: rsa}\n"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected variable 'token' to be defined only once"))
+	})
+
+	It("returns an error when a certificate is neither a CA nor has a CA", func() {
+		_, err := ParseDefinitions([]byte("variables:\n- {name: director_ssl, type: certificate}\n"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected certificate 'director_ssl' to be a CA or to have the name of its CA"))
+	})
+})
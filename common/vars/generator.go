@@ -0,0 +1,228 @@
+package vars
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const (
+	passwordLength      = 20
+	passwordChars       = "abcdefghijklmnopqrstuvwxyz0123456789"
+	keyBits             = 2048
+	certificateValidity = 365 * 24 * time.Hour
+)
+
+// Generator generates the values of variable definitions
+type Generator interface {
+	// Generate returns the value of the definition; certificates are signed by the CA found in the variables.
+	// Passwords are strings; certificates are maps with ca, certificate & private_key keys;
+	// rsa keys are maps with private_key & public_key keys, and ssh keys also have a public_key_fingerprint key.
+	Generate(definition Definition, variables Variables) (interface{}, error)
+}
+
+type generator struct{}
+
+func NewGenerator() Generator {
+	return generator{}
+}
+
+func (g generator) Generate(definition Definition, variables Variables) (interface{}, error) {
+	switch definition.Type {
+	case PasswordType:
+		return g.generatePassword()
+	case CertificateType:
+		return g.generateCertificate(definition, variables)
+	case RSAType:
+		return g.generateRSAKey()
+	case SSHType:
+		return g.generateSSHKey()
+	default:
+		return nil, bosherr.Errorf("Unknown type '%s' of variable '%s'", definition.Type, definition.Name)
+	}
+}
+
+func (g generator) generatePassword() (interface{}, error) {
+	password := make([]byte, passwordLength)
+	for i := range password {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordChars))))
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Generating random password")
+		}
+		password[i] = passwordChars[index.Int64()]
+	}
+	return string(password), nil
+}
+
+func (g generator) generateCertificate(definition Definition, variables Variables) (interface{}, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating private key")
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating serial number")
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Cloud Foundry"},
+			CommonName:   definition.Options.CommonName,
+		},
+		NotBefore:             now,
+		NotAfter:              now.Add(certificateValidity),
+		BasicConstraintsValid: true,
+	}
+
+	for _, name := range definition.Options.AlternativeNames {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+
+	parent := template
+	signerKey := key
+	if definition.Options.IsCA {
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		template.KeyUsage = x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+		parent, signerKey, err = g.findCA(definition, variables)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating certificate")
+	}
+
+	certificatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER}))
+	caPEM := certificatePEM
+	if !definition.Options.IsCA {
+		caPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: parent.Raw}))
+	}
+
+	return map[interface{}]interface{}{
+		"ca":          caPEM,
+		"certificate": certificatePEM,
+		"private_key": g.encodePrivateKey(key),
+	}, nil
+}
+
+func (g generator) findCA(definition Definition, variables Variables) (*x509.Certificate, *rsa.PrivateKey, error) {
+	caName := definition.Options.CA
+
+	certificatePEM, found := variables.Get(caName + ".certificate")
+	if !found {
+		return nil, nil, bosherr.Errorf("Expected to find the certificate of CA '%s'", caName)
+	}
+
+	privateKeyPEM, found := variables.Get(caName + ".private_key")
+	if !found {
+		return nil, nil, bosherr.Errorf("Expected to find the private key of CA '%s'", caName)
+	}
+
+	certificateBlock, _ := pem.Decode([]byte(fmt.Sprintf("%v", certificatePEM)))
+	if certificateBlock == nil {
+		return nil, nil, bosherr.Errorf("Expected the certificate of CA '%s' to be PEM encoded", caName)
+	}
+
+	certificate, err := x509.ParseCertificate(certificateBlock.Bytes)
+	if err != nil {
+		return nil, nil, bosherr.WrapErrorf(err, "Parsing the certificate of CA '%s'", caName)
+	}
+
+	privateKeyBlock, _ := pem.Decode([]byte(fmt.Sprintf("%v", privateKeyPEM)))
+	if privateKeyBlock == nil {
+		return nil, nil, bosherr.Errorf("Expected the private key of CA '%s' to be PEM encoded", caName)
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyBlock.Bytes)
+	if err != nil {
+		return nil, nil, bosherr.WrapErrorf(err, "Parsing the private key of CA '%s'", caName)
+	}
+
+	return certificate, privateKey, nil
+}
+
+func (g generator) generateRSAKey() (interface{}, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating private key")
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling public key")
+	}
+
+	return map[interface{}]interface{}{
+		"private_key": g.encodePrivateKey(key),
+		"public_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})),
+	}, nil
+}
+
+func (g generator) generateSSHKey() (interface{}, error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating private key")
+	}
+
+	// the OpenSSH wire format of an RSA public key (RFC 4253 section 6.6)
+	publicKeyWire := &bytes.Buffer{}
+	g.writeSSHString(publicKeyWire, []byte("ssh-rsa"))
+	g.writeSSHMPInt(publicKeyWire, big.NewInt(int64(key.PublicKey.E)))
+	g.writeSSHMPInt(publicKeyWire, key.PublicKey.N)
+
+	fingerprint := md5.Sum(publicKeyWire.Bytes())
+	fingerprintHex := make([]string, len(fingerprint))
+	for i, b := range fingerprint {
+		fingerprintHex[i] = fmt.Sprintf("%02x", b)
+	}
+
+	return map[interface{}]interface{}{
+		"private_key":            g.encodePrivateKey(key),
+		"public_key":             "ssh-rsa " + base64.StdEncoding.EncodeToString(publicKeyWire.Bytes()),
+		"public_key_fingerprint": strings.Join(fingerprintHex, ":"),
+	}, nil
+}
+
+func (g generator) writeSSHString(buffer *bytes.Buffer, value []byte) {
+	binary.Write(buffer, binary.BigEndian, uint32(len(value)))
+	buffer.Write(value)
+}
+
+func (g generator) writeSSHMPInt(buffer *bytes.Buffer, value *big.Int) {
+	valueBytes := value.Bytes()
+	if len(valueBytes) > 0 && valueBytes[0]&0x80 != 0 {
+		// positive mpints with the high bit set are prefixed with a zero byte
+		valueBytes = append([]byte{0}, valueBytes...)
+	}
+	g.writeSSHString(buffer, valueBytes)
+}
+
+func (g generator) encodePrivateKey(key *rsa.PrivateKey) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
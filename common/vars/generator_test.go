@@ -0,0 +1,104 @@
+package vars_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/common/vars"
+)
+
+var _ = Describe("Generator", func() {
+	var (
+		generator Generator
+	)
+
+	BeforeEach(func() {
+		generator = NewGenerator()
+	})
+
+	var parseCertificate = func(certificatePEM interface{}) *x509.Certificate {
+		block, _ := pem.Decode([]byte(certificatePEM.(string)))
+		Expect(block).ToNot(BeNil())
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+		return certificate
+	}
+
+	It("generates random passwords", func() {
+		password, err := generator.Generate(Definition{Name: "nats_password", Type: "password"}, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(password).To(MatchRegexp("^[a-z0-9]{20}$"))
+
+		otherPassword, err := generator.Generate(Definition{Name: "nats_password", Type: "password"}, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(otherPassword).ToNot(Equal(password))
+	})
+
+	It("generates certificates signed by the CA of the variables", func() {
+		ca, err := generator.Generate(Definition{
+			Name:    "default_ca",
+			Type:    "certificate",
+			Options: DefinitionOptions{IsCA: true, CommonName: "ca"},
+		}, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+
+		certificate, err := generator.Generate(Definition{
+			Name: "director_ssl",
+			Type: "certificate",
+			Options: DefinitionOptions{
+				CA:               "default_ca",
+				CommonName:       "director",
+				AlternativeNames: []string{"10.0.0.6", "director.example.com"},
+			},
+		}, Variables{"default_ca": ca})
+		Expect(err).ToNot(HaveOccurred())
+
+		caMap := ca.(map[interface{}]interface{})
+		certificateMap := certificate.(map[interface{}]interface{})
+		Expect(certificateMap["ca"]).To(Equal(caMap["certificate"]))
+		Expect(certificateMap["private_key"]).To(ContainSubstring("BEGIN RSA PRIVATE KEY"))
+
+		caCertificate := parseCertificate(caMap["certificate"])
+		Expect(caCertificate.IsCA).To(BeTrue())
+
+		directorCertificate := parseCertificate(certificateMap["certificate"])
+		Expect(directorCertificate.Subject.CommonName).To(Equal("director"))
+		Expect(directorCertificate.DNSNames).To(Equal([]string{"director.example.com"}))
+		Expect(directorCertificate.IPAddresses).To(HaveLen(1))
+		Expect(directorCertificate.IPAddresses[0].Equal(net.ParseIP("10.0.0.6"))).To(BeTrue())
+		Expect(directorCertificate.CheckSignatureFrom(caCertificate)).To(Succeed())
+	})
+
+	It("returns an error when the CA of a certificate is missing", func() {
+		_, err := generator.Generate(Definition{
+			Name:    "director_ssl",
+			Type:    "certificate",
+			Options: DefinitionOptions{CA: "default_ca"},
+		}, Variables{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Expected to find the certificate of CA 'default_ca'"))
+	})
+
+	It("generates rsa keys", func() {
+		key, err := generator.Generate(Definition{Name: "registry_key", Type: "rsa"}, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+
+		keyMap := key.(map[interface{}]interface{})
+		Expect(keyMap["private_key"]).To(ContainSubstring("BEGIN RSA PRIVATE KEY"))
+		Expect(keyMap["public_key"]).To(ContainSubstring("BEGIN PUBLIC KEY"))
+	})
+
+	It("generates ssh keys", func() {
+		key, err := generator.Generate(Definition{Name: "jumpbox_ssh", Type: "ssh"}, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+
+		keyMap := key.(map[interface{}]interface{})
+		Expect(keyMap["private_key"]).To(ContainSubstring("BEGIN RSA PRIVATE KEY"))
+		Expect(keyMap["public_key"]).To(MatchRegexp("^ssh-rsa AAAAB3NzaC1yc2E[A-Za-z0-9+/=]+$"))
+		Expect(keyMap["public_key_fingerprint"]).To(MatchRegexp("^([0-9a-f]{2}:){15}[0-9a-f]{2}$"))
+	})
+})
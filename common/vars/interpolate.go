@@ -0,0 +1,125 @@
+package vars
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	"gopkg.in/yaml.v2"
+)
+
+var placeholderRegexp = regexp.MustCompile(`\(\(([-\w\.]+)\)\)`)
+
+// Interpolate replaces the ((name)) placeholders of a YAML document with the values of the variables.
+// A placeholder making up a whole value is replaced by the variable value, which may be a list or a map.
+// A placeholder within a string is replaced by the variable value as a string.
+// A placeholder such as ((director_ssl.certificate)) refers to a key of a map variable value.
+// The undefined variables are all reported, with the YAML path of each placeholder (e.g. /jobs/0/properties/password).
+// The contents are returned unchanged if the document has no placeholders.
+func Interpolate(contents []byte, variables Variables) ([]byte, error) {
+	var document interface{}
+	err := yaml.Unmarshal(contents, &document)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling YAML document")
+	}
+
+	i := &interpolator{variables: variables}
+	result := i.interpolate(document, "")
+
+	if len(i.errors) > 0 {
+		sort.Strings(i.errors)
+		return nil, bosherr.Errorf("Interpolating variables:\n  %s", strings.Join(i.errors, "\n  "))
+	}
+
+	if !i.found {
+		return contents, nil
+	}
+
+	interpolatedContents, err := yaml.Marshal(result)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshalling interpolated YAML document")
+	}
+	return interpolatedContents, nil
+}
+
+type interpolator struct {
+	variables Variables
+	found     bool
+	errors    []string
+}
+
+func (i *interpolator) interpolate(node interface{}, path string) interface{} {
+	switch typedNode := node.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(typedNode))
+		for key, value := range typedNode {
+			valuePath := fmt.Sprintf("%s/%v", path, key)
+			if keyStr, ok := key.(string); ok {
+				// keys have to stay strings, to be used as map keys
+				key = i.interpolateWithinString(keyStr, valuePath)
+			}
+			result[key] = i.interpolate(value, valuePath)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(typedNode))
+		for index, value := range typedNode {
+			result[index] = i.interpolate(value, fmt.Sprintf("%s/%d", path, index))
+		}
+		return result
+
+	case string:
+		match := placeholderRegexp.FindStringSubmatchIndex(typedNode)
+		if match != nil && match[0] == 0 && match[1] == len(typedNode) {
+			i.found = true
+			name := typedNode[match[2]:match[3]]
+			value, found := i.variables.Get(name)
+			if !found {
+				i.addUndefined(name, path)
+				return typedNode
+			}
+			return value
+		}
+		return i.interpolateWithinString(typedNode, path)
+
+	default:
+		return node
+	}
+}
+
+func (i *interpolator) interpolateWithinString(str string, path string) string {
+	return placeholderRegexp.ReplaceAllStringFunc(str, func(placeholder string) string {
+		i.found = true
+		name := placeholderRegexp.FindStringSubmatch(placeholder)[1]
+
+		value, found := i.variables.Get(name)
+		if !found {
+			i.addUndefined(name, path)
+			return placeholder
+		}
+
+		switch value.(type) {
+		case nil:
+			return ""
+		case map[interface{}]interface{}, []interface{}:
+			i.errors = append(i.errors, fmt.Sprintf("Variable '%s' at '%s' is not a string, number or boolean and cannot be part of a string", name, i.displayPath(path)))
+			return placeholder
+		default:
+			return fmt.Sprintf("%v", value)
+		}
+	})
+}
+
+func (i *interpolator) addUndefined(name string, path string) {
+	i.errors = append(i.errors, fmt.Sprintf("Undefined variable '%s' at '%s'", name, i.displayPath(path)))
+}
+
+func (i *interpolator) displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
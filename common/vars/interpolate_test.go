@@ -0,0 +1,106 @@
+package vars_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/common/vars"
+)
+
+var _ = Describe("Interpolate", func() {
+	It("returns the contents unchanged when there are no placeholders", func() {
+		contents := []byte("---\nname: fake-name # comment\n")
+
+		result, err := Interpolate(contents, Variables{"name": "other-name"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(contents))
+	})
+
+	It("replaces whole values with the variable values", func() {
+		contents := []byte(`---
+name: ((name))
+instances: ((instances))
+properties: ((properties))
+`)
+
+		result, err := Interpolate(contents, Variables{
+			"name":      "fake-name",
+			"instances": 2,
+			"properties": map[interface{}]interface{}{
+				"key": "value",
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(result)).To(Equal(`instances: 2
+name: fake-name
+properties:
+  key: value
+`))
+	})
+
+	It("replaces placeholders within strings and keys", func() {
+		contents := []byte(`---
+url: https://((host)):((port))/path
+((key_name)): value
+`)
+
+		result, err := Interpolate(contents, Variables{
+			"host":     "10.0.0.1",
+			"port":     25555,
+			"key_name": "fake-key",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(result)).To(Equal(`fake-key: value
+url: https://10.0.0.1:25555/path
+`))
+	})
+
+	It("replaces placeholders within lists", func() {
+		contents := []byte(`---
+jobs:
+- name: ((job_name))
+  networks: [((network))]
+`)
+
+		result, err := Interpolate(contents, Variables{
+			"job_name": "fake-job",
+			"network":  "fake-network",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(result)).To(Equal(`jobs:
+- name: fake-job
+  networks:
+  - fake-network
+`))
+	})
+
+	It("returns an error listing the undefined variables with their path", func() {
+		contents := []byte(`---
+name: ((name))
+jobs:
+- properties:
+    password: ((password))
+    url: http://((host))/
+`)
+
+		_, err := Interpolate(contents, Variables{"name": "fake-name"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`Interpolating variables:
+  Undefined variable 'host' at '/jobs/0/properties/url'
+  Undefined variable 'password' at '/jobs/0/properties/password'`))
+	})
+
+	It("returns an error when a variable within a string is not a string, number or boolean", func() {
+		contents := []byte("---\nurl: http://((host))/\n")
+
+		_, err := Interpolate(contents, Variables{"host": []interface{}{"a", "b"}})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Variable 'host' at '/url' is not a string, number or boolean and cannot be part of a string"))
+	})
+
+	It("returns an error when the contents are not YAML", func() {
+		_, err := Interpolate([]byte("- not: [yaml"), Variables{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Unmarshalling YAML document"))
+	})
+})
@@ -0,0 +1,100 @@
+package vars
+
+import (
+	"os"
+	"path/filepath"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+)
+
+// Store keeps the generated variables in a YAML file of name: value pairs, e.g. given with --vars-store
+type Store interface {
+	// Generate generates the defined variables that are neither stored nor given, saving them in the store,
+	// and returns the stored variables merged with the given variables, the given values overriding the stored ones
+	Generate(definitions []Definition, variables Variables) (Variables, error)
+}
+
+type fileStore struct {
+	path      string
+	fs        boshsys.FileSystem
+	generator Generator
+}
+
+func NewFileStore(path string, fs boshsys.FileSystem, generator Generator) Store {
+	return fileStore{
+		path:      path,
+		fs:        fs,
+		generator: generator,
+	}
+}
+
+func (s fileStore) Generate(definitions []Definition, variables Variables) (Variables, error) {
+	storedVariables := Variables{}
+	if s.fs.FileExists(s.path) {
+		var err error
+		storedVariables, err = LoadFile(s.path, s.fs)
+		if err != nil {
+			return Variables{}, bosherr.WrapErrorf(err, "Loading variables store '%s'", s.path)
+		}
+	}
+
+	allVariables := storedVariables.Merge(variables)
+	generated := false
+
+	for _, definition := range definitions {
+		if _, found := allVariables[definition.Name]; found {
+			continue
+		}
+
+		value, err := s.generator.Generate(definition, allVariables)
+		if err != nil {
+			return Variables{}, bosherr.WrapErrorf(err, "Generating variable '%s'", definition.Name)
+		}
+
+		storedVariables[definition.Name] = value
+		allVariables[definition.Name] = value
+		generated = true
+	}
+
+	if generated {
+		err := s.save(storedVariables)
+		if err != nil {
+			return Variables{}, err
+		}
+	}
+
+	return allVariables, nil
+}
+
+func (s fileStore) save(storedVariables Variables) error {
+	contents, err := yaml.Marshal(storedVariables)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling variables store")
+	}
+
+	err = s.fs.MkdirAll(filepath.Dir(s.path), os.ModePerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating dir of variables store '%s'", s.path)
+	}
+
+	// the store holds secrets, so it is created only readable by its owner
+	file, err := s.fs.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing variables store '%s'", s.path)
+	}
+
+	_, err = file.Write(contents)
+	if err != nil {
+		file.Close()
+		return bosherr.WrapErrorf(err, "Writing variables store '%s'", s.path)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing variables store '%s'", s.path)
+	}
+
+	return nil
+}
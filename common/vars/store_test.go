@@ -0,0 +1,102 @@
+package vars_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	. "github.com/cloudfoundry/bosh-init/common/vars"
+)
+
+var _ = Describe("FileStore", func() {
+	var (
+		fs          *fakesys.FakeFileSystem
+		store       Store
+		definitions []Definition
+	)
+
+	BeforeEach(func() {
+		fs = fakesys.NewFakeFileSystem()
+		store = NewFileStore("/creds.yml", fs, NewGenerator())
+		definitions = []Definition{
+			{Name: "default_ca", Type: "certificate", Options: DefinitionOptions{IsCA: true, CommonName: "ca"}},
+			{Name: "director_ssl", Type: "certificate", Options: DefinitionOptions{CA: "default_ca", CommonName: "director"}},
+			{Name: "nats_password", Type: "password"},
+		}
+	})
+
+	var readStore = func() map[string]interface{} {
+		contents, err := fs.ReadFile("/creds.yml")
+		Expect(err).ToNot(HaveOccurred())
+
+		stored := map[string]interface{}{}
+		err = yaml.Unmarshal(contents, &stored)
+		Expect(err).ToNot(HaveOccurred())
+		return stored
+	}
+
+	It("generates the variables & writes them to the store, only readable by its owner", func() {
+		variables, err := store.Generate(definitions, Variables{"deployment_name": "fake-deployment"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(variables).To(HaveKey("default_ca"))
+		Expect(variables).To(HaveKey("director_ssl"))
+		Expect(variables).To(HaveKey("nats_password"))
+		Expect(variables["deployment_name"]).To(Equal("fake-deployment"))
+
+		stored := readStore()
+		Expect(stored).To(HaveLen(3))
+		Expect(stored["nats_password"]).To(Equal(variables["nats_password"]))
+		Expect(stored).ToNot(HaveKey("deployment_name"))
+
+		Expect(fs.GetFileTestStat("/creds.yml").FileMode).To(Equal(os.FileMode(0600)))
+	})
+
+	It("reuses the stored variables", func() {
+		firstVariables, err := store.Generate(definitions, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+
+		secondVariables, err := store.Generate(definitions, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secondVariables).To(Equal(firstVariables))
+	})
+
+	It("does not generate the given variables, which override the stored ones", func() {
+		fs.WriteFileString("/creds.yml", "nats_password: stored-password\n")
+
+		variables, err := store.Generate([]Definition{{Name: "nats_password", Type: "password"}}, Variables{"nats_password": "given-password"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(variables["nats_password"]).To(Equal("given-password"))
+		Expect(readStore()).To(Equal(map[string]interface{}{"nats_password": "stored-password"}))
+	})
+
+	It("creates the store only readable by its owner on the file system", func() {
+		dir, err := ioutil.TempDir("", "vars-store")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		storePath := filepath.Join(dir, "creds.yml")
+		osFS := boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone))
+		_, err = NewFileStore(storePath, osFS, NewGenerator()).Generate(definitions, Variables{})
+		Expect(err).ToNot(HaveOccurred())
+
+		info, err := os.Stat(storePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("returns an error when the store cannot be written", func() {
+		fs.OpenFileErr = errors.New("fake-write-error")
+
+		_, err := store.Generate(definitions, Variables{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Writing variables store '/creds.yml': fake-write-error"))
+	})
+})
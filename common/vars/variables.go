@@ -0,0 +1,109 @@
+package vars
+
+import (
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	"gopkg.in/yaml.v2"
+)
+
+// Variables are the values of the ((name)) placeholders of a manifest, by name
+type Variables map[string]interface{}
+
+// Merge returns the variables with the other variables added, the other values overriding the values with the same name
+func (v Variables) Merge(other Variables) Variables {
+	merged := make(Variables, len(v)+len(other))
+	for name, value := range v {
+		merged[name] = value
+	}
+	for name, value := range other {
+		merged[name] = value
+	}
+	return merged
+}
+
+// Get returns the value of the variable with the name.
+// A name such as 'director_ssl.certificate' also refers to the 'certificate' key of the map value of the 'director_ssl' variable.
+func (v Variables) Get(name string) (interface{}, bool) {
+	if value, found := v[name]; found {
+		return value, true
+	}
+
+	keys := strings.Split(name, ".")
+	value, found := v[keys[0]]
+	if !found {
+		return nil, false
+	}
+
+	for _, key := range keys[1:] {
+		valueMap, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		value, found = valueMap[key]
+		if !found {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// ParseKeyValue parses a variable from a name=value string; the value is kept as a string
+func ParseKeyValue(keyValue string) (Variables, error) {
+	pieces := strings.SplitN(keyValue, "=", 2)
+	if len(pieces) != 2 || pieces[0] == "" {
+		return Variables{}, bosherr.Errorf("Expected variable '%s' to be in the format 'name=value'", keyValue)
+	}
+	return Variables{pieces[0]: pieces[1]}, nil
+}
+
+// LoadFile reads the variables from a YAML file of name: value pairs
+func LoadFile(path string, fs boshsys.FileSystem) (Variables, error) {
+	contents, err := fs.ReadFile(path)
+	if err != nil {
+		return Variables{}, bosherr.WrapErrorf(err, "Reading variables file '%s'", path)
+	}
+
+	rawVariables := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(contents, &rawVariables)
+	if err != nil {
+		return Variables{}, bosherr.WrapErrorf(err, "Unmarshalling variables file '%s'", path)
+	}
+
+	variables := make(Variables, len(rawVariables))
+	for name, value := range rawVariables {
+		nameStr, ok := name.(string)
+		if !ok {
+			return Variables{}, bosherr.Errorf("Variables file '%s' contains non-string name %#v", path, name)
+		}
+		variables[nameStr] = value
+	}
+	return variables, nil
+}
+
+// LoadEnv returns the variables of the environment variables named PREFIX_name, from a list of NAME=value strings (see os.Environ).
+// The values are parsed as YAML, so that they may be numbers, booleans, lists or maps.
+func LoadEnv(prefix string, environ []string) (Variables, error) {
+	variables := Variables{}
+	for _, env := range environ {
+		pieces := strings.SplitN(env, "=", 2)
+		if len(pieces) != 2 || !strings.HasPrefix(pieces[0], prefix+"_") {
+			continue
+		}
+
+		name := strings.TrimPrefix(pieces[0], prefix+"_")
+		if name == "" {
+			continue
+		}
+
+		var value interface{}
+		err := yaml.Unmarshal([]byte(pieces[1]), &value)
+		if err != nil {
+			return Variables{}, bosherr.WrapErrorf(err, "Unmarshalling variable '%s' from environment variable '%s'", name, pieces[0])
+		}
+		variables[name] = value
+	}
+	return variables, nil
+}
@@ -0,0 +1,125 @@
+package vars_test
+
+import (
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/common/vars"
+)
+
+var _ = Describe("Variables", func() {
+	Describe("Merge", func() {
+		It("overrides the values with the same name", func() {
+			variables := Variables{"a": "1", "b": "2"}
+
+			merged := variables.Merge(Variables{"b": "3", "c": "4"})
+			Expect(merged).To(Equal(Variables{"a": "1", "b": "3", "c": "4"}))
+			Expect(variables).To(Equal(Variables{"a": "1", "b": "2"}))
+		})
+	})
+
+	Describe("Get", func() {
+		variables := Variables{
+			"password": "fake-password",
+			"director_ssl": map[interface{}]interface{}{
+				"certificate": "fake-certificate",
+			},
+			"dotted.name": "fake-value",
+		}
+
+		It("returns the values of the variables & of the keys of their map values", func() {
+			value, found := variables.Get("password")
+			Expect(found).To(BeTrue())
+			Expect(value).To(Equal("fake-password"))
+
+			value, found = variables.Get("director_ssl.certificate")
+			Expect(found).To(BeTrue())
+			Expect(value).To(Equal("fake-certificate"))
+
+			value, found = variables.Get("dotted.name")
+			Expect(found).To(BeTrue())
+			Expect(value).To(Equal("fake-value"))
+		})
+
+		It("returns false when the variable or the key is missing", func() {
+			_, found := variables.Get("director_ssl.private_key")
+			Expect(found).To(BeFalse())
+
+			_, found = variables.Get("password.length")
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("ParseKeyValue", func() {
+		It("keeps the value as a string, including any '='", func() {
+			variables, err := ParseKeyValue("password=abc=123")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(variables).To(Equal(Variables{"password": "abc=123"}))
+		})
+
+		It("returns an error when there is no name", func() {
+			_, err := ParseKeyValue("=value")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Expected variable '=value' to be in the format 'name=value'"))
+		})
+	})
+
+	Describe("LoadFile", func() {
+		var fs *fakesys.FakeFileSystem
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+		})
+
+		It("reads the variables from the YAML file", func() {
+			fs.WriteFileString("/vars.yml", "name: fake-name\nport: 25555\nlist: [a, b]\n")
+
+			variables, err := LoadFile("/vars.yml", fs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(variables).To(Equal(Variables{
+				"name": "fake-name",
+				"port": 25555,
+				"list": []interface{}{"a", "b"},
+			}))
+		})
+
+		It("returns an error when the file cannot be read", func() {
+			_, err := LoadFile("/missing.yml", fs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Reading variables file '/missing.yml'"))
+		})
+
+		It("returns an error when the file is not a YAML map", func() {
+			fs.WriteFileString("/vars.yml", "- a\n- b\n")
+
+			_, err := LoadFile("/vars.yml", fs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling variables file '/vars.yml'"))
+		})
+	})
+
+	Describe("LoadEnv", func() {
+		It("returns the values of the environment variables with the prefix, parsed as YAML", func() {
+			variables, err := LoadEnv("BOSH_VAR", []string{
+				"BOSH_VAR_name=fake-name",
+				"BOSH_VAR_port=25555",
+				"BOSH_VAR_url=http://host/?a=b",
+				"BOSH_VARIABLE=ignored",
+				"HOME=/root",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(variables).To(Equal(Variables{
+				"name": "fake-name",
+				"port": 25555,
+				"url":  "http://host/?a=b",
+			}))
+		})
+
+		It("returns an error when a value is not YAML", func() {
+			_, err := LoadEnv("BOSH_VAR", []string{"BOSH_VAR_list=[a"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling variable 'list' from environment variable 'BOSH_VAR_list'"))
+		})
+	})
+})
@@ -34,6 +34,7 @@ var _ = Describe("DeploymentRepo", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedConfig := DeploymentState{
+				SchemaVersion:       CurrentDeploymentStateSchemaVersion,
 				DirectorID:          "fake-uuid-0",
 				CurrentManifestSHA1: "fake-manifest-sha1",
 			}
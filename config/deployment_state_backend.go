@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// DeploymentStateBackend stores the contents of a remote deployment state with optimistic concurrency:
+// every stored version has an ETag, and a write or delete of a version that is not the stored one anymore is rejected
+type DeploymentStateBackend interface {
+	// URL returns the location of the deployment state, without credentials
+	URL() string
+
+	// Read returns the stored contents & their version, exists is false when nothing is stored
+	Read() (contents []byte, version string, exists bool, err error)
+
+	// Write stores the contents if the stored version is the given one, or if nothing is stored when the version is empty
+	Write(contents []byte, version string) (newVersion string, err error)
+
+	// Delete removes the stored contents if the stored version is the given one
+	Delete(version string) error
+
+	// Sibling returns the backend storing the contents at the location of this one followed by the suffix
+	Sibling(suffix string) DeploymentStateBackend
+}
+
+// StaleDeploymentStateError is returned when the deployment state was changed by another run since it was read
+type StaleDeploymentStateError struct {
+	URL string
+}
+
+func (e StaleDeploymentStateError) Error() string {
+	return fmt.Sprintf("Deployment state '%s' was changed by another run since it was read", e.URL)
+}
+
+type httpDeploymentStateBackend struct {
+	url       *url.URL
+	client    *http.Client
+	authorize func(request *http.Request, payload []byte)
+	logger    boshlog.Logger
+	logTag    string
+}
+
+// NewWebDAVDeploymentStateBackend stores the deployment state at the given http(s) URL of a WebDAV server.
+// The credentials of the basic authentication are taken from the user info of the URL.
+func NewWebDAVDeploymentStateBackend(stateURL string, client *http.Client, logger boshlog.Logger) (DeploymentStateBackend, error) {
+	parsedURL, err := url.Parse(stateURL)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing deployment state URL")
+	}
+
+	userInfo := parsedURL.User
+	parsedURL.User = nil
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, bosherr.Errorf("Deployment state URL '%s' of a WebDAV server must be an http or https URL", parsedURL)
+	}
+
+	return &httpDeploymentStateBackend{
+		url:    parsedURL,
+		client: client,
+		authorize: func(request *http.Request, _ []byte) {
+			if userInfo != nil {
+				password, _ := userInfo.Password()
+				request.SetBasicAuth(userInfo.Username(), password)
+			}
+		},
+		logger: logger,
+		logTag: "webDAVDeploymentStateBackend",
+	}, nil
+}
+
+func (b *httpDeploymentStateBackend) URL() string {
+	return b.url.String()
+}
+
+func (b *httpDeploymentStateBackend) Read() ([]byte, string, bool, error) {
+	response, err := b.do("GET", nil, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, "", false, nil
+	}
+
+	contents, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", false, bosherr.WrapErrorf(err, "Reading deployment state '%s'", b.URL())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, "", false, b.statusError("Reading", response, contents)
+	}
+
+	version := response.Header.Get("ETag")
+	if version == "" {
+		return nil, "", false, bosherr.Errorf("Reading deployment state '%s': the server did not return an ETag", b.URL())
+	}
+
+	return contents, version, true, nil
+}
+
+func (b *httpDeploymentStateBackend) Write(contents []byte, version string) (string, error) {
+	headers := map[string]string{"If-None-Match": "*"}
+	if version != "" {
+		headers = map[string]string{"If-Match": version}
+	}
+
+	response, err := b.do("PUT", contents, headers)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusPreconditionFailed {
+		return "", StaleDeploymentStateError{URL: b.URL()}
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return "", b.statusError("Writing", response, body)
+	}
+
+	newVersion := response.Header.Get("ETag")
+	if newVersion == "" {
+		// not every server returns the ETag of a written resource
+		_, newVersion, _, err = b.Read()
+		if err != nil {
+			return "", bosherr.WrapError(err, "Reading the version of the written deployment state")
+		}
+	}
+
+	return newVersion, nil
+}
+
+func (b *httpDeploymentStateBackend) Delete(version string) error {
+	headers := map[string]string{}
+	if version != "" {
+		headers["If-Match"] = version
+	}
+
+	response, err := b.do("DELETE", nil, headers)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil
+	case response.StatusCode == http.StatusPreconditionFailed:
+		return StaleDeploymentStateError{URL: b.URL()}
+	case response.StatusCode < 200 || response.StatusCode >= 300:
+		body, _ := ioutil.ReadAll(response.Body)
+		return b.statusError("Deleting", response, body)
+	}
+
+	return nil
+}
+
+func (b *httpDeploymentStateBackend) Sibling(suffix string) DeploymentStateBackend {
+	siblingURL := *b.url
+	siblingURL.Path += suffix
+
+	return &httpDeploymentStateBackend{
+		url:       &siblingURL,
+		client:    b.client,
+		authorize: b.authorize,
+		logger:    b.logger,
+		logTag:    b.logTag,
+	}
+}
+
+func (b *httpDeploymentStateBackend) do(method string, payload []byte, headers map[string]string) (*http.Response, error) {
+	b.logger.Debug(b.logTag, "Sending %s request to '%s'", method, b.URL())
+
+	request, err := http.NewRequest(method, b.URL(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating %s request", method)
+	}
+
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	b.authorize(request, payload)
+
+	response, err := b.client.Do(request)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Performing %s request to '%s'", method, b.URL())
+	}
+
+	return response, nil
+}
+
+func (b *httpDeploymentStateBackend) statusError(action string, response *http.Response, body []byte) error {
+	return bosherr.Errorf("%s deployment state '%s': unexpected status '%s': %s", action, b.URL(), response.Status, body)
+}
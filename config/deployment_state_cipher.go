@@ -0,0 +1,193 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+const (
+	DeploymentStateKeyEnv     = "BOSH_INIT_STATE_KEY"
+	DeploymentStateKeyFileEnv = "BOSH_INIT_STATE_KEY_FILE"
+
+	deploymentStateEncryptionVersion   = 1
+	deploymentStateEncryptionAlgorithm = "aes-256-gcm"
+	deploymentStateKeySize             = 32
+)
+
+// DeploymentStateCipher encrypts deployment states with envelope encryption:
+// every encryption uses a new data key, which is stored encrypted with the key of the cipher
+type DeploymentStateCipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(contents []byte) ([]byte, error)
+}
+
+// DeploymentStateEncryption is the header identifying an encrypted deployment state
+type DeploymentStateEncryption struct {
+	Version   int    `json:"version"`
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+}
+
+type encryptedDeploymentState struct {
+	Encryption   *DeploymentStateEncryption `json:"encryption"`
+	EncryptedKey []byte                     `json:"encrypted_key"`
+	Ciphertext   []byte                     `json:"ciphertext"`
+}
+
+type aesGCMDeploymentStateCipher struct {
+	key   []byte
+	keyID string
+}
+
+func NewDeploymentStateCipher(key []byte) (DeploymentStateCipher, error) {
+	if len(key) != deploymentStateKeySize {
+		return nil, bosherr.Errorf("Deployment state key must be %d bytes long, but is %d bytes long", deploymentStateKeySize, len(key))
+	}
+
+	keyHash := sha256.Sum256(key)
+	return &aesGCMDeploymentStateCipher{
+		key:   key,
+		keyID: hex.EncodeToString(keyHash[:8]),
+	}, nil
+}
+
+// LoadDeploymentStateCipher returns the cipher of the base64 encoded key given by the BOSH_INIT_STATE_KEY
+// environment variable, or stored in the file given by BOSH_INIT_STATE_KEY_FILE, or nil when neither is set
+func LoadDeploymentStateCipher(fs boshsys.FileSystem) (DeploymentStateCipher, error) {
+	encodedKey := os.Getenv(DeploymentStateKeyEnv)
+	if encodedKey == "" {
+		keyFilePath := os.Getenv(DeploymentStateKeyFileEnv)
+		if keyFilePath == "" {
+			return nil, nil
+		}
+
+		keyFileContents, err := fs.ReadFileString(keyFilePath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading deployment state key file '%s'", keyFilePath)
+		}
+		encodedKey = keyFileContents
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Decoding base64 deployment state key")
+	}
+
+	return NewDeploymentStateCipher(key)
+}
+
+// IsEncryptedDeploymentState returns true if the contents have the header of an encrypted deployment state
+func IsEncryptedDeploymentState(contents []byte) bool {
+	encryptedState := encryptedDeploymentState{}
+	err := json.Unmarshal(contents, &encryptedState)
+	return err == nil && encryptedState.Encryption != nil
+}
+
+func (c *aesGCMDeploymentStateCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, deploymentStateKeySize)
+	_, err := io.ReadFull(rand.Reader, dataKey)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating data key")
+	}
+
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Encrypting deployment state")
+	}
+
+	encryptedKey, err := seal(c.key, dataKey)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Encrypting data key")
+	}
+
+	return json.MarshalIndent(encryptedDeploymentState{
+		Encryption: &DeploymentStateEncryption{
+			Version:   deploymentStateEncryptionVersion,
+			Algorithm: deploymentStateEncryptionAlgorithm,
+			KeyID:     c.keyID,
+		},
+		EncryptedKey: encryptedKey,
+		Ciphertext:   ciphertext,
+	}, "", "    ")
+}
+
+func (c *aesGCMDeploymentStateCipher) Decrypt(contents []byte) ([]byte, error) {
+	encryptedState := encryptedDeploymentState{}
+	err := json.Unmarshal(contents, &encryptedState)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling encrypted deployment state")
+	}
+
+	encryption := encryptedState.Encryption
+	if encryption == nil {
+		return nil, bosherr.Error("Deployment state is not encrypted")
+	}
+
+	if encryption.Version != deploymentStateEncryptionVersion || encryption.Algorithm != deploymentStateEncryptionAlgorithm {
+		return nil, bosherr.Errorf("Deployment state is encrypted with unsupported version %d of algorithm '%s'", encryption.Version, encryption.Algorithm)
+	}
+
+	if encryption.KeyID != c.keyID {
+		return nil, bosherr.Errorf("Deployment state is encrypted with key '%s', not with the given key '%s'", encryption.KeyID, c.keyID)
+	}
+
+	dataKey, err := open(c.key, encryptedState.EncryptedKey)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Decrypting data key")
+	}
+
+	plaintext, err := open(dataKey, encryptedState.Ciphertext)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Decrypting deployment state")
+	}
+
+	return plaintext, nil
+}
+
+// seal encrypts the plaintext with AES-GCM, prefixing the ciphertext with its random nonce
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating nonce")
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, bosherr.Error("Ciphertext is too short")
+	}
+
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating AES cipher")
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,150 @@
+package config_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("DeploymentStateCipher", func() {
+	var (
+		key    []byte
+		cipher DeploymentStateCipher
+	)
+
+	BeforeEach(func() {
+		key = bytes.Repeat([]byte{1}, 32)
+
+		var err error
+		cipher, err = NewDeploymentStateCipher(key)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("decrypts what it encrypted", func() {
+		encrypted, err := cipher.Encrypt([]byte(`{"current_vm_cid":"fake-vm-cid"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(encrypted)).ToNot(ContainSubstring("fake-vm-cid"))
+
+		decrypted, err := cipher.Decrypt(encrypted)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(decrypted)).To(Equal(`{"current_vm_cid":"fake-vm-cid"}`))
+	})
+
+	It("gives the encrypted contents a header identifying them", func() {
+		encrypted, err := cipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(IsEncryptedDeploymentState(encrypted)).To(BeTrue())
+		Expect(IsEncryptedDeploymentState([]byte(`{"director_id":"fake-director-id"}`))).To(BeFalse())
+
+		header := struct {
+			Encryption DeploymentStateEncryption `json:"encryption"`
+		}{}
+		Expect(json.Unmarshal(encrypted, &header)).To(Succeed())
+		Expect(header.Encryption.Version).To(Equal(1))
+		Expect(header.Encryption.Algorithm).To(Equal("aes-256-gcm"))
+		Expect(header.Encryption.KeyID).To(HaveLen(16))
+	})
+
+	It("uses a new data key for every encryption", func() {
+		first, err := cipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+		second, err := cipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first).ToNot(Equal(second))
+	})
+
+	It("returns an error when the contents were encrypted with another key", func() {
+		otherCipher, err := NewDeploymentStateCipher(bytes.Repeat([]byte{2}, 32))
+		Expect(err).ToNot(HaveOccurred())
+
+		encrypted, err := otherCipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = cipher.Decrypt(encrypted)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(MatchRegexp("Deployment state is encrypted with key '[0-9a-f]{16}', not with the given key '[0-9a-f]{16}'"))
+	})
+
+	It("returns an error when the encrypted contents were tampered with", func() {
+		encrypted, err := cipher.Encrypt([]byte(`{}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		encryptedState := map[string]interface{}{}
+		Expect(json.Unmarshal(encrypted, &encryptedState)).To(Succeed())
+		encryptedState["ciphertext"] = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0}, 40))
+		tampered, err := json.Marshal(encryptedState)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = cipher.Decrypt(tampered)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Decrypting deployment state"))
+	})
+
+	It("returns an error unless the key is 32 bytes long", func() {
+		_, err := NewDeploymentStateCipher([]byte("short"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Deployment state key must be 32 bytes long, but is 5 bytes long"))
+	})
+
+	Describe("LoadDeploymentStateCipher", func() {
+		var fs *fakesys.FakeFileSystem
+
+		BeforeEach(func() {
+			fs = fakesys.NewFakeFileSystem()
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("BOSH_INIT_STATE_KEY")
+			os.Unsetenv("BOSH_INIT_STATE_KEY_FILE")
+		})
+
+		It("returns no cipher when no key is given", func() {
+			os.Unsetenv("BOSH_INIT_STATE_KEY")
+			os.Unsetenv("BOSH_INIT_STATE_KEY_FILE")
+
+			cipher, err := LoadDeploymentStateCipher(fs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cipher).To(BeNil())
+		})
+
+		It("uses the key of the environment variable", func() {
+			os.Setenv("BOSH_INIT_STATE_KEY", base64.StdEncoding.EncodeToString(key))
+
+			loadedCipher, err := LoadDeploymentStateCipher(fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			encrypted, err := loadedCipher.Encrypt([]byte(`{}`))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cipher.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("uses the key of the key file", func() {
+			fs.WriteFileString("/path/to/state.key", base64.StdEncoding.EncodeToString(key)+"\n")
+			os.Setenv("BOSH_INIT_STATE_KEY_FILE", "/path/to/state.key")
+
+			loadedCipher, err := LoadDeploymentStateCipher(fs)
+			Expect(err).ToNot(HaveOccurred())
+
+			encrypted, err := loadedCipher.Encrypt([]byte(`{}`))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cipher.Decrypt(encrypted)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when the key is not base64 encoded", func() {
+			os.Setenv("BOSH_INIT_STATE_KEY", "not base64!")
+
+			_, err := LoadDeploymentStateCipher(fs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Decoding base64 deployment state key"))
+		})
+	})
+})
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// deploymentStateHistorySize is the number of versions of a deployment state kept in its history
+const deploymentStateHistorySize = 10
+
+// versionFileTimeFormat names the version files so that they sort chronologically
+const versionFileTimeFormat = "20060102T150405.000000000Z"
+
+// DeploymentStateVersion is a copy of the deployment state as written by a bosh-init command
+type DeploymentStateVersion struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Command   string          `json:"command"`
+	State     DeploymentState `json:"state"`
+}
+
+func DeploymentStateHistoryPath(deploymentStatePath string) string {
+	return deploymentStatePath + ".history"
+}
+
+func (s *fileSystemDeploymentStateService) RecordVersion(command string) error {
+	if !s.fs.FileExists(s.configPath) {
+		s.logger.Debug(s.logTag, "Skipping the history of deployment state file '%s': the file does not exist", s.configPath)
+		return nil
+	}
+
+	deploymentStateFileContents, err := s.readDecrypted(s.configPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading deployment state file '%s'", s.configPath)
+	}
+
+	version := DeploymentStateVersion{
+		CreatedAt: time.Now().UTC(),
+		Command:   command,
+	}
+	err = json.Unmarshal(deploymentStateFileContents, &version.State)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Unmarshalling deployment state file '%s'", s.configPath)
+	}
+
+	versionContents, err := json.MarshalIndent(version, "", "    ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling deployment state version")
+	}
+
+	versionPath := filepath.Join(DeploymentStateHistoryPath(s.configPath), version.CreatedAt.Format(versionFileTimeFormat)+".json")
+	err = s.writeEncrypted(versionPath, versionContents)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing deployment state version file '%s'", versionPath)
+	}
+
+	s.logger.Debug(s.logTag, "Recorded version '%s' of deployment state file '%s'", versionPath, s.configPath)
+
+	return s.pruneHistory()
+}
+
+func (s *fileSystemDeploymentStateService) History() ([]DeploymentStateVersion, error) {
+	versionPaths, err := s.versionPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]DeploymentStateVersion, len(versionPaths))
+	for i, versionPath := range versionPaths {
+		versionContents, err := s.readDecrypted(versionPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Reading deployment state version file '%s'", versionPath)
+		}
+
+		// most recent first
+		version := &versions[len(versionPaths)-1-i]
+		err = s.unmarshalVersion(versionContents, version)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Unmarshalling deployment state version file '%s'", versionPath)
+		}
+	}
+
+	return versions, nil
+}
+
+func (s *fileSystemDeploymentStateService) Restore(version DeploymentStateVersion) error {
+	s.logger.Info(s.logTag, "Restoring deployment state file '%s' to the version written by '%s' at %s", s.configPath, version.Command, version.CreatedAt.Format(time.RFC3339))
+
+	return s.Save(version.State)
+}
+
+// unmarshalVersion unmarshals a version of the history, migrating its deployment state to the current schema version
+func (s *fileSystemDeploymentStateService) unmarshalVersion(contents []byte, version *DeploymentStateVersion) error {
+	rawVersion := struct {
+		CreatedAt time.Time       `json:"created_at"`
+		Command   string          `json:"command"`
+		State     json.RawMessage `json:"state"`
+	}{}
+	err := json.Unmarshal(contents, &rawVersion)
+	if err != nil {
+		return err
+	}
+
+	version.CreatedAt = rawVersion.CreatedAt
+	version.Command = rawVersion.Command
+	if rawVersion.State == nil {
+		return nil
+	}
+
+	stateContents, err := MigrateDeploymentState(rawVersion.State, DeploymentStateMigrations)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(stateContents, &version.State)
+}
+
+// versionPaths returns the paths of the version files of the history, the oldest first
+func (s *fileSystemDeploymentStateService) versionPaths() ([]string, error) {
+	historyPath := DeploymentStateHistoryPath(s.configPath)
+	if !s.fs.FileExists(historyPath) {
+		return []string{}, nil
+	}
+
+	versionPaths := []string{}
+	err := s.fs.Walk(historyPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Dir(path) == historyPath && strings.HasSuffix(path, ".json") {
+			versionPaths = append(versionPaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing deployment state history '%s'", historyPath)
+	}
+
+	sort.Strings(versionPaths)
+	return versionPaths, nil
+}
+
+func (s *fileSystemDeploymentStateService) pruneHistory() error {
+	versionPaths, err := s.versionPaths()
+	if err != nil {
+		return err
+	}
+
+	for len(versionPaths) > deploymentStateHistorySize {
+		err = s.fs.RemoveAll(versionPaths[0])
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing deployment state version file '%s'", versionPaths[0])
+		}
+		versionPaths = versionPaths[1:]
+	}
+
+	return nil
+}
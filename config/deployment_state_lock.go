@@ -0,0 +1,171 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"syscall"
+	"time"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// DeploymentStateLock records the bosh-init run holding the lock of a deployment state file
+type DeploymentStateLock struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	User      string    `json:"user"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (l DeploymentStateLock) String() string {
+	return fmt.Sprintf("pid %d of user '%s' on host '%s', started at %s", l.PID, l.User, l.Host, l.StartedAt.Format(time.RFC3339))
+}
+
+func DeploymentStateLockPath(deploymentStatePath string) string {
+	return deploymentStatePath + ".lock"
+}
+
+func (s *fileSystemDeploymentStateService) Lock(forceUnlock bool) error {
+	lockPath := DeploymentStateLockPath(s.configPath)
+
+	if s.fs.FileExists(lockPath) {
+		// an unreadable or empty lock is held by a run that crashed while writing it, or by an unknown run
+		holder, err := s.readLock(lockPath)
+		switch {
+		case err != nil && forceUnlock:
+			s.logger.Warn(s.logTag, "Forcing the unlock of deployment state file '%s' held by an unreadable lock: %s", s.configPath, err.Error())
+		case err != nil:
+			return bosherr.WrapErrorf(err, "Deployment state file '%s' is locked by an unknown run. "+
+				"If no run is in progress, run again with --force-unlock", s.configPath)
+		case forceUnlock:
+			s.logger.Warn(s.logTag, "Forcing the unlock of deployment state file '%s' held by %s", s.configPath, holder)
+		case holder.isStale():
+			s.logger.Warn(s.logTag, "Removing the stale lock of deployment state file '%s' held by %s", s.configPath, holder)
+		default:
+			return bosherr.Errorf("Deployment state file '%s' is locked by %s. "+
+				"If that run is not in progress anymore, run again with --force-unlock", s.configPath, holder)
+		}
+
+		err = s.fs.RemoveAll(lockPath)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Removing deployment state lock file '%s'", lockPath)
+		}
+	}
+
+	lock := newDeploymentStateLock()
+	lockContents, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling deployment state lock")
+	}
+
+	// O_EXCL makes the creation fail when another run took the lock since it was checked
+	lockFile, err := s.fs.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(0644))
+	if err != nil {
+		if os.IsExist(err) {
+			return bosherr.Errorf("Deployment state file '%s' is locked by another run", s.configPath)
+		}
+		return bosherr.WrapErrorf(err, "Creating deployment state lock file '%s'", lockPath)
+	}
+	defer lockFile.Close()
+
+	_, err = lockFile.Write(lockContents)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing deployment state lock file '%s'", lockPath)
+	}
+
+	s.logger.Debug(s.logTag, "Locked deployment state file '%s' for %s", s.configPath, lock)
+	return nil
+}
+
+func (s *fileSystemDeploymentStateService) Unlock() error {
+	lockPath := DeploymentStateLockPath(s.configPath)
+
+	if !s.fs.FileExists(lockPath) {
+		return nil
+	}
+
+	// the lock may have been forcibly taken by another run since this run took it
+	holder, err := s.readLock(lockPath)
+	if err != nil {
+		s.logger.Warn(s.logTag, "Keeping the lock of deployment state file '%s', as it cannot be read: %s", s.configPath, err.Error())
+		return nil
+	}
+	if !holder.isCurrent() {
+		s.logger.Warn(s.logTag, "Keeping the lock of deployment state file '%s', as it is held by %s", s.configPath, holder)
+		return nil
+	}
+
+	err = s.fs.RemoveAll(lockPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing deployment state lock file '%s'", lockPath)
+	}
+
+	s.logger.Debug(s.logTag, "Unlocked deployment state file '%s'", s.configPath)
+	return nil
+}
+
+func (s *fileSystemDeploymentStateService) readLock(lockPath string) (DeploymentStateLock, error) {
+	lock := DeploymentStateLock{}
+
+	lockContents, err := s.fs.ReadFile(lockPath)
+	if err != nil {
+		return lock, bosherr.WrapErrorf(err, "Reading deployment state lock file '%s'", lockPath)
+	}
+
+	err = json.Unmarshal(lockContents, &lock)
+	if err != nil {
+		return lock, bosherr.WrapErrorf(err, "Unmarshalling deployment state lock file '%s'", lockPath)
+	}
+
+	return lock, nil
+}
+
+// isStale returns true if the lock is held by a process of this host that is not running anymore.
+// Locks held on other hosts are never stale, as their processes cannot be checked.
+func (l DeploymentStateLock) isStale() bool {
+	host, err := os.Hostname()
+	if err != nil || l.Host != host {
+		return false
+	}
+
+	if l.PID <= 0 {
+		return true
+	}
+
+	process, err := os.FindProcess(l.PID)
+	if err != nil {
+		return true
+	}
+
+	// signal 0 only checks that the process exists
+	err = process.Signal(syscall.Signal(0))
+	return err != nil && err != syscall.EPERM
+}
+
+// isCurrent returns true if the lock is held by this run
+func (l DeploymentStateLock) isCurrent() bool {
+	current := newDeploymentStateLock()
+	return l.PID == current.PID && l.Host == current.Host
+}
+
+// newDeploymentStateLock returns the lock held by this run
+func newDeploymentStateLock() DeploymentStateLock {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	username := os.Getenv("USER")
+	if currentUser, err := user.Current(); err == nil {
+		username = currentUser.Username
+	}
+
+	return DeploymentStateLock{
+		PID:       os.Getpid(),
+		Host:      host,
+		User:      username,
+		StartedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// CurrentDeploymentStateSchemaVersion is the schema version of the deployment states written by this bosh-init.
+// Changing the schema requires adding the migration from the previous version to DeploymentStateMigrations.
+const CurrentDeploymentStateSchemaVersion = 1
+
+// DeploymentStateMigration migrates the JSON of a deployment state from the schema version before its own
+type DeploymentStateMigration struct {
+	SchemaVersion int
+	Description   string
+	Migrate       func(deploymentState map[string]interface{}) error
+}
+
+// DeploymentStateMigrations are the migrations of the deployment state schema, ordered by schema version.
+// They work on the decoded JSON, as older states may not fit the current DeploymentState anymore.
+var DeploymentStateMigrations = []DeploymentStateMigration{
+	{
+		SchemaVersion: 1,
+		Description:   "initialize the missing lists of release ids, disks, stemcells & releases",
+		Migrate:       migrateDeploymentStateToV1,
+	},
+}
+
+// NewerDeploymentStateError is returned for a deployment state written by a newer bosh-init
+type NewerDeploymentStateError struct {
+	SchemaVersion          int
+	SupportedSchemaVersion int
+}
+
+func (e NewerDeploymentStateError) Error() string {
+	return fmt.Sprintf("Deployment state has schema version %d, but this bosh-init only supports up to schema version %d. "+
+		"It was written by a newer bosh-init: upgrade bosh-init to use it", e.SchemaVersion, e.SupportedSchemaVersion)
+}
+
+// DeploymentStateBackupPath returns the path of the backup of a deployment state taken before migrating it from the schema version
+func DeploymentStateBackupPath(deploymentStatePath string, schemaVersion int) string {
+	return fmt.Sprintf("%s.schema-v%d.backup", deploymentStatePath, schemaVersion)
+}
+
+// DeploymentStateSchemaVersion returns the schema version of the JSON of a deployment state, 0 when it has none
+func DeploymentStateSchemaVersion(contents []byte) (int, error) {
+	versioned := struct {
+		SchemaVersion int `json:"schema_version"`
+	}{}
+
+	err := json.Unmarshal(contents, &versioned)
+	if err != nil {
+		return 0, bosherr.WrapError(err, "Unmarshalling deployment state schema version")
+	}
+
+	return versioned.SchemaVersion, nil
+}
+
+// MigrateDeploymentState migrates the JSON of a deployment state to the schema version of the last migration
+func MigrateDeploymentState(contents []byte, migrations []DeploymentStateMigration) ([]byte, error) {
+	schemaVersion, err := DeploymentStateSchemaVersion(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	supportedSchemaVersion := 0
+	if len(migrations) > 0 {
+		supportedSchemaVersion = migrations[len(migrations)-1].SchemaVersion
+	}
+
+	if schemaVersion > supportedSchemaVersion {
+		return nil, NewerDeploymentStateError{SchemaVersion: schemaVersion, SupportedSchemaVersion: supportedSchemaVersion}
+	}
+
+	if schemaVersion == supportedSchemaVersion {
+		return contents, nil
+	}
+
+	deploymentState := map[string]interface{}{}
+	err = json.Unmarshal(contents, &deploymentState)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshalling deployment state")
+	}
+
+	for _, migration := range migrations {
+		if migration.SchemaVersion <= schemaVersion {
+			continue
+		}
+
+		err = migration.Migrate(deploymentState)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Migrating deployment state to schema version %d (%s)", migration.SchemaVersion, migration.Description)
+		}
+		deploymentState["schema_version"] = migration.SchemaVersion
+	}
+
+	return json.MarshalIndent(deploymentState, "", "    ")
+}
+
+func migrateDeploymentStateToV1(deploymentState map[string]interface{}) error {
+	for _, key := range []string{"current_release_ids", "disks", "stemcells", "releases"} {
+		if deploymentState[key] == nil {
+			deploymentState[key] = []interface{}{}
+		}
+	}
+	return nil
+}
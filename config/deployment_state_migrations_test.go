@@ -0,0 +1,131 @@
+package config_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"errors"
+)
+
+var _ = Describe("DeploymentStateMigrations", func() {
+	It("are ordered by schema version, ending with the current schema version", func() {
+		for i, migration := range DeploymentStateMigrations {
+			Expect(migration.SchemaVersion).To(Equal(i + 1))
+			Expect(migration.Description).ToNot(BeEmpty())
+		}
+		Expect(DeploymentStateMigrations[len(DeploymentStateMigrations)-1].SchemaVersion).To(Equal(CurrentDeploymentStateSchemaVersion))
+	})
+
+	Describe("schema version 1", func() {
+		It("initializes the missing lists of release ids, disks, stemcells & releases", func() {
+			migrated, err := MigrateDeploymentState([]byte(`{
+				"director_id": "fake-director-id",
+				"current_release_ids": null,
+				"disks": [{"id": "fake-disk-id"}]
+			}`), DeploymentStateMigrations[:1])
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(migrated).To(MatchJSON(`{
+				"schema_version": 1,
+				"director_id": "fake-director-id",
+				"current_release_ids": [],
+				"disks": [{"id": "fake-disk-id"}],
+				"stemcells": [],
+				"releases": []
+			}`))
+		})
+	})
+})
+
+var _ = Describe("MigrateDeploymentState", func() {
+	var (
+		migrations []DeploymentStateMigration
+		applied    []int
+	)
+
+	BeforeEach(func() {
+		applied = []int{}
+		migration := func(schemaVersion int) DeploymentStateMigration {
+			return DeploymentStateMigration{
+				SchemaVersion: schemaVersion,
+				Description:   "fake-migration",
+				Migrate: func(deploymentState map[string]interface{}) error {
+					Expect(deploymentState["schema_version"]).To(BeNumerically("==", schemaVersion-1))
+					applied = append(applied, schemaVersion)
+					return nil
+				},
+			}
+		}
+		migrations = []DeploymentStateMigration{migration(1), migration(2), migration(3)}
+	})
+
+	It("applies the migrations after the schema version of the deployment state, in order", func() {
+		migrated, err := MigrateDeploymentState([]byte(`{"schema_version": 1, "director_id": "fake-director-id"}`), migrations)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(Equal([]int{2, 3}))
+		Expect(migrated).To(MatchJSON(`{"schema_version": 3, "director_id": "fake-director-id"}`))
+	})
+
+	It("applies every migration to a deployment state without schema version", func() {
+		migrations[0].Migrate = func(deploymentState map[string]interface{}) error {
+			Expect(deploymentState).ToNot(HaveKey("schema_version"))
+			applied = append(applied, 1)
+			return nil
+		}
+
+		_, err := MigrateDeploymentState([]byte(`{}`), migrations)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(applied).To(Equal([]int{1, 2, 3}))
+	})
+
+	It("returns the deployment state unchanged when it has the last schema version", func() {
+		contents := []byte(`{"schema_version":3,"director_id":"fake-director-id"}`)
+
+		migrated, err := MigrateDeploymentState(contents, migrations)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(migrated).To(Equal(contents))
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("refuses a deployment state written by a newer bosh-init", func() {
+		_, err := MigrateDeploymentState([]byte(`{"schema_version": 4}`), migrations)
+		Expect(err).To(Equal(NewerDeploymentStateError{SchemaVersion: 4, SupportedSchemaVersion: 3}))
+		Expect(err.Error()).To(ContainSubstring("upgrade bosh-init"))
+		Expect(applied).To(BeEmpty())
+	})
+
+	It("returns an error when a migration fails", func() {
+		migrations[1].Migrate = func(map[string]interface{}) error {
+			return errors.New("fake-migrate-error")
+		}
+
+		_, err := MigrateDeploymentState([]byte(`{"schema_version": 1}`), migrations)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Migrating deployment state to schema version 2 (fake-migration)"))
+		Expect(err.Error()).To(ContainSubstring("fake-migrate-error"))
+	})
+
+	It("returns an error when the deployment state is invalid", func() {
+		_, err := MigrateDeploymentState([]byte(`{`), migrations)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("DeploymentStateSchemaVersion", func() {
+	It("is 0 for a deployment state without schema version", func() {
+		schemaVersion, err := DeploymentStateSchemaVersion([]byte(`{"director_id": "fake-director-id"}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schemaVersion).To(Equal(0))
+	})
+
+	It("is the schema version of a marshalled deployment state", func() {
+		contents, err := json.Marshal(DeploymentState{SchemaVersion: 7})
+		Expect(err).ToNot(HaveOccurred())
+
+		schemaVersion, err := DeploymentStateSchemaVersion(contents)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schemaVersion).To(Equal(7))
+	})
+})
@@ -1,10 +1,23 @@
 package config
 
 import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	"github.com/pivotal-golang/clock"
 )
 
 type DeploymentState struct {
+	SchemaVersion       int              `json:"schema_version"`
 	DirectorID          string           `json:"director_id"`
 	InstallationID      string           `json:"installation_id"`
 	CurrentVMCID        string           `json:"current_vm_cid"`
@@ -32,9 +45,16 @@ type DiskRecord struct {
 }
 
 type ReleaseRecord struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	ID       string              `json:"id"`
+	Name     string              `json:"name"`
+	Version  string              `json:"version"`
+	Jobs     []FingerprintRecord `json:"jobs,omitempty"`
+	Packages []FingerprintRecord `json:"packages,omitempty"`
+}
+
+type FingerprintRecord struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
 }
 
 type DeploymentStateService interface {
@@ -43,4 +63,95 @@ type DeploymentStateService interface {
 	Load() (DeploymentState, error)
 	Save(DeploymentState) error
 	Cleanup() error
+
+	// Lock takes the lock of the deployment state for the whole run, failing if another run holds it,
+	// unless the lock is stale or forceUnlock is true
+	Lock(forceUnlock bool) error
+	Unlock() error
+
+	// RecordVersion keeps a copy of the current deployment state in its history, tagged with the command that wrote it
+	RecordVersion(command string) error
+	// History returns the recorded versions of the deployment state, the most recent first
+	History() ([]DeploymentStateVersion, error)
+	// Restore replaces the deployment state with a version of its History
+	Restore(version DeploymentStateVersion) error
+
+	// Reencrypt rewrites the deployment state, and its history, encrypted with the key of the service or in clear
+	Reencrypt(encrypted bool) error
+}
+
+// RedactedDeploymentState returns a copy of the deployment state that can be logged,
+// without the cloud properties of the disks, which may hold credentials
+func RedactedDeploymentState(deploymentState DeploymentState) DeploymentState {
+	redactedDisks := make([]DiskRecord, len(deploymentState.Disks))
+	for i, disk := range deploymentState.Disks {
+		redactedDisks[i] = disk
+		if disk.CloudProperties != nil {
+			redactedCloudProperties := biproperty.Map{}
+			for key := range disk.CloudProperties {
+				redactedCloudProperties[key] = "<redacted>"
+			}
+			redactedDisks[i].CloudProperties = redactedCloudProperties
+		}
+	}
+	deploymentState.Disks = redactedDisks
+	return deploymentState
+}
+
+// IsDeploymentStateURL returns true if the deployment state location is the URL of a remote backend rather than a file path
+func IsDeploymentStateURL(deploymentStateLocation string) bool {
+	for _, prefix := range []string{"http://", "https://", "s3://"} {
+		if strings.HasPrefix(deploymentStateLocation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeploymentStateKey identifies a deployment state in the workspace, for the files kept there on its behalf
+func DeploymentStateKey(deploymentStateLocation string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(deploymentStateLocation)))
+}
+
+// RenderedTemplatesPath returns the workspace dir of the job templates rendered & applied with a deployment state.
+// They are kept by deployment state rather than by deployment name, as unrelated deployments can have the same name.
+func RenderedTemplatesPath(workspaceRootPath string, deploymentStateLocation string) string {
+	return filepath.Join(workspaceRootPath, "rendered_templates", DeploymentStateKey(deploymentStateLocation))
+}
+
+// NewDeploymentStateService returns the service of the deployment state at the given location:
+// a file path, the http(s) URL of a WebDAV server, or the s3://<bucket>/<key> URL of an S3-compatible bucket.
+// The credentials of an S3 bucket default to the AWS_ACCESS_KEY_ID & AWS_SECRET_ACCESS_KEY environment variables,
+// and the deployment state is encrypted when BOSH_INIT_STATE_KEY or BOSH_INIT_STATE_KEY_FILE is set.
+func NewDeploymentStateService(
+	deploymentStateLocation string,
+	fs boshsys.FileSystem,
+	uuidGenerator boshuuid.Generator,
+	logger boshlog.Logger,
+) (DeploymentStateService, error) {
+	cipher, err := LoadDeploymentStateCipher(fs)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Loading deployment state key")
+	}
+
+	if !IsDeploymentStateURL(deploymentStateLocation) {
+		return NewFileSystemDeploymentStateServiceWithCipher(fs, uuidGenerator, cipher, logger, deploymentStateLocation), nil
+	}
+
+	var backend DeploymentStateBackend
+
+	if strings.HasPrefix(deploymentStateLocation, "s3://") {
+		var s3Config S3DeploymentStateConfig
+		s3Config, err = ParseS3DeploymentStateURL(deploymentStateLocation, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+		if err == nil {
+			backend, err = NewS3DeploymentStateBackend(s3Config, http.DefaultClient, clock.NewClock(), logger)
+		}
+	} else {
+		backend, err = NewWebDAVDeploymentStateBackend(deploymentStateLocation, http.DefaultClient, logger)
+	}
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating deployment state backend")
+	}
+
+	return NewRemoteDeploymentStateService(backend, uuidGenerator, cipher, logger), nil
 }
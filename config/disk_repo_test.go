@@ -46,7 +46,8 @@ var _ = Describe("DiskRepo", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedConfig := DeploymentState{
-				DirectorID: "fake-uuid-0",
+				SchemaVersion: CurrentDeploymentStateSchemaVersion,
+				DirectorID:    "fake-uuid-0",
 				Disks: []DiskRecord{
 					{
 						ID:              "fake-uuid-1",
@@ -253,6 +254,7 @@ var _ = Describe("DiskRepo", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedConfig := DeploymentState{
+				SchemaVersion: CurrentDeploymentStateSchemaVersion,
 				DirectorID:    "fake-uuid-0",
 				CurrentDiskID: "",
 			}
@@ -0,0 +1,96 @@
+package fakes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// FakeDeploymentStateServer is a local stand-in for the WebDAV servers & S3-compatible buckets storing deployment states:
+// it keeps the objects in memory, gives them ETags & honors the If-Match & If-None-Match conditions
+type FakeDeploymentStateServer struct {
+	Objects  map[string][]byte
+	ETags    map[string]string
+	Requests []*http.Request
+
+	// OmitWriteETag makes PUT responses omit the ETag, like some WebDAV servers do
+	OmitWriteETag bool
+
+	lock     sync.Mutex
+	versions int
+}
+
+func NewFakeDeploymentStateServer() *FakeDeploymentStateServer {
+	return &FakeDeploymentStateServer{
+		Objects: map[string][]byte{},
+		ETags:   map[string]string{},
+	}
+}
+
+// SetObject stores the contents at the path as if another client wrote them, returning their ETag
+func (s *FakeDeploymentStateServer) SetObject(path string, contents []byte) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.store(path, contents)
+}
+
+func (s *FakeDeploymentStateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.Requests = append(s.Requests, r)
+	path := r.URL.Path
+	etag, exists := s.ETags[path]
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(s.Objects[path])
+
+	case "PUT":
+		contents, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		newETag := s.store(path, contents)
+		if !s.OmitWriteETag {
+			w.Header().Set("ETag", newETag)
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	case "DELETE":
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.Objects, path)
+		delete(s.ETags, path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *FakeDeploymentStateServer) store(path string, contents []byte) string {
+	s.versions++
+	s.Objects[path] = contents
+	s.ETags[path] = fmt.Sprintf(`"version-%d"`, s.versions)
+	return s.ETags[path]
+}
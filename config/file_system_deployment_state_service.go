@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -16,15 +17,23 @@ type fileSystemDeploymentStateService struct {
 	configPath    string
 	fs            boshsys.FileSystem
 	uuidGenerator boshuuid.Generator
+	cipher        DeploymentStateCipher
 	logger        boshlog.Logger
 	logTag        string
 }
 
 func NewFileSystemDeploymentStateService(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger boshlog.Logger, deploymentStatePath string) DeploymentStateService {
+	return NewFileSystemDeploymentStateServiceWithCipher(fs, uuidGenerator, nil, logger, deploymentStatePath)
+}
+
+// NewFileSystemDeploymentStateServiceWithCipher returns a service that encrypts the deployment state & its history
+// with the given cipher, unless the cipher is nil
+func NewFileSystemDeploymentStateServiceWithCipher(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, cipher DeploymentStateCipher, logger boshlog.Logger, deploymentStatePath string) DeploymentStateService {
 	return &fileSystemDeploymentStateService{
 		configPath:    deploymentStatePath,
 		fs:            fs,
 		uuidGenerator: uuidGenerator,
+		cipher:        cipher,
 		logger:        logger,
 		logTag:        "config",
 	}
@@ -35,6 +44,11 @@ func DeploymentStatePath(deploymentManifestPath string) string {
 	return filepath.Join(filepath.Dir(deploymentManifestPath), fmt.Sprintf("%s-state.json", baseFileName))
 }
 
+// CompiledPackagesIndexPath returns the path of the index of the packages compiled for the deployment of a local deployment state
+func CompiledPackagesIndexPath(deploymentStatePath string) string {
+	return deploymentStatePath + ".compiled-packages"
+}
+
 func (s *fileSystemDeploymentStateService) Path() string {
 	return s.configPath
 }
@@ -53,16 +67,28 @@ func (s *fileSystemDeploymentStateService) Load() (DeploymentState, error) {
 	deploymentState := &DeploymentState{}
 
 	if s.fs.FileExists(s.configPath) {
-		deploymentStateFileContents, err := s.fs.ReadFile(s.configPath)
+		deploymentStateFileContents, err := s.readDecrypted(s.configPath)
 		if err != nil {
 			return DeploymentState{}, bosherr.WrapErrorf(err, "Reading deployment state file '%s'", s.configPath)
 		}
-		s.logger.Debug(s.logTag, "Deployment File Contents %#s", deploymentStateFileContents)
+
+		schemaVersion, err := DeploymentStateSchemaVersion(deploymentStateFileContents)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapErrorf(err, "Unmarshalling deployment state file '%s'", s.configPath)
+		}
+
+		if schemaVersion != CurrentDeploymentStateSchemaVersion {
+			deploymentStateFileContents, err = s.migrate(deploymentStateFileContents, schemaVersion)
+			if err != nil {
+				return DeploymentState{}, bosherr.WrapErrorf(err, "Migrating deployment state file '%s'", s.configPath)
+			}
+		}
 
 		err = json.Unmarshal(deploymentStateFileContents, deploymentState)
 		if err != nil {
 			return DeploymentState{}, bosherr.WrapErrorf(err, "Unmarshalling deployment state file '%s'", s.configPath)
 		}
+		s.logger.Debug(s.logTag, "Loaded deployment state %#v", RedactedDeploymentState(*deploymentState))
 	}
 
 	err := s.initDefaults(deploymentState)
@@ -78,14 +104,15 @@ func (s *fileSystemDeploymentStateService) Save(deploymentState DeploymentState)
 		panic("configPath not yet set!")
 	}
 
-	s.logger.Debug(s.logTag, "Saving deployment state %#v", deploymentState)
+	deploymentState.SchemaVersion = CurrentDeploymentStateSchemaVersion
+	s.logger.Debug(s.logTag, "Saving deployment state %#v", RedactedDeploymentState(deploymentState))
 
 	jsonContent, err := json.MarshalIndent(deploymentState, "", "    ")
 	if err != nil {
 		return bosherr.WrapError(err, "Marshalling deployment state into JSON")
 	}
 
-	err = s.fs.WriteFile(s.configPath, jsonContent)
+	err = s.writeEncrypted(s.configPath, jsonContent)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Writing deployment state file '%s'", s.configPath)
 	}
@@ -93,6 +120,142 @@ func (s *fileSystemDeploymentStateService) Save(deploymentState DeploymentState)
 	return nil
 }
 
+// migrate migrates the deployment state to the current schema version,
+// after backing up the deployment state file as it is to a file named after its schema version
+func (s *fileSystemDeploymentStateService) migrate(contents []byte, schemaVersion int) ([]byte, error) {
+	migratedContents, err := MigrateDeploymentState(contents, DeploymentStateMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	backupPath := DeploymentStateBackupPath(s.configPath, schemaVersion)
+	storedContents, err := s.fs.ReadFile(s.configPath)
+	if err == nil {
+		err = s.writeAtomically(backupPath, storedContents)
+	}
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Backing up deployment state file to '%s'", backupPath)
+	}
+
+	err = s.writeEncrypted(s.configPath, migratedContents)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Writing deployment state file '%s'", s.configPath)
+	}
+
+	s.logger.Info(s.logTag, "Migrated deployment state file '%s' from schema version %d to %d, backed up to '%s'",
+		s.configPath, schemaVersion, CurrentDeploymentStateSchemaVersion, backupPath)
+
+	return migratedContents, nil
+}
+
+// readDecrypted reads the file, decrypting it when it is encrypted
+func (s *fileSystemDeploymentStateService) readDecrypted(path string) ([]byte, error) {
+	contents, err := s.fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsEncryptedDeploymentState(contents) {
+		return contents, nil
+	}
+
+	if s.cipher == nil {
+		return nil, bosherr.Errorf("File '%s' is encrypted: set %s or %s to its key", path, DeploymentStateKeyEnv, DeploymentStateKeyFileEnv)
+	}
+
+	return s.cipher.Decrypt(contents)
+}
+
+// writeEncrypted writes the contents atomically, encrypted when the service has a cipher
+func (s *fileSystemDeploymentStateService) writeEncrypted(path string, contents []byte) error {
+	if s.cipher != nil {
+		encryptedContents, err := s.cipher.Encrypt(contents)
+		if err != nil {
+			return err
+		}
+		contents = encryptedContents
+	}
+
+	return s.writeAtomically(path, contents)
+}
+
+// Reencrypt rewrites the deployment state & the versions of its history, encrypted or in clear
+func (s *fileSystemDeploymentStateService) Reencrypt(encrypted bool) error {
+	if encrypted && s.cipher == nil {
+		return bosherr.Errorf("Encrypting deployment state file '%s' requires %s or %s to be set", s.configPath, DeploymentStateKeyEnv, DeploymentStateKeyFileEnv)
+	}
+
+	paths, err := s.versionPaths()
+	if err != nil {
+		return err
+	}
+	if s.fs.FileExists(s.configPath) {
+		paths = append(paths, s.configPath)
+	}
+
+	for _, path := range paths {
+		contents, err := s.readDecrypted(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading file '%s'", path)
+		}
+
+		if encrypted {
+			contents, err = s.cipher.Encrypt(contents)
+			if err != nil {
+				return bosherr.WrapErrorf(err, "Encrypting file '%s'", path)
+			}
+		}
+
+		err = s.writeAtomically(path, contents)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Writing file '%s'", path)
+		}
+	}
+
+	return nil
+}
+
+// writeAtomically writes the contents to a temporary file next to the given path, then renames it over the path,
+// so that a crash or a full disk never leaves a truncated file behind
+func (s *fileSystemDeploymentStateService) writeAtomically(path string, contents []byte) error {
+	err := s.fs.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating dir '%s'", filepath.Dir(path))
+	}
+
+	tempPath := path + ".tmp"
+	tempFile, err := s.fs.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating temporary file '%s'", tempPath)
+	}
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		// boshsys.File does not expose Sync, but the files of the os file system do
+		if syncer, ok := tempFile.(interface {
+			Sync() error
+		}); ok {
+			err = syncer.Sync()
+		}
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.fs.RemoveAll(tempPath)
+		return bosherr.WrapErrorf(err, "Writing temporary file '%s'", tempPath)
+	}
+
+	err = s.fs.Rename(tempPath, path)
+	if err != nil {
+		s.fs.RemoveAll(tempPath)
+		return bosherr.WrapErrorf(err, "Renaming temporary file '%s' to '%s'", tempPath, path)
+	}
+
+	return nil
+}
+
 func (s *fileSystemDeploymentStateService) initDefaults(deploymentState *DeploymentState) error {
 	if deploymentState.DirectorID == "" {
 		uuid, err := s.uuidGenerator.Generate()
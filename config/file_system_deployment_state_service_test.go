@@ -7,6 +7,9 @@ import (
 
 	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"time"
 
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -38,6 +41,12 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 		})
 	})
 
+	Describe("CompiledPackagesIndexPath", func() {
+		It("is next to the deployment state file", func() {
+			Expect(CompiledPackagesIndexPath("/path/to/some-manifest-state.json")).To(Equal("/path/to/some-manifest-state.json.compiled-packages"))
+		})
+	})
+
 	Describe("Exists", func() {
 		It("returns true if the config file exists", func() {
 			fakeFs.WriteFileString(deploymentStatePath, "")
@@ -117,6 +126,41 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 			})
 		})
 
+		Context("when the deployment state has an older schema version", func() {
+			BeforeEach(func() {
+				fakeFs.WriteFileString(deploymentStatePath, `{"director_id":"fake-director-id","disks":null}`)
+			})
+
+			It("backs up the deployment state file & rewrites it with the current schema version", func() {
+				deploymentState, err := service.Load()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(deploymentState.DirectorID).To(Equal("fake-director-id"))
+				Expect(deploymentState.Disks).To(Equal([]DiskRecord{}))
+
+				backup, err := fakeFs.ReadFileString("/some/deployment.json.schema-v0.backup")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(backup).To(Equal(`{"director_id":"fake-director-id","disks":null}`))
+
+				contents, err := fakeFs.ReadFile(deploymentStatePath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(DeploymentStateSchemaVersion(contents)).To(Equal(CurrentDeploymentStateSchemaVersion))
+			})
+		})
+
+		Context("when the deployment state was written by a newer bosh-init", func() {
+			It("returns an error & leaves the deployment state file untouched", func() {
+				fakeFs.WriteFileString(deploymentStatePath, `{"schema_version":999,"director_id":"fake-director-id"}`)
+
+				_, err := service.Load()
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("schema version 999"))
+				Expect(err.Error()).To(ContainSubstring("upgrade bosh-init"))
+
+				Expect(fakeFs.ReadFileString(deploymentStatePath)).To(Equal(`{"schema_version":999,"director_id":"fake-director-id"}`))
+				Expect(fakeFs.FileExists("/some/deployment.json.schema-v999.backup")).To(BeFalse())
+			})
+		})
+
 		Context("when the config is invalid", func() {
 			It("returns an empty DeploymentState and an error", func() {
 				fakeFs.WriteFileString(deploymentStatePath, "some invalid content")
@@ -156,7 +200,8 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 
 			deploymentStateFileContents, err := fakeFs.ReadFileString(deploymentStatePath)
 			deploymentState := DeploymentState{
-				DirectorID: "deadbeef",
+				SchemaVersion: CurrentDeploymentStateSchemaVersion,
+				DirectorID:    "deadbeef",
 				Stemcells: []StemcellRecord{
 					{
 						Name:    "fake-stemcell-name",
@@ -179,9 +224,40 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 			Expect(deploymentStateFileContents).To(Equal(string(expectedDeploymentStateFileContents)))
 		})
 
+		It("writes a temporary file & renames it over the deployment file", func() {
+			fakeFs.WriteFileString(deploymentStatePath, `{"director_id":"old-director-id"}`)
+
+			err := service.Save(DeploymentState{DirectorID: "new-director-id"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFs.RenameOldPaths).To(Equal([]string{"/some/deployment.json.tmp"}))
+			Expect(fakeFs.RenameNewPaths).To(Equal([]string{"/some/deployment.json"}))
+			Expect(fakeFs.FileExists("/some/deployment.json.tmp")).To(BeFalse())
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.DirectorID).To(Equal("new-director-id"))
+		})
+
+		Context("when the temporary file cannot be renamed", func() {
+			BeforeEach(func() {
+				fakeFs.WriteFileString(deploymentStatePath, `{"director_id":"old-director-id"}`)
+				fakeFs.RenameError = errors.New("fake-rename-error")
+			})
+
+			It("leaves the deployment file untouched & removes the temporary file", func() {
+				err := service.Save(DeploymentState{DirectorID: "new-director-id"})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-rename-error"))
+
+				Expect(fakeFs.ReadFileString(deploymentStatePath)).To(Equal(`{"director_id":"old-director-id"}`))
+				Expect(fakeFs.FileExists("/some/deployment.json.tmp")).To(BeFalse())
+			})
+		})
+
 		Context("when the deployment file cannot be written", func() {
 			BeforeEach(func() {
-				fakeFs.WriteFileError = errors.New("")
+				fakeFs.OpenFileErr = errors.New("fake-open-error")
 			})
 
 			It("returns an error when it cannot write the config file", func() {
@@ -218,4 +294,330 @@ var _ = Describe("fileSystemDeploymentStateService", func() {
 			Expect(err.Error()).To(ContainSubstring("Could not do that Dave"))
 		})
 	})
+
+	Describe("Lock", func() {
+		var (
+			lockPath = "/some/deployment.json.lock"
+			host     string
+		)
+
+		BeforeEach(func() {
+			var err error
+			host, err = os.Hostname()
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		var writeLock = func(lock DeploymentStateLock) {
+			lockContents, err := json.Marshal(lock)
+			Expect(err).ToNot(HaveOccurred())
+			fakeFs.WriteFile(lockPath, lockContents)
+		}
+
+		It("records the pid, host, user & start time of the run in the lock file", func() {
+			err := service.Lock(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			lockContents, err := fakeFs.ReadFile(lockPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			lock := DeploymentStateLock{}
+			Expect(json.Unmarshal(lockContents, &lock)).To(Succeed())
+			Expect(lock.PID).To(Equal(os.Getpid()))
+			Expect(lock.Host).To(Equal(host))
+			Expect(lock.StartedAt).ToNot(BeZero())
+		})
+
+		It("returns an error reporting the holder when another run holds the lock", func() {
+			writeLock(DeploymentStateLock{PID: 1234, Host: "fake-other-host", User: "fake-user", StartedAt: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)})
+
+			err := service.Lock(false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment state file '/some/deployment.json' is locked by pid 1234 of user 'fake-user' on host 'fake-other-host', started at 2016-01-02T03:04:05Z"))
+			Expect(err.Error()).To(ContainSubstring("--force-unlock"))
+		})
+
+		It("returns an error when a running process of this host holds the lock", func() {
+			writeLock(DeploymentStateLock{PID: os.Getppid(), Host: host, User: "fake-user"})
+
+			err := service.Lock(false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is locked by"))
+		})
+
+		It("takes the lock when it is held by a process of this host that is not running anymore", func() {
+			writeLock(DeploymentStateLock{PID: 0, Host: host, User: "fake-user"})
+
+			err := service.Lock(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			lockContents, err := fakeFs.ReadFile(lockPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(lockContents)).To(ContainSubstring(fmt.Sprintf(`"pid": %d`, os.Getpid())))
+		})
+
+		It("takes the lock held by another run when forcing the unlock", func() {
+			writeLock(DeploymentStateLock{PID: 1234, Host: "fake-other-host", User: "fake-user"})
+
+			err := service.Lock(true)
+			Expect(err).ToNot(HaveOccurred())
+
+			lockContents, err := fakeFs.ReadFile(lockPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(lockContents)).To(ContainSubstring(fmt.Sprintf(`"pid": %d`, os.Getpid())))
+		})
+
+		It("returns an error suggesting --force-unlock when the lock file is invalid", func() {
+			fakeFs.WriteFileString(lockPath, "not-json")
+
+			err := service.Lock(false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Deployment state file '/some/deployment.json' is locked by an unknown run"))
+			Expect(err.Error()).To(ContainSubstring("--force-unlock"))
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling deployment state lock file '/some/deployment.json.lock'"))
+		})
+
+		It("returns an error when the lock file is empty", func() {
+			fakeFs.WriteFileString(lockPath, "")
+
+			err := service.Lock(false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is locked by an unknown run"))
+		})
+
+		It("returns an error when the lock file cannot be read", func() {
+			fakeFs.WriteFileString(lockPath, "")
+			fakeFs.ReadFileError = errors.New("fake-read-error")
+
+			err := service.Lock(false)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-error"))
+		})
+
+		It("takes the lock when the lock file is invalid and forcing the unlock", func() {
+			fakeFs.WriteFileString(lockPath, "")
+
+			err := service.Lock(true)
+			Expect(err).ToNot(HaveOccurred())
+
+			lockContents, err := fakeFs.ReadFile(lockPath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(lockContents)).To(ContainSubstring(fmt.Sprintf(`"pid": %d`, os.Getpid())))
+		})
+	})
+
+	Describe("Unlock", func() {
+		var lockPath = "/some/deployment.json.lock"
+
+		It("removes the lock file", func() {
+			Expect(service.Lock(false)).To(Succeed())
+			Expect(fakeFs.FileExists(lockPath)).To(BeTrue())
+
+			Expect(service.Unlock()).To(Succeed())
+			Expect(fakeFs.FileExists(lockPath)).To(BeFalse())
+		})
+
+		It("succeeds when there is no lock file", func() {
+			Expect(service.Unlock()).To(Succeed())
+		})
+
+		It("keeps the lock file when another run took the lock", func() {
+			Expect(service.Lock(false)).To(Succeed())
+
+			lockContents, err := json.Marshal(DeploymentStateLock{PID: 1234, Host: "fake-other-host", User: "fake-user"})
+			Expect(err).ToNot(HaveOccurred())
+			fakeFs.WriteFile(lockPath, lockContents)
+
+			Expect(service.Unlock()).To(Succeed())
+			Expect(fakeFs.ReadFile(lockPath)).To(Equal(lockContents))
+		})
+
+		It("keeps the lock file when it is invalid", func() {
+			fakeFs.WriteFileString(lockPath, "not-json")
+
+			Expect(service.Unlock()).To(Succeed())
+			Expect(fakeFs.ReadFileString(lockPath)).To(Equal("not-json"))
+		})
+	})
+
+	Describe("RecordVersion", func() {
+		It("keeps a copy of the deployment state tagged with the command in the history", func() {
+			Expect(service.Save(DeploymentState{DirectorID: "fake-director-id"})).To(Succeed())
+
+			err := service.RecordVersion("deploy")
+			Expect(err).ToNot(HaveOccurred())
+
+			versions, err := service.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(HaveLen(1))
+			Expect(versions[0].Command).To(Equal("deploy"))
+			Expect(versions[0].CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(versions[0].State.DirectorID).To(Equal("fake-director-id"))
+		})
+
+		It("records nothing when the deployment state file does not exist", func() {
+			err := service.RecordVersion("delete")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeFs.FileExists("/some/deployment.json.history")).To(BeFalse())
+		})
+
+		It("keeps only the last 10 versions", func() {
+			for i := 0; i < 12; i++ {
+				Expect(service.Save(DeploymentState{DirectorID: fmt.Sprintf("director-id-%d", i)})).To(Succeed())
+				Expect(service.RecordVersion("deploy")).To(Succeed())
+			}
+
+			versions, err := service.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(HaveLen(10))
+			Expect(versions[0].State.DirectorID).To(Equal("director-id-11"))
+			Expect(versions[9].State.DirectorID).To(Equal("director-id-2"))
+		})
+
+		It("returns an error when the deployment state file is invalid", func() {
+			fakeFs.WriteFileString(deploymentStatePath, "{")
+
+			err := service.RecordVersion("deploy")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling deployment state file '/some/deployment.json'"))
+		})
+	})
+
+	Describe("History", func() {
+		It("returns no versions when none were recorded", func() {
+			versions, err := service.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(BeEmpty())
+		})
+
+		It("returns an error when a version file is invalid", func() {
+			fakeFs.WriteFileString("/some/deployment.json.history/20160102T150405.000000000Z.json", "{")
+
+			_, err := service.History()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Unmarshalling deployment state version file '/some/deployment.json.history/20160102T150405.000000000Z.json'"))
+		})
+	})
+
+	Describe("History", func() {
+		It("migrates the versions recorded with an older schema version", func() {
+			fakeFs.WriteFileString("/some/deployment.json.history/20160102T150405.000000000Z.json",
+				`{"created_at":"2016-01-02T15:04:05Z","command":"deploy","state":{"director_id":"fake-director-id","stemcells":null}}`)
+
+			versions, err := service.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions).To(HaveLen(1))
+			Expect(versions[0].State.SchemaVersion).To(Equal(CurrentDeploymentStateSchemaVersion))
+			Expect(versions[0].State.Stemcells).To(Equal([]StemcellRecord{}))
+		})
+	})
+
+	Describe("Restore", func() {
+		BeforeEach(func() {
+			Expect(service.Save(DeploymentState{DirectorID: "first-director-id"})).To(Succeed())
+			Expect(service.RecordVersion("deploy")).To(Succeed())
+			Expect(service.Save(DeploymentState{DirectorID: "second-director-id"})).To(Succeed())
+			Expect(service.RecordVersion("deploy")).To(Succeed())
+			fakeFs.WriteFileString(deploymentStatePath, `{"director_id":"edited-by-hand"}`)
+		})
+
+		It("replaces the deployment state with the given version", func() {
+			versions, err := service.History()
+			Expect(err).ToNot(HaveOccurred())
+
+			err = service.Restore(versions[1])
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.DirectorID).To(Equal("first-director-id"))
+		})
+	})
+
+	Context("with a cipher", func() {
+		var cipher DeploymentStateCipher
+
+		BeforeEach(func() {
+			var err error
+			cipher, err = NewDeploymentStateCipher([]byte("0123456789abcdef0123456789abcdef"))
+			Expect(err).ToNot(HaveOccurred())
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			service = NewFileSystemDeploymentStateServiceWithCipher(fakeFs, fakeUUIDGenerator, cipher, logger, deploymentStatePath)
+		})
+
+		It("encrypts the deployment state & its history", func() {
+			Expect(service.Save(DeploymentState{DirectorID: "fake-director-id", CurrentVMCID: "fake-vm-cid"})).To(Succeed())
+			Expect(service.RecordVersion("deploy")).To(Succeed())
+
+			deploymentStateFileContents, err := fakeFs.ReadFile(deploymentStatePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(IsEncryptedDeploymentState(deploymentStateFileContents)).To(BeTrue())
+			Expect(string(deploymentStateFileContents)).ToNot(ContainSubstring("fake-vm-cid"))
+
+			deploymentState, err := service.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deploymentState.CurrentVMCID).To(Equal("fake-vm-cid"))
+
+			versions, err := service.History()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(versions[0].State.CurrentVMCID).To(Equal("fake-vm-cid"))
+		})
+
+		It("returns an error when loading the encrypted deployment state without a cipher", func() {
+			Expect(service.Save(DeploymentState{DirectorID: "fake-director-id"})).To(Succeed())
+
+			logger := boshlog.NewLogger(boshlog.LevelNone)
+			service = NewFileSystemDeploymentStateService(fakeFs, fakeUUIDGenerator, logger, deploymentStatePath)
+
+			_, err := service.Load()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("File '/some/deployment.json' is encrypted: set BOSH_INIT_STATE_KEY or BOSH_INIT_STATE_KEY_FILE to its key"))
+		})
+
+		Describe("Reencrypt", func() {
+			BeforeEach(func() {
+				fakeFs.WriteFileString(deploymentStatePath, `{"director_id":"fake-director-id"}`)
+				fakeFs.WriteFileString("/some/deployment.json.history/20160102T150405.000000000Z.json", `{"command":"deploy","state":{"director_id":"fake-director-id"}}`)
+			})
+
+			It("encrypts the deployment state & its history", func() {
+				Expect(service.Reencrypt(true)).To(Succeed())
+
+				for _, path := range []string{deploymentStatePath, "/some/deployment.json.history/20160102T150405.000000000Z.json"} {
+					contents, err := fakeFs.ReadFile(path)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(IsEncryptedDeploymentState(contents)).To(BeTrue())
+				}
+
+				versions, err := service.History()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(versions[0].Command).To(Equal("deploy"))
+			})
+
+			It("decrypts the deployment state & its history", func() {
+				Expect(service.Reencrypt(true)).To(Succeed())
+				Expect(service.Reencrypt(false)).To(Succeed())
+
+				Expect(fakeFs.ReadFileString(deploymentStatePath)).To(Equal(`{"director_id":"fake-director-id"}`))
+				Expect(fakeFs.ReadFileString("/some/deployment.json.history/20160102T150405.000000000Z.json")).To(Equal(`{"command":"deploy","state":{"director_id":"fake-director-id"}}`))
+			})
+		})
+	})
+
+	Describe("RedactedDeploymentState", func() {
+		It("redacts the cloud properties of the disks", func() {
+			deploymentState := DeploymentState{
+				CurrentVMCID: "fake-vm-cid",
+				Disks: []DiskRecord{
+					{CID: "fake-disk-cid", CloudProperties: biproperty.Map{"encryption_key": "fake-secret"}},
+				},
+			}
+
+			redacted := RedactedDeploymentState(deploymentState)
+			Expect(redacted.CurrentVMCID).To(Equal("fake-vm-cid"))
+			Expect(redacted.Disks[0].CID).To(Equal("fake-disk-cid"))
+			Expect(redacted.Disks[0].CloudProperties).To(Equal(biproperty.Map{"encryption_key": "<redacted>"}))
+			Expect(deploymentState.Disks[0].CloudProperties).To(Equal(biproperty.Map{"encryption_key": "fake-secret"}))
+		})
+	})
 })
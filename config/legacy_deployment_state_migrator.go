@@ -142,7 +142,7 @@ func (m *legacyDeploymentStateMigrator) migrate(configPath string) (deploymentSt
 		}
 	}
 
-	m.logger.Debug(m.logTag, "New deployment.json (migrated from legacy bosh-deployments.yml): %#v", deploymentState)
+	m.logger.Debug(m.logTag, "New deployment.json (migrated from legacy bosh-deployments.yml): %#v", RedactedDeploymentState(deploymentState))
 
 	return deploymentState, nil
 }
@@ -111,6 +111,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "fake-uuid-0",
     "installation_id": "",
     "current_vm_cid": "",
@@ -163,6 +164,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "fake-uuid-0",
     "installation_id": "",
     "current_vm_cid": "i-a1624150",
@@ -229,6 +231,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "fake-uuid-0",
     "installation_id": "",
     "current_vm_cid": "i-a1624150",
@@ -281,6 +284,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "fake-uuid-0",
     "installation_id": "",
     "current_vm_cid": "",
@@ -340,6 +344,7 @@ registry_instances:
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(content).To(MatchRegexp(`{
+    "schema_version": 1,
     "director_id": "fake-uuid-0",
     "installation_id": "",
     "current_vm_cid": "",
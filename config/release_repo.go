@@ -35,8 +35,10 @@ func (r releaseRepo) Update(releases []release.Release) error {
 
 	for _, release := range releases {
 		newRecord := ReleaseRecord{
-			Name:    release.Name(),
-			Version: release.Version(),
+			Name:     release.Name(),
+			Version:  release.Version(),
+			Jobs:     jobFingerprintRecords(release),
+			Packages: packageFingerprintRecords(release),
 		}
 		newRecord.ID, err = r.uuidGenerator.Generate()
 		if err != nil {
@@ -62,3 +64,50 @@ func (r releaseRepo) List() ([]ReleaseRecord, error) {
 	}
 	return deploymentState.Releases, nil
 }
+
+// Matches returns true if the record has the same name as the release
+// and the same job and package fingerprints, regardless of the release version.
+func (r ReleaseRecord) Matches(release release.Release) bool {
+	if r.Name != release.Name() {
+		return false
+	}
+
+	return fingerprintsMatch(r.Jobs, jobFingerprintRecords(release)) &&
+		fingerprintsMatch(r.Packages, packageFingerprintRecords(release))
+}
+
+func jobFingerprintRecords(release release.Release) []FingerprintRecord {
+	records := []FingerprintRecord{}
+	for _, job := range release.Jobs() {
+		records = append(records, FingerprintRecord{Name: job.Name, Fingerprint: job.Fingerprint})
+	}
+	return records
+}
+
+func packageFingerprintRecords(release release.Release) []FingerprintRecord {
+	records := []FingerprintRecord{}
+	for _, pkg := range release.Packages() {
+		records = append(records, FingerprintRecord{Name: pkg.Name, Fingerprint: pkg.Fingerprint})
+	}
+	return records
+}
+
+func fingerprintsMatch(recorded, current []FingerprintRecord) bool {
+	if len(recorded) != len(current) {
+		return false
+	}
+
+	recordedFingerprints := map[string]string{}
+	for _, record := range recorded {
+		recordedFingerprints[record.Name] = record.Fingerprint
+	}
+
+	for _, record := range current {
+		fingerprint, found := recordedFingerprints[record.Name]
+		if !found || fingerprint != record.Fingerprint {
+			return false
+		}
+	}
+
+	return true
+}
@@ -5,6 +5,8 @@ import (
 	. "github.com/cloudfoundry/bosh-init/config"
 	"github.com/cloudfoundry/bosh-init/release"
 	fakerelease "github.com/cloudfoundry/bosh-init/release/fakes"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	fakeuuid "github.com/cloudfoundry/bosh-utils/uuid/fakes"
@@ -101,6 +103,26 @@ func rootDesc() {
 			})
 		})
 
+		It("records the job and package fingerprints of the provided releases", func() {
+			fakeRelease := fakerelease.New("name1", "1")
+			fakeRelease.ReleaseJobs = []bireljob.Job{{Name: "job1", Fingerprint: "job1-fingerprint"}}
+			fakeRelease.ReleasePackages = []*birelpkg.Package{{Name: "pkg1", Fingerprint: "pkg1-fingerprint"}}
+
+			err := repo.Update([]release.Release{fakeRelease})
+			Expect(err).ToNot(HaveOccurred())
+			conf, err := deploymentStateService.Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(conf.Releases).To(Equal([]ReleaseRecord{
+				{
+					ID:       "fake-uuid",
+					Name:     "name1",
+					Version:  "1",
+					Jobs:     []FingerprintRecord{{Name: "job1", Fingerprint: "job1-fingerprint"}},
+					Packages: []FingerprintRecord{{Name: "pkg1", Fingerprint: "pkg1-fingerprint"}},
+				},
+			}))
+		})
+
 		Context("when the existing releases exactly match the provided releases", func() {
 			BeforeEach(func() {
 				conf, err := deploymentStateService.Load()
@@ -224,7 +246,7 @@ func rootDesc() {
 
 		Context("when the config service fails to save", func() {
 			BeforeEach(func() {
-				fs.WriteFileError = errors.New("kaboom")
+				fs.OpenFileErr = errors.New("kaboom")
 			})
 
 			It("returns an error", func() {
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"encoding/json"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+)
+
+// remoteDeploymentStateService keeps the deployment state in a DeploymentStateBackend.
+// It is locked by a lock object next to it, created only if it does not exist yet.
+// It also remembers the version it read or wrote last: once it has one,
+// loading or saving a state that another run changed in the meantime is rejected.
+type remoteDeploymentStateService struct {
+	backend       DeploymentStateBackend
+	version       string
+	lockVersion   string
+	uuidGenerator boshuuid.Generator
+	cipher        DeploymentStateCipher
+	logger        boshlog.Logger
+	logTag        string
+}
+
+// NewRemoteDeploymentStateService returns a service that encrypts the deployment state with the given cipher, unless it is nil
+func NewRemoteDeploymentStateService(backend DeploymentStateBackend, uuidGenerator boshuuid.Generator, cipher DeploymentStateCipher, logger boshlog.Logger) DeploymentStateService {
+	return &remoteDeploymentStateService{
+		backend:       backend,
+		uuidGenerator: uuidGenerator,
+		cipher:        cipher,
+		logger:        logger,
+		logTag:        "remoteDeploymentStateService",
+	}
+}
+
+func (s *remoteDeploymentStateService) Path() string {
+	return s.backend.URL()
+}
+
+func (s *remoteDeploymentStateService) Exists() bool {
+	_, _, exists, err := s.backend.Read()
+	if err != nil {
+		// let Load report the error
+		s.logger.Warn(s.logTag, "Checking whether deployment state '%s' exists: %s", s.backend.URL(), err.Error())
+		return true
+	}
+	return exists
+}
+
+func (s *remoteDeploymentStateService) Load() (DeploymentState, error) {
+	s.logger.Debug(s.logTag, "Loading deployment state: %s", s.backend.URL())
+
+	contents, version, exists, err := s.backend.Read()
+	if err != nil {
+		return DeploymentState{}, bosherr.WrapErrorf(err, "Reading deployment state '%s'", s.backend.URL())
+	}
+
+	if s.version != "" && version != s.version {
+		return DeploymentState{}, StaleDeploymentStateError{URL: s.backend.URL()}
+	}
+	s.version = version
+
+	deploymentState := &DeploymentState{}
+	if exists {
+		storedContents := contents
+		contents, err = s.decrypt(storedContents)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapErrorf(err, "Reading deployment state '%s'", s.backend.URL())
+		}
+
+		schemaVersion, err := DeploymentStateSchemaVersion(contents)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapErrorf(err, "Unmarshalling deployment state '%s'", s.backend.URL())
+		}
+
+		if schemaVersion != CurrentDeploymentStateSchemaVersion {
+			contents, err = s.migrate(storedContents, contents, schemaVersion)
+			if err != nil {
+				return DeploymentState{}, bosherr.WrapErrorf(err, "Migrating deployment state '%s'", s.backend.URL())
+			}
+		}
+
+		err = json.Unmarshal(contents, deploymentState)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapErrorf(err, "Unmarshalling deployment state '%s'", s.backend.URL())
+		}
+		s.logger.Debug(s.logTag, "Loaded deployment state %#v", RedactedDeploymentState(*deploymentState))
+	}
+
+	if deploymentState.DirectorID == "" {
+		uuid, err := s.uuidGenerator.Generate()
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapError(err, "Generating DirectorID")
+		}
+		deploymentState.DirectorID = uuid
+
+		err = s.Save(*deploymentState)
+		if err != nil {
+			return DeploymentState{}, bosherr.WrapError(err, "Saving deployment state")
+		}
+	}
+
+	return *deploymentState, nil
+}
+
+func (s *remoteDeploymentStateService) Save(deploymentState DeploymentState) error {
+	deploymentState.SchemaVersion = CurrentDeploymentStateSchemaVersion
+	s.logger.Debug(s.logTag, "Saving deployment state %#v", RedactedDeploymentState(deploymentState))
+
+	jsonContent, err := json.MarshalIndent(deploymentState, "", "    ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling deployment state into JSON")
+	}
+
+	if s.cipher != nil {
+		jsonContent, err = s.cipher.Encrypt(jsonContent)
+		if err != nil {
+			return bosherr.WrapError(err, "Encrypting deployment state")
+		}
+	}
+
+	version, err := s.backend.Write(jsonContent, s.version)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing deployment state '%s'", s.backend.URL())
+	}
+	s.version = version
+
+	return nil
+}
+
+// migrate migrates the deployment state to the current schema version, after backing up the stored contents as they are
+// next to the deployment state. A backup left by an earlier, interrupted migration is kept.
+func (s *remoteDeploymentStateService) migrate(storedContents, contents []byte, schemaVersion int) ([]byte, error) {
+	migratedContents, err := MigrateDeploymentState(contents, DeploymentStateMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := s.backend.Sibling(DeploymentStateBackupPath("", schemaVersion))
+	_, err = backup.Write(storedContents, "")
+	if err != nil {
+		if _, ok := err.(StaleDeploymentStateError); !ok {
+			return nil, bosherr.WrapErrorf(err, "Backing up deployment state to '%s'", backup.URL())
+		}
+	}
+
+	encryptedContents := migratedContents
+	if s.cipher != nil {
+		encryptedContents, err = s.cipher.Encrypt(migratedContents)
+		if err != nil {
+			return nil, bosherr.WrapError(err, "Encrypting deployment state")
+		}
+	}
+
+	s.version, err = s.backend.Write(encryptedContents, s.version)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Writing deployment state '%s'", s.backend.URL())
+	}
+
+	s.logger.Info(s.logTag, "Migrated deployment state '%s' from schema version %d to %d, backed up to '%s'",
+		s.backend.URL(), schemaVersion, CurrentDeploymentStateSchemaVersion, backup.URL())
+
+	return migratedContents, nil
+}
+
+func (s *remoteDeploymentStateService) Cleanup() error {
+	err := s.backend.Delete(s.version)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Could not delete deployment state %s", s.backend.URL())
+	}
+	s.version = ""
+	return nil
+}
+
+func (s *remoteDeploymentStateService) Lock(forceUnlock bool) error {
+	lockBackend := s.lockBackend()
+
+	lockContents, lockVersion, exists, err := lockBackend.Read()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading deployment state lock '%s'", lockBackend.URL())
+	}
+
+	if exists {
+		// an unreadable or empty lock is held by a run that failed while writing it, or by an unknown run
+		holder := DeploymentStateLock{}
+		readable := json.Unmarshal(lockContents, &holder) == nil
+
+		holderDescription := "an unknown run"
+		if readable {
+			holderDescription = holder.String()
+		}
+
+		switch {
+		case forceUnlock:
+			s.logger.Warn(s.logTag, "Forcing the unlock of deployment state '%s' held by %s", s.backend.URL(), holderDescription)
+		case readable && holder.isStale():
+			s.logger.Warn(s.logTag, "Removing the stale lock of deployment state '%s' held by %s", s.backend.URL(), holderDescription)
+		default:
+			return bosherr.Errorf("Deployment state '%s' is locked by %s. "+
+				"If that run is not in progress anymore, run again with --force-unlock", s.backend.URL(), holderDescription)
+		}
+
+		err = lockBackend.Delete(lockVersion)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Deleting deployment state lock '%s'", lockBackend.URL())
+		}
+	}
+
+	lock := newDeploymentStateLock()
+	lockContents, err = json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling deployment state lock")
+	}
+
+	// the lock is only created if it does not exist, failing when another run took the lock since it was checked
+	s.lockVersion, err = lockBackend.Write(lockContents, "")
+	if err != nil {
+		if _, ok := err.(StaleDeploymentStateError); ok {
+			return bosherr.Errorf("Deployment state '%s' is locked by another run", s.backend.URL())
+		}
+		return bosherr.WrapErrorf(err, "Writing deployment state lock '%s'", lockBackend.URL())
+	}
+
+	s.logger.Debug(s.logTag, "Locked deployment state '%s' for %s", s.backend.URL(), lock)
+	return nil
+}
+
+func (s *remoteDeploymentStateService) Unlock() error {
+	if s.lockVersion == "" {
+		return nil
+	}
+
+	lockBackend := s.lockBackend()
+
+	// the lock may have been forcibly taken by another run since this run took it
+	err := lockBackend.Delete(s.lockVersion)
+	if err != nil {
+		if _, ok := err.(StaleDeploymentStateError); !ok {
+			return bosherr.WrapErrorf(err, "Deleting deployment state lock '%s'", lockBackend.URL())
+		}
+		s.logger.Warn(s.logTag, "Keeping the lock of deployment state '%s', as it is held by another run", s.backend.URL())
+	}
+	s.lockVersion = ""
+
+	s.logger.Debug(s.logTag, "Unlocked deployment state '%s'", s.backend.URL())
+	return nil
+}
+
+func (s *remoteDeploymentStateService) lockBackend() DeploymentStateBackend {
+	return s.backend.Sibling(DeploymentStateLockPath(""))
+}
+
+func (s *remoteDeploymentStateService) RecordVersion(command string) error {
+	s.logger.Debug(s.logTag, "Not recording a version of deployment state '%s': the history is only kept for local files", s.backend.URL())
+	return nil
+}
+
+func (s *remoteDeploymentStateService) History() ([]DeploymentStateVersion, error) {
+	return nil, bosherr.Errorf("The history of deployment state '%s' is not kept: it is only kept for local files", s.backend.URL())
+}
+
+func (s *remoteDeploymentStateService) Restore(version DeploymentStateVersion) error {
+	return bosherr.Errorf("The history of deployment state '%s' is not kept: it is only kept for local files", s.backend.URL())
+}
+
+func (s *remoteDeploymentStateService) Reencrypt(encrypted bool) error {
+	if encrypted && s.cipher == nil {
+		return bosherr.Errorf("Encrypting deployment state '%s' requires %s or %s to be set", s.backend.URL(), DeploymentStateKeyEnv, DeploymentStateKeyFileEnv)
+	}
+
+	contents, version, exists, err := s.backend.Read()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading deployment state '%s'", s.backend.URL())
+	}
+	if !exists {
+		return nil
+	}
+
+	contents, err = s.decrypt(contents)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading deployment state '%s'", s.backend.URL())
+	}
+
+	if encrypted {
+		contents, err = s.cipher.Encrypt(contents)
+		if err != nil {
+			return bosherr.WrapError(err, "Encrypting deployment state")
+		}
+	}
+
+	s.version, err = s.backend.Write(contents, version)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing deployment state '%s'", s.backend.URL())
+	}
+
+	return nil
+}
+
+func (s *remoteDeploymentStateService) decrypt(contents []byte) ([]byte, error) {
+	if !IsEncryptedDeploymentState(contents) {
+		return contents, nil
+	}
+
+	if s.cipher == nil {
+		return nil, bosherr.Errorf("Deployment state is encrypted: set %s or %s to its key", DeploymentStateKeyEnv, DeploymentStateKeyFileEnv)
+	}
+
+	return s.cipher.Decrypt(contents)
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/pivotal-golang/clock"
+)
+
+const defaultS3Region = "us-east-1"
+
+// S3DeploymentStateConfig locates a deployment state in a bucket of an S3-compatible server
+type S3DeploymentStateConfig struct {
+	// Endpoint defaults to the AWS endpoint of the region
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Key             string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// ParseS3DeploymentStateURL parses a s3://<bucket>/<key>[?region=<region>&endpoint=<url>] URL.
+// The credentials are taken from the user info of the URL, or from the given access key id & secret access key.
+func ParseS3DeploymentStateURL(stateURL string, accessKeyID string, secretAccessKey string) (S3DeploymentStateConfig, error) {
+	parsedURL, err := url.Parse(stateURL)
+	if err != nil {
+		return S3DeploymentStateConfig{}, bosherr.WrapError(err, "Parsing deployment state URL")
+	}
+
+	if parsedURL.User != nil {
+		accessKeyID = parsedURL.User.Username()
+		secretAccessKey, _ = parsedURL.User.Password()
+		parsedURL.User = nil
+	}
+
+	key := strings.TrimPrefix(parsedURL.Path, "/")
+	if parsedURL.Scheme != "s3" || parsedURL.Host == "" || key == "" {
+		return S3DeploymentStateConfig{}, bosherr.Errorf("Deployment state URL '%s' of an S3 bucket must be like 's3://<bucket>/<key>'", parsedURL)
+	}
+
+	config := S3DeploymentStateConfig{
+		Endpoint:        parsedURL.Query().Get("endpoint"),
+		Region:          parsedURL.Query().Get("region"),
+		Bucket:          parsedURL.Host,
+		Key:             key,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+	}
+
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+
+	return config, nil
+}
+
+// NewS3DeploymentStateBackend stores the deployment state as an object of an S3-compatible bucket,
+// using path-style requests signed with AWS Signature Version 4
+func NewS3DeploymentStateBackend(config S3DeploymentStateConfig, client *http.Client, timeService clock.Clock, logger boshlog.Logger) (DeploymentStateBackend, error) {
+	objectURL, err := url.Parse(fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(config.Endpoint, "/"), config.Bucket, config.Key))
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Parsing S3 endpoint '%s'", config.Endpoint)
+	}
+
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, bosherr.Errorf("Deployment state in S3 bucket '%s' requires an access key id & a secret access key", config.Bucket)
+	}
+
+	signer := s3Signer{
+		accessKeyID:     config.AccessKeyID,
+		secretAccessKey: config.SecretAccessKey,
+		region:          config.Region,
+		timeService:     timeService,
+	}
+
+	return &httpDeploymentStateBackend{
+		url:       objectURL,
+		client:    client,
+		authorize: signer.Sign,
+		logger:    logger,
+		logTag:    "s3DeploymentStateBackend",
+	}, nil
+}
+
+type s3Signer struct {
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+	timeService     clock.Clock
+}
+
+// Sign adds the AWS Signature Version 4 authorization of the request,
+// signing the host, the conditional headers & the x-amz-* headers
+func (s s3Signer) Sign(request *http.Request, payload []byte) {
+	now := s.timeService.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(payload)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name := range request.Header {
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, "x-amz-") || strings.HasPrefix(lowerName, "if-") {
+			headers[lowerName] = strings.TrimSpace(request.Header.Get(name))
+		}
+	}
+
+	headerNames := []string{}
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	canonicalHeaders := ""
+	for _, name := range headerNames {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
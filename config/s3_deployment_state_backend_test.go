@@ -0,0 +1,141 @@
+package config_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	fakebiconfig "github.com/cloudfoundry/bosh-init/config/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	"github.com/pivotal-golang/clock/fakeclock"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+var _ = Describe("S3DeploymentStateBackend", func() {
+	var (
+		logger    boshlog.Logger
+		fakeClock *fakeclock.FakeClock
+	)
+
+	BeforeEach(func() {
+		logger = boshlog.NewLogger(boshlog.LevelNone)
+		fakeClock = fakeclock.NewFakeClock(time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC))
+	})
+
+	Describe("ParseS3DeploymentStateURL", func() {
+		It("defaults to the AWS endpoint of the us-east-1 region & to the given credentials", func() {
+			config, err := ParseS3DeploymentStateURL("s3://fake-bucket/path/to/state.json", "fake-key-id", "fake-secret")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config).To(Equal(S3DeploymentStateConfig{
+				Endpoint:        "https://s3.us-east-1.amazonaws.com",
+				Region:          "us-east-1",
+				Bucket:          "fake-bucket",
+				Key:             "path/to/state.json",
+				AccessKeyID:     "fake-key-id",
+				SecretAccessKey: "fake-secret",
+			}))
+		})
+
+		It("takes the region, the endpoint & the credentials from the URL", func() {
+			config, err := ParseS3DeploymentStateURL("s3://url-key-id:url-secret@fake-bucket/state.json?region=fake-region&endpoint=http://minio.local:9000", "fake-key-id", "fake-secret")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config).To(Equal(S3DeploymentStateConfig{
+				Endpoint:        "http://minio.local:9000",
+				Region:          "fake-region",
+				Bucket:          "fake-bucket",
+				Key:             "state.json",
+				AccessKeyID:     "url-key-id",
+				SecretAccessKey: "url-secret",
+			}))
+		})
+
+		It("returns an error without a bucket & a key", func() {
+			_, err := ParseS3DeploymentStateURL("s3://key-id:secret@fake-bucket", "", "")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Deployment state URL 's3://fake-bucket' of an S3 bucket must be like 's3://<bucket>/<key>'"))
+		})
+	})
+
+	It("returns an error without credentials", func() {
+		_, err := NewS3DeploymentStateBackend(S3DeploymentStateConfig{Endpoint: "https://s3.example.com", Bucket: "fake-bucket", Key: "state.json"}, http.DefaultClient, fakeClock, logger)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Deployment state in S3 bucket 'fake-bucket' requires an access key id & a secret access key"))
+	})
+
+	It("signs the requests with AWS Signature Version 4", func() {
+		var request *http.Request
+		client := &http.Client{
+			Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				request = r
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Etag": []string{`"fake-etag"`}},
+					Body:       ioutil.NopCloser(strings.NewReader("")),
+				}, nil
+			}),
+		}
+
+		backend, err := NewS3DeploymentStateBackend(S3DeploymentStateConfig{
+			Endpoint:        "https://s3.example.com",
+			Region:          "fake-region",
+			Bucket:          "fake-bucket",
+			Key:             "path/to/state.json",
+			AccessKeyID:     "fake-key-id",
+			SecretAccessKey: "fake-secret",
+		}, client, fakeClock, logger)
+		Expect(err).ToNot(HaveOccurred())
+
+		version, err := backend.Write([]byte("fake-contents"), "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version).To(Equal(`"fake-etag"`))
+
+		Expect(request.Method).To(Equal("PUT"))
+		Expect(request.URL.String()).To(Equal("https://s3.example.com/fake-bucket/path/to/state.json"))
+		Expect(request.Header.Get("X-Amz-Date")).To(Equal("20160102T030405Z"))
+		Expect(request.Header.Get("X-Amz-Content-Sha256")).To(Equal("d12d3a3ee8dcdc9e7ea3416fd618298ea50abde2cf434313c6c3edb213f441cd"))
+		Expect(request.Header.Get("Authorization")).To(Equal(
+			"AWS4-HMAC-SHA256 Credential=fake-key-id/20160102/fake-region/s3/aws4_request, " +
+				"SignedHeaders=host;if-none-match;x-amz-content-sha256;x-amz-date, " +
+				"Signature=2c87a19b892a1d4851034d326b6e672085361aa3a3b8f9535a9d3151861a8cd1",
+		))
+	})
+
+	It("stores the deployment state as an object of the bucket", func() {
+		fakeS3 := fakebiconfig.NewFakeDeploymentStateServer()
+		server := httptest.NewServer(fakeS3)
+		defer server.Close()
+
+		backend, err := NewS3DeploymentStateBackend(S3DeploymentStateConfig{
+			Endpoint:        server.URL,
+			Region:          "us-east-1",
+			Bucket:          "fake-bucket",
+			Key:             "state.json",
+			AccessKeyID:     "fake-key-id",
+			SecretAccessKey: "fake-secret",
+		}, http.DefaultClient, fakeClock, logger)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backend.URL()).To(Equal(server.URL + "/fake-bucket/state.json"))
+
+		version, err := backend.Write([]byte("fake-contents"), "")
+		Expect(err).ToNot(HaveOccurred())
+
+		contents, readVersion, exists, err := backend.Read()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exists).To(BeTrue())
+		Expect(string(contents)).To(Equal("fake-contents"))
+		Expect(readVersion).To(Equal(version))
+
+		Expect(fakeS3.Requests[0].Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=fake-key-id/20160102/us-east-1/s3/aws4_request"))
+	})
+})
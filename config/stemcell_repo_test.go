@@ -34,7 +34,8 @@ var _ = Describe("StemcellRepo", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedConfig := DeploymentState{
-				DirectorID: "fake-uuid-0",
+				SchemaVersion: CurrentDeploymentStateSchemaVersion,
+				DirectorID:    "fake-uuid-0",
 				Stemcells: []StemcellRecord{
 					{
 						ID:      "fake-uuid-1",
@@ -96,7 +97,8 @@ var _ = Describe("StemcellRepo", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				expectedConfig := DeploymentState{
-					DirectorID: "fake-uuid-0",
+					SchemaVersion: CurrentDeploymentStateSchemaVersion,
+					DirectorID:    "fake-uuid-0",
 					Stemcells: []StemcellRecord{
 						{
 							ID:      "fake-uuid-1",
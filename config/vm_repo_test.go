@@ -58,8 +58,9 @@ var _ = Describe("VMRepo", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedConfig := DeploymentState{
-				DirectorID:   "fake-uuid-0",
-				CurrentVMCID: "fake-vm-cid",
+				SchemaVersion: CurrentDeploymentStateSchemaVersion,
+				DirectorID:    "fake-uuid-0",
+				CurrentVMCID:  "fake-vm-cid",
 			}
 			Expect(deploymentState).To(Equal(expectedConfig))
 		})
@@ -74,8 +75,9 @@ var _ = Describe("VMRepo", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedConfig := DeploymentState{
-				DirectorID:   "fake-uuid-0",
-				CurrentVMCID: "",
+				SchemaVersion: CurrentDeploymentStateSchemaVersion,
+				DirectorID:    "fake-uuid-0",
+				CurrentVMCID:  "",
 			}
 			Expect(deploymentState).To(Equal(expectedConfig))
 
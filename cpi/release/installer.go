@@ -44,26 +44,12 @@ func (i CpiInstaller) installCpiRelease(installationManifest biinstallmanifest.M
 	return installation, nil
 }
 
-func (i CpiInstaller) WithInstalledCpiRelease(installationManifest biinstallmanifest.Manifest, stage biui.Stage, fn func(biinstall.Installation) error) (errToReturn error) {
+// WithInstalledCpiRelease keeps the CPI job installed afterwards, so that the next run can reuse it when it is unchanged
+func (i CpiInstaller) WithInstalledCpiRelease(installationManifest biinstallmanifest.Manifest, stage biui.Stage, fn func(biinstall.Installation) error) error {
 	installation, err := i.installCpiRelease(installationManifest, stage)
 	if err != nil {
-		errToReturn = err
-		return
+		return err
 	}
 
-	defer func() {
-		err = i.cleanupInstall(installation, stage)
-		if errToReturn == nil {
-			errToReturn = err
-		}
-	}()
-
-	errToReturn = fn(installation)
-	return
-}
-
-func (i CpiInstaller) cleanupInstall(installation biinstall.Installation, stage biui.Stage) error {
-	return stage.Perform("Cleaning up rendered CPI jobs", func() error {
-		return i.Installer.Cleanup(installation)
-	})
+	return fn(installation)
 }
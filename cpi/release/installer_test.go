@@ -22,7 +22,6 @@ var _ = Describe("Installer", func() {
 			installStage                 *fakeui.FakeStage
 			installation                 *mocks.MockInstallation
 			expectInstallPackagesAndJobs *gomock.Call
-			expectCleanup                *gomock.Call
 		)
 
 		BeforeEach(func() {
@@ -40,8 +39,6 @@ var _ = Describe("Installer", func() {
 			installation = mocks.NewMockInstallation(mockCtrl)
 
 			expectInstallPackagesAndJobs = mockInstaller.EXPECT().InstallPackagesAndJobs(installationManifest, gomock.Any())
-			expectCleanup = mockInstaller.EXPECT().Cleanup(installation).Return(nil)
-
 		})
 
 		It("should install the CPI and call the passed in function with the installation", func() {
@@ -87,24 +84,7 @@ var _ = Describe("Installer", func() {
 			))
 		})
 
-		It("cleans up the installation afterwards", func() {
-			cpiInstaller := release.CpiInstaller{
-				Installer: mockInstaller,
-			}
-
-			cleanupCalled := false
-			expectInstallPackagesAndJobs.Return(installation, nil)
-			expectCleanup.Times(1).Do(func(_ biinstallation.Installation) {
-				cleanupCalled = true
-			})
-			err := cpiInstaller.WithInstalledCpiRelease(installationManifest, installStage, func(installation biinstallation.Installation) error {
-				Expect(cleanupCalled).To(BeFalse())
-				return nil
-			})
-			Expect(err).ToNot(HaveOccurred())
-		})
-
-		It("creates a stage for the cleanup", func() {
+		It("keeps the installation afterwards, for the next run to reuse it", func() {
 			cpiInstaller := release.CpiInstaller{
 				Installer: mockInstaller,
 			}
@@ -115,12 +95,11 @@ var _ = Describe("Installer", func() {
 			})
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(installStage.PerformCalls).To(ContainElement(
+			Expect(installStage.PerformCalls).ToNot(ContainElement(
 				&fakeui.PerformCall{
 					Name: "Cleaning up rendered CPI jobs",
 				},
 			))
-
 		})
 
 		Context("when installing the cpi fails", func() {
@@ -130,7 +109,6 @@ var _ = Describe("Installer", func() {
 				}
 
 				expectInstallPackagesAndJobs.Return(nil, errors.New("couldn't install that"))
-				expectCleanup.Times(0)
 
 				err := cpiInstaller.WithInstalledCpiRelease(installationManifest, installStage, func(installation biinstallation.Installation) error {
 					return nil
@@ -157,52 +135,23 @@ var _ = Describe("Installer", func() {
 				Expect(err.Error()).To(ContainSubstring("My passed in function failed"))
 			})
 
-			It("cleans up the installation", func() {
+			It("keeps the installation, for the next run to reuse it", func() {
 				cpiInstaller := release.CpiInstaller{
 					Installer: mockInstaller,
 				}
 
 				expectInstallPackagesAndJobs.Return(installation, nil)
-				expectCleanup.Times(1)
 
 				err := cpiInstaller.WithInstalledCpiRelease(installationManifest, installStage, func(installation biinstallation.Installation) error {
 					return errors.New("My passed in function failed")
 				})
 				Expect(err).To(HaveOccurred())
-			})
-		})
-
-		Context("when cleanup fails", func() {
-			It("returns the error", func() {
-				cpiInstaller := release.CpiInstaller{
-					Installer: mockInstaller,
-				}
 
-				expectInstallPackagesAndJobs.Return(installation, nil)
-				expectCleanup.Return(errors.New("cleanup failed"))
-
-				err := cpiInstaller.WithInstalledCpiRelease(installationManifest, installStage, func(installation biinstallation.Installation) error {
-					return nil
-				})
-
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("cleanup failed"))
-			})
-
-			It("returns the error from the function instead, if present", func() {
-				cpiInstaller := release.CpiInstaller{
-					Installer: mockInstaller,
-				}
-
-				expectInstallPackagesAndJobs.Return(installation, nil)
-				expectCleanup.Return(errors.New("cleanup failed"))
-
-				err := cpiInstaller.WithInstalledCpiRelease(installationManifest, installStage, func(installation biinstallation.Installation) error {
-					return errors.New("My passed in function failed")
-				})
-
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("My passed in function failed"))
+				Expect(installStage.PerformCalls).ToNot(ContainElement(
+					&fakeui.PerformCall{
+						Name: "Cleaning up rendered CPI jobs",
+					},
+				))
 			})
 		})
 	})
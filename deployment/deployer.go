@@ -21,6 +21,7 @@ type Deployer interface {
 		bicloud.Cloud,
 		bideplmanifest.Manifest,
 		bistemcell.CloudStemcell,
+		bistemcell.ExtractedStemcell,
 		biinstallmanifest.Registry,
 		bivm.Manager,
 		biblobstore.Blobstore,
@@ -55,6 +56,7 @@ func (d *deployer) Deploy(
 	cloud bicloud.Cloud,
 	deploymentManifest bideplmanifest.Manifest,
 	cloudStemcell bistemcell.CloudStemcell,
+	extractedStemcell bistemcell.ExtractedStemcell,
 	registryConfig biinstallmanifest.Registry,
 	vmManager bivm.Manager,
 	blobstore biblobstore.Blobstore,
@@ -68,7 +70,7 @@ func (d *deployer) Deploy(
 		return nil, err
 	}
 
-	instances, disks, err := d.createAllInstances(deploymentManifest, instanceManager, cloudStemcell, registryConfig, deployStage)
+	instances, disks, err := d.createAllInstances(deploymentManifest, instanceManager, cloudStemcell, extractedStemcell, registryConfig, deployStage)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +83,7 @@ func (d *deployer) createAllInstances(
 	deploymentManifest bideplmanifest.Manifest,
 	instanceManager biinstance.Manager,
 	cloudStemcell bistemcell.CloudStemcell,
+	extractedStemcell bistemcell.ExtractedStemcell,
 	registryConfig biinstallmanifest.Registry,
 	deployStage biui.Stage,
 ) ([]biinstance.Instance, []bidisk.Disk, error) {
@@ -103,7 +106,7 @@ func (d *deployer) createAllInstances(
 			instances = append(instances, instance)
 			disks = append(disks, instanceDisks...)
 
-			err = instance.UpdateJobs(deploymentManifest, deployStage)
+			err = instance.UpdateJobs(deploymentManifest, extractedStemcell, deployStage)
 			if err != nil {
 				return instances, disks, err
 			}
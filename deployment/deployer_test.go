@@ -12,6 +12,7 @@ import (
 	mock_agentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient/mocks"
 	mock_instance_state "github.com/cloudfoundry/bosh-init/deployment/instance/state/mocks"
 	mock_vm "github.com/cloudfoundry/bosh-init/deployment/vm/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -25,6 +26,7 @@ import (
 	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
 	fakebicloud "github.com/cloudfoundry/bosh-init/cloud/fakes"
 	fakebiconfig "github.com/cloudfoundry/bosh-init/config/fakes"
@@ -59,7 +61,8 @@ var _ = Describe("Deployer", func() {
 		fakeStage              *fakebiui.FakeStage
 		fakeVM                 *fakebivm.FakeVM
 
-		cloudStemcell bistemcell.CloudStemcell
+		cloudStemcell     bistemcell.CloudStemcell
+		extractedStemcell bistemcell.ExtractedStemcell
 
 		applySpec bias.ApplySpec
 
@@ -140,12 +143,24 @@ var _ = Describe("Deployer", func() {
 		Expect(err).ToNot(HaveOccurred())
 
 		cloudStemcell = bistemcell.NewCloudStemcell(stemcellRecord, fakeStemcellRepo, cloud)
+		extractedStemcell = bistemcell.NewExtractedStemcell(
+			bistemcell.Manifest{
+				Name:    "fake-stemcell-name",
+				Version: "fake-stemcell-version",
+				OS:      "fake-stemcell-os",
+			},
+			"/fake-extracted-stemcell-path",
+			fakesys.NewFakeFileSystem(),
+		)
 
 		mockStateBuilderFactory = mock_instance_state.NewMockBuilderFactory(mockCtrl)
 		mockStateBuilder = mock_instance_state.NewMockBuilder(mockCtrl)
 		mockState = mock_instance_state.NewMockState(mockCtrl)
 
-		instanceFactory := biinstance.NewFactory(mockStateBuilderFactory)
+		mockRenderedJobListArchiveRepo := mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+		mockRenderedJobListArchiveRepo.EXPECT().CommitPending().AnyTimes()
+
+		instanceFactory := biinstance.NewFactory(mockStateBuilderFactory, mockRenderedJobListArchiveRepo, &fakebiui.FakeUI{})
 		instanceManagerFactory := biinstance.NewManagerFactory(fakeSSHTunnelFactory, instanceFactory, logger)
 
 		mockBlobstore = mock_blobstore.NewMockBlobstore(mockCtrl)
@@ -172,8 +187,9 @@ var _ = Describe("Deployer", func() {
 		}
 
 		mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
-		mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, fakeStage).Return(mockState, nil).AnyTimes()
+		mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, extractedStemcell, fakeStage).Return(mockState, nil).AnyTimes()
 		mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
+		mockState.EXPECT().RenderedJobListDiff().Return("").AnyTimes()
 	})
 
 	Context("when a previous instance exists", func() {
@@ -186,7 +202,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("deletes existing vm", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeExistingVM.DeleteCalled).To(Equal(1))
@@ -200,7 +216,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("creates a vm", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVMManager.CreateInput).To(Equal(fakebivm.CreateInput{
@@ -227,7 +243,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("starts the SSH tunnel", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(fakeSSHTunnel.Started).To(BeTrue())
 			Expect(fakeSSHTunnelFactory.NewSSHTunnelOptions).To(Equal(bisshtunnel.Options{
@@ -247,7 +263,7 @@ var _ = Describe("Deployer", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+				_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-ssh-tunnel-start-error"))
 			})
@@ -255,7 +271,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("waits for the vm", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(fakeVM.WaitUntilReadyInputs).To(ContainElement(fakebivm.WaitUntilReadyInput{
 			Timeout: 10 * time.Minute,
@@ -264,7 +280,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("logs start and stop events to the eventLogger", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeStage.PerformCalls[1]).To(Equal(&fakebiui.PerformCall{
@@ -282,7 +298,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-wait-error"))
 
@@ -294,7 +310,7 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("updates the vm", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVM.ApplyInputs).To(Equal([]fakebivm.ApplyInput{
@@ -303,14 +319,14 @@ var _ = Describe("Deployer", func() {
 	})
 
 	It("starts the agent", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVM.StartCalled).To(Equal(1))
 	})
 
 	It("waits until agent reports state as running", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeVM.WaitToBeRunningInputs).To(ContainElement(fakebivm.WaitInput{
@@ -325,13 +341,13 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).To(HaveOccurred())
 		})
 	})
 
 	It("logs instance update ui stages", func() {
-		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+		_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeStage.PerformCalls[2:4]).To(Equal([]*fakebiui.PerformCall{
@@ -346,7 +362,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
 
@@ -362,7 +378,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-start-error"))
 
@@ -382,7 +398,7 @@ var _ = Describe("Deployer", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
+			_, err := deployer.Deploy(cloud, deploymentManifest, cloudStemcell, extractedStemcell, registryConfig, fakeVMManager, mockBlobstore, fakeStage)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("fake-wait-running-error"))
 
@@ -12,6 +12,7 @@ import (
 	mock_cloud "github.com/cloudfoundry/bosh-init/cloud/mocks"
 	mock_agentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient/mocks"
 	mock_instance_state "github.com/cloudfoundry/bosh-init/deployment/instance/state/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
@@ -107,8 +108,9 @@ var _ = Describe("Deployment", func() {
 			}
 
 			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
-			mockStateBuilder.EXPECT().Build(jobName, jobIndex, gomock.Any(), fakeStage).Return(mockState, nil).AnyTimes()
+			mockStateBuilder.EXPECT().Build(jobName, jobIndex, gomock.Any(), gomock.Any(), fakeStage).Return(mockState, nil).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
+			mockState.EXPECT().RenderedJobListDiff().Return("").AnyTimes()
 		}
 
 		BeforeEach(func() {
@@ -145,7 +147,10 @@ var _ = Describe("Deployment", func() {
 			mockStateBuilder = mock_instance_state.NewMockBuilder(mockCtrl)
 			mockState = mock_instance_state.NewMockState(mockCtrl)
 
-			instanceFactory := biinstance.NewFactory(mockStateBuilderFactory)
+			mockRenderedJobListArchiveRepo := mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+			mockRenderedJobListArchiveRepo.EXPECT().CommitPending().AnyTimes()
+
+			instanceFactory := biinstance.NewFactory(mockStateBuilderFactory, mockRenderedJobListArchiveRepo, &fakebiui.FakeUI{})
 			instanceManagerFactory := biinstance.NewManagerFactory(sshTunnelFactory, instanceFactory, logger)
 			stemcellManagerFactory := bistemcell.NewManagerFactory(stemcellRepo)
 
@@ -98,7 +98,7 @@ var _ = Describe("Manager", func() {
 
 		Context("when updating disk record fails", func() {
 			BeforeEach(func() {
-				fakeFs.WriteFileError = errors.New("fake-write-error")
+				fakeFs.OpenFileErr = errors.New("fake-write-error")
 			})
 
 			It("returns an error", func() {
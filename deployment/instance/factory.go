@@ -5,6 +5,8 @@ import (
 	biinstancestate "github.com/cloudfoundry/bosh-init/deployment/instance/state"
 	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
 	bivm "github.com/cloudfoundry/bosh-init/deployment/vm"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	biui "github.com/cloudfoundry/bosh-init/ui"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
@@ -21,14 +23,20 @@ type Factory interface {
 }
 
 type factory struct {
-	stateBuilderFactory biinstancestate.BuilderFactory
+	stateBuilderFactory        biinstancestate.BuilderFactory
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo
+	ui                         biui.UI
 }
 
 func NewFactory(
 	stateBuilderFactory biinstancestate.BuilderFactory,
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo,
+	ui biui.UI,
 ) Factory {
 	return &factory{
-		stateBuilderFactory: stateBuilderFactory,
+		stateBuilderFactory:        stateBuilderFactory,
+		renderedJobListArchiveRepo: renderedJobListArchiveRepo,
+		ui:                         ui,
 	}
 }
 
@@ -50,6 +58,8 @@ func (f *factory) NewInstance(
 		vmManager,
 		sshTunnelFactory,
 		stateBuilder,
+		f.renderedJobListArchiveRepo,
+		f.ui,
 		logger,
 	)
 }
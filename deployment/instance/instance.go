@@ -2,6 +2,7 @@ package instance
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
@@ -11,6 +12,8 @@ import (
 	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
 	bivm "github.com/cloudfoundry/bosh-init/deployment/vm"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -22,7 +25,7 @@ type Instance interface {
 	Disks() ([]bidisk.Disk, error)
 	WaitUntilReady(biinstallmanifest.Registry, biui.Stage) error
 	UpdateDisks(bideplmanifest.Manifest, biui.Stage) ([]bidisk.Disk, error)
-	UpdateJobs(bideplmanifest.Manifest, biui.Stage) error
+	UpdateJobs(bideplmanifest.Manifest, bistemcell.ExtractedStemcell, biui.Stage) error
 	Delete(
 		pingTimeout time.Duration,
 		pingDelay time.Duration,
@@ -31,14 +34,16 @@ type Instance interface {
 }
 
 type instance struct {
-	jobName          string
-	id               int
-	vm               bivm.VM
-	vmManager        bivm.Manager
-	sshTunnelFactory bisshtunnel.Factory
-	stateBuilder     biinstancestate.Builder
-	logger           boshlog.Logger
-	logTag           string
+	jobName                    string
+	id                         int
+	vm                         bivm.VM
+	vmManager                  bivm.Manager
+	sshTunnelFactory           bisshtunnel.Factory
+	stateBuilder               biinstancestate.Builder
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo
+	ui                         biui.UI
+	logger                     boshlog.Logger
+	logTag                     string
 }
 
 func NewInstance(
@@ -48,17 +53,21 @@ func NewInstance(
 	vmManager bivm.Manager,
 	sshTunnelFactory bisshtunnel.Factory,
 	stateBuilder biinstancestate.Builder,
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo,
+	ui biui.UI,
 	logger boshlog.Logger,
 ) Instance {
 	return &instance{
-		jobName:          jobName,
-		id:               id,
-		vm:               vm,
-		vmManager:        vmManager,
-		sshTunnelFactory: sshTunnelFactory,
-		stateBuilder:     stateBuilder,
-		logger:           logger,
-		logTag:           "instance",
+		jobName:                    jobName,
+		id:                         id,
+		vm:                         vm,
+		vmManager:                  vmManager,
+		sshTunnelFactory:           sshTunnelFactory,
+		stateBuilder:               stateBuilder,
+		renderedJobListArchiveRepo: renderedJobListArchiveRepo,
+		ui:                         ui,
+		logger:                     logger,
+		logTag:                     "instance",
 	}
 }
 
@@ -128,13 +137,16 @@ func (i *instance) UpdateDisks(deploymentManifest bideplmanifest.Manifest, stage
 
 func (i *instance) UpdateJobs(
 	deploymentManifest bideplmanifest.Manifest,
+	stemcell bistemcell.ExtractedStemcell,
 	stage biui.Stage,
 ) error {
-	newState, err := i.stateBuilder.Build(i.jobName, i.id, deploymentManifest, stage)
+	newState, err := i.stateBuilder.Build(i.jobName, i.id, deploymentManifest, stemcell, stage)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Building state for instance '%s/%d'", i.jobName, i.id)
 	}
 
+	i.printRenderedJobListDiff(newState)
+
 	stepName := fmt.Sprintf("Updating instance '%s/%d'", i.jobName, i.id)
 	err = stage.Perform(stepName, func() error {
 		err := i.vm.Stop()
@@ -158,9 +170,27 @@ func (i *instance) UpdateJobs(
 		return err
 	}
 
+	// the applied templates are only kept to be compared with later deploys, so failing to keep them does not fail the deploy
+	err = i.renderedJobListArchiveRepo.CommitPending()
+	if err != nil {
+		i.logger.Warn(i.logTag, "Failed to record the applied job templates: %s", err.Error())
+	}
+
 	return i.waitUntilJobsAreRunning(deploymentManifest.Update.UpdateWatchTime, stage)
 }
 
+func (i *instance) printRenderedJobListDiff(newState biinstancestate.State) {
+	diff := newState.RenderedJobListDiff()
+	if diff == "" {
+		return
+	}
+
+	i.ui.PrintLinef("Job template changes for instance '%s/%d':", i.jobName, i.id)
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		i.ui.PrintLinef("%s", line)
+	}
+}
+
 func (i *instance) Delete(
 	pingTimeout time.Duration,
 	pingDelay time.Duration,
@@ -9,6 +9,7 @@ import (
 
 	"code.google.com/p/gomock/gomock"
 	mock_instance_state "github.com/cloudfoundry/bosh-init/deployment/instance/state/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
@@ -16,8 +17,10 @@ import (
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
 	bisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
+	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
 	fakebidisk "github.com/cloudfoundry/bosh-init/deployment/disk/fakes"
 	fakebisshtunnel "github.com/cloudfoundry/bosh-init/deployment/sshtunnel/fakes"
@@ -40,6 +43,9 @@ var _ = Describe("Instance", func() {
 		mockStateBuilder *mock_instance_state.MockBuilder
 		mockState        *mock_instance_state.MockState
 
+		mockRenderedJobListArchiveRepo *mock_template.MockRenderedJobListArchiveRepo
+		fakeUI                         *fakebiui.FakeUI
+
 		fakeVMManager        *fakebivm.FakeManager
 		fakeVM               *fakebivm.FakeVM
 		fakeSSHTunnelFactory *fakebisshtunnel.FakeFactory
@@ -67,6 +73,9 @@ var _ = Describe("Instance", func() {
 		mockStateBuilder = mock_instance_state.NewMockBuilder(mockCtrl)
 		mockState = mock_instance_state.NewMockState(mockCtrl)
 
+		mockRenderedJobListArchiveRepo = mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+		fakeUI = &fakebiui.FakeUI{}
+
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 
 		instance = NewInstance(
@@ -76,6 +85,8 @@ var _ = Describe("Instance", func() {
 			fakeVMManager,
 			fakeSSHTunnelFactory,
 			mockStateBuilder,
+			mockRenderedJobListArchiveRepo,
+			fakeUI,
 			logger,
 		)
 
@@ -266,10 +277,13 @@ var _ = Describe("Instance", func() {
 	Describe("UpdateJobs", func() {
 		var (
 			deploymentManifest bideplmanifest.Manifest
+			extractedStemcell  bistemcell.ExtractedStemcell
 
 			applySpec bias.ApplySpec
 
-			expectStateBuild *gomock.Call
+			expectStateBuild    *gomock.Call
+			expectStateDiff     *gomock.Call
+			expectCommitPending *gomock.Call
 		)
 
 		BeforeEach(func() {
@@ -284,6 +298,16 @@ var _ = Describe("Instance", func() {
 				},
 			}
 
+			extractedStemcell = bistemcell.NewExtractedStemcell(
+				bistemcell.Manifest{
+					Name:    "fake-stemcell-name",
+					Version: "fake-stemcell-version",
+					OS:      "fake-stemcell-os",
+				},
+				"/fake-extracted-stemcell-path",
+				fakesys.NewFakeFileSystem(),
+			)
+
 			// apply spec is just returned from instance.State.ToApplySpec() and passed to agentClient.Apply()
 			applySpec = bias.ApplySpec{
 				Deployment: "fake-deployment-name",
@@ -291,19 +315,21 @@ var _ = Describe("Instance", func() {
 		})
 
 		JustBeforeEach(func() {
-			expectStateBuild = mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, fakeStage).Return(mockState, nil).AnyTimes()
+			expectStateBuild = mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, extractedStemcell, fakeStage).Return(mockState, nil).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
+			expectStateDiff = mockState.EXPECT().RenderedJobListDiff().Return("").AnyTimes()
+			expectCommitPending = mockRenderedJobListArchiveRepo.EXPECT().CommitPending().Return(nil).AnyTimes()
 		})
 
 		It("builds a new instance state", func() {
 			expectStateBuild.Times(1)
 
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("tells agent to stop jobs, apply a new spec (with new rendered jobs templates), and start jobs", func() {
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeVM.StopCalled).To(Equal(1))
@@ -314,7 +340,7 @@ var _ = Describe("Instance", func() {
 		})
 
 		It("waits until agent reports state as running", func() {
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeVM.WaitToBeRunningInputs).To(ContainElement(fakebivm.WaitInput{
@@ -324,7 +350,7 @@ var _ = Describe("Instance", func() {
 		})
 
 		It("logs start and stop events to the eventLogger", func() {
-			err := instance.UpdateJobs(deploymentManifest, fakeStage)
+			err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
@@ -333,13 +359,55 @@ var _ = Describe("Instance", func() {
 			}))
 		})
 
+		It("records the rendered job templates as applied", func() {
+			expectCommitPending.Times(1)
+
+			err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not print job template changes when there are none", func() {
+			err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeUI.Said).To(BeEmpty())
+		})
+
+		Context("when the job templates changed since they were last applied", func() {
+			JustBeforeEach(func() {
+				expectStateDiff.Return("--- a/fake-job/monit\n+++ b/fake-job/monit\n")
+			})
+
+			It("prints the job template changes", func() {
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeUI.Said).To(Equal([]string{
+					"Job template changes for instance 'fake-job-name/0':",
+					"--- a/fake-job/monit",
+					"+++ b/fake-job/monit",
+				}))
+			})
+		})
+
+		Context("when recording the applied job templates fails", func() {
+			JustBeforeEach(func() {
+				expectCommitPending.Return(bosherr.Error("fake-commit-error"))
+			})
+
+			It("does not return an error", func() {
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
 		Context("when instance state building fails", func() {
 			JustBeforeEach(func() {
 				expectStateBuild.Return(nil, bosherr.Error("fake-template-err")).Times(1)
 			})
 
 			It("returns an error", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-template-err"))
 			})
@@ -351,7 +419,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-stop-error"))
 
@@ -367,7 +435,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-apply-error"))
 
@@ -375,6 +443,13 @@ var _ = Describe("Instance", func() {
 				Expect(fakeStage.PerformCalls[0].Error).To(HaveOccurred())
 				Expect(fakeStage.PerformCalls[0].Error.Error()).To(Equal("Applying the agent state: fake-apply-error"))
 			})
+
+			It("does not record the rendered job templates as applied", func() {
+				expectCommitPending.Times(0)
+
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
+				Expect(err).To(HaveOccurred())
+			})
 		})
 
 		Context("when starting vm fails", func() {
@@ -383,7 +458,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs start and stop events to the eventLogger", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-start-error"))
 
@@ -403,7 +478,7 @@ var _ = Describe("Instance", func() {
 			})
 
 			It("logs instance update stages", func() {
-				err := instance.UpdateJobs(deploymentManifest, fakeStage)
+				err := instance.UpdateJobs(deploymentManifest, extractedStemcell, fakeStage)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-wait-running-error"))
 
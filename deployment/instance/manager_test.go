@@ -12,6 +12,7 @@ import (
 	mock_blobstore "github.com/cloudfoundry/bosh-init/blobstore/mocks"
 	mock_agentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient/mocks"
 	mock_instance_state "github.com/cloudfoundry/bosh-init/deployment/instance/state/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
 
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
 	bias "github.com/cloudfoundry/bosh-init/deployment/applyspec"
@@ -47,6 +48,9 @@ var _ = Describe("Manager", func() {
 		mockStateBuilder        *mock_instance_state.MockBuilder
 		mockState               *mock_instance_state.MockState
 
+		mockRenderedJobListArchiveRepo *mock_template.MockRenderedJobListArchiveRepo
+		fakeUI                         *fakebiui.FakeUI
+
 		mockBlobstore *mock_blobstore.MockBlobstore
 
 		fakeVMManager        *fakebivm.FakeManager
@@ -73,7 +77,11 @@ var _ = Describe("Manager", func() {
 		mockStateBuilder = mock_instance_state.NewMockBuilder(mockCtrl)
 		mockState = mock_instance_state.NewMockState(mockCtrl)
 
-		instanceFactory = NewFactory(mockStateBuilderFactory)
+		mockRenderedJobListArchiveRepo = mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+		mockRenderedJobListArchiveRepo.EXPECT().CommitPending().AnyTimes()
+		fakeUI = &fakebiui.FakeUI{}
+
+		instanceFactory = NewFactory(mockStateBuilderFactory, mockRenderedJobListArchiveRepo, fakeUI)
 
 		mockBlobstore = mock_blobstore.NewMockBlobstore(mockCtrl)
 
@@ -128,8 +136,9 @@ var _ = Describe("Manager", func() {
 			}
 
 			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
-			mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, fakeStage).Return(mockState, nil).AnyTimes()
+			mockStateBuilder.EXPECT().Build(jobName, jobIndex, deploymentManifest, gomock.Any(), fakeStage).Return(mockState, nil).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
+			mockState.EXPECT().RenderedJobListDiff().Return("").AnyTimes()
 		}
 
 		BeforeEach(func() {
@@ -176,6 +185,8 @@ var _ = Describe("Manager", func() {
 				fakeVMManager,
 				fakeSSHTunnelFactory,
 				mockStateBuilder,
+				mockRenderedJobListArchiveRepo,
+				fakeUI,
 				logger,
 			)
 
@@ -87,14 +87,14 @@ func (_mr *_MockInstanceRecorder) UpdateDisks(arg0, arg1 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateDisks", arg0, arg1)
 }
 
-func (_m *MockInstance) UpdateJobs(_param0 manifest0.Manifest, _param1 ui.Stage) error {
-	ret := _m.ctrl.Call(_m, "UpdateJobs", _param0, _param1)
+func (_m *MockInstance) UpdateJobs(_param0 manifest0.Manifest, _param1 stemcell.ExtractedStemcell, _param2 ui.Stage) error {
+	ret := _m.ctrl.Call(_m, "UpdateJobs", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockInstanceRecorder) UpdateJobs(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateJobs", arg0, arg1)
+func (_mr *_MockInstanceRecorder) UpdateJobs(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateJobs", arg0, arg1, arg2)
 }
 
 func (_m *MockInstance) WaitUntilReady(_param0 manifest.Registry, _param1 ui.Stage) error {
@@ -7,6 +7,8 @@ import (
 	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
+	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
@@ -14,17 +16,19 @@ import (
 )
 
 type Builder interface {
-	Build(jobName string, instanceID int, deploymentManifest bideplmanifest.Manifest, stage biui.Stage) (State, error)
+	Build(jobName string, instanceID int, deploymentManifest bideplmanifest.Manifest, stemcell bistemcell.ExtractedStemcell, stage biui.Stage) (State, error)
 }
 
 type builder struct {
-	releaseJobResolver        bideplrel.JobResolver
-	jobDependencyCompiler     bistatejob.DependencyCompiler
-	jobListRenderer           bitemplate.JobListRenderer
-	renderedJobListCompressor bitemplate.RenderedJobListCompressor
-	blobstore                 biblobstore.Blobstore
-	logger                    boshlog.Logger
-	logTag                    string
+	releaseJobResolver         bideplrel.JobResolver
+	jobDependencyCompiler      bistatejob.DependencyCompiler
+	jobListRenderer            bitemplate.JobListRenderer
+	renderedJobListCompressor  bitemplate.RenderedJobListCompressor
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo
+	renderedJobListDiffer      bitemplate.RenderedJobListDiffer
+	blobstore                  biblobstore.Blobstore
+	logger                     boshlog.Logger
+	logTag                     string
 }
 
 func NewBuilder(
@@ -32,26 +36,32 @@ func NewBuilder(
 	jobDependencyCompiler bistatejob.DependencyCompiler,
 	jobListRenderer bitemplate.JobListRenderer,
 	renderedJobListCompressor bitemplate.RenderedJobListCompressor,
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo,
+	renderedJobListDiffer bitemplate.RenderedJobListDiffer,
 	blobstore biblobstore.Blobstore,
 	logger boshlog.Logger,
 ) Builder {
 	return &builder{
-		releaseJobResolver:        releaseJobResolver,
-		jobDependencyCompiler:     jobDependencyCompiler,
-		jobListRenderer:           jobListRenderer,
-		renderedJobListCompressor: renderedJobListCompressor,
-		blobstore:                 blobstore,
-		logger:                    logger,
-		logTag:                    "instanceStateBuilder",
+		releaseJobResolver:         releaseJobResolver,
+		jobDependencyCompiler:      jobDependencyCompiler,
+		jobListRenderer:            jobListRenderer,
+		renderedJobListCompressor:  renderedJobListCompressor,
+		renderedJobListArchiveRepo: renderedJobListArchiveRepo,
+		renderedJobListDiffer:      renderedJobListDiffer,
+		blobstore:                  blobstore,
+		logger:                     logger,
+		logTag:                     "instanceStateBuilder",
 	}
 }
 
 type renderedJobs struct {
 	BlobstoreID string
 	Archive     bitemplate.RenderedJobListArchive
+	// Diff is the masked diff from the last applied templates (empty if unchanged or never applied)
+	Diff string
 }
 
-func (b *builder) Build(jobName string, instanceID int, deploymentManifest bideplmanifest.Manifest, stage biui.Stage) (State, error) {
+func (b *builder) Build(jobName string, instanceID int, deploymentManifest bideplmanifest.Manifest, stemcell bistemcell.ExtractedStemcell, stage biui.Stage) (State, error) {
 	deploymentJob, found := deploymentManifest.FindJobByName(jobName)
 	if !found {
 		return nil, bosherr.Errorf("Job '%s' not found in deployment manifest", jobName)
@@ -62,12 +72,24 @@ func (b *builder) Build(jobName string, instanceID int, deploymentManifest bidep
 		return nil, bosherr.WrapErrorf(err, "Resolving jobs for instance '%s/%d'", jobName, instanceID)
 	}
 
-	renderedJobTemplates, err := b.renderJobTemplates(releaseJobs, deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, stage)
+	address, err := deploymentManifest.DefaultIP(deploymentJob.Name)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding the default IP of job '%s'", jobName)
+	}
+
+	renderedJobTemplates, err := b.renderJobTemplates(releaseJobs, b.linkOverrides(deploymentJob.Templates), deploymentJob.Properties, deploymentManifest.Properties, deploymentManifest.Name, address, stage)
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Rendering job templates for instance '%s/%d'", jobName, instanceID)
 	}
 
-	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(releaseJobs, stage)
+	stemcellManifest := stemcell.Manifest()
+	compiledPackageStemcell := bistatepkg.Stemcell{
+		Name:    stemcellManifest.Name,
+		Version: stemcellManifest.Version,
+		OS:      stemcellManifest.OS,
+	}
+
+	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(releaseJobs, compiledPackageStemcell, stage)
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Compiling job package dependencies for instance '%s/%d'", jobName, instanceID)
 	}
@@ -120,6 +142,7 @@ func (b *builder) Build(jobName string, instanceID int, deploymentManifest bidep
 		compiledPackages:       compiledDeploymentPackageRefs,
 		renderedJobs:           renderedJobRefs,
 		renderedJobListArchive: renderedJobListArchiveBlobRef,
+		renderedJobListDiff:    renderedJobTemplates.Diff,
 		hash: renderedJobTemplates.Archive.Fingerprint(),
 	}, nil
 }
@@ -137,20 +160,34 @@ func (b *builder) resolveJobs(jobRefs []bideplmanifest.ReleaseJobRef) ([]bireljo
 	return releaseJobs, nil
 }
 
+func (b *builder) linkOverrides(jobRefs []bideplmanifest.ReleaseJobRef) map[string]bitemplate.LinkOverrides {
+	linkOverrides := map[string]bitemplate.LinkOverrides{}
+	for _, jobRef := range jobRefs {
+		linkOverrides[jobRef.Name] = bitemplate.LinkOverrides{
+			Consumes: jobRef.Consumes,
+			Provides: jobRef.Provides,
+		}
+	}
+	return linkOverrides
+}
+
 // renderJobTemplates renders all the release job templates for multiple release jobs specified by a deployment job
 func (b *builder) renderJobTemplates(
 	releaseJobs []bireljob.Job,
+	linkOverrides map[string]bitemplate.LinkOverrides,
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
+	address string,
 	stage biui.Stage,
 ) (renderedJobs, error) {
 	var (
 		renderedJobListArchive bitemplate.RenderedJobListArchive
 		blobID                 string
+		diff                   string
 	)
 	err := stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, linkOverrides, jobProperties, globalProperties, deploymentName, address)
 		if err != nil {
 			return err
 		}
@@ -167,6 +204,23 @@ func (b *builder) renderJobTemplates(
 			return bosherr.WrapErrorf(err, "Uploading rendered job template archive '%s' to the blobstore", renderedJobListArchive.Path())
 		}
 
+		secretValues := b.renderedJobListDiffer.SecretValues(renderedJobList.All(), jobProperties, globalProperties)
+
+		// comparing with the last applied templates is informational, so it does not fail the deploy
+		lastArchive, found := b.renderedJobListArchiveRepo.FindLast()
+		if found {
+			diff, err = b.renderedJobListDiffer.Diff(lastArchive, renderedJobList.All(), secretValues)
+			if err != nil {
+				b.logger.Warn(b.logTag, "Failed to compare with the last applied job templates: %s", err.Error())
+			}
+		}
+
+		// keep the rendered templates, to be recorded as applied once the instance is updated
+		err = b.renderedJobListArchiveRepo.SavePending(renderedJobListArchive, secretValues)
+		if err != nil {
+			b.logger.Warn(b.logTag, "Failed to save rendered job list archive: %s", err.Error())
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -176,5 +230,6 @@ func (b *builder) renderJobTemplates(
 	return renderedJobs{
 		BlobstoreID: blobID,
 		Archive:     renderedJobListArchive,
+		Diff:        diff,
 	}, nil
 }
@@ -2,6 +2,7 @@ package state
 
 import (
 	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	bideplrel "github.com/cloudfoundry/bosh-init/deployment/release"
 	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
@@ -15,38 +16,54 @@ type BuilderFactory interface {
 }
 
 type builderFactory struct {
-	packageRepo               bistatepkg.CompiledPackageRepo
-	releaseJobResolver        bideplrel.JobResolver
-	jobRenderer               bitemplate.JobListRenderer
-	renderedJobListCompressor bitemplate.RenderedJobListCompressor
-	logger                    boshlog.Logger
+	packageRepo                bistatepkg.CompiledPackageRepo
+	packageCache               bistatepkg.CompiledPackageCache
+	sha1Calculator             bicrypto.SHA1Calculator
+	releaseJobResolver         bideplrel.JobResolver
+	jobRenderer                bitemplate.JobListRenderer
+	renderedJobListCompressor  bitemplate.RenderedJobListCompressor
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo
+	renderedJobListDiffer      bitemplate.RenderedJobListDiffer
+	logger                     boshlog.Logger
 }
 
+// NewBuilderFactory returns a BuilderFactory.
+// The packageCache is optional: when nil, compiled packages are not shared with other deployments.
 func NewBuilderFactory(
 	packageRepo bistatepkg.CompiledPackageRepo,
+	packageCache bistatepkg.CompiledPackageCache,
+	sha1Calculator bicrypto.SHA1Calculator,
 	releaseJobResolver bideplrel.JobResolver,
 	jobRenderer bitemplate.JobListRenderer,
 	renderedJobListCompressor bitemplate.RenderedJobListCompressor,
+	renderedJobListArchiveRepo bitemplate.RenderedJobListArchiveRepo,
+	renderedJobListDiffer bitemplate.RenderedJobListDiffer,
 	logger boshlog.Logger,
 ) BuilderFactory {
 	return &builderFactory{
-		packageRepo:               packageRepo,
-		releaseJobResolver:        releaseJobResolver,
-		jobRenderer:               jobRenderer,
-		renderedJobListCompressor: renderedJobListCompressor,
+		packageRepo:                packageRepo,
+		packageCache:               packageCache,
+		sha1Calculator:             sha1Calculator,
+		releaseJobResolver:         releaseJobResolver,
+		jobRenderer:                jobRenderer,
+		renderedJobListCompressor:  renderedJobListCompressor,
+		renderedJobListArchiveRepo: renderedJobListArchiveRepo,
+		renderedJobListDiffer:      renderedJobListDiffer,
 		logger: logger,
 	}
 }
 
 func (f *builderFactory) NewBuilder(blobstore biblobstore.Blobstore, agentClient biagentclient.AgentClient) Builder {
-	packageCompiler := NewRemotePackageCompiler(blobstore, agentClient, f.packageRepo)
-	jobDependencyCompiler := bistatejob.NewDependencyCompiler(packageCompiler, f.logger)
+	packageCompiler := NewRemotePackageCompiler(blobstore, agentClient, f.packageRepo, f.packageCache, f.sha1Calculator, f.logger)
+	jobDependencyCompiler := bistatejob.NewDependencyCompiler(packageCompiler, packageCompiler, f.packageRepo, f.logger)
 
 	return NewBuilder(
 		f.releaseJobResolver,
 		jobDependencyCompiler,
 		f.jobRenderer,
 		f.renderedJobListCompressor,
+		f.renderedJobListArchiveRepo,
+		f.renderedJobListDiffer,
 		blobstore,
 		f.logger,
 	)
@@ -17,7 +17,12 @@ import (
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
+	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
+	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
 	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
 )
@@ -42,6 +47,8 @@ func describeBuilder() {
 		mockDependencyCompiler *mock_state_job.MockDependencyCompiler
 		mockJobListRenderer    *mock_template.MockJobListRenderer
 		mockCompressor         *mock_template.MockRenderedJobListCompressor
+		mockArchiveRepo        *mock_template.MockRenderedJobListArchiveRepo
+		mockDiffer             *mock_template.MockRenderedJobListDiffer
 		mockBlobstore          *mock_blobstore.MockBlobstore
 
 		stateBuilder Builder
@@ -54,6 +61,8 @@ func describeBuilder() {
 		mockDependencyCompiler = mock_state_job.NewMockDependencyCompiler(mockCtrl)
 		mockJobListRenderer = mock_template.NewMockJobListRenderer(mockCtrl)
 		mockCompressor = mock_template.NewMockRenderedJobListCompressor(mockCtrl)
+		mockArchiveRepo = mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+		mockDiffer = mock_template.NewMockRenderedJobListDiffer(mockCtrl)
 		mockBlobstore = mock_blobstore.NewMockBlobstore(mockCtrl)
 	})
 
@@ -61,10 +70,13 @@ func describeBuilder() {
 		var (
 			mockRenderedJobList        *mock_template.MockRenderedJobList
 			mockRenderedJobListArchive *mock_template.MockRenderedJobListArchive
+			expectSavePending          *gomock.Call
+			expectFindLast             *gomock.Call
 
 			jobName            string
 			instanceID         int
 			deploymentManifest bideplmanifest.Manifest
+			extractedStemcell  bistemcell.ExtractedStemcell
 			fakeStage          *fakebiui.FakeStage
 
 			releasePackageLibyaml *birelpkg.Package
@@ -88,7 +100,8 @@ func describeBuilder() {
 						Name: "fake-deployment-job-name",
 						Networks: []bideplmanifest.JobNetwork{
 							{
-								Name: "fake-network-name",
+								Name:      "fake-network-name",
+								StaticIPs: []string{"fake-static-ip"},
 							},
 						},
 						Templates: []bideplmanifest.ReleaseJobRef{
@@ -118,11 +131,23 @@ func describeBuilder() {
 
 			fakeStage = fakebiui.NewFakeStage()
 
+			extractedStemcell = bistemcell.NewExtractedStemcell(
+				bistemcell.Manifest{
+					Name:    "fake-stemcell-name",
+					Version: "fake-stemcell-version",
+					OS:      "fake-stemcell-os",
+				},
+				"/fake-extracted-stemcell-path",
+				fakesys.NewFakeFileSystem(),
+			)
+
 			stateBuilder = NewBuilder(
 				mockReleaseJobResolver,
 				mockDependencyCompiler,
 				mockJobListRenderer,
 				mockCompressor,
+				mockArchiveRepo,
+				mockDiffer,
 				mockBlobstore,
 				logger,
 			)
@@ -179,7 +204,11 @@ func describeBuilder() {
 					SHA1:        "fake-package-compiled-archive-sha1-cpi",
 				},
 			}
-			expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
+			expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, bistatepkg.Stemcell{
+				Name:    "fake-stemcell-name",
+				Version: "fake-stemcell-version",
+				OS:      "fake-stemcell-os",
+			}, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
 
 			jobProperties := biproperty.Map{
 				"fake-job-property": "fake-job-property-value",
@@ -187,7 +216,10 @@ func describeBuilder() {
 			globalProperties := biproperty.Map{
 				"fake-job-property": "fake-global-property-value",
 			}
-			mockJobListRenderer.EXPECT().Render(releaseJobs, jobProperties, globalProperties, "fake-deployment-name").Return(mockRenderedJobList, nil)
+			linkOverrides := map[string]bitemplate.LinkOverrides{
+				"fake-release-job-name": {},
+			}
+			mockJobListRenderer.EXPECT().Render(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-deployment-name", "fake-static-ip").Return(mockRenderedJobList, nil)
 
 			mockRenderedJobList.EXPECT().DeleteSilently()
 
@@ -200,23 +232,92 @@ func describeBuilder() {
 			mockRenderedJobListArchive.EXPECT().Fingerprint().Return("fake-rendered-job-list-fingerprint")
 
 			mockBlobstore.EXPECT().Add("fake-rendered-job-list-archive-path").Return("fake-rendered-job-list-archive-blob-id", nil)
+
+			renderedJobs := []bitemplate.RenderedJob{mock_template.NewMockRenderedJob(mockCtrl)}
+			mockRenderedJobList.EXPECT().All().Return(renderedJobs).AnyTimes()
+			mockDiffer.EXPECT().SecretValues(renderedJobs, jobProperties, globalProperties).Return([]string{"fake-secret-value"})
+
+			expectFindLast = mockArchiveRepo.EXPECT().FindLast().Return(bitemplate.AppliedRenderedJobListArchive{}, false).AnyTimes()
+			expectSavePending = mockArchiveRepo.EXPECT().SavePending(mockRenderedJobListArchive, []string{"fake-secret-value"}).Return(nil).AnyTimes()
+		})
+
+		It("saves the rendered job list archive as pending", func() {
+			expectSavePending.Times(1)
+
+			_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when saving the rendered job list archive fails", func() {
+			JustBeforeEach(func() {
+				expectSavePending.Return(bosherr.Error("fake-save-error"))
+			})
+
+			It("does not return an error", func() {
+				_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		It("builds a new instance state without rendered job template changes when none were applied before", func() {
+			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(state.RenderedJobListDiff()).To(BeEmpty())
+		})
+
+		Context("when rendered job templates were applied before", func() {
+			var (
+				expectDiff *gomock.Call
+			)
+
+			JustBeforeEach(func() {
+				lastArchive := bitemplate.AppliedRenderedJobListArchive{
+					Path:             "/fake-last-archive-path",
+					SecretValuesPath: "/fake-last-archive-secrets-path",
+				}
+				expectFindLast.Return(lastArchive, true)
+
+				expectDiff = mockDiffer.EXPECT().Diff(lastArchive, gomock.Any(), []string{"fake-secret-value"}).Return("fake-diff", nil)
+			})
+
+			It("builds a new instance state with the changes from the last applied job templates", func() {
+				state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(state.RenderedJobListDiff()).To(Equal("fake-diff"))
+			})
+
+			Context("when comparing with the last applied job templates fails", func() {
+				JustBeforeEach(func() {
+					expectDiff.Return("", bosherr.Error("fake-diff-error"))
+				})
+
+				It("does not return an error", func() {
+					state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(state.RenderedJobListDiff()).To(BeEmpty())
+				})
+			})
 		})
 
 		It("compiles the dependencies of the jobs", func() {
 			expectCompile.Times(1)
 
-			_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("builds a new instance state with zero-to-many networks", func() {
-			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(state.NetworkInterfaces()).To(ContainElement(NetworkRef{
 				Name: "fake-network-name",
 				Interface: biproperty.Map{
 					"type":    "fake-network-type",
+					"ip":      "fake-static-ip",
 					"default": []bideplmanifest.NetworkDefault{"dns", "gateway"},
 					"cloud_properties": biproperty.Map{
 						"fake-network-cloud-property": "fake-network-cloud-property-value",
@@ -227,7 +328,7 @@ func describeBuilder() {
 		})
 
 		It("builds a new instance state with zero-to-many rendered jobs from one or more releases", func() {
-			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(state.RenderedJobs()).To(ContainElement(JobRef{
@@ -244,7 +345,7 @@ func describeBuilder() {
 		})
 
 		It("prints ui stages for compiling packages and rendering job templates", func() {
-			_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			_, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
@@ -254,7 +355,7 @@ func describeBuilder() {
 		})
 
 		It("builds a new instance state with the compiled packages required by the release jobs", func() {
-			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(state.CompiledPackages()).To(ContainElement(PackageRef{
@@ -276,7 +377,7 @@ func describeBuilder() {
 		})
 
 		It("builds a new instance state that includes transitively dependent compiled packages", func() {
-			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(state.CompiledPackages()).To(ContainElement(PackageRef{
@@ -312,7 +413,7 @@ func describeBuilder() {
 			})
 
 			It("does not recompile dependant packages", func() {
-				state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+				state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(state.CompiledPackages()).To(ContainElement(PackageRef{
@@ -344,7 +445,7 @@ func describeBuilder() {
 		})
 
 		It("builds an instance state that can be converted to an ApplySpec", func() {
-			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, fakeStage)
+			state, err := stateBuilder.Build(jobName, instanceID, deploymentManifest, extractedStemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(state.ToApplySpec()).To(Equal(bias.ApplySpec{
@@ -353,6 +454,7 @@ func describeBuilder() {
 				Networks: map[string]biproperty.Map{
 					"fake-network-name": biproperty.Map{
 						"type":    "fake-network-type",
+						"ip":      "fake-static-ip",
 						"default": []bideplmanifest.NetworkDefault{"dns", "gateway"},
 						"cloud_properties": biproperty.Map{
 							"fake-network-cloud-property": "fake-network-cloud-property-value",
@@ -10,6 +10,7 @@ import (
 	applyspec "github.com/cloudfoundry/bosh-init/deployment/applyspec"
 	state "github.com/cloudfoundry/bosh-init/deployment/instance/state"
 	manifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
+	stemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	ui "github.com/cloudfoundry/bosh-init/ui"
 )
 
@@ -65,15 +66,15 @@ func (_m *MockBuilder) EXPECT() *_MockBuilderRecorder {
 	return _m.recorder
 }
 
-func (_m *MockBuilder) Build(_param0 string, _param1 int, _param2 manifest.Manifest, _param3 ui.Stage) (state.State, error) {
-	ret := _m.ctrl.Call(_m, "Build", _param0, _param1, _param2, _param3)
+func (_m *MockBuilder) Build(_param0 string, _param1 int, _param2 manifest.Manifest, _param3 stemcell.ExtractedStemcell, _param4 ui.Stage) (state.State, error) {
+	ret := _m.ctrl.Call(_m, "Build", _param0, _param1, _param2, _param3, _param4)
 	ret0, _ := ret[0].(state.State)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockBuilderRecorder) Build(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Build", arg0, arg1, arg2, arg3)
+func (_mr *_MockBuilderRecorder) Build(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Build", arg0, arg1, arg2, arg3, arg4)
 }
 
 // Mock of State interface
@@ -127,6 +128,16 @@ func (_mr *_MockStateRecorder) RenderedJobListArchive() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RenderedJobListArchive")
 }
 
+func (_m *MockState) RenderedJobListDiff() string {
+	ret := _m.ctrl.Call(_m, "RenderedJobListDiff")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+func (_mr *_MockStateRecorder) RenderedJobListDiff() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "RenderedJobListDiff")
+}
+
 func (_m *MockState) RenderedJobs() []state.JobRef {
 	ret := _m.ctrl.Call(_m, "RenderedJobs")
 	ret0, _ := ret[0].([]state.JobRef)
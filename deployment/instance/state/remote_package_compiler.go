@@ -2,27 +2,61 @@ package state
 
 import (
 	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
 
+// RemotePackageCompiler compiles packages with the agent, or uploads their pre-compiled archives for the agent
+type RemotePackageCompiler interface {
+	bistatepkg.Compiler
+	bistatepkg.Importer
+}
+
 type remotePackageCompiler struct {
-	blobstore   biblobstore.Blobstore
-	agentClient biagentclient.AgentClient
-	packageRepo bistatepkg.CompiledPackageRepo
+	blobstore      biblobstore.Blobstore
+	agentClient    biagentclient.AgentClient
+	packageRepo    bistatepkg.CompiledPackageRepo
+	packageCache   bistatepkg.CompiledPackageCache
+	sha1Calculator bicrypto.SHA1Calculator
+	logger         boshlog.Logger
+	logTag         string
 }
 
-func NewRemotePackageCompiler(blobstore biblobstore.Blobstore, agentClient biagentclient.AgentClient, packageRepo bistatepkg.CompiledPackageRepo) bistatepkg.Compiler {
+// NewRemotePackageCompiler returns a compiler that compiles packages with the agent.
+// The packageCache is optional: when nil, compiled packages are not shared with other deployments.
+func NewRemotePackageCompiler(
+	blobstore biblobstore.Blobstore,
+	agentClient biagentclient.AgentClient,
+	packageRepo bistatepkg.CompiledPackageRepo,
+	packageCache bistatepkg.CompiledPackageCache,
+	sha1Calculator bicrypto.SHA1Calculator,
+	logger boshlog.Logger,
+) RemotePackageCompiler {
 	return &remotePackageCompiler{
-		blobstore:   blobstore,
-		agentClient: agentClient,
-		packageRepo: packageRepo,
+		blobstore:      blobstore,
+		agentClient:    agentClient,
+		packageRepo:    packageRepo,
+		packageCache:   packageCache,
+		sha1Calculator: sha1Calculator,
+		logger:         logger,
+		logTag:         "remotePackageCompiler",
 	}
 }
 
-func (c *remotePackageCompiler) Compile(releasePackage *birelpkg.Package) (record bistatepkg.CompiledPackageRecord, err error) {
+func (c *remotePackageCompiler) Compile(releasePackage *birelpkg.Package, stemcell bistatepkg.Stemcell) (record bistatepkg.CompiledPackageRecord, err error) {
+	if c.packageCache != nil {
+		cachedPackage, found, err := c.packageCache.Find(*releasePackage, stemcell)
+		if err != nil {
+			return record, bosherr.WrapErrorf(err, "Finding package '%s/%s' in compiled package cache", releasePackage.Name, releasePackage.Fingerprint)
+		}
+		if found {
+			return c.addCompiledPackage(releasePackage, stemcell, cachedPackage.ArchivePath, cachedPackage.SHA1)
+		}
+	}
 
 	blobID, err := c.blobstore.Add(releasePackage.ArchivePath)
 	if err != nil {
@@ -40,7 +74,7 @@ func (c *remotePackageCompiler) Compile(releasePackage *birelpkg.Package) (recor
 	// Only install the package's immediate dependencies when compiling (not all transitive dependencies).
 	packageDependencies := make([]biagentclient.BlobRef, len(releasePackage.Dependencies), len(releasePackage.Dependencies))
 	for i, dependency := range releasePackage.Dependencies {
-		compiledPackageRecord, found, err := c.packageRepo.Find(*dependency)
+		compiledPackageRecord, found, err := c.packageRepo.Find(*dependency, stemcell)
 		if err != nil {
 			return record, bosherr.WrapErrorf(
 				err,
@@ -78,10 +112,72 @@ func (c *remotePackageCompiler) Compile(releasePackage *birelpkg.Package) (recor
 		BlobSHA1: compiledPackageRef.SHA1,
 	}
 
-	err = c.packageRepo.Save(*releasePackage, record)
+	err = c.packageRepo.Save(*releasePackage, stemcell, record)
+	if err != nil {
+		return record, bosherr.WrapErrorf(err, "Saving compiled package record %#v of package %#v", record, releasePackage)
+	}
+
+	// the package is compiled even if it cannot be cached, the next deployment will only have to compile it again
+	if c.packageCache != nil {
+		err = c.cachePackage(releasePackage, stemcell, record)
+		if err != nil {
+			c.logger.Warn(c.logTag, "Failed to cache compiled package '%s/%s': %s", releasePackage.Name, releasePackage.Fingerprint, err.Error())
+		}
+	}
+
+	return record, nil
+}
+
+func (c *remotePackageCompiler) Import(releasePackage *birelpkg.Package, stemcell bistatepkg.Stemcell) (record bistatepkg.CompiledPackageRecord, err error) {
+	if releasePackage.Compiled == nil {
+		return record, bosherr.Errorf("Package '%s/%s' does not have a compiled archive", releasePackage.Name, releasePackage.Fingerprint)
+	}
+
+	// the agent verifies the sha1 of the blob against the uploaded archive, not against the release
+	archiveSHA1, err := c.sha1Calculator.Calculate(releasePackage.Compiled.ArchivePath)
+	if err != nil {
+		return record, bosherr.WrapErrorf(err, "Calculating sha1 of compiled package archive '%s'", releasePackage.Compiled.ArchivePath)
+	}
+	if archiveSHA1 != releasePackage.Compiled.SHA1 {
+		return record, bosherr.Errorf(
+			"Compiled package '%s/%s' archive sha1 '%s' does not match the sha1 '%s' of the release",
+			releasePackage.Name,
+			releasePackage.Fingerprint,
+			archiveSHA1,
+			releasePackage.Compiled.SHA1,
+		)
+	}
+
+	return c.addCompiledPackage(releasePackage, stemcell, releasePackage.Compiled.ArchivePath, releasePackage.Compiled.SHA1)
+}
+
+// addCompiledPackage uploads an already compiled package archive to the blobstore and records it as compiled
+func (c *remotePackageCompiler) addCompiledPackage(releasePackage *birelpkg.Package, stemcell bistatepkg.Stemcell, archivePath string, sha1 string) (record bistatepkg.CompiledPackageRecord, err error) {
+	blobID, err := c.blobstore.Add(archivePath)
+	if err != nil {
+		return record, bosherr.WrapErrorf(err, "Adding compiled package archive '%s' to blobstore", archivePath)
+	}
+
+	record = bistatepkg.CompiledPackageRecord{
+		BlobID:   blobID,
+		BlobSHA1: sha1,
+	}
+
+	err = c.packageRepo.Save(*releasePackage, stemcell, record)
 	if err != nil {
 		return record, bosherr.WrapErrorf(err, "Saving compiled package record %#v of package %#v", record, releasePackage)
 	}
 
 	return record, nil
 }
+
+// cachePackage downloads the compiled package from the blobstore into the local compiled package cache
+func (c *remotePackageCompiler) cachePackage(releasePackage *birelpkg.Package, stemcell bistatepkg.Stemcell, record bistatepkg.CompiledPackageRecord) error {
+	localBlob, err := c.blobstore.Get(record.BlobID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Getting compiled package blob '%s' from blobstore", record.BlobID)
+	}
+	defer localBlob.DeleteSilently()
+
+	return c.packageCache.Save(*releasePackage, stemcell, localBlob.Path(), record.BlobSHA1)
+}
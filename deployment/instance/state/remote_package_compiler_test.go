@@ -9,10 +9,15 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	biblobstore "github.com/cloudfoundry/bosh-init/blobstore"
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
 	biagentclient "github.com/cloudfoundry/bosh-init/deployment/agentclient"
 	biindex "github.com/cloudfoundry/bosh-init/index"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
 
 var _ = Describe("RemotePackageCompiler", describeRemotePackageCompiler)
@@ -30,6 +35,7 @@ func describeRemotePackageCompiler() {
 
 	var (
 		packageRepo bistatepkg.CompiledPackageRepo
+		stemcell    bistatepkg.Stemcell
 
 		pkgDependency *birelpkg.Package
 		pkg           *birelpkg.Package
@@ -37,9 +43,11 @@ func describeRemotePackageCompiler() {
 		mockBlobstore   *mock_blobstore.MockBlobstore
 		mockAgentClient *mock_agentclient.MockAgentClient
 
+		fakeSHA1Calculator *fakebicrypto.FakeSha1Calculator
+
 		archivePath = "fake-archive-path"
 
-		remotePackageCompiler bistatepkg.Compiler
+		remotePackageCompiler RemotePackageCompiler
 
 		compiledPackages map[bistatepkg.CompiledPackageRecord]*birelpkg.Package
 
@@ -53,7 +61,14 @@ func describeRemotePackageCompiler() {
 
 		index := biindex.NewInMemoryIndex()
 		packageRepo = bistatepkg.NewCompiledPackageRepo(index)
-		remotePackageCompiler = NewRemotePackageCompiler(mockBlobstore, mockAgentClient, packageRepo)
+		fakeSHA1Calculator = fakebicrypto.NewFakeSha1Calculator()
+		remotePackageCompiler = NewRemotePackageCompiler(mockBlobstore, mockAgentClient, packageRepo, nil, fakeSHA1Calculator, boshlog.NewLogger(boshlog.LevelNone))
+
+		stemcell = bistatepkg.Stemcell{
+			Name:    "fake-stemcell-name",
+			Version: "fake-stemcell-version",
+			OS:      "fake-stemcell-os",
+		}
 
 		pkgDependency = &birelpkg.Package{
 			Name:        "fake-package-name-dep",
@@ -81,7 +96,7 @@ func describeRemotePackageCompiler() {
 	JustBeforeEach(func() {
 		// add compiled packages to the repo
 		for record, dependency := range compiledPackages {
-			err := packageRepo.Save(*dependency, record)
+			err := packageRepo.Save(*dependency, stemcell, record)
 			Expect(err).ToNot(HaveOccurred())
 		}
 
@@ -117,7 +132,7 @@ func describeRemotePackageCompiler() {
 				expectAgentCompile.Times(1),
 			)
 
-			compiledPackageRecord, err := remotePackageCompiler.Compile(pkg)
+			compiledPackageRecord, err := remotePackageCompiler.Compile(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(compiledPackageRecord).To(Equal(bistatepkg.CompiledPackageRecord{
 				BlobID:   "fake-compiled-package-blob-id",
@@ -126,10 +141,10 @@ func describeRemotePackageCompiler() {
 		})
 
 		It("saves the compiled package ref in the package repo", func() {
-			compiledPackageRecord, err := remotePackageCompiler.Compile(pkg)
+			compiledPackageRecord, err := remotePackageCompiler.Compile(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 
-			record, found, err := packageRepo.Find(*pkg)
+			record, found, err := packageRepo.Find(*pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeTrue())
 			Expect(record).To(Equal(compiledPackageRecord))
@@ -141,10 +156,173 @@ func describeRemotePackageCompiler() {
 			})
 
 			It("returns an error", func() {
-				_, err := remotePackageCompiler.Compile(pkg)
+				_, err := remotePackageCompiler.Compile(pkg, stemcell)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Remote compilation failure: Package 'fake-package-name/fake-package-fingerprint' requires package 'fake-package-name-dep/fake-package-fingerprint-dep', but it has not been compiled"))
 			})
 		})
+
+		Context("when the compiled package cache is enabled", func() {
+			var (
+				fakeFs       *fakesys.FakeFileSystem
+				packageCache bistatepkg.CompiledPackageCache
+			)
+
+			BeforeEach(func() {
+				fakeFs = fakesys.NewFakeFileSystem()
+				cacheIndex := biindex.NewFileIndex("/cache/index.json", fakeFs)
+				packageCache = bistatepkg.NewCompiledPackageCache("/cache", cacheIndex, fakeSHA1Calculator, fakeFs, boshlog.NewLogger(boshlog.LevelNone))
+				remotePackageCompiler = NewRemotePackageCompiler(mockBlobstore, mockAgentClient, packageRepo, packageCache, fakeSHA1Calculator, boshlog.NewLogger(boshlog.LevelNone))
+			})
+
+			It("downloads the compiled package into the cache", func() {
+				fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+					"/cache/fake-compiled-package-sha1.tgz": {Sha1: "fake-compiled-package-sha1"},
+				})
+				fakeFs.WriteFileString("/downloaded-blob", "fake-compiled-package-content")
+				localBlob := biblobstore.NewLocalBlob("/downloaded-blob", fakeFs, boshlog.NewLogger(boshlog.LevelNone))
+				mockBlobstore.EXPECT().Get("fake-compiled-package-blob-id").Return(localBlob, nil)
+
+				_, err := remotePackageCompiler.Compile(pkg, stemcell)
+				Expect(err).ToNot(HaveOccurred())
+
+				cachedPackage, found, err := packageCache.Find(*pkg, stemcell)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeTrue())
+				Expect(cachedPackage.SHA1).To(Equal("fake-compiled-package-sha1"))
+				Expect(fakeFs.ReadFileString(cachedPackage.ArchivePath)).To(Equal("fake-compiled-package-content"))
+				Expect(fakeFs.FileExists("/downloaded-blob")).To(BeFalse())
+			})
+
+			It("returns the compiled package when it cannot be downloaded into the cache", func() {
+				mockBlobstore.EXPECT().Get("fake-compiled-package-blob-id").Return(nil, bosherr.Error("fake-get-error"))
+
+				compiledPackageRecord, err := remotePackageCompiler.Compile(pkg, stemcell)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(compiledPackageRecord).To(Equal(bistatepkg.CompiledPackageRecord{
+					BlobID:   "fake-compiled-package-blob-id",
+					BlobSHA1: "fake-compiled-package-sha1",
+				}))
+
+				_, found, err := packageCache.Find(*pkg, stemcell)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(found).To(BeFalse())
+			})
+
+			Context("when the package is already in the cache", func() {
+				BeforeEach(func() {
+					fakeFs.WriteFileString("/compiled-package.tgz", "fake-compiled-package-content")
+					err := packageCache.Save(*pkg, stemcell, "/compiled-package.tgz", "fake-cached-package-sha1")
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("uploads the cached package without compiling it with the agent", func() {
+					fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+						"/cache/fake-cached-package-sha1.tgz": {Sha1: "fake-cached-package-sha1"},
+					})
+					expectAgentCompile.Times(0)
+					mockBlobstore.EXPECT().Add("/cache/fake-cached-package-sha1.tgz").Return("fake-cached-package-blob-id", nil)
+
+					compiledPackageRecord, err := remotePackageCompiler.Compile(pkg, stemcell)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(compiledPackageRecord).To(Equal(bistatepkg.CompiledPackageRecord{
+						BlobID:   "fake-cached-package-blob-id",
+						BlobSHA1: "fake-cached-package-sha1",
+					}))
+
+					record, found, err := packageRepo.Find(*pkg, stemcell)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(found).To(BeTrue())
+					Expect(record).To(Equal(compiledPackageRecord))
+				})
+
+				It("compiles the package with the agent when the cached archive does not match its sha1", func() {
+					fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+						"/cache/fake-cached-package-sha1.tgz": {Sha1: "fake-corrupted-package-sha1"},
+					})
+					mockBlobstore.EXPECT().Get("fake-compiled-package-blob-id").Return(nil, bosherr.Error("fake-get-error"))
+
+					compiledPackageRecord, err := remotePackageCompiler.Compile(pkg, stemcell)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(compiledPackageRecord).To(Equal(bistatepkg.CompiledPackageRecord{
+						BlobID:   "fake-compiled-package-blob-id",
+						BlobSHA1: "fake-compiled-package-sha1",
+					}))
+					Expect(fakeFs.FileExists("/cache/fake-cached-package-sha1.tgz")).To(BeFalse())
+				})
+			})
+		})
+	})
+
+	Describe("Import", func() {
+		BeforeEach(func() {
+			pkg.Compiled = &birelpkg.CompiledArchive{
+				Stemcell:    "fake-stemcell-os/fake-stemcell-version",
+				SHA1:        "fake-compiled-archive-sha1",
+				ArchivePath: "fake-compiled-archive-path",
+			}
+			fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+				"fake-compiled-archive-path": {Sha1: "fake-compiled-archive-sha1"},
+			})
+		})
+
+		It("uploads the compiled archive to the blobstore without compiling it with the agent", func() {
+			expectAgentCompile.Times(0)
+			mockBlobstore.EXPECT().Add("fake-compiled-archive-path").Return("fake-compiled-archive-blob-id", nil)
+
+			compiledPackageRecord, err := remotePackageCompiler.Import(pkg, stemcell)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiledPackageRecord).To(Equal(bistatepkg.CompiledPackageRecord{
+				BlobID:   "fake-compiled-archive-blob-id",
+				BlobSHA1: "fake-compiled-archive-sha1",
+			}))
+
+			record, found, err := packageRepo.Find(*pkg, stemcell)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(record).To(Equal(compiledPackageRecord))
+		})
+
+		Context("when the package does not have a compiled archive", func() {
+			BeforeEach(func() {
+				pkg.Compiled = nil
+			})
+
+			It("returns an error", func() {
+				_, err := remotePackageCompiler.Import(pkg, stemcell)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Package 'fake-package-name/fake-package-fingerprint' does not have a compiled archive"))
+			})
+		})
+
+		Context("when the sha1 of the compiled archive does not match the release", func() {
+			BeforeEach(func() {
+				fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+					"fake-compiled-archive-path": {Sha1: "fake-other-sha1"},
+				})
+			})
+
+			It("returns an error without uploading the archive", func() {
+				mockBlobstore.EXPECT().Add(gomock.Any()).Times(0)
+
+				_, err := remotePackageCompiler.Import(pkg, stemcell)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Compiled package 'fake-package-name/fake-package-fingerprint' archive sha1 'fake-other-sha1' does not match the sha1 'fake-compiled-archive-sha1' of the release"))
+			})
+		})
+
+		Context("when calculating the sha1 of the compiled archive fails", func() {
+			BeforeEach(func() {
+				fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+					"fake-compiled-archive-path": {Err: bosherr.Error("fake-calculate-error")},
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := remotePackageCompiler.Import(pkg, stemcell)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-calculate-error"))
+			})
+		})
 	})
 }
@@ -10,6 +10,9 @@ type State interface {
 	RenderedJobs() []JobRef
 	CompiledPackages() []PackageRef
 	RenderedJobListArchive() BlobRef
+	// RenderedJobListDiff is the diff from the last applied job templates, with secret property values masked.
+	// It is empty when the templates did not change or were never applied.
+	RenderedJobListDiff() string
 	ToApplySpec() bias.ApplySpec
 }
 
@@ -48,6 +51,7 @@ type state struct {
 	renderedJobs           []JobRef
 	compiledPackages       []PackageRef
 	renderedJobListArchive BlobRef
+	renderedJobListDiff    string
 	hash                   string
 }
 
@@ -81,6 +85,8 @@ func (s *state) CompiledPackages() []PackageRef { return s.compiledPackages }
 
 func (s *state) RenderedJobListArchive() BlobRef { return s.renderedJobListArchive }
 
+func (s *state) RenderedJobListDiff() string { return s.renderedJobListDiff }
+
 func (s *state) ToApplySpec() bias.ApplySpec {
 	jobTemplateList := make([]bias.Blob, len(s.renderedJobs), len(s.renderedJobs))
 	for i, renderedJob := range s.renderedJobs {
@@ -12,6 +12,7 @@ import (
 	mock_instance_state "github.com/cloudfoundry/bosh-init/deployment/instance/state/mocks"
 	mock_deployment "github.com/cloudfoundry/bosh-init/deployment/mocks"
 	mock_stemcell "github.com/cloudfoundry/bosh-init/stemcell/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -192,7 +193,10 @@ var _ = Describe("Manager", func() {
 
 			mockStateBuilderFactory = mock_instance_state.NewMockBuilderFactory(mockCtrl)
 
-			instanceFactory := biinstance.NewFactory(mockStateBuilderFactory)
+			mockRenderedJobListArchiveRepo := mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+			mockRenderedJobListArchiveRepo.EXPECT().CommitPending().AnyTimes()
+
+			instanceFactory := biinstance.NewFactory(mockStateBuilderFactory, mockRenderedJobListArchiveRepo, &fakebiui.FakeUI{})
 			instanceManagerFactory := biinstance.NewManagerFactory(sshTunnelFactory, instanceFactory, logger)
 			stemcellManagerFactory := bistemcell.NewManagerFactory(stemcellRepo)
 
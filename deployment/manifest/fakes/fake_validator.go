@@ -1,6 +1,7 @@
 package fakes
 
 import (
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
 	bideplmanifest "github.com/cloudfoundry/bosh-init/deployment/manifest"
 	birel "github.com/cloudfoundry/bosh-init/release"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
@@ -38,7 +39,8 @@ type ValidateOutput struct {
 }
 
 type ValidateReleaseJobsOutput struct {
-	Err error
+	Warnings []bivalidation.Problem
+	Err      error
 }
 
 func (v *FakeValidator) Validate(manifest bideplmanifest.Manifest, releaseSetManifest birelsetmanifest.Manifest) error {
@@ -55,18 +57,18 @@ func (v *FakeValidator) Validate(manifest bideplmanifest.Manifest, releaseSetMan
 	return validateOutput.Err
 }
 
-func (v *FakeValidator) ValidateReleaseJobs(manifest bideplmanifest.Manifest, releaseManager birel.Manager) error {
+func (v *FakeValidator) ValidateReleaseJobs(manifest bideplmanifest.Manifest, releaseManager birel.Manager) ([]bivalidation.Problem, error) {
 	v.ValidateReleaseJobsInputs = append(v.ValidateReleaseJobsInputs, ValidateReleaseJobsInput{
 		Manifest:       manifest,
 		ReleaseManager: releaseManager,
 	})
 
 	if len(v.validateReleaseJobsOutputs) == 0 {
-		return bosherr.Errorf("Unexpected FakeValidator.ValidateReleaseJobs(manifest, releaseManager) called with manifest: %#v", manifest)
+		return nil, bosherr.Errorf("Unexpected FakeValidator.ValidateReleaseJobs(manifest, releaseManager) called with manifest: %#v", manifest)
 	}
 	validateReleaseJobsOutput := v.validateReleaseJobsOutputs[0]
 	v.validateReleaseJobsOutputs = v.validateReleaseJobsOutputs[1:]
-	return validateReleaseJobsOutput.Err
+	return validateReleaseJobsOutput.Warnings, validateReleaseJobsOutput.Err
 }
 
 func (v *FakeValidator) SetValidateBehavior(outputs []ValidateOutput) {
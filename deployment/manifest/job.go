@@ -26,6 +26,11 @@ const (
 type ReleaseJobRef struct {
 	Name    string
 	Release string
+
+	// Consumes maps consumed link names to the names of the provided links to use ('consumes.<name>.from')
+	Consumes map[string]string
+	// Provides maps provided link names to the names they are provided as ('provides.<name>.as')
+	Provides map[string]string
 }
 
 type JobNetwork struct {
@@ -46,6 +46,27 @@ func (d Manifest) NetworkInterfaces(jobName string) (map[string]biproperty.Map,
 	return ifaceMap, nil
 }
 
+// DefaultIP returns the static IP of the network providing the default gateway of the job,
+// or an empty string when that network has no static IP, e.g. when it is dynamic
+func (d Manifest) DefaultIP(jobName string) (string, error) {
+	networkInterfaces, err := d.NetworkInterfaces(jobName)
+	if err != nil {
+		return "", err
+	}
+
+	for _, networkInterface := range networkInterfaces {
+		networkDefaults, _ := networkInterface["default"].([]NetworkDefault)
+		for _, networkDefault := range networkDefaults {
+			if networkDefault == NetworkDefaultGateway {
+				ip, _ := networkInterface["ip"].(string)
+				return ip, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
 func (d Manifest) JobName() string {
 	// Currently we deploy only one job
 	return d.Jobs[0].Name
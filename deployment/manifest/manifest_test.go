@@ -59,7 +59,7 @@ var _ = Describe("Manifest", func() {
 								},
 								{
 									Name:      "fake-manual-network-name",
-									StaticIPs: []string{"5.6.7.9"},
+									StaticIPs: []string{"1.2.3.9"},
 								},
 							},
 						},
@@ -89,7 +89,7 @@ var _ = Describe("Manifest", func() {
 					},
 					"fake-manual-network-name": biproperty.Map{
 						"type":             "manual",
-						"ip":               "5.6.7.9",
+						"ip":               "1.2.3.9",
 						"netmask":          "255.255.252.0",
 						"gateway":          "1.1.1.1",
 						"cloud_properties": biproperty.Map{},
@@ -186,6 +186,54 @@ var _ = Describe("Manifest", func() {
 		})
 	})
 
+	Describe("DefaultIP", func() {
+		BeforeEach(func() {
+			deploymentManifest = Manifest{
+				Networks: []Network{
+					{Name: "fake-dynamic-network-name", Type: "dynamic", CloudProperties: biproperty.Map{}},
+					{Name: "vip", Type: "vip", CloudProperties: biproperty.Map{}},
+				},
+				Jobs: []Job{
+					{
+						Name: "fake-job-name",
+						Networks: []JobNetwork{
+							{Name: "fake-dynamic-network-name", Defaults: []NetworkDefault{"dns", "gateway"}},
+							{Name: "vip", StaticIPs: []string{"1.2.3.4"}},
+						},
+					},
+					{
+						Name: "job-with-default-static-ip",
+						Networks: []JobNetwork{
+							{Name: "fake-dynamic-network-name"},
+							{Name: "vip", StaticIPs: []string{"1.2.3.4"}, Defaults: []NetworkDefault{"gateway"}},
+						},
+					},
+					{
+						Name:     "job-with-single-network",
+						Networks: []JobNetwork{{Name: "vip", StaticIPs: []string{"5.6.7.8"}}},
+					},
+				},
+			}
+		})
+
+		It("returns the static IP of the network providing the default gateway", func() {
+			Expect(deploymentManifest.DefaultIP("job-with-default-static-ip")).To(Equal("1.2.3.4"))
+		})
+
+		It("returns the static IP of the single network of the job", func() {
+			Expect(deploymentManifest.DefaultIP("job-with-single-network")).To(Equal("5.6.7.8"))
+		})
+
+		It("returns an empty IP when the network providing the default gateway has no static IP", func() {
+			Expect(deploymentManifest.DefaultIP("fake-job-name")).To(Equal(""))
+		})
+
+		It("returns an error when the deployment does not have a job with requested name", func() {
+			_, err := deploymentManifest.DefaultIP("non-existant-job")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("ResourcePool", func() {
 		BeforeEach(func() {
 			deploymentManifest = Manifest{
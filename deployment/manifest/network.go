@@ -1,10 +1,9 @@
 package manifest
 
 import (
-	"encoding/hex"
-	"fmt"
 	"net"
 
+	binet "github.com/cloudfoundry/bosh-init/common/net"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 )
@@ -33,34 +32,66 @@ type Subnet struct {
 	Range           string
 	Gateway         string
 	DNS             []string
+	Reserved        []string
+	Static          []string
 	CloudProperties biproperty.Map
 }
 
+// FindSubnetByIP returns the subnet whose range contains the ip
+func (n Network) FindSubnetByIP(ip string) (Subnet, bool) {
+	parsedIP := net.ParseIP(ip)
+	for _, subnet := range n.Subnets {
+		_, ipNet, err := net.ParseCIDR(subnet.Range)
+		if err == nil && ipNet.Contains(parsedIP) {
+			return subnet, true
+		}
+	}
+	return Subnet{}, false
+}
+
+// subnetFor returns the subnet containing the first static ip, or the only subnet when there is no static ip
+func (n Network) subnetFor(staticIPs []string) (Subnet, error) {
+	if len(staticIPs) > 0 {
+		subnet, found := n.FindSubnetByIP(staticIPs[0])
+		if !found {
+			return Subnet{}, bosherr.Errorf("Static IP '%s' is not within the range of a subnet of network '%s'", staticIPs[0], n.Name)
+		}
+		return subnet, nil
+	}
+
+	if len(n.Subnets) != 1 {
+		return Subnet{}, bosherr.Errorf("A static IP is required to choose one of the %d subnets of network '%s'", len(n.Subnets), n.Name)
+	}
+	return n.Subnets[0], nil
+}
+
 // Interface returns a property map representing a generic network interface.
+// Manual networks use the subnet containing the static ip.
 // Expected Keys: ip, type, cloud properties.
-// Optional Keys: netmask, gateway, dns
+// Optional Keys: netmask (IPv4 mask or IPv6 prefix length of the subnet range), gateway, dns
 func (n Network) Interface(staticIPs []string, networkDefaults []NetworkDefault) (biproperty.Map, error) {
 	networkInterface := biproperty.Map{
 		"type": n.Type.String(),
 	}
 
 	if n.Type == Manual {
-		networkInterface["gateway"] = n.Subnets[0].Gateway
-		if len(n.Subnets[0].DNS) > 0 {
-			networkInterface["dns"] = n.Subnets[0].DNS
+		subnet, err := n.subnetFor(staticIPs)
+		if err != nil {
+			return biproperty.Map{}, err
 		}
 
-		_, ipNet, err := net.ParseCIDR(n.Subnets[0].Range)
-		if err != nil {
-			return biproperty.Map{}, bosherr.WrapError(err, "Failed to parse subnet range")
+		networkInterface["gateway"] = subnet.Gateway
+		if len(subnet.DNS) > 0 {
+			networkInterface["dns"] = subnet.DNS
 		}
-		ipParts, err := hex.DecodeString(ipNet.Mask.String())
+
+		_, ipNet, err := net.ParseCIDR(subnet.Range)
 		if err != nil {
-			return biproperty.Map{}, bosherr.WrapError(err, "Failed to convert subnet range to IP string")
+			return biproperty.Map{}, bosherr.WrapError(err, "Failed to parse subnet range")
 		}
-		networkInterface["netmask"] = fmt.Sprintf("%v.%v.%v.%v", ipParts[0], ipParts[1], ipParts[2], ipParts[3])
+		networkInterface["netmask"] = binet.Netmask(ipNet)
 
-		networkInterface["cloud_properties"] = n.Subnets[0].CloudProperties
+		networkInterface["cloud_properties"] = subnet.CloudProperties
 	} else {
 		networkInterface["cloud_properties"] = n.CloudProperties
 	}
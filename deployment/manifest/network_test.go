@@ -51,11 +51,11 @@ var _ = Describe("Network", func() {
 			})
 
 			It("includes gateway, dns, ip from the job and netmask calculated from range", func() {
-				iface, err := network.Interface([]string{"5.6.7.9"}, []NetworkDefault{})
+				iface, err := network.Interface([]string{"1.2.3.9"}, []NetworkDefault{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(iface).To(Equal(biproperty.Map{
 					"type":    "manual",
-					"ip":      "5.6.7.9",
+					"ip":      "1.2.3.9",
 					"gateway": "1.1.1.1",
 					"netmask": "255.255.252.0",
 					"dns":     []string{"1.2.3.4"},
@@ -65,13 +65,73 @@ var _ = Describe("Network", func() {
 				}))
 			})
 
+			It("uses the only subnet when there is no static ip", func() {
+				iface, err := network.Interface([]string{}, []NetworkDefault{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(iface["gateway"]).To(Equal("1.1.1.1"))
+				Expect(iface).ToNot(HaveKey("ip"))
+			})
+
+			It("returns an error when the static ip is not within a subnet", func() {
+				_, err := network.Interface([]string{"5.6.7.9"}, []NetworkDefault{})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("Static IP '5.6.7.9' is not within the range of a subnet of network 'fake-manual-network-name'"))
+			})
+
+			Context("when there are several subnets", func() {
+				BeforeEach(func() {
+					network.Subnets = append(network.Subnets, Subnet{
+						Range:   "5.6.7.0/24",
+						Gateway: "5.6.7.1",
+						CloudProperties: biproperty.Map{
+							"cp_key": "other_cp_value",
+						},
+					})
+				})
+
+				It("uses the subnet containing the static ip", func() {
+					iface, err := network.Interface([]string{"5.6.7.9"}, []NetworkDefault{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(iface).To(Equal(biproperty.Map{
+						"type":    "manual",
+						"ip":      "5.6.7.9",
+						"gateway": "5.6.7.1",
+						"netmask": "255.255.255.0",
+						"cloud_properties": biproperty.Map{
+							"cp_key": "other_cp_value",
+						},
+					}))
+				})
+
+				It("returns an error when there is no static ip", func() {
+					_, err := network.Interface([]string{}, []NetworkDefault{})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(Equal("A static IP is required to choose one of the 2 subnets of network 'fake-manual-network-name'"))
+				})
+			})
+
+			Context("when the subnet is an IPv6 subnet", func() {
+				BeforeEach(func() {
+					network.Subnets[0].Range = "2001:db8:1234::/64"
+					network.Subnets[0].Gateway = "2001:db8:1234::1"
+				})
+
+				It("includes the prefix length of the IPv6 subnet as the netmask", func() {
+					iface, err := network.Interface([]string{"2001:db8:1234::10"}, []NetworkDefault{})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(iface["ip"]).To(Equal("2001:db8:1234::10"))
+					Expect(iface["gateway"]).To(Equal("2001:db8:1234::1"))
+					Expect(iface["netmask"]).To(Equal("64"))
+				})
+			})
+
 			Context("when range is invalid", func() {
 				BeforeEach(func() {
 					network.Subnets[0].Range = "invalid-range"
 				})
 
 				It("returns an error", func() {
-					_, err := network.Interface([]string{"5.6.7.9"}, []NetworkDefault{})
+					_, err := network.Interface([]string{}, []NetworkDefault{})
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("Failed to parse subnet range"))
 				})
@@ -1,7 +1,9 @@
 package manifest
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -9,7 +11,7 @@ import (
 )
 
 type Parser interface {
-	Parse(path string) (Manifest, error)
+	Parse(path string, variables bivars.Variables, ops bipatch.Ops) (Manifest, error)
 }
 
 type parser struct {
@@ -47,6 +49,8 @@ type subnet struct {
 	Range           string                      `yaml:"range"`
 	Gateway         string                      `yaml:"gateway"`
 	DNS             []string                    `yaml:"dns"`
+	Reserved        []string                    `yaml:"reserved"`
+	Static          []string                    `yaml:"static"`
 	CloudProperties map[interface{}]interface{} `yaml:"cloud_properties"`
 }
 
@@ -77,8 +81,18 @@ type job struct {
 }
 
 type releaseJobRef struct {
-	Name    string
-	Release string
+	Name     string
+	Release  string
+	Consumes map[string]consumedLinkRef
+	Provides map[string]providedLinkRef
+}
+
+type consumedLinkRef struct {
+	From string
+}
+
+type providedLinkRef struct {
+	As string
 }
 
 type stemcellRef struct {
@@ -109,12 +123,22 @@ func NewParser(fs boshsys.FileSystem, logger boshlog.Logger) Parser {
 	}
 }
 
-func (p *parser) Parse(path string) (Manifest, error) {
+func (p *parser) Parse(path string, variables bivars.Variables, ops bipatch.Ops) (Manifest, error) {
 	contents, err := p.fs.ReadFile(path)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Reading file %s", path)
 	}
 
+	contents, err = ops.Apply(contents)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Applying ops to %s", path)
+	}
+
+	contents, err = bivars.Interpolate(contents, variables)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Interpolating variables in %s", path)
+	}
+
 	comboManifest := manifest{}
 	err = yaml.Unmarshal(contents, &comboManifest)
 	if err != nil {
@@ -197,6 +221,20 @@ func (p *parser) parseJobManifests(rawJobs []job) ([]Job, error) {
 					Name:    rawJobRef.Name,
 					Release: rawJobRef.Release,
 				}
+
+				if rawJobRef.Consumes != nil {
+					releaseJobRefs[i].Consumes = map[string]string{}
+					for linkName, rawLinkRef := range rawJobRef.Consumes {
+						releaseJobRefs[i].Consumes[linkName] = rawLinkRef.From
+					}
+				}
+
+				if rawJobRef.Provides != nil {
+					releaseJobRefs[i].Provides = map[string]string{}
+					for linkName, rawLinkRef := range rawJobRef.Provides {
+						releaseJobRefs[i].Provides[linkName] = rawLinkRef.As
+					}
+				}
 			}
 			job.Templates = releaseJobRefs
 		}
@@ -260,6 +298,8 @@ func (p *parser) parseNetworkManifests(rawNetworks []network) ([]Network, error)
 				Range:           subnet.Range,
 				Gateway:         subnet.Gateway,
 				DNS:             subnet.DNS,
+				Reserved:        subnet.Reserved,
+				Static:          subnet.Static,
 				CloudProperties: cloudProperties,
 			})
 		}
@@ -7,7 +7,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 )
@@ -33,7 +35,7 @@ var _ = Describe("Parser", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -44,7 +46,7 @@ var _ = Describe("Parser", func() {
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -71,6 +73,8 @@ networks:
   - range: 1.2.3.0/22
     gateway: 1.1.1.1
     dns: [2.2.2.2]
+    reserved: [1.2.3.2 - 1.2.3.9]
+    static: [1.2.3.10 - 1.2.3.20, 1.2.3.30]
     cloud_properties:
       cp_key: cp_value
   cloud_properties:
@@ -86,6 +90,15 @@ disk_pools:
     fake-disk-pool-cloud-property-key: fake-disk-pool-cloud-property-value
 jobs:
 - name: bosh
+  templates:
+  - name: director
+    release: bosh
+    consumes:
+      db: {from: bosh_db}
+  - name: postgres
+    release: bosh
+    provides:
+      db: {as: bosh_db}
   networks:
   - name: vip
     static_ips: [1.2.3.4]
@@ -105,7 +118,7 @@ properties:
 	})
 
 	It("parses deployment manifest from combo manifest file", func() {
-		deploymentManifest, err := parser.Parse(comboManifestPath)
+		deploymentManifest, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(deploymentManifest).To(Equal(Manifest{
@@ -122,9 +135,11 @@ properties:
 					Type: Dynamic,
 					Subnets: []Subnet{
 						{
-							Range:   "1.2.3.0/22",
-							Gateway: "1.1.1.1",
-							DNS:     []string{"2.2.2.2"},
+							Range:    "1.2.3.0/22",
+							Gateway:  "1.1.1.1",
+							DNS:      []string{"2.2.2.2"},
+							Reserved: []string{"1.2.3.2 - 1.2.3.9"},
+							Static:   []string{"1.2.3.10 - 1.2.3.20", "1.2.3.30"},
 							CloudProperties: biproperty.Map{
 								"cp_key": "cp_value",
 							},
@@ -171,6 +186,18 @@ properties:
 			Jobs: []Job{
 				{
 					Name: "bosh",
+					Templates: []ReleaseJobRef{
+						{
+							Name:     "director",
+							Release:  "bosh",
+							Consumes: map[string]string{"db": "bosh_db"},
+						},
+						{
+							Name:     "postgres",
+							Release:  "bosh",
+							Provides: map[string]string{"db": "bosh_db"},
+						},
+					},
 					Networks: []JobNetwork{
 						{
 							Name:      "vip",
@@ -210,7 +237,7 @@ properties:
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Parsing global manifest properties"))
 		})
@@ -229,7 +256,7 @@ jobs:
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Parsing job 'fake-deployment-job' properties"))
 		})
@@ -248,7 +275,7 @@ networks:
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Parsing network 'fake-network' cloud_properties"))
 		})
@@ -267,7 +294,7 @@ resource_pools:
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Parsing resource_pool 'fake-resource-pool' cloud_properties"))
 		})
@@ -286,7 +313,7 @@ resource_pools:
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Parsing resource_pool 'fake-resource-pool' env"))
 		})
@@ -305,7 +332,7 @@ disk_pools:
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("Parsing disk_pool 'fake-disk-pool' cloud_properties"))
 		})
@@ -321,7 +348,7 @@ name: fake-deployment-name
 		})
 
 		It("uses default values", func() {
-			deploymentManifest, err := parser.Parse(comboManifestPath)
+			deploymentManifest, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(deploymentManifest.Name).To(Equal("fake-deployment-name"))
@@ -329,4 +356,75 @@ name: fake-deployment-name
 			Expect(deploymentManifest.Update.UpdateWatchTime.End).To(Equal(300000))
 		})
 	})
+
+	Context("when the manifest has variables", func() {
+		BeforeEach(func() {
+			contents := `
+---
+name: ((deployment_name))
+jobs:
+- name: fake-job-name
+  instances: ((instances))
+  properties:
+    password: ((password))
+`
+			fakeFs.WriteFileString(comboManifestPath, contents)
+		})
+
+		It("interpolates the variables", func() {
+			deploymentManifest, err := parser.Parse(comboManifestPath, bivars.Variables{
+				"deployment_name": "fake-deployment-name",
+				"instances":       1,
+				"password":        "fake-password",
+			}, bipatch.Ops{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(deploymentManifest.Name).To(Equal("fake-deployment-name"))
+			Expect(deploymentManifest.Jobs[0].Instances).To(Equal(1))
+			Expect(deploymentManifest.Jobs[0].Properties).To(Equal(biproperty.Map{
+				"password": "fake-password",
+			}))
+		})
+
+		It("returns an error when a variable is undefined", func() {
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{
+				"deployment_name": "fake-deployment-name",
+				"instances":       1,
+			}, bipatch.Ops{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Undefined variable 'password' at '/jobs/0/properties/password'"))
+		})
+
+		It("applies the ops before interpolating the variables", func() {
+			ops, err := bipatch.ParseOps([]byte(`
+- type: replace
+  path: /jobs/name=fake-job-name/properties/password
+  value: ((other_password))
+- type: replace
+  path: /jobs/name=fake-job-name/instances
+  value: 2
+`))
+			Expect(err).ToNot(HaveOccurred())
+
+			deploymentManifest, err := parser.Parse(comboManifestPath, bivars.Variables{
+				"deployment_name": "fake-deployment-name",
+				"other_password":  "fake-other-password",
+			}, ops)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(deploymentManifest.Jobs[0].Instances).To(Equal(2))
+			Expect(deploymentManifest.Jobs[0].Properties).To(Equal(biproperty.Map{
+				"password": "fake-other-password",
+			}))
+		})
+
+		It("returns an error when an op cannot be applied", func() {
+			ops, err := bipatch.ParseOps([]byte("- type: remove\n  path: /jobs/name=missing-job\n"))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = parser.Parse(comboManifestPath, bivars.Variables{}, ops)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Applying operation 0 (remove '/jobs/name=missing-job')"))
+		})
+	})
 })
@@ -0,0 +1,232 @@
+package manifest
+
+import (
+	"sort"
+	"strings"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+)
+
+// validateJobProperties checks the job and global properties of a deployment job against the property definitions
+// of its release jobs. Values that do not match the declared type are errors. Properties that are not declared
+// by any release job and declared properties without a default or a value are only warnings, because templates
+// may use them optionally (with if_p), unless the validator is strict, which fails on undeclared job properties.
+func (v *validator) validateJobProperties(jobIdx int, job Job, releaseJobs []bireljob.Job, globalProperties biproperty.Map) ([]bivalidation.Problem, []error) {
+	warnings := []bivalidation.Problem{}
+	errs := []error{}
+
+	declaredNames := map[string]struct{}{}
+	for _, releaseJob := range releaseJobs {
+		for propertyName := range releaseJob.Properties {
+			declaredNames[propertyName] = struct{}{}
+		}
+	}
+
+	for _, propertyName := range v.undeclaredProperties(job.Properties, "", declaredNames) {
+		path := bivalidation.Path("jobs", jobIdx, "properties", v.propertyPath(propertyName))
+		format := "jobs[%d].properties.%s is not declared by any job template%s"
+		if v.strictProperties {
+			errs = append(errs, bivalidation.Errorf(path, format, jobIdx, propertyName, v.didYouMean(propertyName, declaredNames)))
+		} else {
+			warnings = append(warnings, v.warning(bivalidation.Warningf(path, format, jobIdx, propertyName, v.didYouMean(propertyName, declaredNames))))
+		}
+	}
+
+	for _, propertyName := range v.undeclaredProperties(globalProperties, "", declaredNames) {
+		warnings = append(warnings, v.warning(bivalidation.Warningf(
+			bivalidation.Path("properties", v.propertyPath(propertyName)),
+			"properties.%s is not declared by any template of jobs[%d]%s", propertyName, jobIdx, v.didYouMean(propertyName, declaredNames),
+		)))
+	}
+
+	for templateIdx, releaseJob := range releaseJobs {
+		propertyNames := make([]string, 0, len(releaseJob.Properties))
+		for propertyName := range releaseJob.Properties {
+			propertyNames = append(propertyNames, propertyName)
+		}
+		sort.Strings(propertyNames)
+
+		for _, propertyName := range propertyNames {
+			definition := releaseJob.Properties[propertyName]
+			keys := strings.Split(propertyName, ".")
+
+			valuePath := bivalidation.Path("jobs", jobIdx, "properties", v.propertyPath(propertyName))
+			value, found := v.lookupProperty(job.Properties, keys)
+			if !found {
+				valuePath = bivalidation.Path("properties", v.propertyPath(propertyName))
+				value, found = v.lookupProperty(globalProperties, keys)
+			}
+			if !found {
+				if definition.Default == nil {
+					warnings = append(warnings, v.warning(bivalidation.Warningf(
+						bivalidation.Path("jobs", jobIdx, "templates", templateIdx),
+						"jobs[%d].templates[%d] property '%s' of job '%s' has no default and is not provided", jobIdx, templateIdx, propertyName, releaseJob.Name,
+					)))
+				}
+				continue
+			}
+
+			if !v.matchesPropertyType(value, definition.Type) {
+				errs = append(errs, bivalidation.Errorf(
+					valuePath,
+					"jobs[%d].templates[%d] property '%s' of job '%s' must be of type '%s'", jobIdx, templateIdx, propertyName, releaseJob.Name, definition.Type,
+				))
+			}
+		}
+	}
+
+	return warnings, errs
+}
+
+// warning logs the warning in addition to returning it, so that it can be found in the logs of a deploy
+func (v *validator) warning(warning bivalidation.Problem) bivalidation.Problem {
+	v.logger.Warn(v.logTag, warning.Message)
+	return warning
+}
+
+// propertyPath returns the YAML path of a dot separated property name relative to the properties, e.g. fake-map/fake-key
+func (v *validator) propertyPath(propertyName string) string {
+	return strings.Replace(propertyName, ".", "/", -1)
+}
+
+// undeclaredProperties returns the dot separated names of the properties that are neither declared
+// nor nested in a declared property
+func (v *validator) undeclaredProperties(properties biproperty.Map, prefix string, declaredNames map[string]struct{}) []string {
+	undeclared := []string{}
+	for key, value := range properties {
+		name := prefix + key
+		if _, found := declaredNames[name]; found {
+			continue
+		}
+
+		nestedProperties, isMap := value.(biproperty.Map)
+		if isMap && v.isDeclaredPrefix(name, declaredNames) {
+			undeclared = append(undeclared, v.undeclaredProperties(nestedProperties, name+".", declaredNames)...)
+			continue
+		}
+
+		undeclared = append(undeclared, name)
+	}
+	sort.Strings(undeclared)
+	return undeclared
+}
+
+func (v *validator) isDeclaredPrefix(name string, declaredNames map[string]struct{}) bool {
+	for declaredName := range declaredNames {
+		if strings.HasPrefix(declaredName, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// didYouMean returns a suggestion of the closest declared property (or declared property prefix) to the given name
+func (v *validator) didYouMean(name string, declaredNames map[string]struct{}) string {
+	candidates := map[string]struct{}{}
+	for declaredName := range declaredNames {
+		keys := strings.Split(declaredName, ".")
+		for i := range keys {
+			candidates[strings.Join(keys[:i+1], ".")] = struct{}{}
+		}
+	}
+
+	suggestion := ""
+	bestDistance := len(name)/3 + 1
+	for candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		distance := levenshteinDistance(name, candidate)
+		if distance < bestDistance || (distance == bestDistance && candidate < suggestion) {
+			suggestion = candidate
+			bestDistance = distance
+		}
+	}
+
+	if suggestion == "" {
+		return ""
+	}
+	return ", did you mean '" + suggestion + "'?"
+}
+
+func (v *validator) lookupProperty(properties biproperty.Map, keys []string) (biproperty.Property, bool) {
+	var value biproperty.Property = properties
+	for _, key := range keys {
+		propertyMap, ok := value.(biproperty.Map)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = propertyMap[key]
+		if !ok || value == nil {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// matchesPropertyType returns true if the value is of the declared type.
+// Types that are not known (e.g. certificate) and undeclared types match any value.
+func (v *validator) matchesPropertyType(value biproperty.Property, propertyType string) bool {
+	switch propertyType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch value.(type) {
+		case int, int64, uint64:
+			return true
+		}
+		return false
+	case "numeric", "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	case "array":
+		_, ok := value.(biproperty.List)
+		return ok
+	case "hash":
+		_, ok := value.(biproperty.Map)
+		return ok
+	}
+	return true
+}
+
+func levenshteinDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	binet "github.com/cloudfoundry/bosh-init/common/net"
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
 	birel "github.com/cloudfoundry/bosh-init/release"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -14,23 +16,39 @@ import (
 
 type Validator interface {
 	Validate(Manifest, birelsetmanifest.Manifest) error
-	ValidateReleaseJobs(Manifest, birel.Manager) error
+
+	// ValidateReleaseJobs returns the warnings of the deployment jobs, which do not prevent deploying them,
+	// or an error with the problems of the jobs that do
+	ValidateReleaseJobs(Manifest, birel.Manager) ([]bivalidation.Problem, error)
 }
 
 type validator struct {
-	logger boshlog.Logger
+	strictProperties bool
+	logger           boshlog.Logger
+	logTag           string
 }
 
 func NewValidator(logger boshlog.Logger) Validator {
 	return &validator{
 		logger: logger,
+		logTag: "validator",
+	}
+}
+
+// NewStrictValidator returns a Validator that fails on job properties not declared by any job template,
+// instead of only warning about them
+func NewStrictValidator(logger boshlog.Logger) Validator {
+	return &validator{
+		strictProperties: true,
+		logger:           logger,
+		logTag:           "validator",
 	}
 }
 
 func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest birelsetmanifest.Manifest) error {
 	errs := []error{}
 	if v.isBlank(deploymentManifest.Name) {
-		errs = append(errs, bosherr.Error("name must be provided"))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("name"), "name must be provided"))
 	}
 
 	networksErrors := v.validateNetworks(deploymentManifest.Networks)
@@ -38,89 +56,101 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 
 	for idx, resourcePool := range deploymentManifest.ResourcePools {
 		if v.isBlank(resourcePool.Name) {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].name must be provided", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("resource_pools", idx, "name"), "resource_pools[%d].name must be provided", idx))
 		}
 		if v.isBlank(resourcePool.Network) {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].network must be provided", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("resource_pools", idx, "network"), "resource_pools[%d].network must be provided", idx))
 		} else if _, ok := v.networkNames(deploymentManifest)[resourcePool.Network]; !ok {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].network must be the name of a network", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("resource_pools", idx, "network"), "resource_pools[%d].network must be the name of a network", idx))
 		}
 
 		if v.isBlank(resourcePool.Stemcell.URL) {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.url must be provided", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("resource_pools", idx, "stemcell", "url"), "resource_pools[%d].stemcell.url must be provided", idx))
 		}
 
 		matched, err := regexp.MatchString("^(file|http|https)://", resourcePool.Stemcell.URL)
 		if err != nil || !matched {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.url must be a valid URL (file:// or http(s)://)", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("resource_pools", idx, "stemcell", "url"), "resource_pools[%d].stemcell.url must be a valid URL (file:// or http(s)://)", idx))
 		}
 
 		if strings.HasPrefix(resourcePool.Stemcell.URL, "http") && v.isBlank(resourcePool.Stemcell.SHA1) {
-			errs = append(errs, bosherr.Errorf("resource_pools[%d].stemcell.sha1 must be provided for http URL", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("resource_pools", idx, "stemcell", "sha1"), "resource_pools[%d].stemcell.sha1 must be provided for http URL", idx))
 		}
 	}
 
 	for idx, diskPool := range deploymentManifest.DiskPools {
 		if v.isBlank(diskPool.Name) {
-			errs = append(errs, bosherr.Errorf("disk_pools[%d].name must be provided", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("disk_pools", idx, "name"), "disk_pools[%d].name must be provided", idx))
 		}
 		if diskPool.DiskSize <= 0 {
-			errs = append(errs, bosherr.Errorf("disk_pools[%d].disk_size must be > 0", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("disk_pools", idx, "disk_size"), "disk_pools[%d].disk_size must be > 0", idx))
 		}
 	}
 
 	if len(deploymentManifest.Jobs) > 1 {
-		errs = append(errs, bosherr.Error("jobs must be of size 1"))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs"), "jobs must be of size 1"))
 	}
 
 	for idx, job := range deploymentManifest.Jobs {
 		if v.isBlank(job.Name) {
-			errs = append(errs, bosherr.Errorf("jobs[%d].name must be provided", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "name"), "jobs[%d].name must be provided", idx))
 		}
 		if job.PersistentDisk < 0 {
-			errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disk must be >= 0", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "persistent_disk"), "jobs[%d].persistent_disk must be >= 0", idx))
 		}
 		if job.PersistentDiskPool != "" {
 			if _, ok := v.diskPoolNames(deploymentManifest)[job.PersistentDiskPool]; !ok {
-				errs = append(errs, bosherr.Errorf("jobs[%d].persistent_disk_pool must be the name of a disk pool", idx))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "persistent_disk_pool"), "jobs[%d].persistent_disk_pool must be the name of a disk pool", idx))
 			}
 		}
 		if job.Instances < 0 {
-			errs = append(errs, bosherr.Errorf("jobs[%d].instances must be >= 0", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "instances"), "jobs[%d].instances must be >= 0", idx))
 		}
 		if len(job.Networks) == 0 {
-			errs = append(errs, bosherr.Errorf("jobs[%d].networks must be a non-empty array", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "networks"), "jobs[%d].networks must be a non-empty array", idx))
 		}
 		if v.isBlank(job.ResourcePool) {
-			errs = append(errs, bosherr.Errorf("jobs[%d].resource_pool must be provided", idx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "resource_pool"), "jobs[%d].resource_pool must be provided", idx))
 		} else {
 			if _, ok := v.resourcePoolNames(deploymentManifest)[job.ResourcePool]; !ok {
-				errs = append(errs, bosherr.Errorf("jobs[%d].resource_pool must be the name of a resource pool", idx))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "resource_pool"), "jobs[%d].resource_pool must be the name of a resource pool", idx))
 			}
 		}
 
 		errs = append(errs, v.validateJobNetworks(job.Networks, deploymentManifest.Networks, idx)...)
 
 		if job.Lifecycle != "" && job.Lifecycle != JobLifecycleService {
-			errs = append(errs, bosherr.Errorf("jobs[%d].lifecycle must be 'service' ('%s' not supported)", idx, job.Lifecycle))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "lifecycle"), "jobs[%d].lifecycle must be 'service' ('%s' not supported)", idx, job.Lifecycle))
 		}
 
 		templateNames := map[string]struct{}{}
 		for templateIdx, template := range job.Templates {
 			if v.isBlank(template.Name) {
-				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].name must be provided", idx, templateIdx))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "name"), "jobs[%d].templates[%d].name must be provided", idx, templateIdx))
 			}
 			if _, found := templateNames[template.Name]; found {
-				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].name '%s' must be unique", idx, templateIdx, template.Name))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "name"), "jobs[%d].templates[%d].name '%s' must be unique", idx, templateIdx, template.Name))
 			}
 			templateNames[template.Name] = struct{}{}
 
 			if v.isBlank(template.Release) {
-				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].release must be provided", idx, templateIdx))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "release"), "jobs[%d].templates[%d].release must be provided", idx, templateIdx))
 			} else {
 				_, found := releaseSetManifest.FindByName(template.Release)
 				if !found {
-					errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].release '%s' must refer to release in releases", idx, templateIdx, template.Release))
+					errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "release"), "jobs[%d].templates[%d].release '%s' must refer to release in releases", idx, templateIdx, template.Release))
+				}
+			}
+
+			for linkName, from := range template.Consumes {
+				if v.isBlank(from) {
+					errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "consumes", linkName, "from"), "jobs[%d].templates[%d].consumes.%s.from must be provided", idx, templateIdx, linkName))
+				}
+			}
+
+			for linkName, as := range template.Provides {
+				if v.isBlank(as) {
+					errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "provides", linkName, "as"), "jobs[%d].templates[%d].provides.%s.as must be provided", idx, templateIdx, linkName))
 				}
 			}
 		}
@@ -133,28 +163,39 @@ func (v *validator) Validate(deploymentManifest Manifest, releaseSetManifest bir
 	return nil
 }
 
-func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseManager birel.Manager) error {
+func (v *validator) ValidateReleaseJobs(deploymentManifest Manifest, releaseManager birel.Manager) ([]bivalidation.Problem, error) {
+	warnings := []bivalidation.Problem{}
 	errs := []error{}
 
 	for idx, job := range deploymentManifest.Jobs {
+		releaseJobs := []bireljob.Job{}
 		for templateIdx, template := range job.Templates {
 			release, found := releaseManager.Find(template.Release)
 			if !found {
-				errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d].release '%s' must refer to release in releases", idx, templateIdx, template.Release))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "release"), "jobs[%d].templates[%d].release '%s' must refer to release in releases", idx, templateIdx, template.Release))
 			} else {
-				_, found := release.FindJobByName(template.Name)
+				releaseJob, found := release.FindJobByName(template.Name)
 				if !found {
-					errs = append(errs, bosherr.Errorf("jobs[%d].templates[%d] must refer to a job in '%s', but there is no job named '%s'", idx, templateIdx, release.Name(), template.Name))
+					errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", idx, "templates", templateIdx, "name"), "jobs[%d].templates[%d] must refer to a job in '%s', but there is no job named '%s'", idx, templateIdx, release.Name(), template.Name))
+				} else {
+					releaseJobs = append(releaseJobs, releaseJob)
 				}
 			}
 		}
+
+		// properties can only be checked against the definitions of all the templates
+		if len(releaseJobs) == len(job.Templates) {
+			propertyWarnings, propertyErrs := v.validateJobProperties(idx, job, releaseJobs, deploymentManifest.Properties)
+			warnings = append(warnings, propertyWarnings...)
+			errs = append(errs, propertyErrs...)
+		}
 	}
 
 	if len(errs) > 0 {
-		return bosherr.NewMultiError(errs...)
+		return warnings, bosherr.NewMultiError(errs...)
 	}
 
-	return nil
+	return warnings, nil
 }
 
 func (v *validator) isBlank(str string) bool {
@@ -208,19 +249,38 @@ func (in *somethingIpNet) Try(fn func(*net.IPNet) error) error {
 	return fn(in.ipNet)
 }
 
-func (v *validator) validateRange(idx int, ipRange string) ([]error, maybeIPNet) {
+func (v *validator) validateRange(idx int, subnetIdx int, ipRange string) ([]error, maybeIPNet) {
 	if v.isBlank(ipRange) {
-		return []error{bosherr.Errorf("networks[%d].subnets[0].range must be provided", idx)}, &nothingIpNet{}
+		return []error{bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "range"), "networks[%d].subnets[%d].range must be provided", idx, subnetIdx)}, &nothingIpNet{}
 	} else {
 		_, ipNet, err := net.ParseCIDR(ipRange)
 		if err != nil {
-			return []error{bosherr.Errorf("networks[%d].subnets[0].range must be an ip range", idx)}, &nothingIpNet{}
+			return []error{bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "range"), "networks[%d].subnets[%d].range must be an ip range", idx, subnetIdx)}, &nothingIpNet{}
 		}
 
 		return []error{}, &somethingIpNet{ipNet: ipNet}
 	}
 }
 
+func (v *validator) validateSubnetIPRanges(idx int, subnetIdx int, key string, ipRanges []string, ipNet maybeIPNet) []error {
+	errs := []error{}
+	for rangeIdx, ipRange := range ipRanges {
+		parsedRange, err := binet.ParseIPRange(ipRange)
+		if err != nil {
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, key, rangeIdx), "networks[%d].subnets[%d].%s[%d] must be an ip or an ip range", idx, subnetIdx, key, rangeIdx))
+			continue
+		}
+
+		ipNet.Try(func(ipNet *net.IPNet) error {
+			if !parsedRange.Within(ipNet) {
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, key, rangeIdx), "networks[%d].subnets[%d].%s[%d] '%s' must be within the subnet range '%s'", idx, subnetIdx, key, rangeIdx, ipRange, ipNet))
+			}
+			return nil
+		})
+	}
+	return errs
+}
+
 func (v *validator) validateNetworks(networks []Network) []error {
 	errs := []error{}
 
@@ -236,22 +296,25 @@ func (v *validator) validateNetwork(network Network, networkIdx int) []error {
 	errs := []error{}
 
 	if v.isBlank(network.Name) {
-		errs = append(errs, bosherr.Errorf("networks[%d].name must be provided", networkIdx))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("networks", networkIdx, "name"), "networks[%d].name must be provided", networkIdx))
 	}
 	if network.Type != Dynamic && network.Type != Manual && network.Type != VIP {
-		errs = append(errs, bosherr.Errorf("networks[%d].type must be 'manual', 'dynamic', or 'vip'", networkIdx))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("networks", networkIdx, "type"), "networks[%d].type must be 'manual', 'dynamic', or 'vip'", networkIdx))
 	}
 	if network.Type == Manual {
-		if len(network.Subnets) != 1 {
-			errs = append(errs, bosherr.Errorf("networks[%d].subnets must be of size 1", networkIdx))
-		} else {
-			ipRange := network.Subnets[0].Range
-			rangeErrors, maybeIpNet := v.validateRange(networkIdx, ipRange)
+		if len(network.Subnets) == 0 {
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("networks", networkIdx, "subnets"), "networks[%d].subnets must be a non-empty array", networkIdx))
+		}
+
+		for subnetIdx, subnet := range network.Subnets {
+			rangeErrors, maybeIpNet := v.validateRange(networkIdx, subnetIdx, subnet.Range)
 			errs = append(errs, rangeErrors...)
 
-			gateway := network.Subnets[0].Gateway
-			gatewayErrors := v.validateGateway(networkIdx, gateway, maybeIpNet)
+			gatewayErrors := v.validateGateway(networkIdx, subnetIdx, subnet.Gateway, maybeIpNet)
 			errs = append(errs, gatewayErrors...)
+
+			errs = append(errs, v.validateSubnetIPRanges(networkIdx, subnetIdx, "reserved", subnet.Reserved, maybeIpNet)...)
+			errs = append(errs, v.validateSubnetIPRanges(networkIdx, subnetIdx, "static", subnet.Static, maybeIpNet)...)
 		}
 	}
 
@@ -265,7 +328,7 @@ func (v *validator) validateJobNetworks(jobNetworks []JobNetwork, networks []Net
 	for networkIdx, jobNetwork := range jobNetworks {
 
 		if v.isBlank(jobNetwork.Name) {
-			errs = append(errs, bosherr.Errorf("jobs[%d].networks[%d].name must be provided", jobIdx, networkIdx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "name"), "jobs[%d].networks[%d].name must be provided", jobIdx, networkIdx))
 		}
 
 		var matchingNetwork Network
@@ -278,7 +341,7 @@ func (v *validator) validateJobNetworks(jobNetworks []JobNetwork, networks []Net
 		}
 
 		if !found {
-			errs = append(errs, bosherr.Errorf("jobs[%d].networks[%d] not found in networks", jobIdx, networkIdx))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "name"), "jobs[%d].networks[%d] not found in networks", jobIdx, networkIdx))
 		}
 
 		for ipIdx, ip := range jobNetwork.StaticIPs {
@@ -288,7 +351,7 @@ func (v *validator) validateJobNetworks(jobNetworks []JobNetwork, networks []Net
 
 		for defaultIdx, value := range jobNetwork.Defaults {
 			if value != NetworkDefaultDNS && value != NetworkDefaultGateway {
-				errs = append(errs, bosherr.Errorf("jobs[%d].networks[%d].default[%d] must be 'dns' or 'gateway'", jobIdx, networkIdx, defaultIdx))
+				errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "default", defaultIdx), "jobs[%d].networks[%d].default[%d] must be 'dns' or 'gateway'", jobIdx, networkIdx, defaultIdx))
 			}
 		}
 
@@ -304,9 +367,9 @@ func (v *validator) validateJobNetworks(jobNetworks []JobNetwork, networks []Net
 	for _, dflt := range []NetworkDefault{"dns", "gateway"} {
 		count, found := defaultCounts[dflt]
 		if len(jobNetworks) > 1 && !found {
-			errs = append(errs, bosherr.Errorf("with multiple networks, a default for '%s' must be specified", dflt))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks"), "with multiple networks, a default for '%s' must be specified", dflt))
 		} else if count > 1 {
-			errs = append(errs, bosherr.Errorf("only one network can be the default for '%s'", dflt))
+			errs = append(errs, bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks"), "only one network can be the default for '%s'", dflt))
 		}
 	}
 
@@ -315,49 +378,63 @@ func (v *validator) validateJobNetworks(jobNetworks []JobNetwork, networks []Net
 
 func (v *validator) validateStaticIP(ip string, jobNetwork JobNetwork, network Network, jobIdx, networkIdx, ipIdx int) []error {
 	if !v.isValidIP(ip) {
-		return []error{bosherr.Errorf("jobs[%d].networks[%d].static_ips[%d] must be a valid IP", jobIdx, networkIdx, ipIdx)}
+		return []error{bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "static_ips", ipIdx), "jobs[%d].networks[%d].static_ips[%d] must be a valid IP", jobIdx, networkIdx, ipIdx)}
 	}
 
 	if network.Type != Manual {
 		return []error{}
 	}
 
-	foundInSubnetRange := false
-	for _, subnet := range network.Subnets {
-		_, rangeNet, err := net.ParseCIDR(subnet.Range)
-		if err == nil && rangeNet.Contains(net.ParseIP(ip)) {
-			foundInSubnetRange = true
-		}
+	subnet, found := network.FindSubnetByIP(ip)
+	if !found {
+		return []error{bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "static_ips", ipIdx), "jobs[%d].networks[%d] static ip '%s' must be within subnet range", jobIdx, networkIdx, ip)}
 	}
 
-	if foundInSubnetRange {
-		return []error{}
-	} else {
-		return []error{bosherr.Errorf("jobs[%d].networks[%d] static ip '%s' must be within subnet range", jobIdx, networkIdx, ip)}
+	if v.isInIPRanges(ip, subnet.Reserved) {
+		return []error{bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "static_ips", ipIdx), "jobs[%d].networks[%d] static ip '%s' must not be within a reserved range of the subnet", jobIdx, networkIdx, ip)}
+	}
+
+	// subnets without static ranges accept static ips anywhere in their range
+	if len(subnet.Static) > 0 && !v.isInIPRanges(ip, subnet.Static) {
+		return []error{bivalidation.Errorf(bivalidation.Path("jobs", jobIdx, "networks", networkIdx, "static_ips", ipIdx), "jobs[%d].networks[%d] static ip '%s' must be within a static range of the subnet", jobIdx, networkIdx, ip)}
+	}
+
+	return []error{}
+}
+
+func (v *validator) isInIPRanges(ip string, ipRanges []string) bool {
+	parsedIP := net.ParseIP(ip)
+	for _, ipRange := range ipRanges {
+		parsedRange, err := binet.ParseIPRange(ipRange)
+		if err == nil && parsedRange.Contains(parsedIP) {
+			return true
+		}
 	}
+	return false
 }
 
-func (v *validator) validateGateway(idx int, gateway string, ipNet maybeIPNet) []error {
+func (v *validator) validateGateway(idx int, subnetIdx int, gateway string, ipNet maybeIPNet) []error {
 	if v.isBlank(gateway) {
-		return []error{bosherr.Errorf("networks[%d].subnets[0].gateway must be provided", idx)}
+		return []error{bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "gateway"), "networks[%d].subnets[%d].gateway must be provided", idx, subnetIdx)}
 	} else {
 		errors := []error{}
 		ipNet.Try(func(ipNet *net.IPNet) error {
 			gatewayIp := net.ParseIP(gateway)
 			if gatewayIp == nil {
-				errors = append(errors, bosherr.Errorf("networks[%d].subnets[0].gateway must be an ip", idx))
+				errors = append(errors, bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "gateway"), "networks[%d].subnets[%d].gateway must be an ip", idx, subnetIdx))
 			}
 
 			if !ipNet.Contains(gatewayIp) {
-				errors = append(errors, bosherr.Errorf("subnet gateway '%s' must be within the specified range '%s'", gateway, ipNet))
+				errors = append(errors, bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "gateway"), "subnet gateway '%s' must be within the specified range '%s'", gateway, ipNet))
 			}
 
 			if ipNet.IP.Equal(gatewayIp) {
-				errors = append(errors, bosherr.Errorf("subnet gateway can't be the network address '%s'", gatewayIp))
+				errors = append(errors, bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "gateway"), "subnet gateway can't be the network address '%s'", gatewayIp))
 			}
 
-			if binet.LastAddress(ipNet).Equal(gatewayIp) {
-				errors = append(errors, bosherr.Errorf("subnet gateway can't be the broadcast address '%s'", gatewayIp))
+			// IPv6 networks have no broadcast address
+			if binet.IsIPv4(ipNet) && binet.LastAddress(ipNet).Equal(gatewayIp) {
+				errors = append(errors, bivalidation.Errorf(bivalidation.Path("networks", idx, "subnets", subnetIdx, "gateway"), "subnet gateway can't be the broadcast address '%s'", gatewayIp))
 			}
 
 			return nil
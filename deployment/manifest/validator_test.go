@@ -2,10 +2,12 @@ package manifest_test
 
 import (
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
 	birel "github.com/cloudfoundry/bosh-init/release"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -120,6 +122,20 @@ var _ = Describe("Validator", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("returns the problems with the YAML path of the invalid values", func() {
+			deploymentManifest := validManifest
+			deploymentManifest.Name = ""
+			deploymentManifest.Jobs = []Job{validManifest.Jobs[0]}
+			deploymentManifest.Jobs[0].Templates = []ReleaseJobRef{{Name: "fake-job-name"}}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(bosherr.MultiError).Errors).To(Equal([]error{
+				bivalidation.Errorf("/name", "name must be provided"),
+				bivalidation.Errorf("/jobs/0/templates/0/release", "jobs[0].templates[0].release must be provided"),
+			}))
+		})
+
 		It("validates name is not empty", func() {
 			deploymentManifest := Manifest{
 				Name: "",
@@ -356,7 +372,7 @@ var _ = Describe("Validator", func() {
 			})
 
 			Context("manual networks", func() {
-				It("validates that there is exactly 1 subnet", func() {
+				It("validates that there is at least 1 subnet", func() {
 					deploymentManifest := Manifest{
 						Networks: []Network{
 							{
@@ -368,7 +384,7 @@ var _ = Describe("Validator", func() {
 
 					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
 					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("networks[0].subnets must be of size 1"))
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets must be a non-empty array"))
 				})
 
 				It("validates that range is present", func() {
@@ -514,6 +530,62 @@ var _ = Describe("Validator", func() {
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("subnet gateway can't be the broadcast address '10.10.0.255'"))
 				})
+
+				It("validates IPv6 subnets", func() {
+					err := validator.Validate(Manifest{
+						Networks: []Network{
+							{
+								Type: "manual",
+								Subnets: []Subnet{
+									{Range: "2001:db8:1234::/64", Gateway: "2001:db8:1234:0:ffff:ffff:ffff:ffff"},
+									{Range: "2001:db8:5678::/64", Gateway: "10.10.0.1"},
+								},
+							},
+						},
+					}, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).ToNot(ContainSubstring("networks[0].subnets[0]"))
+					Expect(err.Error()).ToNot(ContainSubstring("broadcast"))
+					Expect(err.Error()).To(ContainSubstring("subnet gateway '10.10.0.1' must be within the specified range '2001:db8:5678::/64'"))
+				})
+
+				It("validates every subnet", func() {
+					err := validator.Validate(Manifest{
+						Networks: []Network{
+							{
+								Type: "manual",
+								Subnets: []Subnet{
+									{Range: "10.10.0.0/24", Gateway: "10.10.0.1"},
+									{Range: "10.20.0.0/24"},
+								},
+							},
+						},
+					}, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[1].gateway must be provided"))
+					Expect(err.Error()).ToNot(ContainSubstring("networks[0].subnets[0]"))
+				})
+
+				It("validates that reserved & static ranges are ip ranges within the subnet range", func() {
+					err := validator.Validate(Manifest{
+						Networks: []Network{
+							{
+								Type: "manual",
+								Subnets: []Subnet{{
+									Range:    "10.10.0.0/24",
+									Gateway:  "10.10.0.1",
+									Reserved: []string{"10.10.0.2 - 10.10.0.10", "not-an-ip"},
+									Static:   []string{"10.10.0.20", "10.10.0.200 - 10.10.1.10"},
+								}},
+							},
+						},
+					}, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).ToNot(ContainSubstring("reserved[0]"))
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[0].reserved[1] must be an ip or an ip range"))
+					Expect(err.Error()).ToNot(ContainSubstring("static[0]"))
+					Expect(err.Error()).To(ContainSubstring("networks[0].subnets[0].static[1] '10.10.0.200 - 10.10.1.10' must be within the subnet range '10.10.0.0/24'"))
+				})
 			})
 
 			Context("dynamic networks", func() {
@@ -841,6 +913,98 @@ var _ = Describe("Validator", func() {
 
 			})
 
+			It("validates job network IPv6 static ips", func() {
+				deploymentManifest := Manifest{
+					Networks: []Network{
+						{
+							Name: "fake-network-name",
+							Type: "manual",
+							Subnets: []Subnet{{
+								Range:   "2001:db8:1234::/64",
+								Gateway: "2001:db8:1234::1",
+								Static:  []string{"2001:db8:1234::10 - 2001:db8:1234::20"},
+							}},
+						},
+					},
+					Jobs: []Job{
+						{
+							Networks: []JobNetwork{
+								{
+									Name:      "fake-network-name",
+									StaticIPs: []string{"2001:db8:1234::15"},
+								},
+							},
+						},
+					},
+				}
+
+				err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).ToNot(ContainSubstring("static ip"))
+
+				deploymentManifest.Jobs[0].Networks[0].StaticIPs = []string{"2001:db8:1234::30"}
+				err = validator.Validate(deploymentManifest, validReleaseSetManifest)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '2001:db8:1234::30' must be within a static range of the subnet"))
+			})
+
+			Context("when the manual network has several subnets with reserved & static ranges", func() {
+				var deploymentManifest Manifest
+
+				BeforeEach(func() {
+					deploymentManifest = Manifest{
+						Networks: []Network{
+							{
+								Name: "fake-network-name",
+								Type: "manual",
+								Subnets: []Subnet{
+									{
+										Range:   "10.10.0.0/24",
+										Gateway: "10.10.0.1",
+									},
+									{
+										Range:    "10.20.0.0/24",
+										Gateway:  "10.20.0.1",
+										Reserved: []string{"10.20.0.2 - 10.20.0.9"},
+										Static:   []string{"10.20.0.5 - 10.20.0.20"},
+									},
+								},
+							},
+						},
+						Jobs: []Job{
+							{
+								Networks: []JobNetwork{
+									{
+										Name: "fake-network-name",
+									},
+								},
+							},
+						},
+					}
+				})
+
+				It("accepts a static ip in a static range of any subnet", func() {
+					deploymentManifest.Jobs[0].Networks[0].StaticIPs = []string{"10.20.0.10"}
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).ToNot(ContainSubstring("static ip"))
+				})
+
+				It("validates that the static ip is not in a reserved range", func() {
+					deploymentManifest.Jobs[0].Networks[0].StaticIPs = []string{"10.20.0.6"}
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '10.20.0.6' must not be within a reserved range of the subnet"))
+				})
+
+				It("validates that the static ip is in a static range of its subnet", func() {
+					deploymentManifest.Jobs[0].Networks[0].StaticIPs = []string{"10.20.0.42"}
+					err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("jobs[0].networks[0] static ip '10.20.0.42' must be within a static range of the subnet"))
+				})
+			})
+
 			Describe("defaults", func() {
 				var deploymentManifest Manifest
 				Context("with multiple networks", func() {
@@ -1007,6 +1171,23 @@ var _ = Describe("Validator", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0].release 'fake-other-release-name' must refer to release in releases"))
 		})
+
+		It("validates job template link overrides name a link", func() {
+			deploymentManifest := validManifest
+			deploymentManifest.Jobs[0].Templates = []ReleaseJobRef{
+				{
+					Name:     "fake-job-name",
+					Release:  "fake-release-name",
+					Consumes: map[string]string{"fake-consumed-link": ""},
+					Provides: map[string]string{"fake-provided-link": " "},
+				},
+			}
+
+			err := validator.Validate(deploymentManifest, validReleaseSetManifest)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0].consumes.fake-consumed-link.from must be provided"))
+			Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0].provides.fake-provided-link.as must be provided"))
+		})
 	})
 
 	Describe("ValidateReleaseJobs", func() {
@@ -1020,9 +1201,145 @@ var _ = Describe("Validator", func() {
 			fakeRelease.ReleaseJobs = []bireljob.Job{{Name: "fake-job-name"}}
 			releaseManager.Add(fakeRelease)
 
-			err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+			_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] must refer to a job in 'fake-release-name', but there is no job named 'fake-other-job-name'"))
 		})
+
+		Context("when the release job declares properties", func() {
+			var deploymentManifest Manifest
+
+			BeforeEach(func() {
+				deploymentManifest = validManifest
+				deploymentManifest.Jobs = []Job{validManifest.Jobs[0]}
+				deploymentManifest.Jobs[0].Properties = biproperty.Map{
+					"fake-prop-key": "fake-prop-value",
+					"fake-prop-map-key": biproperty.Map{
+						"fake-prop-key": 5,
+					},
+				}
+
+				fakeRelease.ReleaseJobs = []bireljob.Job{
+					{
+						Name: "fake-job-name",
+						Properties: map[string]bireljob.PropertyDefinition{
+							"fake-prop-key":                   {Type: "string"},
+							"fake-prop-map-key.fake-prop-key": {Type: "integer"},
+							"fake-optional-prop-key":          {Default: "fake-default-value"},
+						},
+					},
+				}
+			})
+
+			It("validates the declared properties", func() {
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(BeEmpty())
+			})
+
+			It("allows job properties not declared by a job template, with a warning", func() {
+				deploymentManifest.Jobs[0].Properties["fake-prop-map-key"] = biproperty.Map{
+					"fake-prop-key":  5,
+					"fake-prop-kye2": "fake-prop-value",
+				}
+				deploymentManifest.Jobs[0].Properties["unknown"] = "fake-prop-value"
+
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(Equal([]bivalidation.Problem{
+					bivalidation.Warningf(
+						"/jobs/0/properties/fake-prop-map-key/fake-prop-kye2",
+						"jobs[0].properties.fake-prop-map-key.fake-prop-kye2 is not declared by any job template, did you mean 'fake-prop-map-key.fake-prop-key'?",
+					),
+					bivalidation.Warningf("/jobs/0/properties/unknown", "jobs[0].properties.unknown is not declared by any job template"),
+				}))
+			})
+
+			It("validates job properties are declared by a job template when strict", func() {
+				deploymentManifest.Jobs[0].Properties["fake-prop-map-key"] = biproperty.Map{
+					"fake-prop-key":  5,
+					"fake-prop-kye2": "fake-prop-value",
+				}
+				deploymentManifest.Jobs[0].Properties["unknown"] = "fake-prop-value"
+
+				_, err := NewStrictValidator(logger).ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].properties.fake-prop-map-key.fake-prop-kye2 is not declared by any job template, did you mean 'fake-prop-map-key.fake-prop-key'?"))
+				Expect(err.Error()).To(ContainSubstring("jobs[0].properties.unknown is not declared by any job template"))
+				Expect(err.Error()).ToNot(ContainSubstring("unknown is not declared by any job template, did you mean"))
+			})
+
+			It("allows undeclared global properties, with a warning", func() {
+				deploymentManifest.Properties = biproperty.Map{
+					"unknown": "fake-prop-value",
+					"fake-prop-map-key": biproperty.Map{
+						"fake-prop-kye": 5,
+					},
+				}
+
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(Equal([]bivalidation.Problem{
+					bivalidation.Warningf(
+						"/properties/fake-prop-map-key/fake-prop-kye",
+						"properties.fake-prop-map-key.fake-prop-kye is not declared by any template of jobs[0], did you mean 'fake-prop-map-key.fake-prop-key'?",
+					),
+					bivalidation.Warningf("/properties/unknown", "properties.unknown is not declared by any template of jobs[0]"),
+				}))
+			})
+
+			It("validates properties match their declared type", func() {
+				deploymentManifest.Jobs[0].Properties["fake-prop-key"] = true
+				deploymentManifest.Jobs[0].Properties["fake-prop-map-key"] = biproperty.Map{
+					"fake-prop-key": "5",
+				}
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] property 'fake-prop-key' of job 'fake-job-name' must be of type 'string'"))
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] property 'fake-prop-map-key.fake-prop-key' of job 'fake-job-name' must be of type 'integer'"))
+			})
+
+			It("validates global properties match their declared type", func() {
+				delete(deploymentManifest.Jobs[0].Properties, "fake-prop-key")
+				deploymentManifest.Properties = biproperty.Map{
+					"fake-prop-key": biproperty.List{"fake-prop-value"},
+				}
+
+				_, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("jobs[0].templates[0] property 'fake-prop-key' of job 'fake-job-name' must be of type 'string'"))
+			})
+
+			It("returns the property problems with the YAML path of the property value", func() {
+				deploymentManifest.Jobs[0].Properties["fake-prop-key"] = true
+				deploymentManifest.Jobs[0].Properties["unknown"] = "fake-prop-value"
+				deploymentManifest.Properties = biproperty.Map{
+					"fake-prop-map-key": biproperty.Map{
+						"fake-prop-key": "5",
+					},
+				}
+				delete(deploymentManifest.Jobs[0].Properties, "fake-prop-map-key")
+
+				_, err := NewStrictValidator(logger).ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(bosherr.MultiError).Errors).To(Equal([]error{
+					bivalidation.Errorf("/jobs/0/properties/unknown", "jobs[0].properties.unknown is not declared by any job template"),
+					bivalidation.Errorf("/jobs/0/properties/fake-prop-key", "jobs[0].templates[0] property 'fake-prop-key' of job 'fake-job-name' must be of type 'string'"),
+					bivalidation.Errorf("/properties/fake-prop-map-key/fake-prop-key", "jobs[0].templates[0] property 'fake-prop-map-key.fake-prop-key' of job 'fake-job-name' must be of type 'integer'"),
+				}))
+			})
+
+			It("allows declared properties without a default that are not provided, with a warning", func() {
+				delete(deploymentManifest.Jobs[0].Properties, "fake-prop-key")
+				deploymentManifest.Properties = biproperty.Map{}
+
+				warnings, err := validator.ValidateReleaseJobs(deploymentManifest, releaseManager)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(warnings).To(Equal([]bivalidation.Problem{
+					bivalidation.Warningf("/jobs/0/templates/0", "jobs[0].templates[0] property 'fake-prop-key' of job 'fake-job-name' has no default and is not provided"),
+				}))
+			})
+		})
 	})
 })
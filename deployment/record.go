@@ -1,41 +1,48 @@
 package deployment
 
 import (
+	"crypto/sha1"
+	"fmt"
+
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
-	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
 	birel "github.com/cloudfoundry/bosh-init/release"
 	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+// Record keeps track of what is deployed: the sha1 of the patched & interpolated deployment manifest, the releases & the stemcell.
+// The manifest ops & variables are part of the sha1, so the same manifest with different ops or variables is a different deployment.
 type Record interface {
-	IsDeployed(manifestPath string, releases []birel.Release, stemcell bistemcell.ExtractedStemcell) (bool, error)
+	IsDeployed(manifestPath string, manifestVars bivars.Variables, manifestOps bipatch.Ops, releases []birel.Release, stemcell bistemcell.ExtractedStemcell) (bool, error)
 	Clear() error
-	Update(manifestPath string, releases []birel.Release) error
+	Update(manifestPath string, manifestVars bivars.Variables, manifestOps bipatch.Ops, releases []birel.Release) error
 }
 
 type deploymentRecord struct {
 	deploymentRepo biconfig.DeploymentRepo
 	releaseRepo    biconfig.ReleaseRepo
 	stemcellRepo   biconfig.StemcellRepo
-	sha1Calculator bicrypto.SHA1Calculator
+	fs             boshsys.FileSystem
 }
 
 func NewRecord(
 	deploymentRepo biconfig.DeploymentRepo,
 	releaseRepo biconfig.ReleaseRepo,
 	stemcellRepo biconfig.StemcellRepo,
-	sha1Calculator bicrypto.SHA1Calculator,
+	fs boshsys.FileSystem,
 ) Record {
 	return &deploymentRecord{
 		deploymentRepo: deploymentRepo,
 		releaseRepo:    releaseRepo,
 		stemcellRepo:   stemcellRepo,
-		sha1Calculator: sha1Calculator,
+		fs:             fs,
 	}
 }
 
-func (v *deploymentRecord) IsDeployed(manifestPath string, releases []birel.Release, stemcell bistemcell.ExtractedStemcell) (bool, error) {
+func (v *deploymentRecord) IsDeployed(manifestPath string, manifestVars bivars.Variables, manifestOps bipatch.Ops, releases []birel.Release, stemcell bistemcell.ExtractedStemcell) (bool, error) {
 	manifestSHA1, found, err := v.deploymentRepo.FindCurrent()
 	if err != nil {
 		return false, bosherr.WrapError(err, "Finding sha1 of currently deployed manifest")
@@ -45,7 +52,7 @@ func (v *deploymentRecord) IsDeployed(manifestPath string, releases []birel.Rele
 		return false, nil
 	}
 
-	newSHA1, err := v.sha1Calculator.Calculate(manifestPath)
+	newSHA1, err := v.calculateManifestSHA1(manifestPath, manifestVars, manifestOps)
 	if err != nil {
 		return false, bosherr.WrapError(err, "Calculating sha1 of current deployment manifest")
 	}
@@ -83,7 +90,7 @@ func (v *deploymentRecord) IsDeployed(manifestPath string, releases []birel.Rele
 	for _, release := range releases {
 		found := false
 		for _, releaseRecord := range currentReleaseRecords {
-			if releaseRecord.Name == release.Name() && releaseRecord.Version == release.Version() {
+			if releaseRecord.Matches(release) {
 				found = true
 				break
 			}
@@ -110,8 +117,8 @@ func (v *deploymentRecord) Clear() error {
 	return nil
 }
 
-func (v *deploymentRecord) Update(manifestPath string, releases []birel.Release) error {
-	manifestSHA1, err := v.sha1Calculator.Calculate(manifestPath)
+func (v *deploymentRecord) Update(manifestPath string, manifestVars bivars.Variables, manifestOps bipatch.Ops, releases []birel.Release) error {
+	manifestSHA1, err := v.calculateManifestSHA1(manifestPath, manifestVars, manifestOps)
 	if err != nil {
 		return bosherr.WrapError(err, "Calculating sha1 of current deployment manifest")
 	}
@@ -128,3 +135,22 @@ func (v *deploymentRecord) Update(manifestPath string, releases []birel.Release)
 
 	return nil
 }
+
+func (v *deploymentRecord) calculateManifestSHA1(manifestPath string, manifestVars bivars.Variables, manifestOps bipatch.Ops) (string, error) {
+	contents, err := v.fs.ReadFile(manifestPath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Reading deployment manifest '%s'", manifestPath)
+	}
+
+	contents, err = manifestOps.Apply(contents)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Applying ops to deployment manifest '%s'", manifestPath)
+	}
+
+	contents, err = bivars.Interpolate(contents, manifestVars)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Interpolating variables in deployment manifest '%s'", manifestPath)
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum(contents)), nil
+}
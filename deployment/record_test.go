@@ -1,13 +1,18 @@
 package deployment_test
 
 import (
+	"crypto/sha1"
 	"errors"
+	"fmt"
 
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	fakebiconfig "github.com/cloudfoundry/bosh-init/config/fakes"
-	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
 	"github.com/cloudfoundry/bosh-init/release"
 	fakebirel "github.com/cloudfoundry/bosh-init/release/fakes"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistemcell "github.com/cloudfoundry/bosh-init/stemcell"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	. "github.com/onsi/ginkgo"
@@ -20,23 +25,27 @@ var _ = Describe("Record", rootDesc)
 
 func rootDesc() {
 	var (
-		fakeRelease        *fakebirel.FakeRelease
-		stemcell           bistemcell.ExtractedStemcell
-		deploymentRepo     *fakebiconfig.FakeDeploymentRepo
-		releaseRepo        *fakebiconfig.FakeReleaseRepo
-		stemcellRepo       *fakebiconfig.FakeStemcellRepo
-		fakeSHA1Calculator *fakebicrypto.FakeSha1Calculator
-		deploymentRecord   Record
-		releases           []release.Release
+		fakeRelease      *fakebirel.FakeRelease
+		stemcell         bistemcell.ExtractedStemcell
+		deploymentRepo   *fakebiconfig.FakeDeploymentRepo
+		releaseRepo      *fakebiconfig.FakeReleaseRepo
+		stemcellRepo     *fakebiconfig.FakeStemcellRepo
+		fakeFS           *fakesys.FakeFileSystem
+		deploymentRecord Record
+		releases         []release.Release
+		manifestVars     bivars.Variables
+		manifestSHA1     string
 	)
 
 	BeforeEach(func() {
 		fakeRelease = &fakebirel.FakeRelease{
-			ReleaseName:    "fake-release-name",
-			ReleaseVersion: "fake-release-version",
+			ReleaseName:     "fake-release-name",
+			ReleaseVersion:  "fake-release-version",
+			ReleaseJobs:     []bireljob.Job{{Name: "fake-job-name", Fingerprint: "fake-job-fingerprint"}},
+			ReleasePackages: []*birelpkg.Package{{Name: "fake-package-name", Fingerprint: "fake-package-fingerprint"}},
 		}
 		releases = []release.Release{fakeRelease}
-		fakeFS := fakesys.NewFakeFileSystem()
+		fakeFS = fakesys.NewFakeFileSystem()
 		stemcell = bistemcell.NewExtractedStemcell(
 			bistemcell.Manifest{
 				Name:    "fake-stemcell-name",
@@ -48,8 +57,11 @@ func rootDesc() {
 		deploymentRepo = fakebiconfig.NewFakeDeploymentRepo()
 		releaseRepo = &fakebiconfig.FakeReleaseRepo{}
 		stemcellRepo = fakebiconfig.NewFakeStemcellRepo()
-		fakeSHA1Calculator = fakebicrypto.NewFakeSha1Calculator()
-		deploymentRecord = NewRecord(deploymentRepo, releaseRepo, stemcellRepo, fakeSHA1Calculator)
+		deploymentRecord = NewRecord(deploymentRepo, releaseRepo, stemcellRepo, fakeFS)
+
+		fakeFS.WriteFileString("fake-manifest-path", "---\nname: ((deployment_name))\n")
+		manifestVars = bivars.Variables{"deployment_name": "fake-deployment-name"}
+		manifestSHA1 = fmt.Sprintf("%x", sha1.Sum([]byte("name: fake-deployment-name\n")))
 	})
 
 	Describe("IsDeployed", func() {
@@ -62,13 +74,7 @@ func rootDesc() {
 			}
 			stemcellRepo.SetFindCurrentBehavior(stemcellRecord, true, nil)
 
-			deploymentRepo.SetFindCurrentBehavior("fake-manifest-sha1", true, nil)
-			fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-				"fake-manifest-path": fakebicrypto.CalculateInput{
-					Sha1: "fake-manifest-sha1",
-					Err:  nil,
-				},
-			})
+			deploymentRepo.SetFindCurrentBehavior(manifestSHA1, true, nil)
 		})
 
 		Context("when the stemcell and manifest do not change", func() {
@@ -78,7 +84,7 @@ func rootDesc() {
 				})
 
 				It("returns false", func() {
-					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(isDeployed).To(BeFalse())
 				})
@@ -90,35 +96,93 @@ func rootDesc() {
 						ID:      "fake-release-id",
 						Name:    fakeRelease.Name(),
 						Version: fakeRelease.Version(),
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "fake-job-name", Fingerprint: "fake-job-fingerprint"},
+						},
+						Packages: []biconfig.FingerprintRecord{
+							{Name: "fake-package-name", Fingerprint: "fake-package-fingerprint"},
+						},
 					}}
 					releaseRepo.ListReturns(releaseRecords, nil)
 				})
 
 				It("returns true", func() {
-					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(isDeployed).To(BeTrue())
 				})
 			})
 
-			Context("when a different version of the same release is currently deployed", func() {
+			Context("when a release with different job fingerprints is currently deployed", func() {
 				BeforeEach(func() {
-					Expect("other-version").ToNot(Equal(fakeRelease.Version()))
 					releaseRecords := []biconfig.ReleaseRecord{{
 						ID:      "fake-release-id-2",
 						Name:    fakeRelease.Name(),
-						Version: "other-version",
+						Version: fakeRelease.Version(),
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "fake-job-name", Fingerprint: "other-job-fingerprint"},
+						},
+						Packages: []biconfig.FingerprintRecord{
+							{Name: "fake-package-name", Fingerprint: "fake-package-fingerprint"},
+						},
 					}}
 					releaseRepo.ListReturns(releaseRecords, nil)
 				})
 
 				It("returns false", func() {
-					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(isDeployed).To(BeFalse())
 				})
 			})
 
+			Context("when a release with different package fingerprints is currently deployed", func() {
+				BeforeEach(func() {
+					releaseRecords := []biconfig.ReleaseRecord{{
+						ID:      "fake-release-id-2",
+						Name:    fakeRelease.Name(),
+						Version: fakeRelease.Version(),
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "fake-job-name", Fingerprint: "fake-job-fingerprint"},
+						},
+						Packages: []biconfig.FingerprintRecord{
+							{Name: "fake-package-name", Fingerprint: "other-package-fingerprint"},
+						},
+					}}
+					releaseRepo.ListReturns(releaseRecords, nil)
+				})
+
+				It("returns false", func() {
+					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(isDeployed).To(BeFalse())
+				})
+			})
+
+			Context("when a different version of the same release with the same fingerprints is currently deployed", func() {
+				BeforeEach(func() {
+					Expect("other-version").ToNot(Equal(fakeRelease.Version()))
+					releaseRecords := []biconfig.ReleaseRecord{{
+						ID:      "fake-release-id-2",
+						Name:    fakeRelease.Name(),
+						Version: "other-version",
+						Jobs: []biconfig.FingerprintRecord{
+							{Name: "fake-job-name", Fingerprint: "fake-job-fingerprint"},
+						},
+						Packages: []biconfig.FingerprintRecord{
+							{Name: "fake-package-name", Fingerprint: "fake-package-fingerprint"},
+						},
+					}}
+					releaseRepo.ListReturns(releaseRecords, nil)
+				})
+
+				It("returns true", func() {
+					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(isDeployed).To(BeTrue())
+				})
+			})
+
 			Context("when a same version of a different release is currently deployed", func() {
 				BeforeEach(func() {
 					Expect("other-release").ToNot(Equal(fakeRelease.Name()))
@@ -131,7 +195,7 @@ func rootDesc() {
 				})
 
 				It("returns false", func() {
-					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+					isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(isDeployed).To(BeFalse())
 				})
@@ -150,6 +214,12 @@ func rootDesc() {
 							ID:      "fake-release-id-1",
 							Name:    fakeRelease.Name(),
 							Version: fakeRelease.Version(),
+							Jobs: []biconfig.FingerprintRecord{
+								{Name: "fake-job-name", Fingerprint: "fake-job-fingerprint"},
+							},
+							Packages: []biconfig.FingerprintRecord{
+								{Name: "fake-package-name", Fingerprint: "fake-package-fingerprint"},
+							},
 						},
 						{
 							ID:      "other-fake-release-id-1",
@@ -170,7 +240,7 @@ func rootDesc() {
 						})
 
 						It("returns true", func() {
-							isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+							isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(isDeployed).To(BeTrue())
 						})
@@ -185,7 +255,7 @@ func rootDesc() {
 						})
 
 						It("returns true", func() {
-							isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+							isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(isDeployed).To(BeTrue())
 						})
@@ -199,7 +269,7 @@ func rootDesc() {
 						})
 
 						It("returns false", func() {
-							isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+							isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 							Expect(err).ToNot(HaveOccurred())
 							Expect(isDeployed).To(BeFalse())
 						})
@@ -214,7 +284,7 @@ func rootDesc() {
 			})
 
 			It("returns an error", func() {
-				_, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				_, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-find-error"))
 			})
@@ -226,7 +296,7 @@ func rootDesc() {
 			})
 
 			It("returns false", func() {
-				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(isDeployed).To(BeFalse())
 			})
@@ -234,18 +304,13 @@ func rootDesc() {
 
 		Context("when calculating the deployment manifest sha1 fails", func() {
 			BeforeEach(func() {
-				fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-					"fake-manifest-path": fakebicrypto.CalculateInput{
-						Sha1: "",
-						Err:  errors.New("fake-calculate-error"),
-					},
-				})
+				fakeFS.ReadFileError = errors.New("fake-read-error")
 			})
 
 			It("returns an error", func() {
-				_, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				_, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("fake-calculate-error"))
+				Expect(err.Error()).To(ContainSubstring("fake-read-error"))
 			})
 		})
 
@@ -255,19 +320,46 @@ func rootDesc() {
 			})
 
 			It("returns false", func() {
-				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDeployed).To(BeFalse())
+			})
+		})
+
+		Context("when the deployment manifest is deployed with different variables", func() {
+			It("returns false", func() {
+				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", bivars.Variables{"deployment_name": "other-deployment-name"}, bipatch.Ops{}, releases, stemcell)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isDeployed).To(BeFalse())
+			})
+		})
+
+		Context("when the deployment manifest is deployed with different ops", func() {
+			It("returns false", func() {
+				ops, err := bipatch.ParseOps([]byte("- type: replace\n  path: /name\n  value: other-deployment-name\n"))
+				Expect(err).ToNot(HaveOccurred())
+
+				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, ops, releases, stemcell)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(isDeployed).To(BeFalse())
 			})
 		})
 
+		Context("when a deployment manifest variable is undefined", func() {
+			It("returns an error", func() {
+				_, err := deploymentRecord.IsDeployed("fake-manifest-path", bivars.Variables{}, bipatch.Ops{}, releases, stemcell)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Undefined variable 'deployment_name'"))
+			})
+		})
+
 		Context("when finding the currently deployed stemcell fails", func() {
 			BeforeEach(func() {
 				stemcellRepo.SetFindCurrentBehavior(biconfig.StemcellRecord{}, false, errors.New("fake-find-error"))
 			})
 
 			It("returns an error", func() {
-				_, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				_, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-find-error"))
 			})
@@ -279,7 +371,7 @@ func rootDesc() {
 			})
 
 			It("returns false", func() {
-				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(isDeployed).To(BeFalse())
 			})
@@ -297,7 +389,7 @@ func rootDesc() {
 			})
 
 			It("returns false", func() {
-				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				isDeployed, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(isDeployed).To(BeFalse())
 			})
@@ -309,7 +401,7 @@ func rootDesc() {
 			})
 
 			It("returns an error", func() {
-				_, err := deploymentRecord.IsDeployed("fake-manifest-path", releases, stemcell)
+				_, err := deploymentRecord.IsDeployed("fake-manifest-path", manifestVars, bipatch.Ops{}, releases, stemcell)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-find-error"))
 			})
@@ -317,23 +409,14 @@ func rootDesc() {
 	})
 
 	Describe("Update", func() {
-		BeforeEach(func() {
-			fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-				"fake-manifest-path": fakebicrypto.CalculateInput{
-					Sha1: "fake-manifest-sha1",
-					Err:  nil,
-				},
-			})
-		})
-
 		It("calculates and updates sha1 of currently deployed manifest", func() {
-			err := deploymentRecord.Update("fake-manifest-path", releases)
+			err := deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(deploymentRepo.UpdateCurrentManifestSHA1).To(Equal("fake-manifest-sha1"))
+			Expect(deploymentRepo.UpdateCurrentManifestSHA1).To(Equal(manifestSHA1))
 		})
 
 		It("passes the releases to the release repo", func() {
-			err := deploymentRecord.Update("fake-manifest-path", releases)
+			err := deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(releaseRepo.UpdateCallCount()).To(Equal(1))
 			Expect(releaseRepo.UpdateArgsForCall(0)).To(Equal(releases))
@@ -341,22 +424,17 @@ func rootDesc() {
 
 		Context("when calculating the deployment manifest sha1 fails", func() {
 			BeforeEach(func() {
-				fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-					"fake-manifest-path": fakebicrypto.CalculateInput{
-						Sha1: "",
-						Err:  errors.New("fake-calculate-error"),
-					},
-				})
+				fakeFS.ReadFileError = errors.New("fake-read-error")
 			})
 
 			It("returns an error", func() {
-				err := deploymentRecord.Update("fake-manifest-path", releases)
+				err := deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("fake-calculate-error"))
+				Expect(err.Error()).To(ContainSubstring("fake-read-error"))
 			})
 
 			It("does not update the release records", func() {
-				deploymentRecord.Update("fake-manifest-path", releases)
+				deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 				Expect(releaseRepo.UpdateCallCount()).To(Equal(0))
 			})
 		})
@@ -367,13 +445,13 @@ func rootDesc() {
 			})
 
 			It("returns an error", func() {
-				err := deploymentRecord.Update("fake-manifest-path", releases)
+				err := deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-update-error"))
 			})
 
 			It("does not update the release records", func() {
-				deploymentRecord.Update("fake-manifest-path", releases)
+				deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 				Expect(releaseRepo.UpdateCallCount()).To(Equal(0))
 			})
 		})
@@ -384,7 +462,7 @@ func rootDesc() {
 			})
 
 			It("returns an error", func() {
-				err := deploymentRecord.Update("fake-manifest-path", releases)
+				err := deploymentRecord.Update("fake-manifest-path", manifestVars, bipatch.Ops{}, releases)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-update-error"))
 			})
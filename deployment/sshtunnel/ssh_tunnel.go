@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	binet "github.com/cloudfoundry/bosh-init/common/net"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	"github.com/pivotal-golang/clock"
@@ -74,8 +75,8 @@ func (s *sshTunnel) Start(readyErrCh chan<- error, errCh chan<- error) {
 		Auth: authMethods,
 	}
 
-	s.logger.Debug(s.logTag, "Dialing remote server at %s:%d", s.options.Host, s.options.Port)
-	remoteAddr := fmt.Sprintf("%s:%d", s.options.Host, s.options.Port)
+	remoteAddr := binet.JoinHostPort(s.options.Host, s.options.Port)
+	s.logger.Debug(s.logTag, "Dialing remote server at %s", remoteAddr)
 
 	retryStrategy := &SSHRetryStrategy{
 		TimeService:              s.timeService,
@@ -1,16 +1,37 @@
 package index
 
 import (
+	"bytes"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
+	"time"
 
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
+// FileIndex keeps the entries of a JSON file in memory & looks them up by their canonical key.
+// The file can be shared by several processes, like the compiled packages cache: entries that are not found
+// are looked up again in the file, in case another process saved them, and every save merges its entries
+// into the current file under a lock file, before writing it atomically.
+// Saves can be batched, to lock & write the file once for many entries.
 type FileIndex struct {
-	path string
-	fs   boshsys.FileSystem
+	path        string
+	fs          boshsys.FileSystem
+	lockTimeout time.Duration
+
+	lock      sync.Mutex
+	loaded    bool
+	contents  []byte
+	entries   []indexEntry
+	positions map[string]int
+
+	batchDepth     int
+	pendingKeys    []string
+	pendingEntries map[string]indexEntry
 }
 
 type indexEntry struct {
@@ -18,110 +39,285 @@ type indexEntry struct {
 	Value json.RawMessage
 }
 
-func NewFileIndex(path string, fs boshsys.FileSystem) FileIndex {
-	return FileIndex{path: path, fs: fs}
+const indexLockRetryInterval = 50 * time.Millisecond
+
+func NewFileIndex(path string, fs boshsys.FileSystem) *FileIndex {
+	return &FileIndex{path: path, fs: fs, lockTimeout: 30 * time.Second, pendingEntries: map[string]indexEntry{}}
 }
 
-func (ri FileIndex) Find(key interface{}, value interface{}) error {
-	rawEntries, err := ri.readRawEntries()
+func (ri *FileIndex) Find(key interface{}, value interface{}) error {
+	canonicalKey, _, err := ri.canonicalKey(key)
 	if err != nil {
 		return err
 	}
 
-	rawKey, err := ri.structToMap(key)
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
+
+	position, found := ri.positions[canonicalKey]
+	if !found || !ri.loaded {
+		// the entry may have been saved by another process since the file was last read
+		err = ri.load()
+		if err != nil {
+			return err
+		}
+
+		position, found = ri.positions[canonicalKey]
+		if !found {
+			return ErrNotFound
+		}
+	}
+
+	return json.Unmarshal(ri.entries[position].Value, value)
+}
+
+func (ri *FileIndex) Save(key interface{}, value interface{}) error {
+	canonicalKey, rawKey, err := ri.canonicalKey(key)
 	if err != nil {
 		return err
 	}
 
-	for _, rawEntry := range rawEntries {
-		if reflect.DeepEqual(rawEntry.Key, rawKey) {
-			err := json.Unmarshal(rawEntry.Value, value)
-			if err != nil {
-				return err
-			}
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
 
-			return nil
+	if !ri.loaded {
+		err = ri.load()
+		if err != nil {
+			return err
 		}
 	}
 
-	return ErrNotFound
+	entry := indexEntry{Key: rawKey, Value: rawValue}
+	if _, found := ri.pendingEntries[canonicalKey]; !found {
+		ri.pendingKeys = append(ri.pendingKeys, canonicalKey)
+	}
+	ri.pendingEntries[canonicalKey] = entry
+	ri.put(canonicalKey, entry)
+
+	if ri.batchDepth > 0 {
+		return nil
+	}
+
+	return ri.flush()
 }
 
-func (ri FileIndex) Save(key interface{}, value interface{}) error {
-	rawEntries, err := ri.readRawEntries()
+// Batch runs the function, writing the entries it saves to the file once, when it returns,
+// so that the file is locked & written once rather than for every entry.
+// The file is not locked while the function runs. The saved entries are written even when the function fails.
+func (ri *FileIndex) Batch(fn func() error) error {
+	ri.lock.Lock()
+	ri.batchDepth++
+	ri.lock.Unlock()
+
+	fnErr := fn()
+
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
+
+	ri.batchDepth--
+
+	var err error
+	if ri.batchDepth == 0 {
+		err = ri.flush()
+	}
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return err
+}
+
+// flush merges the pending entries into the current index file under the lock file, & writes it.
+// The pending entries are kept when writing fails, to be written by the next save.
+func (ri *FileIndex) flush() error {
+	if len(ri.pendingKeys) == 0 {
+		return nil
+	}
+
+	err := ri.lockFile()
 	if err != nil {
 		return err
 	}
+	defer ri.unlockFile()
 
-	rawKey, err := ri.structToMap(key)
+	// the entries saved by other processes since the file was last read are kept
+	err = ri.load()
 	if err != nil {
 		return err
 	}
 
-	rawValue, err := json.Marshal(value)
+	err = ri.write()
 	if err != nil {
 		return err
 	}
 
-	foundI := -1
+	ri.pendingKeys = nil
+	ri.pendingEntries = map[string]indexEntry{}
+	return nil
+}
 
-	for i, rawEntry := range rawEntries {
-		if reflect.DeepEqual(rawEntry.Key, rawKey) {
-			foundI = i
-			break
-		}
+func (ri *FileIndex) put(canonicalKey string, entry indexEntry) {
+	if position, found := ri.positions[canonicalKey]; found {
+		ri.entries[position].Value = entry.Value
+	} else {
+		ri.positions[canonicalKey] = len(ri.entries)
+		ri.entries = append(ri.entries, entry)
 	}
+}
 
-	if foundI >= 0 {
-		rawEntries[foundI].Value = rawValue
-	} else {
-		rawEntries = append(rawEntries, indexEntry{
-			Key:   rawKey,
-			Value: rawValue,
-		})
+// canonicalKey returns the key as an entry key of the file, and serialized with sorted field names to look entries up
+func (ri *FileIndex) canonicalKey(key interface{}) (string, map[string]interface{}, error) {
+	rawKey, err := ri.structToMap(key)
+	if err != nil {
+		return "", nil, err
 	}
 
-	err = ri.writeRawEntries(rawEntries)
+	canonicalKey, err := json.Marshal(rawKey)
 	if err != nil {
-		return err
+		return "", nil, bosherr.WrapError(err, "Marshalling index key")
 	}
 
-	return nil
+	return string(canonicalKey), rawKey, nil
 }
 
-func (ri FileIndex) readRawEntries() ([]indexEntry, error) {
-	var entries []indexEntry
+// load reads the index file, only parsing its entries again when its contents changed since it was last read.
+// The pending entries that were not written yet are applied to the parsed entries.
+func (ri *FileIndex) load() error {
+	var contents []byte
 
 	if ri.fs.FileExists(ri.path) {
-		bytes, err := ri.fs.ReadFile(ri.path)
+		var err error
+		contents, err = ri.fs.ReadFile(ri.path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading index file %s", ri.path)
+		}
+	}
+
+	if ri.loaded && bytes.Equal(contents, ri.contents) {
+		return nil
+	}
+
+	var entries []indexEntry
+	if contents != nil {
+		err := json.Unmarshal(contents, &entries)
 		if err != nil {
-			return entries, bosherr.WrapErrorf(err, "Reading index file %s", ri.path)
+			return bosherr.WrapError(err, "Unmarshalling index entries")
 		}
+	}
 
-		err = json.Unmarshal(bytes, &entries)
+	positions := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		canonicalKey, err := json.Marshal(entry.Key)
 		if err != nil {
-			return entries, bosherr.WrapError(err, "Unmarshalling index entries")
+			return bosherr.WrapError(err, "Marshalling index key")
+		}
+
+		// the first of duplicate entries is the one that was found & updated
+		if _, found := positions[string(canonicalKey)]; !found {
+			positions[string(canonicalKey)] = i
 		}
 	}
 
-	return entries, nil
+	ri.entries = entries
+	ri.positions = positions
+	ri.contents = contents
+	ri.loaded = true
+
+	for _, canonicalKey := range ri.pendingKeys {
+		ri.put(canonicalKey, ri.pendingEntries[canonicalKey])
+	}
+
+	return nil
 }
 
-func (ri FileIndex) writeRawEntries(entries []indexEntry) error {
-	bytes, err := json.Marshal(entries)
+// write writes the entries to a temporary file next to the index file, syncs it, then renames it over the index file,
+// so that a crash never leaves a truncated index behind
+func (ri *FileIndex) write() error {
+	contents, err := json.Marshal(ri.entries)
 	if err != nil {
 		return bosherr.WrapError(err, "Marshalling index entries")
 	}
 
-	err = ri.fs.WriteFile(ri.path, bytes)
+	tempPath := ri.path + ".tmp"
+	err = ri.fs.WriteFile(tempPath, contents)
 	if err != nil {
-		return bosherr.WrapErrorf(err, "Writing index file %s", ri.path)
+		ri.fs.RemoveAll(tempPath)
+		return bosherr.WrapErrorf(err, "Writing index file %s", tempPath)
 	}
 
+	err = ri.syncFile(tempPath)
+	if err != nil {
+		ri.fs.RemoveAll(tempPath)
+		return bosherr.WrapErrorf(err, "Syncing index file %s", tempPath)
+	}
+
+	err = ri.fs.Rename(tempPath, ri.path)
+	if err != nil {
+		ri.fs.RemoveAll(tempPath)
+		return bosherr.WrapErrorf(err, "Renaming index file %s to %s", tempPath, ri.path)
+	}
+
+	ri.contents = contents
 	return nil
 }
 
-func (ri FileIndex) structToMap(s interface{}) (map[string]interface{}, error) {
+// syncFile flushes the file to disk, when the file system supports it
+func (ri *FileIndex) syncFile(path string) error {
+	file, err := ri.fs.OpenFile(path, os.O_RDONLY, os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if syncer, ok := file.(interface {
+		Sync() error
+	}); ok {
+		return syncer.Sync()
+	}
+
+	return nil
+}
+
+// lockFile creates the lock file of the index, waiting for the other processes saving to the index to remove theirs
+func (ri *FileIndex) lockFile() error {
+	lockPath := ri.path + ".lock"
+
+	err := ri.fs.MkdirAll(filepath.Dir(ri.path), os.ModePerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating index directory %s", filepath.Dir(ri.path))
+	}
+
+	deadline := time.Now().Add(ri.lockTimeout)
+	for {
+		if !ri.fs.FileExists(lockPath) {
+			// O_EXCL makes the creation fail when another process took the lock since it was checked
+			lockFile, err := ri.fs.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(0644))
+			if err == nil {
+				return lockFile.Close()
+			}
+			if !os.IsExist(err) {
+				return bosherr.WrapErrorf(err, "Creating index lock file %s", lockPath)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return bosherr.Errorf("Index file %s is locked by another process. If no other bosh-init is running, remove %s", ri.path, lockPath)
+		}
+		time.Sleep(indexLockRetryInterval)
+	}
+}
+
+func (ri *FileIndex) unlockFile() {
+	ri.fs.RemoveAll(ri.path + ".lock")
+}
+
+func (ri *FileIndex) structToMap(s interface{}) (map[string]interface{}, error) {
 	res := map[string]interface{}{}
 	st := reflect.TypeOf(s)
 	stv := reflect.ValueOf(s)
@@ -138,11 +334,11 @@ func (ri FileIndex) structToMap(s interface{}) (map[string]interface{}, error) {
 	return res, nil
 }
 
-func (ri FileIndex) mapToStruct(m map[string]interface{}, t interface{}) (reflect.Value, error) {
+func (ri *FileIndex) mapToStruct(m map[string]interface{}, t interface{}) (reflect.Value, error) {
 	return ri.mapToNewStruct(m, reflect.ValueOf(t).Elem().Type())
 }
 
-func (ri FileIndex) mapToStructFromSlice(m map[string]interface{}, t interface{}) (reflect.Value, error) {
+func (ri *FileIndex) mapToStructFromSlice(m map[string]interface{}, t interface{}) (reflect.Value, error) {
 	slice := reflect.ValueOf(t).Elem()
 
 	if slice.Kind() != reflect.Slice {
@@ -155,7 +351,7 @@ func (ri FileIndex) mapToStructFromSlice(m map[string]interface{}, t interface{}
 	return ri.mapToNewStruct(m, slice.Type().Elem())
 }
 
-func (ri FileIndex) mapToNewStruct(m map[string]interface{}, t reflect.Type) (reflect.Value, error) {
+func (ri *FileIndex) mapToNewStruct(m map[string]interface{}, t reflect.Type) (reflect.Value, error) {
 	if t.Kind() != reflect.Struct {
 		return reflect.Value{}, bosherr.Errorf(
 			"Must be reflect.Struct: %#v (%#v)",
@@ -208,6 +404,6 @@ var kindToStrMap = map[reflect.Kind]string{
 	reflect.UnsafePointer: "UnsafePointer",
 }
 
-func (ri FileIndex) kindToStr(k reflect.Kind) string {
+func (ri *FileIndex) kindToStr(k reflect.Kind) string {
 	return kindToStrMap[k]
 }
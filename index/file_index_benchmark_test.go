@@ -0,0 +1,206 @@
+package index_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/cloudfoundry/bosh-init/index"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// rescanningFileIndex is the FileIndex before it kept its entries in memory,
+// kept to benchmark against: every Find & Save reads the whole file & scans its entries
+type rescanningFileIndex struct {
+	path string
+	fs   boshsys.FileSystem
+}
+
+type rescanningIndexEntry struct {
+	Key   map[string]interface{}
+	Value json.RawMessage
+}
+
+func (ri rescanningFileIndex) Find(key interface{}, value interface{}) error {
+	entries, err := ri.readEntries()
+	if err != nil {
+		return err
+	}
+
+	rawKey := ri.structToMap(key)
+	for _, entry := range entries {
+		if reflect.DeepEqual(entry.Key, rawKey) {
+			return json.Unmarshal(entry.Value, value)
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (ri rescanningFileIndex) Save(key interface{}, value interface{}) error {
+	entries, err := ri.readEntries()
+	if err != nil {
+		return err
+	}
+
+	rawKey := ri.structToMap(key)
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, entry := range entries {
+		if reflect.DeepEqual(entry.Key, rawKey) {
+			entries[i].Value = rawValue
+			found = true
+			break
+		}
+	}
+	if !found {
+		entries = append(entries, rescanningIndexEntry{Key: rawKey, Value: rawValue})
+	}
+
+	bytes, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return ri.fs.WriteFile(ri.path, bytes)
+}
+
+func (ri rescanningFileIndex) readEntries() ([]rescanningIndexEntry, error) {
+	var entries []rescanningIndexEntry
+
+	if ri.fs.FileExists(ri.path) {
+		bytes, err := ri.fs.ReadFile(ri.path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(bytes, &entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+func (ri rescanningFileIndex) structToMap(s interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	st := reflect.TypeOf(s)
+	stv := reflect.ValueOf(s)
+	for i := 0; i < st.NumField(); i++ {
+		res[st.Field(i).Name] = stv.Field(i).Interface()
+	}
+	return res
+}
+
+type benchmarkKey struct {
+	PackageName        string
+	PackageFingerprint string
+	DependencyKey      string
+}
+
+const benchmarkEntries = 500
+
+func benchmarkKeyAt(i int) benchmarkKey {
+	return benchmarkKey{
+		PackageName:        fmt.Sprintf("package-%d", i),
+		PackageFingerprint: fmt.Sprintf("fingerprint-%d", i),
+		DependencyKey:      fmt.Sprintf("dependency-%d", i),
+	}
+}
+
+func benchmarkIndexPath(b *testing.B) (string, boshsys.FileSystem, func()) {
+	dir, err := ioutil.TempDir("", "file-index-benchmark")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return filepath.Join(dir, "index.json"), boshsys.NewOsFileSystem(boshlog.NewLogger(boshlog.LevelNone)), func() { os.RemoveAll(dir) }
+}
+
+func benchmarkSave(b *testing.B, newIndex func(path string, fs boshsys.FileSystem) Index) {
+	path, fs, cleanup := benchmarkIndexPath(b)
+	defer cleanup()
+
+	for n := 0; n < b.N; n++ {
+		fs.RemoveAll(path)
+		index := newIndex(path, fs)
+
+		for i := 0; i < benchmarkEntries; i++ {
+			err := index.Save(benchmarkKeyAt(i), Value{Name: "compiled-package", Count: float64(i)})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchmarkFind(b *testing.B, newIndex func(path string, fs boshsys.FileSystem) Index) {
+	path, fs, cleanup := benchmarkIndexPath(b)
+	defer cleanup()
+
+	index := newIndex(path, fs)
+	for i := 0; i < benchmarkEntries; i++ {
+		err := index.Save(benchmarkKeyAt(i), Value{Name: "compiled-package", Count: float64(i)})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var value Value
+		err := index.Find(benchmarkKeyAt(n%benchmarkEntries), &value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newRescanningFileIndex(path string, fs boshsys.FileSystem) Index {
+	return rescanningFileIndex{path: path, fs: fs}
+}
+
+func newFileIndex(path string, fs boshsys.FileSystem) Index {
+	return NewFileIndex(path, fs)
+}
+
+func BenchmarkRescanningFileIndexSave(b *testing.B) { benchmarkSave(b, newRescanningFileIndex) }
+
+func BenchmarkFileIndexSave(b *testing.B) { benchmarkSave(b, newFileIndex) }
+
+func BenchmarkFileIndexBatchSave(b *testing.B) {
+	path, fs, cleanup := benchmarkIndexPath(b)
+	defer cleanup()
+
+	for n := 0; n < b.N; n++ {
+		fs.RemoveAll(path)
+		index := NewFileIndex(path, fs)
+
+		err := index.Batch(func() error {
+			for i := 0; i < benchmarkEntries; i++ {
+				err := index.Save(benchmarkKeyAt(i), Value{Name: "compiled-package", Count: float64(i)})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRescanningFileIndexFind(b *testing.B) { benchmarkFind(b, newRescanningFileIndex) }
+
+func BenchmarkFileIndexFind(b *testing.B) { benchmarkFind(b, newFileIndex) }
@@ -1,9 +1,12 @@
 package index_test
 
 import (
+	"errors"
+
 	. "github.com/cloudfoundry/bosh-init/index"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -12,7 +15,7 @@ var _ = Describe("FileIndex", func() {
 	var (
 		fs            boshsys.FileSystem
 		indexFilePath string
-		index         FileIndex
+		index         *FileIndex
 	)
 
 	BeforeEach(func() {
@@ -150,7 +153,7 @@ var _ = Describe("FileIndex", func() {
 
 		Context("when a new FileIndex is constructed backed by the same file", func() {
 			var (
-				index2 FileIndex
+				index2 *FileIndex
 			)
 
 			BeforeEach(func() {
@@ -171,4 +174,206 @@ var _ = Describe("FileIndex", func() {
 			})
 		})
 	})
+
+	Describe("file", func() {
+		var (
+			fakeFs    *fakesys.FakeFileSystem
+			fakeIndex *FileIndex
+		)
+
+		BeforeEach(func() {
+			fakeFs = fakesys.NewFakeFileSystem()
+			fakeIndex = NewFileIndex("/index.json", fakeFs)
+		})
+
+		It("finds the entries of an index file written by earlier versions, whatever the order of the key fields", func() {
+			fakeFs.WriteFileString("/index.json", `[{"Key":{"B":"b-1","A":"a-1"},"Value":{"Name":"value-1","Count":1}}]`)
+
+			var value Value
+			err := fakeIndex.Find(struct{ A, B string }{A: "a-1", B: "b-1"}, &value)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(Value{Name: "value-1", Count: 1}))
+		})
+
+		It("keeps the format of the index file", func() {
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+			Expect(fakeIndex.Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).To(Succeed())
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-3", Count: 3})).To(Succeed())
+
+			Expect(fakeFs.ReadFileString("/index.json")).To(MatchJSON(`[
+				{"Key":{"Key":"key-1"},"Value":{"Name":"value-3","Count":3}},
+				{"Key":{"Key":"key-2"},"Value":{"Name":"value-2","Count":2}}
+			]`))
+		})
+
+		It("finds the entries saved to the same file by another process", func() {
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+			Expect(NewFileIndex("/index.json", fakeFs).Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).To(Succeed())
+
+			var value Value
+			Expect(fakeIndex.Find(Key{Key: "key-2"}, &value)).To(Succeed())
+			Expect(value).To(Equal(Value{Name: "value-2", Count: 2}))
+		})
+
+		It("keeps the entries saved to the same file by another process when saving", func() {
+			otherIndex := NewFileIndex("/index.json", fakeFs)
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+			Expect(otherIndex.Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).To(Succeed())
+			Expect(fakeIndex.Save(Key{Key: "key-3"}, Value{Name: "value-3", Count: 3})).To(Succeed())
+
+			var value Value
+			Expect(otherIndex.Find(Key{Key: "key-2"}, &value)).To(Succeed())
+			Expect(otherIndex.Find(Key{Key: "key-3"}, &value)).To(Succeed())
+			Expect(value).To(Equal(Value{Name: "value-3", Count: 3}))
+		})
+
+		It("returns an error when the index file cannot be read", func() {
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+			fakeFs.ReadFileError = errors.New("fake-read-error")
+
+			var value Value
+			err := fakeIndex.Find(Key{Key: "key-2"}, &value)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-read-error"))
+		})
+
+		It("finds the entries it already read without reading the index file again", func() {
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+			fakeFs.ReadFileError = errors.New("fake-read-error")
+
+			var value Value
+			Expect(fakeIndex.Find(Key{Key: "key-1"}, &value)).To(Succeed())
+			Expect(value).To(Equal(Value{Name: "value-1", Count: 1}))
+		})
+
+		It("writes the entry of a failed save with the next save", func() {
+			fakeFs.RenameError = errors.New("fake-rename-error")
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).ToNot(Succeed())
+
+			fakeFs.RenameError = nil
+			Expect(fakeIndex.Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).To(Succeed())
+
+			Expect(fakeFs.ReadFileString("/index.json")).To(MatchJSON(`[
+				{"Key":{"Key":"key-1"},"Value":{"Name":"value-1","Count":1}},
+				{"Key":{"Key":"key-2"},"Value":{"Name":"value-2","Count":2}}
+			]`))
+		})
+
+		It("removes its lock file after saving", func() {
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+			Expect(fakeFs.FileExists("/index.json.lock")).To(BeFalse())
+
+			fakeFs.RenameError = errors.New("fake-rename-error")
+			Expect(fakeIndex.Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).ToNot(Succeed())
+			Expect(fakeFs.FileExists("/index.json.lock")).To(BeFalse())
+		})
+
+		It("writes a temporary file & renames it over the index file", func() {
+			Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+
+			Expect(fakeFs.RenameOldPaths).To(Equal([]string{"/index.json.tmp"}))
+			Expect(fakeFs.RenameNewPaths).To(Equal([]string{"/index.json"}))
+			Expect(fakeFs.FileExists("/index.json.tmp")).To(BeFalse())
+		})
+
+		Describe("Batch", func() {
+			It("locks & writes the index file once for all the entries saved in the batch", func() {
+				err := fakeIndex.Batch(func() error {
+					Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+					Expect(fakeIndex.Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).To(Succeed())
+					Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-3", Count: 3})).To(Succeed())
+
+					Expect(fakeFs.FileExists("/index.json")).To(BeFalse())
+					Expect(fakeFs.FileExists("/index.json.lock")).To(BeFalse())
+					return nil
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeFs.RenameOldPaths).To(Equal([]string{"/index.json.tmp"}))
+				Expect(fakeFs.FileExists("/index.json.lock")).To(BeFalse())
+				Expect(fakeFs.ReadFileString("/index.json")).To(MatchJSON(`[
+					{"Key":{"Key":"key-1"},"Value":{"Name":"value-3","Count":3}},
+					{"Key":{"Key":"key-2"},"Value":{"Name":"value-2","Count":2}}
+				]`))
+			})
+
+			It("finds the entries saved in the batch before they are written", func() {
+				err := fakeIndex.Batch(func() error {
+					Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+
+					var value Value
+					Expect(fakeIndex.Find(Key{Key: "key-1"}, &value)).To(Succeed())
+					Expect(value).To(Equal(Value{Name: "value-1", Count: 1}))
+					return nil
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("keeps the entries saved to the same file by another process during the batch", func() {
+				err := fakeIndex.Batch(func() error {
+					Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+					Expect(NewFileIndex("/index.json", fakeFs).Save(Key{Key: "key-2"}, Value{Name: "value-2", Count: 2})).To(Succeed())
+
+					var value Value
+					Expect(fakeIndex.Find(Key{Key: "key-2"}, &value)).To(Succeed())
+					Expect(fakeIndex.Find(Key{Key: "key-1"}, &value)).To(Succeed())
+					Expect(value).To(Equal(Value{Name: "value-1", Count: 1}))
+					return nil
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeFs.ReadFileString("/index.json")).To(MatchJSON(`[
+					{"Key":{"Key":"key-2"},"Value":{"Name":"value-2","Count":2}},
+					{"Key":{"Key":"key-1"},"Value":{"Name":"value-1","Count":1}}
+				]`))
+			})
+
+			It("writes the entries once, when the outermost batch returns", func() {
+				err := fakeIndex.Batch(func() error {
+					return fakeIndex.Batch(func() error {
+						return fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})
+					})
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fakeFs.RenameOldPaths).To(HaveLen(1))
+			})
+
+			It("writes the saved entries & returns the error of the function when it fails", func() {
+				err := fakeIndex.Batch(func() error {
+					Expect(fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})).To(Succeed())
+					return errors.New("fake-batch-error")
+				})
+				Expect(err).To(MatchError("fake-batch-error"))
+
+				Expect(fakeFs.ReadFileString("/index.json")).To(MatchJSON(`[{"Key":{"Key":"key-1"},"Value":{"Name":"value-1","Count":1}}]`))
+			})
+
+			It("returns an error when the index file cannot be written", func() {
+				fakeFs.RenameError = errors.New("fake-rename-error")
+
+				err := fakeIndex.Batch(func() error {
+					return fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})
+				})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-rename-error"))
+			})
+
+			It("does not write the index file when nothing was saved", func() {
+				Expect(fakeIndex.Batch(func() error { return nil })).To(Succeed())
+				Expect(fakeFs.FileExists("/index.json")).To(BeFalse())
+			})
+		})
+
+		It("leaves the index file untouched when the temporary file cannot be renamed", func() {
+			fakeFs.WriteFileString("/index.json", `[]`)
+			fakeFs.RenameError = errors.New("fake-rename-error")
+
+			err := fakeIndex.Save(Key{Key: "key-1"}, Value{Name: "value-1", Count: 1})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-rename-error"))
+
+			Expect(fakeFs.ReadFileString("/index.json")).To(Equal(`[]`))
+			Expect(fakeFs.FileExists("/index.json.tmp")).To(BeFalse())
+		})
+	})
 })
@@ -1,18 +1,42 @@
 package installation
 
 import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
 	biinstalljob "github.com/cloudfoundry/bosh-init/installation/job"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	biinstallpkg "github.com/cloudfoundry/bosh-init/installation/pkg"
 	biregistry "github.com/cloudfoundry/bosh-init/registry"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
 )
 
 type Installer interface {
 	InstallPackagesAndJobs(biinstallmanifest.Manifest, biui.Stage) (Installation, error)
-	Cleanup(Installation) error
+}
+
+// installedCpi is the record of the CPI job installed in the target, with the fingerprint
+// of the CPI release job, its packages & the cloud_provider properties it was rendered with
+type installedCpi struct {
+	Fingerprint string `json:"fingerprint"`
+	Job         string `json:"job"`
+}
+
+// cpiFingerprint is what the fingerprint of the installed CPI is computed from
+type cpiFingerprint struct {
+	Release    string            `json:"release"`
+	Deployment string            `json:"deployment"`
+	Jobs       map[string]string `json:"jobs"`
+	Packages   map[string]string `json:"packages"`
+	Properties biproperty.Map    `json:"properties"`
 }
 
 type installer struct {
@@ -24,6 +48,7 @@ type installer struct {
 	packageInstaller      biinstallpkg.Installer
 	jobInstaller          biinstalljob.Installer
 	registryServerManager biregistry.ServerManager
+	fs                    boshsys.FileSystem
 	logger                boshlog.Logger
 	logTag                string
 }
@@ -37,6 +62,7 @@ func NewInstaller(
 	packageInstaller biinstallpkg.Installer,
 	jobInstaller biinstalljob.Installer,
 	registryServerManager biregistry.ServerManager,
+	fs boshsys.FileSystem,
 	logger boshlog.Logger,
 ) Installer {
 	return &installer{
@@ -48,11 +74,14 @@ func NewInstaller(
 		packageInstaller:      packageInstaller,
 		jobInstaller:          jobInstaller,
 		registryServerManager: registryServerManager,
+		fs:                    fs,
 		logger:                logger,
 		logTag:                "installer",
 	}
 }
 
+// InstallPackagesAndJobs reuses the CPI job & packages installed in the target when they were installed with the same fingerprint.
+// Otherwise it cleans up the previously rendered CPI job before installing the packages & rendering the job again.
 func (i *installer) InstallPackagesAndJobs(manifest biinstallmanifest.Manifest, stage biui.Stage) (Installation, error) {
 	i.logger.Info(i.logTag, "Installing CPI deployment '%s'", manifest.Name)
 	i.logger.Debug(i.logTag, "Installing CPI deployment '%s' with manifest: %#v", manifest.Name, manifest)
@@ -62,6 +91,38 @@ func (i *installer) InstallPackagesAndJobs(manifest biinstallmanifest.Manifest,
 		return nil, bosherr.WrapError(err, "Resolving jobs from manifest")
 	}
 
+	fingerprint, err := i.fingerprint(manifest, jobs)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Fingerprinting CPI installation")
+	}
+
+	previousCpi, found, err := i.loadInstalledCpi()
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		previousJob := i.installedJob(previousCpi.Job)
+		if previousCpi.Fingerprint == fingerprint && i.isInstalled(previousJob, jobs) {
+			i.logger.Info(i.logTag, "Reusing CPI job '%s' installed with fingerprint '%s'", previousJob.Name, fingerprint)
+			err = stage.Perform(fmt.Sprintf("Installing job '%s'", previousJob.Name), func() error {
+				return biui.NewSkipStageError(bosherr.Errorf("Found CPI job installed with fingerprint '%s'", fingerprint), "Already installed")
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return NewInstallation(i.target, previousJob, manifest, i.registryServerManager), nil
+		}
+
+		err = stage.Perform("Cleaning up rendered CPI jobs", func() error {
+			return i.cleanup(previousJob)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	compiledPackages, err := i.packageCompiler.For(jobs, i.packagesPath, stage)
 	if err != nil {
 		return nil, err
@@ -75,12 +136,21 @@ func (i *installer) InstallPackagesAndJobs(manifest biinstallmanifest.Manifest,
 	}
 
 	renderedJobRefs, err := i.jobRenderer.RenderAndUploadFrom(manifest, jobs, stage)
+	if err != nil {
+		return nil, err
+	}
+
 	renderedCPIJob := renderedJobRefs[0]
 	installedJob, err := i.jobInstaller.Install(renderedCPIJob, stage)
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Installing job '%s' for CPI release", renderedCPIJob.Name)
 	}
 
+	err = i.saveInstalledCpi(installedCpi{Fingerprint: fingerprint, Job: installedJob.Name})
+	if err != nil {
+		return nil, err
+	}
+
 	return NewInstallation(
 		i.target,
 		installedJob,
@@ -89,10 +159,6 @@ func (i *installer) InstallPackagesAndJobs(manifest biinstallmanifest.Manifest,
 	), nil
 }
 
-func (i *installer) Cleanup(installation Installation) error {
-	return i.jobInstaller.Cleanup(installation.Job())
-}
-
 func (i *installer) install(compiledPackages []biinstallpkg.CompiledPackageRef) error {
 	for _, compiledPackageRef := range compiledPackages {
 		err := i.packageInstaller.Install(compiledPackageRef, i.packagesPath)
@@ -102,3 +168,113 @@ func (i *installer) install(compiledPackages []biinstallpkg.CompiledPackageRef)
 	}
 	return nil
 }
+
+func (i *installer) installedJob(name string) biinstalljob.InstalledJob {
+	return biinstalljob.InstalledJob{
+		Name: name,
+		Path: filepath.Join(i.target.JobsPath(), name),
+	}
+}
+
+// isInstalled returns whether the job & the packages of the jobs are still installed, as they may have been removed since
+func (i *installer) isInstalled(job biinstalljob.InstalledJob, jobs []bireljob.Job) bool {
+	if !i.fs.FileExists(job.Path) {
+		i.logger.Info(i.logTag, "Installed CPI job '%s' was removed", job.Path)
+		return false
+	}
+
+	packageFingerprints := map[string]string{}
+	for _, job := range jobs {
+		addPackageFingerprints(packageFingerprints, job.Packages)
+	}
+
+	for name := range packageFingerprints {
+		packagePath := filepath.Join(i.packagesPath, name)
+		if !i.fs.FileExists(packagePath) {
+			i.logger.Info(i.logTag, "Installed CPI package '%s' was removed", packagePath)
+			return false
+		}
+	}
+
+	return true
+}
+
+// cleanup forgets the fingerprint before removing the job, so that a job only partially removed is never reused
+func (i *installer) cleanup(job biinstalljob.InstalledJob) error {
+	err := i.fs.RemoveAll(i.target.InstalledCpiPath())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing installed CPI record '%s'", i.target.InstalledCpiPath())
+	}
+
+	return i.jobInstaller.Cleanup(job)
+}
+
+func (i *installer) loadInstalledCpi() (installedCpi, bool, error) {
+	record := installedCpi{}
+
+	path := i.target.InstalledCpiPath()
+	if !i.fs.FileExists(path) {
+		return record, false, nil
+	}
+
+	contents, err := i.fs.ReadFile(path)
+	if err != nil {
+		return record, false, bosherr.WrapErrorf(err, "Reading installed CPI record '%s'", path)
+	}
+
+	err = json.Unmarshal(contents, &record)
+	if err != nil {
+		// a broken record only means that the CPI job gets installed again
+		i.logger.Warn(i.logTag, "Unmarshalling installed CPI record '%s': %s", path, err.Error())
+		return installedCpi{}, true, nil
+	}
+
+	return record, true, nil
+}
+
+func (i *installer) saveInstalledCpi(record installedCpi) error {
+	contents, err := json.Marshal(record)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling installed CPI record")
+	}
+
+	path := i.target.InstalledCpiPath()
+	err = i.fs.WriteFile(path, contents)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing installed CPI record '%s'", path)
+	}
+
+	return nil
+}
+
+func (i *installer) fingerprint(manifest biinstallmanifest.Manifest, jobs []bireljob.Job) (string, error) {
+	input := cpiFingerprint{
+		Release:    manifest.Template.Release,
+		Deployment: manifest.Name,
+		Jobs:       map[string]string{},
+		Packages:   map[string]string{},
+		Properties: manifest.Properties,
+	}
+
+	for _, job := range jobs {
+		input.Jobs[job.Name] = job.Fingerprint
+		addPackageFingerprints(input.Packages, job.Packages)
+	}
+
+	contents, err := json.Marshal(input)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling CPI fingerprint")
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum(contents)), nil
+}
+
+func addPackageFingerprints(fingerprints map[string]string, packages []*birelpkg.Package) {
+	for _, pkg := range packages {
+		if _, found := fingerprints[pkg.Name]; found {
+			continue
+		}
+		fingerprints[pkg.Name] = pkg.Fingerprint
+		addPackageFingerprints(fingerprints, pkg.Dependencies)
+	}
+}
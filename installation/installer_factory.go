@@ -88,6 +88,7 @@ func (f *installerFactory) NewInstaller() (Installer, error) {
 		context.PackageInstaller(),
 		context.JobInstaller(),
 		f.registryServerManager,
+		f.fs,
 		f.logger,
 	), nil
 }
@@ -141,8 +142,11 @@ func (c *installerFactoryContext) JobDependencyCompiler() bistatejob.DependencyC
 		return c.jobDependencyCompiler
 	}
 
+	// installation packages are compiled for the local machine, so pre-compiled packages are never imported
 	c.jobDependencyCompiler = bistatejob.NewDependencyCompiler(
 		c.InstallationStatePackageCompiler(),
+		nil,
+		c.CompiledPackageRepo(),
 		c.logger,
 	)
 
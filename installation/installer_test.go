@@ -16,10 +16,14 @@ import (
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	biinstallpkg "github.com/cloudfoundry/bosh-init/installation/pkg"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
 	"errors"
 	fakebiui "github.com/cloudfoundry/bosh-init/ui/fakes"
+	"os"
+	"path/filepath"
 )
 
 var _ = Describe("Installer", func() {
@@ -43,6 +47,7 @@ var _ = Describe("Installer", func() {
 		mockRegistryServerManager *mock_registry.MockServerManager
 
 		logger boshlog.Logger
+		fs     *fakesys.FakeFileSystem
 
 		packagesPath string
 		installer    Installer
@@ -51,6 +56,7 @@ var _ = Describe("Installer", func() {
 
 	BeforeEach(func() {
 		logger = boshlog.NewLogger(boshlog.LevelNone)
+		fs = fakesys.NewFakeFileSystem()
 
 		mockJobRenderer = mock_install.NewMockJobRenderer(mockCtrl)
 		mockJobResolver = mock_install.NewMockJobResolver(mockCtrl)
@@ -74,6 +80,7 @@ var _ = Describe("Installer", func() {
 			mockPackageInstaller,
 			mockJobInstaller,
 			mockRegistryServerManager,
+			fs,
 			logger,
 		)
 	})
@@ -102,7 +109,7 @@ var _ = Describe("Installer", func() {
 
 			installedJob = biinstalljob.InstalledJob{
 				Name: "cpi",
-				Path: "/extracted-release-path/cpi",
+				Path: "fake-installation-path/jobs/cpi",
 			}
 		})
 
@@ -122,7 +129,15 @@ var _ = Describe("Installer", func() {
 			}
 			compiledPackages := []biinstallpkg.CompiledPackageRef{compiledPackageRef}
 
-			releaseJobs := []bireljob.Job{}
+			releaseJobs := []bireljob.Job{
+				{
+					Name:        "cpi",
+					Fingerprint: "fake-release-job-fingerprint",
+					Packages: []*birelpkg.Package{
+						{Name: "fake-release-package-name", Fingerprint: "fake-release-package-fingerprint"},
+					},
+				},
+			}
 			renderedJobRefs := []biinstalljob.RenderedJobRef{renderedCPIJob}
 			expectedResolveJobsFrom = mockJobResolver.EXPECT().From(installationManifest).Return(releaseJobs, nil).AnyTimes()
 			expectedPackageCompilerFrom = mockPackageCompiler.EXPECT().For(releaseJobs, packagesPath, fakeStage).Return(compiledPackages, nil).AnyTimes()
@@ -133,7 +148,15 @@ var _ = Describe("Installer", func() {
 			expectJobInstall = mockJobInstaller.EXPECT().Install(renderedCPIJob, fakeStage).Return(installedJob, nil).AnyTimes()
 		})
 
+		It("resolves the jobs of the installation", func() {
+			expectedResolveJobsFrom.Times(1)
+
+			_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("compiles and installs the jobs' packages", func() {
+			expectedPackageCompilerFrom.Times(1)
 			expectPackageInstall.Times(1)
 
 			_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
@@ -160,30 +183,96 @@ var _ = Describe("Installer", func() {
 
 			Expect(installation).To(Equal(expectedInstallation))
 		})
-	})
-
-	Describe("Cleanup", func() {
-		It("cleans up installed jobs", func() {
-			installation := mock_install.NewMockInstallation(mockCtrl)
-			installationJob := biinstalljob.InstalledJob{}
-			installation.EXPECT().Job().Return(installationJob)
-
-			mockJobInstaller.EXPECT().Cleanup(installationJob)
+		It("records the fingerprint of the installed CPI job", func() {
+			_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+			Expect(err).NotTo(HaveOccurred())
 
-			err := installer.Cleanup(installation)
-			Expect(err).ToNot(HaveOccurred())
+			Expect(fs.FileExists("fake-installation-path/installed_cpi.json")).To(BeTrue())
 		})
 
-		It("returns errors when cleaning up installed jobs", func() {
-			installation := mock_install.NewMockInstallation(mockCtrl)
-			installationJob := biinstalljob.InstalledJob{}
-			installation.EXPECT().Job().Return(installationJob)
-
-			mockJobInstaller.EXPECT().Cleanup(installationJob).Return(errors.New("nope"))
-
-			err := installer.Cleanup(installation)
-			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("nope"))
+		Context("when the CPI job was installed with the same fingerprint", func() {
+			JustBeforeEach(func() {
+				_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fs.MkdirAll(installedJob.Path, os.ModePerm)).To(Succeed())
+				Expect(fs.MkdirAll(filepath.Join(packagesPath, "fake-release-package-name"), os.ModePerm)).To(Succeed())
+				fakeStage.PerformCalls = nil
+			})
+
+			It("reuses the installed CPI job without installing it again", func() {
+				expectedPackageCompilerFrom.Times(1)
+				expectPackageInstall.Times(1)
+				expectJobInstall.Times(1)
+				expectedRenderAndUploadFrom.Times(1)
+
+				installation, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(installation.Job()).To(Equal(installedJob))
+
+				Expect(fakeStage.PerformCalls).To(HaveLen(1))
+				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Installing job 'cpi'"))
+				Expect(fakeStage.PerformCalls[0].SkipError).To(HaveOccurred())
+			})
+
+			It("installs the CPI job again when it was removed", func() {
+				expectJobInstall.Times(2)
+				mockJobInstaller.EXPECT().Cleanup(installedJob)
+
+				Expect(fs.RemoveAll(installedJob.Path)).To(Succeed())
+
+				_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("installs the packages & the CPI job again when one of its packages was removed", func() {
+				expectPackageInstall.Times(2)
+				expectJobInstall.Times(2)
+				mockJobInstaller.EXPECT().Cleanup(installedJob)
+
+				Expect(fs.RemoveAll(filepath.Join(packagesPath, "fake-release-package-name"))).To(Succeed())
+
+				_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("cleans up the rendered CPI job & installs it again when the cloud_provider properties changed", func() {
+				changedManifest := biinstallmanifest.Manifest{
+					Name:       "fake-installation-name",
+					Properties: biproperty.Map{"fake-property": "fake-value"},
+				}
+				mockJobResolver.EXPECT().From(changedManifest).Return([]bireljob.Job{
+					{
+						Name:        "cpi",
+						Fingerprint: "fake-release-job-fingerprint",
+						Packages: []*birelpkg.Package{
+							{Name: "fake-release-package-name", Fingerprint: "fake-release-package-fingerprint"},
+						},
+					},
+				}, nil)
+				mockJobRenderer.EXPECT().RenderAndUploadFrom(changedManifest, gomock.Any(), fakeStage).Return([]biinstalljob.RenderedJobRef{
+					{Name: "cpi", Version: "fake-release-job-fingerprint"},
+				}, nil)
+				mockJobInstaller.EXPECT().Install(gomock.Any(), fakeStage).Return(installedJob, nil)
+
+				mockJobInstaller.EXPECT().Cleanup(installedJob).Return(nil)
+
+				_, err := installer.InstallPackagesAndJobs(changedManifest, fakeStage)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeStage.PerformCalls[0].Name).To(Equal("Cleaning up rendered CPI jobs"))
+			})
+
+			It("returns an error when cleaning up the rendered CPI job fails", func() {
+				mockJobInstaller.EXPECT().Cleanup(installedJob).Return(errors.New("fake-cleanup-error"))
+
+				Expect(fs.RemoveAll(installedJob.Path)).To(Succeed())
+
+				_, err := installer.InstallPackagesAndJobs(installationManifest, fakeStage)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("fake-cleanup-error"))
+				Expect(fs.FileExists("fake-installation-path/installed_cpi.json")).To(BeFalse())
+			})
 		})
 	})
 })
@@ -65,7 +65,7 @@ func (b *jobRenderer) renderJobTemplates(
 ) ([]biinstalljob.RenderedJobRef, error) {
 	renderedJobRefs := make([]biinstalljob.RenderedJobRef, 0, len(releaseJobs))
 	err := stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+		renderedJobList, err := b.jobListRenderer.Render(releaseJobs, map[string]bitemplate.LinkOverrides{}, jobProperties, globalProperties, deploymentName, "")
 		if err != nil {
 			return err
 		}
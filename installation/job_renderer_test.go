@@ -17,6 +17,7 @@ import (
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bitemplate "github.com/cloudfoundry/bosh-init/templatescompiler"
 	fakeboshblob "github.com/cloudfoundry/bosh-utils/blobstore/fakes"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	fakeboshcmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakeboshsys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -136,7 +137,7 @@ var _ = Describe("JobRenderer", func() {
 		renderedJobList = bitemplate.NewRenderedJobList()
 		renderedJobList.Add(bitemplate.NewRenderedJob(releaseJob, "/fake-rendered-job-cpi", fakeFS, logger))
 
-		expectJobRender = mockJobListRenderer.EXPECT().Render(releaseJobs, jobProperties, globalProperties, deploymentName).Return(renderedJobList, nil).AnyTimes()
+		expectJobRender = mockJobListRenderer.EXPECT().Render(releaseJobs, map[string]bitemplate.LinkOverrides{}, jobProperties, globalProperties, deploymentName, "").Return(renderedJobList, nil).AnyTimes()
 
 		fakeCompressor.CompressFilesInDirTarballPath = "/fake-rendered-job-tarball-cpi.tgz"
 
@@ -145,6 +146,21 @@ var _ = Describe("JobRenderer", func() {
 	})
 
 	Describe("RenderAndUploadFrom", func() {
+		It("renders the jobs with the installation properties only", func() {
+			expectJobRender.Times(1)
+
+			_, err := renderer.RenderAndUploadFrom(manifest, releaseJobs, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns an error when rendering the jobs fails", func() {
+			expectJobRender.Return(nil, bosherr.Error("fake-render-error"))
+
+			_, err := renderer.RenderAndUploadFrom(manifest, releaseJobs, fakeStage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-render-error"))
+		})
+
 		It("logs compile & render stages", func() {
 			_, err := renderer.RenderAndUploadFrom(manifest, releaseJobs, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
@@ -80,9 +80,7 @@ var _ = Describe("JobResolver", func() {
 
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(jobs).To(Equal([]bireljob.Job{
-				releaseJob,
-			}))
+			Expect(jobs).To(Equal(releaseJobs))
 		})
 
 		It("when the release does not contain a 'cpi' job returns an error", func() {
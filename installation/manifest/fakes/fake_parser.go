@@ -1,12 +1,16 @@
 package fakes
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biinstallmanifest "github.com/cloudfoundry/bosh-init/installation/manifest"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 )
 
 type FakeParser struct {
 	ParsePath          string
+	ParseVariables     bivars.Variables
+	ParseOps           bipatch.Ops
 	ReleaseSetManifest birelsetmanifest.Manifest
 	ParseManifest      biinstallmanifest.Manifest
 	ParseErr           error
@@ -16,8 +20,10 @@ func NewFakeParser() *FakeParser {
 	return &FakeParser{}
 }
 
-func (p *FakeParser) Parse(path string, releaseSetManifest birelsetmanifest.Manifest) (biinstallmanifest.Manifest, error) {
+func (p *FakeParser) Parse(path string, variables bivars.Variables, ops bipatch.Ops, releaseSetManifest birelsetmanifest.Manifest) (biinstallmanifest.Manifest, error) {
 	p.ParsePath = path
+	p.ParseVariables = variables
+	p.ParseOps = ops
 	p.ReleaseSetManifest = releaseSetManifest
 	return p.ParseManifest, p.ParseErr
 }
@@ -1,7 +1,9 @@
 package manifest
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -11,7 +13,7 @@ import (
 )
 
 type Parser interface {
-	Parse(path string, releaseSetManifest birelsetmanifest.Manifest) (Manifest, error)
+	Parse(path string, variables bivars.Variables, ops bipatch.Ops, releaseSetManifest birelsetmanifest.Manifest) (Manifest, error)
 }
 
 type parser struct {
@@ -53,12 +55,22 @@ func NewParser(fs boshsys.FileSystem, uuidGenerator boshuuid.Generator, logger b
 	}
 }
 
-func (p *parser) Parse(path string, releaseSetManifest birelsetmanifest.Manifest) (Manifest, error) {
+func (p *parser) Parse(path string, variables bivars.Variables, ops bipatch.Ops, releaseSetManifest birelsetmanifest.Manifest) (Manifest, error) {
 	contents, err := p.fs.ReadFile(path)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Reading file %s", path)
 	}
 
+	contents, err = ops.Apply(contents)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Applying ops to %s", path)
+	}
+
+	contents, err = bivars.Interpolate(contents, variables)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Interpolating variables in %s", path)
+	}
+
 	comboManifest := manifest{}
 	err = yaml.Unmarshal(contents, &comboManifest)
 	if err != nil {
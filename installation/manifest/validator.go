@@ -3,6 +3,7 @@ package manifest
 import (
 	"strings"
 
+	bivalidation "github.com/cloudfoundry/bosh-init/common/validation"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -27,17 +28,17 @@ func (v *validator) Validate(manifest Manifest, releaseSetManifest birelsetmanif
 
 	cpiJobName := manifest.Template.Name
 	if v.isBlank(cpiJobName) {
-		errs = append(errs, bosherr.Error("cloud_provider.template.name must be provided"))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("cloud_provider", "template", "name"), "cloud_provider.template.name must be provided"))
 	}
 
 	cpiReleaseName := manifest.Template.Release
 	if v.isBlank(cpiReleaseName) {
-		errs = append(errs, bosherr.Error("cloud_provider.template.release must be provided"))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("cloud_provider", "template", "release"), "cloud_provider.template.release must be provided"))
 	}
 
 	_, found := releaseSetManifest.FindByName(cpiReleaseName)
 	if !found {
-		errs = append(errs, bosherr.Errorf("cloud_provider.template.release '%s' must refer to a release in releases", cpiReleaseName))
+		errs = append(errs, bivalidation.Errorf(bivalidation.Path("cloud_provider", "template", "release"), "cloud_provider.template.release '%s' must refer to a release in releases", cpiReleaseName))
 	}
 
 	if len(errs) > 0 {
@@ -4,6 +4,7 @@ import (
 	biinstallpkg "github.com/cloudfoundry/bosh-init/installation/pkg"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
 	biui "github.com/cloudfoundry/bosh-init/ui"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshsys "github.com/cloudfoundry/bosh-utils/system"
@@ -36,7 +37,8 @@ func (b *packageCompiler) For(jobs []bireljob.Job, packagesPath string, stage bi
 		return nil, bosherr.WrapErrorf(err, "Creating packages directory '%s'", packagesPath)
 	}
 
-	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(jobs, stage)
+	// packages are compiled for the local machine, so stemcell-specific compiled packages are never used
+	compiledPackageRefs, err := b.jobDependencyCompiler.Compile(jobs, bistatepkg.Stemcell{}, stage)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Compiling job package dependencies for installation")
 	}
@@ -14,6 +14,7 @@ import (
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistatejob "github.com/cloudfoundry/bosh-init/state/job"
+	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	fakeboshsys "github.com/cloudfoundry/bosh-utils/system/fakes"
 
@@ -107,7 +108,7 @@ var _ = Describe("PackageCompiler", func() {
 				SHA1:        "fake-compiled-package-sha1-2",
 			},
 		}
-		expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
+		expectCompile = mockDependencyCompiler.EXPECT().Compile(releaseJobs, bistatepkg.Stemcell{}, fakeStage).Return(compiledPackageRefs, nil).AnyTimes()
 	})
 
 	Describe("From", func() {
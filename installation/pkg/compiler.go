@@ -48,9 +48,9 @@ func NewPackageCompiler(
 	}
 }
 
-func (c *compiler) Compile(pkg *birelpkg.Package) (record bistatepkg.CompiledPackageRecord, err error) {
+func (c *compiler) Compile(pkg *birelpkg.Package, stemcell bistatepkg.Stemcell) (record bistatepkg.CompiledPackageRecord, err error) {
 	c.logger.Debug(c.logTag, "Checking for compiled package '%s/%s'", pkg.Name, pkg.Fingerprint)
-	record, found, err := c.compiledPackageRepo.Find(*pkg)
+	record, found, err := c.compiledPackageRepo.Find(*pkg, stemcell)
 	if err != nil {
 		return record, bosherr.WrapErrorf(err, "Attempting to find compiled package '%s'", pkg.Name)
 	}
@@ -59,7 +59,7 @@ func (c *compiler) Compile(pkg *birelpkg.Package) (record bistatepkg.CompiledPac
 	}
 
 	c.logger.Debug(c.logTag, "Installing dependencies of package '%s/%s'", pkg.Name, pkg.Fingerprint)
-	err = c.installPackages(pkg.Dependencies)
+	err = c.installPackages(pkg.Dependencies, stemcell)
 	if err != nil {
 		return record, bosherr.WrapErrorf(err, "Installing dependencies of package '%s'", pkg.Name)
 	}
@@ -111,7 +111,7 @@ func (c *compiler) Compile(pkg *birelpkg.Package) (record bistatepkg.CompiledPac
 		BlobID:   blobID,
 		BlobSHA1: blobSHA1,
 	}
-	err = c.compiledPackageRepo.Save(*pkg, record)
+	err = c.compiledPackageRepo.Save(*pkg, stemcell, record)
 	if err != nil {
 		return record, bosherr.WrapError(err, "Saving compiled package")
 	}
@@ -119,10 +119,10 @@ func (c *compiler) Compile(pkg *birelpkg.Package) (record bistatepkg.CompiledPac
 	return record, nil
 }
 
-func (c *compiler) installPackages(packages []*birelpkg.Package) error {
+func (c *compiler) installPackages(packages []*birelpkg.Package, stemcell bistatepkg.Stemcell) error {
 	for _, pkg := range packages {
 		c.logger.Debug(c.logTag, "Checking for compiled package '%s/%s'", pkg.Name, pkg.Fingerprint)
-		record, found, err := c.compiledPackageRepo.Find(*pkg)
+		record, found, err := c.compiledPackageRepo.Find(*pkg, stemcell)
 		if err != nil {
 			return bosherr.WrapErrorf(err, "Attempting to find compiled package '%s'", pkg.Name)
 		}
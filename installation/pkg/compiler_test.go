@@ -109,13 +109,13 @@ var _ = Describe("PackageCompiler", func() {
 		})
 
 		JustBeforeEach(func() {
-			expectFind = mockCompiledPackageRepo.EXPECT().Find(*pkg).Return(bistatepkg.CompiledPackageRecord{}, false, nil).AnyTimes()
+			expectFind = mockCompiledPackageRepo.EXPECT().Find(*pkg, bistatepkg.Stemcell{}).Return(bistatepkg.CompiledPackageRecord{}, false, nil).AnyTimes()
 
 			compiledDependency1 := bistatepkg.CompiledPackageRecord{
 				BlobID:   "fake-dependency-blobstore-id-1",
 				BlobSHA1: "fake-dependency-sha1-1",
 			}
-			mockCompiledPackageRepo.EXPECT().Find(*dependency1).Return(compiledDependency1, true, nil).AnyTimes()
+			mockCompiledPackageRepo.EXPECT().Find(*dependency1, bistatepkg.Stemcell{}).Return(compiledDependency1, true, nil).AnyTimes()
 
 			compiledPackageRef1 := CompiledPackageRef{
 				Name:        "fake-package-name-dependency-1",
@@ -129,7 +129,7 @@ var _ = Describe("PackageCompiler", func() {
 				BlobID:   "fake-dependency-blobstore-id-2",
 				BlobSHA1: "fake-dependency-sha1-2",
 			}
-			mockCompiledPackageRepo.EXPECT().Find(*dependency2).Return(compiledDependency2, true, nil).AnyTimes()
+			mockCompiledPackageRepo.EXPECT().Find(*dependency2, bistatepkg.Stemcell{}).Return(compiledDependency2, true, nil).AnyTimes()
 
 			compiledPackageRef2 := CompiledPackageRef{
 				Name:        "fake-package-name-dependency-2",
@@ -148,7 +148,7 @@ var _ = Describe("PackageCompiler", func() {
 				BlobID:   "fake-blob-id",
 				BlobSHA1: "fake-fingerprint",
 			}
-			expectSave = mockCompiledPackageRepo.EXPECT().Save(*pkg, record).AnyTimes()
+			expectSave = mockCompiledPackageRepo.EXPECT().Save(*pkg, bistatepkg.Stemcell{}, record).AnyTimes()
 		})
 
 		Context("when the compiled package repo already has the package", func() {
@@ -160,7 +160,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("skips the compilation", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(len(runner.RunComplexCommands)).To(Equal(0))
@@ -171,12 +171,12 @@ var _ = Describe("PackageCompiler", func() {
 			expectPackageInstall1.Times(1)
 			expectPackageInstall2.Times(1)
 
-			_, err := compiler.Compile(pkg)
+			_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("runs the packaging script in package extractedPath dir", func() {
-			_, err := compiler.Compile(pkg)
+			_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 
 			expectedCmd := boshsys.Command{
@@ -198,7 +198,7 @@ var _ = Describe("PackageCompiler", func() {
 		})
 
 		It("compresses the compiled package", func() {
-			_, err := compiler.Compile(pkg)
+			_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(compressor.CompressFilesInDirDir).To(Equal(installPath))
@@ -206,7 +206,7 @@ var _ = Describe("PackageCompiler", func() {
 		})
 
 		It("moves the compressed package to a blobstore", func() {
-			_, err := compiler.Compile(pkg)
+			_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(blobstore.CreateFileNames).To(Equal([]string{compiledPackageTarballPath}))
@@ -215,12 +215,12 @@ var _ = Describe("PackageCompiler", func() {
 		It("stores the compiled package blobID and fingerprint into the compile package repo", func() {
 			expectSave.Times(1)
 
-			_, err := compiler.Compile(pkg)
+			_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 		})
 
 		It("returns the repo record", func() {
-			record, err := compiler.Compile(pkg)
+			record, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(record).To(Equal(bistatepkg.CompiledPackageRecord{
@@ -230,7 +230,7 @@ var _ = Describe("PackageCompiler", func() {
 		})
 
 		It("cleans up the packages dir", func() {
-			_, err := compiler.Compile(pkg)
+			_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fs.FileExists(packagesDir)).To(BeFalse())
@@ -242,7 +242,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-install-error"))
 			})
@@ -255,7 +255,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Packaging script for package 'fake-package-1' not found"))
 			})
@@ -271,7 +271,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Compiling package"))
 				Expect(err.Error()).To(ContainSubstring("fake-error"))
@@ -284,7 +284,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Compressing compiled package"))
 			})
@@ -296,7 +296,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Creating blob"))
 				Expect(err.Error()).To(ContainSubstring("fake-error"))
@@ -309,7 +309,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Saving compiled package"))
 				Expect(err.Error()).To(ContainSubstring("fake-error"))
@@ -322,7 +322,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Creating package install dir"))
 				Expect(err.Error()).To(ContainSubstring("fake-error"))
@@ -335,7 +335,7 @@ var _ = Describe("PackageCompiler", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := compiler.Compile(pkg)
+				_, err := compiler.Compile(pkg, bistatepkg.Stemcell{})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("Attempting to find compiled package '%s'", pkg.Name)))
 				Expect(err.Error()).To(ContainSubstring("fake-error"))
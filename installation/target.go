@@ -37,3 +37,8 @@ func (t Target) PackagesPath() string {
 func (t Target) JobsPath() string {
 	return filepath.Join(t.path, "jobs")
 }
+
+// InstalledCpiPath is the record of the fingerprint of the installed CPI job, used to reuse it when it is unchanged
+func (t Target) InstalledCpiPath() string {
+	return filepath.Join(t.path, "installed_cpi.json")
+}
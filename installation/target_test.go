@@ -28,5 +28,9 @@ var _ = Describe("Target", func() {
 		It("returns the packages path", func() {
 			Expect(target.PackagesPath()).To(Equal("/home/fake/madcow/packages"))
 		})
+
+		It("returns the installed CPI path", func() {
+			Expect(target.InstalledCpiPath()).To(Equal("/home/fake/madcow/installed_cpi.json"))
+		})
 	})
 })
@@ -24,9 +24,12 @@ import (
 	mock_instance_state "github.com/cloudfoundry/bosh-init/deployment/instance/state/mocks"
 	mock_install "github.com/cloudfoundry/bosh-init/installation/mocks"
 	mock_release "github.com/cloudfoundry/bosh-init/release/mocks"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
 
 	bicloud "github.com/cloudfoundry/bosh-init/cloud"
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
 	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	biconfig "github.com/cloudfoundry/bosh-init/config"
 	bicpirel "github.com/cloudfoundry/bosh-init/cpi/release"
 	bidepl "github.com/cloudfoundry/bosh-init/deployment"
@@ -215,10 +218,6 @@ cloud_provider:
 				SSHTunnelPrivateKey: sshConfig.PrivateKey,
 			}
 			updateManifest(context)
-
-			fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-				deploymentManifestPath: {Sha1: "fake-deployment-sha1-1"},
-			})
 		}
 
 		var writeDeploymentManifestWithLargerDisk = func() {
@@ -228,10 +227,6 @@ cloud_provider:
 				SSHTunnelPrivateKey: sshConfig.PrivateKey,
 			}
 			updateManifest(context)
-
-			fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
-				deploymentManifestPath: {Sha1: "fake-deployment-sha1-2"},
-			})
 		}
 
 		var writeCPIReleaseTarball = func() {
@@ -312,7 +307,6 @@ cloud_provider:
 			mockInstaller.EXPECT().InstallPackagesAndJobs(installationManifest, gomock.Any()).Do(func(_ interface{}, stage biui.Stage) {
 				Expect(fakeStage.SubStages).To(ContainElement(stage))
 			}).Return(installation, nil).AnyTimes()
-			mockInstaller.EXPECT().Cleanup(installation).AnyTimes()
 			mockCloudFactory.EXPECT().NewCloud(installation, directorID).Return(mockCloud, nil).AnyTimes()
 		}
 
@@ -370,8 +364,9 @@ cloud_provider:
 			//TODO: use a real state builder
 
 			mockStateBuilderFactory.EXPECT().NewBuilder(mockBlobstore, mockAgentClient).Return(mockStateBuilder).AnyTimes()
-			mockStateBuilder.EXPECT().Build(jobName, jobIndex, gomock.Any(), gomock.Any()).Return(mockState, nil).AnyTimes()
+			mockStateBuilder.EXPECT().Build(jobName, jobIndex, gomock.Any(), gomock.Any(), gomock.Any()).Return(mockState, nil).AnyTimes()
 			mockState.EXPECT().ToApplySpec().Return(applySpec).AnyTimes()
+			mockState.EXPECT().RenderedJobListDiff().Return("").AnyTimes()
 		}
 
 		var newDeployCmd = func() Cmd {
@@ -385,7 +380,10 @@ cloud_provider:
 
 			deploymentValidator := bideplmanifest.NewValidator(logger)
 
-			instanceFactory := biinstance.NewFactory(mockStateBuilderFactory)
+			mockRenderedJobListArchiveRepo := mock_template.NewMockRenderedJobListArchiveRepo(mockCtrl)
+			mockRenderedJobListArchiveRepo.EXPECT().CommitPending().AnyTimes()
+
+			instanceFactory := biinstance.NewFactory(mockStateBuilderFactory, mockRenderedJobListArchiveRepo, &fakebiui.FakeUI{})
 			instanceManagerFactory := biinstance.NewManagerFactory(sshTunnelFactory, instanceFactory, logger)
 
 			pingTimeout := 1 * time.Second
@@ -393,9 +391,9 @@ cloud_provider:
 			deploymentFactory := bidepl.NewFactory(pingTimeout, pingDelay)
 
 			ui := biui.NewWriterUI(stdOut, stdErr, logger)
-			doGet := func(deploymentManifestPath string) (DeploymentPreparer, error) {
+			doGet := func(deploymentManifestPath string, deploymentStatePath string, forceUnlock bool, deploymentVars bivars.Variables, deploymentOps bipatch.Ops) (DeploymentPreparer, error) {
 				// todo: figure this out?
-				deploymentStateService = biconfig.NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, biconfig.DeploymentStatePath(deploymentManifestPath))
+				deploymentStateService = biconfig.NewFileSystemDeploymentStateService(fs, fakeUUIDGenerator, logger, deploymentStatePath)
 				vmRepo = biconfig.NewVMRepo(deploymentStateService)
 				diskRepo = biconfig.NewDiskRepo(deploymentStateService, fakeRepoUUIDGenerator)
 				stemcellRepo = biconfig.NewStemcellRepo(deploymentStateService, fakeRepoUUIDGenerator)
@@ -403,7 +401,7 @@ cloud_provider:
 				releaseRepo = biconfig.NewReleaseRepo(deploymentStateService, fakeRepoUUIDGenerator)
 
 				legacyDeploymentStateMigrator = biconfig.NewLegacyDeploymentStateMigrator(deploymentStateService, fs, fakeUUIDGenerator, logger)
-				deploymentRecord := bidepl.NewRecord(deploymentRepo, releaseRepo, stemcellRepo, fakeSHA1Calculator)
+				deploymentRecord := bidepl.NewRecord(deploymentRepo, releaseRepo, stemcellRepo, fs)
 				stemcellManagerFactory = bistemcell.NewManagerFactory(stemcellRepo)
 				diskManagerFactory = bidisk.NewManagerFactory(diskRepo, logger)
 				diskDeployer = bivm.NewDiskDeployer(diskManagerFactory, diskRepo, logger)
@@ -437,6 +435,7 @@ cloud_provider:
 					DeploymentParser:    deploymentParser,
 					DeploymentValidator: deploymentValidator,
 					ReleaseManager:      releaseManager,
+					UI:                  ui,
 				}
 
 				return NewDeploymentPreparer(
@@ -444,6 +443,7 @@ cloud_provider:
 					logger,
 					"deployCmd",
 					deploymentStateService,
+					forceUnlock,
 					legacyDeploymentStateMigrator,
 					releaseManager,
 					deploymentRecord,
@@ -454,6 +454,8 @@ cloud_provider:
 					mockBlobstoreFactory,
 					deployer,
 					deploymentManifestPath,
+					deploymentVars,
+					deploymentOps,
 					cpiInstaller,
 					releaseFetcher,
 					stemcellFetcher,
@@ -814,6 +816,17 @@ cloud_provider:
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("keeps the installed CPI for the next deploy to reuse", func() {
+			expectDeployFlow()
+
+			err := newDeployCmd().Run(fakeStage, []string{deploymentManifestPath})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeStage.PerformCalls).ToNot(ContainElement(&fakebiui.PerformCall{
+				Name: "Cleaning up rendered CPI jobs",
+			}))
+		})
+
 		Context("when multiple releases are provided", func() {
 			var (
 				otherReleaseTarballPath = "/fake-other-release.tgz"
@@ -35,6 +35,7 @@ func main() {
 		logger,
 		boshuuid.NewGenerator(),
 		workspaceRootPath,
+		os.Getenv("BOSH_INIT_COMPILED_PACKAGE_CACHE") == "true",
 	)
 
 	cmdRunner := bicmd.NewRunner(cmdFactory)
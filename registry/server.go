@@ -1,10 +1,10 @@
 package registry
 
 import (
-	"fmt"
 	"net"
 	"net/http"
 
+	binet "github.com/cloudfoundry/bosh-init/common/net"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 )
@@ -66,9 +66,10 @@ func NewServer(logger boshlog.Logger) Server {
 }
 
 func (s *server) start(username string, password string, host string, port int, readyErrCh chan error) error {
-	s.logger.Debug(s.logTag, "Starting registry server at %s:%d", host, port)
+	listenAddr := binet.JoinHostPort(host, port)
+	s.logger.Debug(s.logTag, "Starting registry server at %s", listenAddr)
 	var err error
-	s.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
+	s.listener, err = net.Listen("tcp", listenAddr)
 	if err != nil {
 		readyErrCh <- bosherr.WrapError(err, "Starting registry listener")
 		return nil
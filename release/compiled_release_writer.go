@@ -0,0 +1,141 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// CompiledPackage is a local copy of a package archive compiled against a specific stemcell
+type CompiledPackage struct {
+	Package     *birelpkg.Package
+	ArchivePath string
+	SHA1        string
+}
+
+type CompiledReleaseWriter interface {
+	// Write creates a compiled release tarball at destinationPath
+	// from the jobs of the release and the specified compiled packages.
+	Write(release Release, stemcell string, compiledPackages []CompiledPackage, destinationPath string) error
+}
+
+type compiledReleaseWriter struct {
+	fs         boshsys.FileSystem
+	compressor boshcmd.Compressor
+	logger     boshlog.Logger
+	logTag     string
+}
+
+func NewCompiledReleaseWriter(
+	fs boshsys.FileSystem,
+	compressor boshcmd.Compressor,
+	logger boshlog.Logger,
+) CompiledReleaseWriter {
+	return &compiledReleaseWriter{
+		fs:         fs,
+		compressor: compressor,
+		logger:     logger,
+		logTag:     "compiledReleaseWriter",
+	}
+}
+
+func (w *compiledReleaseWriter) Write(release Release, stemcell string, compiledPackages []CompiledPackage, destinationPath string) error {
+	releaseDir, err := w.fs.TempDir("bosh-init-compiled-release")
+	if err != nil {
+		return bosherr.WrapError(err, "Creating compiled release dir")
+	}
+	defer func() {
+		if err := w.fs.RemoveAll(releaseDir); err != nil {
+			w.logger.Warn(w.logTag, "Failed to delete compiled release dir '%s': %s", releaseDir, err.Error())
+		}
+	}()
+
+	manifest := birelmanifest.Manifest{
+		Name:             release.Name(),
+		Version:          release.Version(),
+		Jobs:             []birelmanifest.JobRef{},
+		Packages:         []birelmanifest.PackageRef{},
+		CompiledPackages: []birelmanifest.CompiledPackageRef{},
+	}
+
+	for _, job := range release.Jobs() {
+		err = w.copyArchive(job.ArchivePath, filepath.Join(releaseDir, "jobs", job.Name+".tgz"))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Adding job '%s'", job.Name)
+		}
+
+		manifest.Jobs = append(manifest.Jobs, birelmanifest.JobRef{
+			Name:        job.Name,
+			Version:     job.Fingerprint,
+			Fingerprint: job.Fingerprint,
+			SHA1:        job.SHA1,
+		})
+	}
+
+	for _, compiledPackage := range compiledPackages {
+		pkg := compiledPackage.Package
+		err = w.copyArchive(compiledPackage.ArchivePath, filepath.Join(releaseDir, "compiled_packages", pkg.Name+".tgz"))
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Adding compiled package '%s'", pkg.Name)
+		}
+
+		dependencyNames := []string{}
+		for _, dependency := range pkg.Dependencies {
+			dependencyNames = append(dependencyNames, dependency.Name)
+		}
+
+		manifest.CompiledPackages = append(manifest.CompiledPackages, birelmanifest.CompiledPackageRef{
+			Name:         pkg.Name,
+			Version:      pkg.Fingerprint,
+			Fingerprint:  pkg.Fingerprint,
+			SHA1:         compiledPackage.SHA1,
+			Stemcell:     stemcell,
+			Dependencies: dependencyNames,
+		})
+	}
+
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling release manifest")
+	}
+
+	manifestPath := filepath.Join(releaseDir, "release.MF")
+	err = w.fs.WriteFile(manifestPath, manifestBytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing release manifest '%s'", manifestPath)
+	}
+
+	tarballPath, err := w.compressor.CompressFilesInDir(releaseDir)
+	if err != nil {
+		return bosherr.WrapError(err, "Compressing compiled release")
+	}
+	defer func() {
+		if err := w.compressor.CleanUp(tarballPath); err != nil {
+			w.logger.Warn(w.logTag, "Failed to delete compressed release '%s': %s", tarballPath, err.Error())
+		}
+	}()
+
+	err = w.fs.CopyFile(tarballPath, destinationPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying compiled release to '%s'", destinationPath)
+	}
+
+	return nil
+}
+
+func (w *compiledReleaseWriter) copyArchive(srcPath, dstPath string) error {
+	err := w.fs.MkdirAll(filepath.Dir(dstPath), os.ModeDir|0700)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory '%s'", filepath.Dir(dstPath))
+	}
+
+	return w.fs.CopyFile(srcPath, dstPath)
+}
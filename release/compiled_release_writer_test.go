@@ -0,0 +1,142 @@
+package release_test
+
+import (
+	"errors"
+
+	. "github.com/cloudfoundry/bosh-init/release"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
+
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+	fakecmd "github.com/cloudfoundry/bosh-utils/fileutil/fakes"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CompiledReleaseWriter", func() {
+	var (
+		fakeFs         *fakesys.FakeFileSystem
+		fakeCompressor *fakecmd.FakeCompressor
+		writer         CompiledReleaseWriter
+
+		release          Release
+		compiledPackages []CompiledPackage
+	)
+
+	BeforeEach(func() {
+		fakeFs = fakesys.NewFakeFileSystem()
+		fakeCompressor = fakecmd.NewFakeCompressor()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		writer = NewCompiledReleaseWriter(fakeFs, fakeCompressor, logger)
+
+		fakeFs.TempDirDir = "/compiled-release"
+		// keep the release dir around to inspect its contents
+		fakeFs.RegisterRemoveAllError("/compiled-release", errors.New("fake-remove-all-error"))
+
+		fakeCompressor.CompressFilesInDirTarballPath = "/compressed-release.tgz"
+		fakeFs.WriteFileString("/compressed-release.tgz", "fake-tarball-content")
+
+		fakeFs.WriteFileString("/extracted-release/jobs/fake-job.tgz", "fake-job-content")
+		fakeFs.WriteFileString("/compiled-blobs/fake-package-1", "fake-package-1-content")
+		fakeFs.WriteFileString("/compiled-blobs/fake-package-2", "fake-package-2-content")
+
+		package1 := &birelpkg.Package{
+			Name:         "fake-package-1",
+			Fingerprint:  "fake-package-1-fingerprint",
+			Dependencies: []*birelpkg.Package{},
+		}
+		package2 := &birelpkg.Package{
+			Name:         "fake-package-2",
+			Fingerprint:  "fake-package-2-fingerprint",
+			Dependencies: []*birelpkg.Package{package1},
+		}
+
+		release = NewRelease(
+			"fake-release-name",
+			"fake-release-version",
+			[]bireljob.Job{
+				{
+					Name:        "fake-job",
+					Fingerprint: "fake-job-fingerprint",
+					SHA1:        "fake-job-sha1",
+					ArchivePath: "/extracted-release/jobs/fake-job.tgz",
+					Packages:    []*birelpkg.Package{package2},
+				},
+			},
+			[]*birelpkg.Package{package1, package2},
+			"/extracted-release",
+			fakeFs,
+		)
+
+		compiledPackages = []CompiledPackage{
+			{Package: package1, ArchivePath: "/compiled-blobs/fake-package-1", SHA1: "fake-package-1-sha1"},
+			{Package: package2, ArchivePath: "/compiled-blobs/fake-package-2", SHA1: "fake-package-2-sha1"},
+		}
+	})
+
+	It("writes the jobs and compiled packages into a release tarball", func() {
+		err := writer.Write(release, "ubuntu-trusty/2690", compiledPackages, "/fake-release.tgz")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(fakeCompressor.CompressFilesInDirDir).To(Equal("/compiled-release"))
+		Expect(fakeCompressor.CleanUpTarballPath).To(Equal("/compressed-release.tgz"))
+		Expect(fakeFs.ReadFileString("/fake-release.tgz")).To(Equal("fake-tarball-content"))
+
+		Expect(fakeFs.ReadFileString("/compiled-release/jobs/fake-job.tgz")).To(Equal("fake-job-content"))
+		Expect(fakeFs.ReadFileString("/compiled-release/compiled_packages/fake-package-1.tgz")).To(Equal("fake-package-1-content"))
+		Expect(fakeFs.ReadFileString("/compiled-release/compiled_packages/fake-package-2.tgz")).To(Equal("fake-package-2-content"))
+
+		manifestBytes, err := fakeFs.ReadFile("/compiled-release/release.MF")
+		Expect(err).ToNot(HaveOccurred())
+
+		var manifest birelmanifest.Manifest
+		err = yaml.Unmarshal(manifestBytes, &manifest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(manifest).To(Equal(birelmanifest.Manifest{
+			Name:    "fake-release-name",
+			Version: "fake-release-version",
+			Jobs: []birelmanifest.JobRef{
+				{Name: "fake-job", Version: "fake-job-fingerprint", Fingerprint: "fake-job-fingerprint", SHA1: "fake-job-sha1"},
+			},
+			Packages: []birelmanifest.PackageRef{},
+			CompiledPackages: []birelmanifest.CompiledPackageRef{
+				{
+					Name:         "fake-package-1",
+					Version:      "fake-package-1-fingerprint",
+					Fingerprint:  "fake-package-1-fingerprint",
+					SHA1:         "fake-package-1-sha1",
+					Stemcell:     "ubuntu-trusty/2690",
+					Dependencies: []string{},
+				},
+				{
+					Name:         "fake-package-2",
+					Version:      "fake-package-2-fingerprint",
+					Fingerprint:  "fake-package-2-fingerprint",
+					SHA1:         "fake-package-2-sha1",
+					Stemcell:     "ubuntu-trusty/2690",
+					Dependencies: []string{"fake-package-1"},
+				},
+			},
+		}))
+	})
+
+	It("returns an error when a compiled package archive cannot be copied", func() {
+		compiledPackages[1].ArchivePath = "/compiled-blobs/missing"
+
+		err := writer.Write(release, "ubuntu-trusty/2690", compiledPackages, "/fake-release.tgz")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Adding compiled package 'fake-package-2'"))
+	})
+
+	It("returns an error when compressing the release fails", func() {
+		fakeCompressor.CompressFilesInDirErr = errors.New("fake-compress-error")
+
+		err := writer.Write(release, "ubuntu-trusty/2690", compiledPackages, "/fake-release.tgz")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-compress-error"))
+	})
+})
@@ -0,0 +1,594 @@
+package release
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
+	bireljobmanifest "github.com/cloudfoundry/bosh-init/release/job/manifest"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// FileModeReader returns the permissions of a file, which are part of the job and package fingerprints
+type FileModeReader interface {
+	Mode(path string) (os.FileMode, error)
+}
+
+type DevReleaseBuilder interface {
+	// IsReleaseDir returns true if the path is a release source directory (rather than a release tarball)
+	IsReleaseDir(path string) bool
+
+	// Build creates a dev release tarball from the jobs, packages, src and blobs of a release source directory.
+	// Use compressor.CleanUp to delete the returned tarball.
+	Build(releaseDirPath string) (tarballPath string, err error)
+}
+
+type releaseConfig struct {
+	Name      string `yaml:"name"`
+	FinalName string `yaml:"final_name"`
+	DevName   string `yaml:"dev_name"`
+}
+
+type packageSpec struct {
+	Name          string   `yaml:"name"`
+	Dependencies  []string `yaml:"dependencies"`
+	Files         []string `yaml:"files"`
+	ExcludedFiles []string `yaml:"excluded_files"`
+}
+
+// blobRecord is an entry of config/blobs.yml
+type blobRecord struct {
+	ObjectID string `yaml:"object_id"`
+	SHA      string `yaml:"sha"`
+	Size     int    `yaml:"size"`
+}
+
+// buildsIndex is the .final_builds/<jobs|packages>/<name>/index.yml of a release directory, with the builds by fingerprint
+type buildsIndex struct {
+	Builds map[string]buildRecord `yaml:"builds"`
+}
+
+type buildRecord struct {
+	Version string `yaml:"version"`
+}
+
+// releaseFile is a file of a job or package, identified by its path inside the job or package archive
+type releaseFile struct {
+	SourcePath   string
+	RelativePath string
+}
+
+type devReleaseBuilder struct {
+	fs             boshsys.FileSystem
+	compressor     boshcmd.Compressor
+	sha1Calculator bicrypto.SHA1Calculator
+	fileModeReader FileModeReader
+	logger         boshlog.Logger
+	logTag         string
+}
+
+func NewDevReleaseBuilder(
+	fs boshsys.FileSystem,
+	compressor boshcmd.Compressor,
+	sha1Calculator bicrypto.SHA1Calculator,
+	fileModeReader FileModeReader,
+	logger boshlog.Logger,
+) DevReleaseBuilder {
+	return &devReleaseBuilder{
+		fs:             fs,
+		compressor:     compressor,
+		sha1Calculator: sha1Calculator,
+		fileModeReader: fileModeReader,
+		logger:         logger,
+		logTag:         "devReleaseBuilder",
+	}
+}
+
+func (b *devReleaseBuilder) IsReleaseDir(path string) bool {
+	// unlike FileExists, Glob does not report '<tarball>/jobs' as existing when path is a file
+	matches, err := b.fs.Glob(filepath.Join(path, "jobs"))
+	return err == nil && len(matches) > 0
+}
+
+func (b *devReleaseBuilder) Build(releaseDirPath string) (string, error) {
+	releaseName, err := b.readReleaseName(releaseDirPath)
+	if err != nil {
+		return "", err
+	}
+
+	buildDir, err := b.fs.TempDir("bosh-init-dev-release")
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Creating temp directory to build release '%s'", releaseDirPath)
+	}
+	defer func() {
+		if err := b.fs.RemoveAll(buildDir); err != nil {
+			b.logger.Warn(b.logTag, "Failed to delete dev release build dir '%s': %s", buildDir, err.Error())
+		}
+	}()
+
+	b.logger.Info(b.logTag, "Building dev release '%s' from '%s' in '%s'", releaseName, releaseDirPath, buildDir)
+
+	releasePath := filepath.Join(buildDir, "release")
+
+	packageRefs, err := b.buildPackages(releaseDirPath, buildDir, releasePath)
+	if err != nil {
+		return "", err
+	}
+
+	jobRefs, err := b.buildJobs(releaseDirPath, buildDir, releasePath)
+	if err != nil {
+		return "", err
+	}
+
+	manifest := birelmanifest.Manifest{
+		Name:     releaseName,
+		Version:  b.devVersion(jobRefs, packageRefs),
+		Jobs:     jobRefs,
+		Packages: packageRefs,
+	}
+
+	manifestBytes, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Marshalling release manifest")
+	}
+
+	manifestPath := filepath.Join(releasePath, "release.MF")
+	err = b.fs.WriteFile(manifestPath, manifestBytes)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Writing release manifest '%s'", manifestPath)
+	}
+
+	tarballPath, err := b.compressor.CompressFilesInDir(releasePath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Compressing dev release '%s'", releaseName)
+	}
+
+	b.logger.Info(b.logTag, "Built dev release %s version %s", manifest.Name, manifest.Version)
+
+	return tarballPath, nil
+}
+
+// readReleaseName returns the dev_name of config/dev.yml, falling back to the name of config/final.yml
+func (b *devReleaseBuilder) readReleaseName(releaseDirPath string) (string, error) {
+	for _, configFile := range []string{"dev.yml", "final.yml"} {
+		configPath := filepath.Join(releaseDirPath, "config", configFile)
+		if !b.fs.FileExists(configPath) {
+			continue
+		}
+
+		var config releaseConfig
+		err := b.readYAML(configPath, &config)
+		if err != nil {
+			return "", err
+		}
+
+		for _, name := range []string{config.DevName, config.FinalName, config.Name} {
+			if name != "" {
+				return name, nil
+			}
+		}
+	}
+
+	return "", bosherr.Errorf("Release name not found in 'config/dev.yml' or 'config/final.yml' of release directory '%s'", releaseDirPath)
+}
+
+func (b *devReleaseBuilder) buildPackages(releaseDirPath, buildDir, releasePath string) ([]birelmanifest.PackageRef, error) {
+	packageDirs, err := b.fs.Glob(filepath.Join(releaseDirPath, "packages", "*"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing packages")
+	}
+
+	blobs, err := b.readBlobs(releaseDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	srcFiles, err := b.listFiles(filepath.Join(releaseDirPath, "src"))
+	if err != nil {
+		return nil, err
+	}
+
+	blobFiles, err := b.listFiles(filepath.Join(releaseDirPath, "blobs"))
+	if err != nil {
+		return nil, err
+	}
+
+	packageRefs := []birelmanifest.PackageRef{}
+	errs := []error{}
+	for _, packageDir := range packageDirs {
+		var spec packageSpec
+		err := b.readYAML(filepath.Join(packageDir, "spec"), &spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		files := []releaseFile{{SourcePath: filepath.Join(packageDir, "packaging"), RelativePath: "packaging"}}
+		matchedPaths := map[string]struct{}{}
+		for _, pattern := range spec.Files {
+			matchedFiles := b.matchFiles(pattern, spec.ExcludedFiles, srcFiles, filepath.Join(releaseDirPath, "src"))
+			if len(matchedFiles) == 0 {
+				matchedFiles = b.matchFiles(pattern, spec.ExcludedFiles, blobFiles, filepath.Join(releaseDirPath, "blobs"))
+			}
+			if len(matchedFiles) == 0 {
+				if b.matchesBlob(pattern, blobs) {
+					errs = append(errs, bosherr.Errorf("Package '%s' blob '%s' is not present in 'blobs'. Sync blobs before using the release directory", spec.Name, pattern))
+				} else {
+					errs = append(errs, bosherr.Errorf("Package '%s' file pattern '%s' does not match any files in 'src' or 'blobs'", spec.Name, pattern))
+				}
+				continue
+			}
+
+			for _, matchedFile := range matchedFiles {
+				if _, found := matchedPaths[matchedFile.RelativePath]; !found {
+					matchedPaths[matchedFile.RelativePath] = struct{}{}
+					files = append(files, matchedFile)
+				}
+			}
+		}
+
+		dependencies := append([]string{}, spec.Dependencies...)
+		sort.Strings(dependencies)
+
+		fingerprint, err := b.fingerprint(files, dependencies)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "Calculating fingerprint of package '%s'", spec.Name))
+			continue
+		}
+
+		version, err := b.version(releaseDirPath, "packages", spec.Name, fingerprint)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		archivePath := filepath.Join(releasePath, "packages", spec.Name+".tgz")
+		archiveSHA1, err := b.buildArchive(files, filepath.Join(buildDir, "packages", spec.Name), archivePath)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "Building package '%s'", spec.Name))
+			continue
+		}
+
+		packageRefs = append(packageRefs, birelmanifest.PackageRef{
+			Name:         spec.Name,
+			Version:      version,
+			Fingerprint:  fingerprint,
+			SHA1:         archiveSHA1,
+			Dependencies: spec.Dependencies,
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, bosherr.NewMultiError(errs...)
+	}
+
+	return packageRefs, nil
+}
+
+func (b *devReleaseBuilder) buildJobs(releaseDirPath, buildDir, releasePath string) ([]birelmanifest.JobRef, error) {
+	jobDirs, err := b.fs.Glob(filepath.Join(releaseDirPath, "jobs", "*"))
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing jobs")
+	}
+
+	jobRefs := []birelmanifest.JobRef{}
+	errs := []error{}
+	for _, jobDir := range jobDirs {
+		var spec bireljobmanifest.Manifest
+		err := b.readYAML(filepath.Join(jobDir, "spec"), &spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// the job spec becomes the job.MF of the job archive
+		files := []releaseFile{
+			{SourcePath: filepath.Join(jobDir, "spec"), RelativePath: "job.MF"},
+			{SourcePath: filepath.Join(jobDir, "monit"), RelativePath: "monit"},
+		}
+		for templateName := range spec.Templates {
+			files = append(files, releaseFile{
+				SourcePath:   filepath.Join(jobDir, "templates", templateName),
+				RelativePath: filepath.Join("templates", templateName),
+			})
+		}
+
+		fingerprint, err := b.fingerprint(files, []string{})
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "Calculating fingerprint of job '%s'", spec.Name))
+			continue
+		}
+
+		version, err := b.version(releaseDirPath, "jobs", spec.Name, fingerprint)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		archivePath := filepath.Join(releasePath, "jobs", spec.Name+".tgz")
+		archiveSHA1, err := b.buildArchive(files, filepath.Join(buildDir, "jobs", spec.Name), archivePath)
+		if err != nil {
+			errs = append(errs, bosherr.WrapErrorf(err, "Building job '%s'", spec.Name))
+			continue
+		}
+
+		jobRefs = append(jobRefs, birelmanifest.JobRef{
+			Name:        spec.Name,
+			Version:     version,
+			Fingerprint: fingerprint,
+			SHA1:        archiveSHA1,
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, bosherr.NewMultiError(errs...)
+	}
+
+	return jobRefs, nil
+}
+
+// buildArchive copies the files into stagingDir, compresses it to archivePath and returns the sha1 of the archive
+func (b *devReleaseBuilder) buildArchive(files []releaseFile, stagingDir, archivePath string) (string, error) {
+	for _, file := range files {
+		err := b.copyFile(file.SourcePath, filepath.Join(stagingDir, file.RelativePath))
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Copying '%s'", file.SourcePath)
+		}
+	}
+
+	tarballPath, err := b.compressor.CompressFilesInDir(stagingDir)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Compressing archive")
+	}
+	defer func() {
+		if err := b.compressor.CleanUp(tarballPath); err != nil {
+			b.logger.Warn(b.logTag, "Failed to delete archive '%s': %s", tarballPath, err.Error())
+		}
+	}()
+
+	err = b.copyFile(tarballPath, archivePath)
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Copying archive to '%s'", archivePath)
+	}
+
+	return b.sha1Calculator.Calculate(archivePath)
+}
+
+// fingerprint is calculated like the bosh CLI does (fingerprint scheme 2), so that the builds of .final_builds are recognized:
+// the sha1 of "v2", followed by the relative path, digest and tracked mode of each file sorted by path,
+// followed by the comma separated dependency names
+func (b *devReleaseBuilder) fingerprint(files []releaseFile, dependencies []string) (string, error) {
+	sortedFiles := append([]releaseFile{}, files...)
+	sort.Sort(releaseFilesByPath(sortedFiles))
+
+	h := sha1.New()
+	io.WriteString(h, "v2")
+	for _, file := range sortedFiles {
+		digest, err := b.sha1Calculator.Calculate(file.SourcePath)
+		if err != nil {
+			return "", err
+		}
+
+		mode, err := b.fileModeReader.Mode(file.SourcePath)
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Reading mode of '%s'", file.SourcePath)
+		}
+
+		// only the executable bit of the owner is tracked
+		trackedMode := "100644"
+		if mode&0100 != 0 {
+			trackedMode = "100755"
+		}
+
+		io.WriteString(h, fmt.Sprintf("%s%s%s", file.RelativePath, digest, trackedMode))
+	}
+
+	io.WriteString(h, strings.Join(dependencies, ","))
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// version returns the version of the final build of the job or package with the fingerprint,
+// or the fingerprint itself when there is no such build, as the bosh CLI does for new builds
+func (b *devReleaseBuilder) version(releaseDirPath, kind, name, fingerprint string) (string, error) {
+	indexPath := filepath.Join(releaseDirPath, ".final_builds", kind, name, "index.yml")
+	if !b.fs.FileExists(indexPath) {
+		return fingerprint, nil
+	}
+
+	var index buildsIndex
+	err := b.readYAML(indexPath, &index)
+	if err != nil {
+		return "", err
+	}
+
+	record, found := index.Builds[fingerprint]
+	if !found || record.Version == "" {
+		return fingerprint, nil
+	}
+
+	return record.Version, nil
+}
+
+// devVersion derives the release version from the job and package fingerprints,
+// so that it changes whenever the contents of the release change
+func (b *devReleaseBuilder) devVersion(jobRefs []birelmanifest.JobRef, packageRefs []birelmanifest.PackageRef) string {
+	fingerprints := []string{}
+	for _, jobRef := range jobRefs {
+		fingerprints = append(fingerprints, jobRef.Name+jobRef.Fingerprint)
+	}
+	for _, packageRef := range packageRefs {
+		fingerprints = append(fingerprints, packageRef.Name+packageRef.Fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	h := sha1.New()
+	for _, fingerprint := range fingerprints {
+		io.WriteString(h, fingerprint)
+	}
+
+	return fmt.Sprintf("0+dev.%x", h.Sum(nil)[:4])
+}
+
+func (b *devReleaseBuilder) readBlobs(releaseDirPath string) (map[string]blobRecord, error) {
+	blobs := map[string]blobRecord{}
+
+	blobsPath := filepath.Join(releaseDirPath, "config", "blobs.yml")
+	if !b.fs.FileExists(blobsPath) {
+		return blobs, nil
+	}
+
+	err := b.readYAML(blobsPath, &blobs)
+	if err != nil {
+		return nil, err
+	}
+
+	return blobs, nil
+}
+
+// listFiles returns the paths of the files under dir, relative to dir
+func (b *devReleaseBuilder) listFiles(dir string) ([]string, error) {
+	files := []string{}
+	if !b.fs.FileExists(dir) {
+		return files, nil
+	}
+
+	err := b.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, relativePath)
+		return nil
+	})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Listing files in '%s'", dir)
+	}
+
+	return files, nil
+}
+
+func (b *devReleaseBuilder) matchFiles(pattern string, excludedPatterns []string, relativePaths []string, dir string) []releaseFile {
+	files := []releaseFile{}
+	for _, relativePath := range relativePaths {
+		if !matchFilePattern(pattern, relativePath) || b.isExcluded(relativePath, excludedPatterns) {
+			continue
+		}
+		files = append(files, releaseFile{
+			SourcePath:   filepath.Join(dir, relativePath),
+			RelativePath: relativePath,
+		})
+	}
+	return files
+}
+
+func (b *devReleaseBuilder) matchesBlob(pattern string, blobs map[string]blobRecord) bool {
+	for blobPath := range blobs {
+		if matchFilePattern(pattern, blobPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *devReleaseBuilder) isExcluded(relativePath string, excludedPatterns []string) bool {
+	for _, excludedPattern := range excludedPatterns {
+		if matchFilePattern(excludedPattern, relativePath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *devReleaseBuilder) copyFile(srcPath, dstPath string) error {
+	err := b.fs.MkdirAll(filepath.Dir(dstPath), os.ModeDir|0700)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating directory '%s'", filepath.Dir(dstPath))
+	}
+
+	return b.fs.CopyFile(srcPath, dstPath)
+}
+
+func (b *devReleaseBuilder) readYAML(path string, out interface{}) error {
+	contents, err := b.fs.ReadFile(path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Reading '%s'", path)
+	}
+
+	err = yaml.Unmarshal(contents, out)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Parsing '%s'", path)
+	}
+
+	return nil
+}
+
+// matchFilePattern matches a '/' separated path against a glob pattern, where '**' matches any number of directories
+func matchFilePattern(pattern, path string) bool {
+	return matchPathSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchPathSegments(patternSegments, pathSegments []string) bool {
+	if len(patternSegments) == 0 {
+		return len(pathSegments) == 0
+	}
+
+	if patternSegments[0] == "**" {
+		for i := 0; i <= len(pathSegments); i++ {
+			if matchPathSegments(patternSegments[1:], pathSegments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(pathSegments) == 0 {
+		return false
+	}
+
+	matched, err := filepath.Match(patternSegments[0], pathSegments[0])
+	if err != nil || !matched {
+		return false
+	}
+
+	return matchPathSegments(patternSegments[1:], pathSegments[1:])
+}
+
+type fileModeReader struct{}
+
+// NewFileModeReader returns a FileModeReader of the local file system
+func NewFileModeReader() FileModeReader {
+	return fileModeReader{}
+}
+
+func (r fileModeReader) Mode(path string) (os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Mode(), nil
+}
+
+type releaseFilesByPath []releaseFile
+
+func (f releaseFilesByPath) Len() int           { return len(f) }
+func (f releaseFilesByPath) Less(i, j int) bool { return f[i].RelativePath < f[j].RelativePath }
+func (f releaseFilesByPath) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
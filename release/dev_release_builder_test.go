@@ -0,0 +1,290 @@
+package release_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	. "github.com/cloudfoundry/bosh-init/release"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"gopkg.in/yaml.v2"
+
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
+	fakebirel "github.com/cloudfoundry/bosh-init/release/fakes"
+	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+
+	testfakes "github.com/cloudfoundry/bosh-init/testutils/fakes"
+)
+
+var _ = Describe("DevReleaseBuilder", func() {
+	var (
+		fakeFS             *fakesys.FakeFileSystem
+		fakeCompressor     *testfakes.FakeMultiResponseExtractor
+		fakeSHA1Calculator *fakebicrypto.FakeSha1Calculator
+		fakeFileModeReader *fakebirel.FakeFileModeReader
+		sha1s              map[string]fakebicrypto.CalculateInput
+		builder            DevReleaseBuilder
+	)
+
+	readManifest := func() birelmanifest.Manifest {
+		manifestBytes, err := fakeFS.ReadFile("/build/release/release.MF")
+		Expect(err).ToNot(HaveOccurred())
+
+		var manifest birelmanifest.Manifest
+		err = yaml.Unmarshal(manifestBytes, &manifest)
+		Expect(err).ToNot(HaveOccurred())
+		return manifest
+	}
+
+	BeforeEach(func() {
+		fakeFS = fakesys.NewFakeFileSystem()
+		fakeCompressor = testfakes.NewFakeMultiResponseExtractor()
+		fakeSHA1Calculator = fakebicrypto.NewFakeSha1Calculator()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		fakeFileModeReader = fakebirel.NewFakeFileModeReader()
+		builder = NewDevReleaseBuilder(fakeFS, fakeCompressor, fakeSHA1Calculator, fakeFileModeReader, logger)
+
+		fakeFS.TempDirDir = "/build"
+		// keep the build dir around to inspect its contents
+		fakeFS.RegisterRemoveAllError("/build", errors.New("fake-remove-all-error"))
+
+		fakeFS.WriteFileString("/release-dir/config/final.yml", "final_name: fake-release-name\n")
+		fakeFS.WriteFileString("/release-dir/config/blobs.yml", `---
+fake-package/fake-blob.tgz:
+  object_id: fake-object-id
+  sha: fake-blob-sha1
+  size: 10
+fake-package/missing-blob.tgz:
+  object_id: fake-missing-object-id
+  sha: fake-missing-blob-sha1
+  size: 10
+`)
+
+		fakeFS.WriteFileString("/release-dir/jobs/fake-job/spec", `---
+name: fake-job
+templates:
+  ctl.erb: bin/ctl
+packages:
+- fake-package
+`)
+		fakeFS.WriteFileString("/release-dir/jobs/fake-job/monit", "fake-monit")
+		fakeFS.WriteFileString("/release-dir/jobs/fake-job/templates/ctl.erb", "fake-ctl")
+
+		fakeFS.WriteFileString("/release-dir/packages/fake-package/spec", `---
+name: fake-package
+dependencies: []
+files:
+- fake-package/**/*.txt
+- fake-package/fake-blob.tgz
+excluded_files:
+- fake-package/ignored.txt
+`)
+		fakeFS.WriteFileString("/release-dir/packages/fake-package/packaging", "fake-packaging")
+
+		fakeFS.MkdirAll("/release-dir/src", os.ModeDir|0700)
+		fakeFS.WriteFileString("/release-dir/src/fake-package/source.txt", "fake-source")
+		fakeFS.WriteFileString("/release-dir/src/fake-package/nested/nested.txt", "fake-nested-source")
+		fakeFS.WriteFileString("/release-dir/src/fake-package/ignored.txt", "fake-ignored-source")
+		fakeFS.MkdirAll("/release-dir/blobs", os.ModeDir|0700)
+		fakeFS.WriteFileString("/release-dir/blobs/fake-package/fake-blob.tgz", "fake-blob")
+
+		fakeFS.SetGlob("/release-dir/jobs/*", []string{"/release-dir/jobs/fake-job"})
+		fakeFS.SetGlob("/release-dir/packages/*", []string{"/release-dir/packages/fake-package"})
+
+		fakeCompressor.SetCompressBehavior("/build/packages/fake-package", "/compressed-package.tgz", nil)
+		fakeFS.WriteFileString("/compressed-package.tgz", "fake-package-archive")
+		fakeCompressor.SetCompressBehavior("/build/jobs/fake-job", "/compressed-job.tgz", nil)
+		fakeFS.WriteFileString("/compressed-job.tgz", "fake-job-archive")
+		fakeCompressor.SetCompressBehavior("/build/release", "/compressed-release.tgz", nil)
+
+		sha1s = map[string]fakebicrypto.CalculateInput{
+			"/release-dir/jobs/fake-job/spec":                 {Sha1: "job-spec-sha1"},
+			"/release-dir/jobs/fake-job/monit":                {Sha1: "job-monit-sha1"},
+			"/release-dir/jobs/fake-job/templates/ctl.erb":    {Sha1: "job-ctl-sha1"},
+			"/release-dir/packages/fake-package/packaging":    {Sha1: "packaging-sha1"},
+			"/release-dir/src/fake-package/source.txt":        {Sha1: "source-sha1"},
+			"/release-dir/src/fake-package/nested/nested.txt": {Sha1: "nested-sha1"},
+			"/release-dir/blobs/fake-package/fake-blob.tgz":   {Sha1: "fake-blob-sha1"},
+			"/build/release/packages/fake-package.tgz":        {Sha1: "package-archive-sha1"},
+			"/build/release/jobs/fake-job.tgz":                {Sha1: "job-archive-sha1"},
+		}
+		fakeSHA1Calculator.SetCalculateBehavior(sha1s)
+	})
+
+	Describe("IsReleaseDir", func() {
+		It("returns true when the path contains a jobs directory", func() {
+			fakeFS.SetGlob("/release-dir/jobs", []string{"/release-dir/jobs"})
+			Expect(builder.IsReleaseDir("/release-dir")).To(BeTrue())
+		})
+
+		It("returns false for a release tarball", func() {
+			Expect(builder.IsReleaseDir("/release.tgz")).To(BeFalse())
+		})
+	})
+
+	Describe("Build", func() {
+		It("returns the compressed dev release", func() {
+			tarballPath, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tarballPath).To(Equal("/compressed-release.tgz"))
+		})
+
+		It("stages the package sources and blobs matched by the package spec", func() {
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFS.ReadFileString("/build/packages/fake-package/packaging")).To(Equal("fake-packaging"))
+			Expect(fakeFS.ReadFileString("/build/packages/fake-package/fake-package/source.txt")).To(Equal("fake-source"))
+			Expect(fakeFS.ReadFileString("/build/packages/fake-package/fake-package/nested/nested.txt")).To(Equal("fake-nested-source"))
+			Expect(fakeFS.ReadFileString("/build/packages/fake-package/fake-package/fake-blob.tgz")).To(Equal("fake-blob"))
+			Expect(fakeFS.FileExists("/build/packages/fake-package/fake-package/ignored.txt")).To(BeFalse())
+
+			Expect(fakeFS.ReadFileString("/build/release/packages/fake-package.tgz")).To(Equal("fake-package-archive"))
+		})
+
+		It("stages the job spec as the job manifest with the monit file and templates", func() {
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeFS.ReadFileString("/build/jobs/fake-job/job.MF")).To(ContainSubstring("name: fake-job"))
+			Expect(fakeFS.ReadFileString("/build/jobs/fake-job/monit")).To(Equal("fake-monit"))
+			Expect(fakeFS.ReadFileString("/build/jobs/fake-job/templates/ctl.erb")).To(Equal("fake-ctl"))
+
+			Expect(fakeFS.ReadFileString("/build/release/jobs/fake-job.tgz")).To(Equal("fake-job-archive"))
+		})
+
+		It("writes a release manifest with the job and package fingerprints", func() {
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+
+			manifest := readManifest()
+			Expect(manifest.Name).To(Equal("fake-release-name"))
+			Expect(strings.HasPrefix(manifest.Version, "0+dev.")).To(BeTrue())
+
+			Expect(manifest.Jobs).To(HaveLen(1))
+			Expect(manifest.Jobs[0].Name).To(Equal("fake-job"))
+			Expect(manifest.Jobs[0].SHA1).To(Equal("job-archive-sha1"))
+			Expect(manifest.Jobs[0].Fingerprint).ToNot(BeEmpty())
+
+			Expect(manifest.Packages).To(HaveLen(1))
+			Expect(manifest.Packages[0].Name).To(Equal("fake-package"))
+			Expect(manifest.Packages[0].SHA1).To(Equal("package-archive-sha1"))
+			Expect(manifest.Packages[0].Fingerprint).ToNot(BeEmpty())
+		})
+
+		It("calculates the fingerprints like the bosh CLI, with the tracked modes of the files", func() {
+			fakeFileModeReader.Modes["/release-dir/jobs/fake-job/templates/ctl.erb"] = 0755
+			fakeFileModeReader.Modes["/release-dir/packages/fake-package/packaging"] = 0755
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+
+			manifest := readManifest()
+			Expect(manifest.Jobs[0].Fingerprint).To(Equal("536e8ff65eaf4c00108e825f99d9af7ba322c39e"))
+			Expect(manifest.Jobs[0].Version).To(Equal("536e8ff65eaf4c00108e825f99d9af7ba322c39e"))
+			Expect(manifest.Packages[0].Fingerprint).To(Equal("24e1f16dad57bfe9d7626a1d24d9a9ebd387ee38"))
+			Expect(manifest.Packages[0].Version).To(Equal("24e1f16dad57bfe9d7626a1d24d9a9ebd387ee38"))
+		})
+
+		It("reuses the versions of the final builds with the same fingerprint", func() {
+			fakeFileModeReader.Modes["/release-dir/jobs/fake-job/templates/ctl.erb"] = 0755
+			fakeFileModeReader.Modes["/release-dir/packages/fake-package/packaging"] = 0755
+			fakeFS.WriteFileString("/release-dir/.final_builds/jobs/fake-job/index.yml", `---
+builds:
+  536e8ff65eaf4c00108e825f99d9af7ba322c39e:
+    version: "3"
+    sha1: fake-final-job-sha1
+    blobstore_id: fake-final-job-blobstore-id
+format-version: "2"
+`)
+			fakeFS.WriteFileString("/release-dir/.final_builds/packages/fake-package/index.yml", `---
+builds:
+  fake-other-fingerprint:
+    version: "2"
+format-version: "2"
+`)
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+
+			manifest := readManifest()
+			Expect(manifest.Jobs[0].Version).To(Equal("3"))
+			Expect(manifest.Packages[0].Version).To(Equal(manifest.Packages[0].Fingerprint))
+		})
+
+		It("returns an error when the mode of a file cannot be read", func() {
+			fakeFileModeReader.Errs["/release-dir/jobs/fake-job/monit"] = errors.New("fake-mode-error")
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-mode-error"))
+		})
+
+		It("changes the package fingerprint and release version when a package source changes", func() {
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+			manifest := readManifest()
+
+			sha1s["/release-dir/src/fake-package/source.txt"] = fakebicrypto.CalculateInput{Sha1: "changed-source-sha1"}
+			_, err = builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+			changedManifest := readManifest()
+
+			Expect(changedManifest.Jobs[0].Fingerprint).To(Equal(manifest.Jobs[0].Fingerprint))
+			Expect(changedManifest.Packages[0].Fingerprint).ToNot(Equal(manifest.Packages[0].Fingerprint))
+			Expect(changedManifest.Version).ToNot(Equal(manifest.Version))
+		})
+
+		It("prefers the dev name of the release", func() {
+			fakeFS.WriteFileString("/release-dir/config/dev.yml", "dev_name: fake-dev-release-name\n")
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readManifest().Name).To(Equal("fake-dev-release-name"))
+		})
+
+		It("returns an error when the release name is not configured", func() {
+			fakeFS.RemoveAll("/release-dir/config/final.yml")
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Release name not found"))
+		})
+
+		It("returns an error when a package blob has not been synced", func() {
+			fakeFS.WriteFileString("/release-dir/packages/fake-package/spec", `---
+name: fake-package
+files:
+- fake-package/missing-blob.tgz
+`)
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Package 'fake-package' blob 'fake-package/missing-blob.tgz' is not present in 'blobs'"))
+		})
+
+		It("returns an error when a package file pattern does not match any files", func() {
+			fakeFS.WriteFileString("/release-dir/packages/fake-package/spec", `---
+name: fake-package
+files:
+- fake-package/*.missing
+`)
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Package 'fake-package' file pattern 'fake-package/*.missing' does not match any files"))
+		})
+
+		It("returns an error when compressing the release fails", func() {
+			fakeCompressor.SetCompressBehavior("/build/release", "", errors.New("fake-compress-error"))
+
+			_, err := builder.Build("/release-dir")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-compress-error"))
+		})
+	})
+})
@@ -12,32 +12,54 @@ type Extractor interface {
 }
 
 type extractor struct {
-	fs         boshsys.FileSystem
-	compressor boshcmd.Compressor
-	validator  Validator
-	logger     boshlog.Logger
-	logTag     string
+	fs                boshsys.FileSystem
+	compressor        boshcmd.Compressor
+	validator         Validator
+	devReleaseBuilder DevReleaseBuilder
+	logger            boshlog.Logger
+	logTag            string
 }
 
 func NewExtractor(
 	fs boshsys.FileSystem,
 	compressor boshcmd.Compressor,
 	validator Validator,
+	devReleaseBuilder DevReleaseBuilder,
 	logger boshlog.Logger,
 ) Extractor {
 	return &extractor{
-		fs:         fs,
-		compressor: compressor,
-		validator:  validator,
-		logger:     logger,
-		logTag:     "releaseExtractor",
+		fs:                fs,
+		compressor:        compressor,
+		validator:         validator,
+		devReleaseBuilder: devReleaseBuilder,
+		logger:            logger,
+		logTag:            "releaseExtractor",
 	}
 }
 
 // Extract decompresses a release tarball into a temp directory (release.extractedPath),
 // parses the release manifest, decompresses the packages and jobs, and validates the release.
+// If releaseTarballPath is a release source directory, a dev release tarball is built from it first.
 // Use release.Delete() to clean up the temp directory.
 func (e *extractor) Extract(releaseTarballPath string) (Release, error) {
+	if e.devReleaseBuilder.IsReleaseDir(releaseTarballPath) {
+		devReleaseTarballPath, err := e.devReleaseBuilder.Build(releaseTarballPath)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Building dev release from '%s'", releaseTarballPath)
+		}
+		defer func() {
+			if err := e.compressor.CleanUp(devReleaseTarballPath); err != nil {
+				e.logger.Warn(e.logTag, "Failed to delete dev release tarball '%s': %s", devReleaseTarballPath, err.Error())
+			}
+		}()
+
+		return e.extractTarball(devReleaseTarballPath)
+	}
+
+	return e.extractTarball(releaseTarballPath)
+}
+
+func (e *extractor) extractTarball(releaseTarballPath string) (Release, error) {
 	extractedReleasePath, err := e.fs.TempDir("bosh-init-release")
 	if err != nil {
 		return nil, bosherr.WrapErrorf(err, "Creating temp directory to extract release '%s'", releaseTarballPath)
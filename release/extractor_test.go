@@ -18,18 +18,20 @@ import (
 var _ = Describe("Extractor", func() {
 
 	var (
-		fakeFS               *fakesys.FakeFileSystem
-		fakeExtractor        *testfakes.FakeMultiResponseExtractor
-		fakeReleaseValidator *fakebirel.FakeValidator
-		releaseExtractor     Extractor
+		fakeFS                *fakesys.FakeFileSystem
+		fakeExtractor         *testfakes.FakeMultiResponseExtractor
+		fakeReleaseValidator  *fakebirel.FakeValidator
+		fakeDevReleaseBuilder *fakebirel.FakeDevReleaseBuilder
+		releaseExtractor      Extractor
 	)
 
 	BeforeEach(func() {
 		fakeFS = fakesys.NewFakeFileSystem()
 		fakeExtractor = testfakes.NewFakeMultiResponseExtractor()
 		fakeReleaseValidator = fakebirel.NewFakeValidator()
+		fakeDevReleaseBuilder = fakebirel.NewFakeDevReleaseBuilder()
 		logger := boshlog.NewLogger(boshlog.LevelNone)
-		releaseExtractor = NewExtractor(fakeFS, fakeExtractor, fakeReleaseValidator, logger)
+		releaseExtractor = NewExtractor(fakeFS, fakeExtractor, fakeReleaseValidator, fakeDevReleaseBuilder, logger)
 	})
 
 	Describe("Extract", func() {
@@ -97,6 +99,7 @@ properties: {}
 								Fingerprint:   "fake-release-job-fingerprint",
 								SHA1:          "fake-release-job-sha1",
 								ExtractedPath: "/extracted-release-path/extracted_jobs/cpi",
+								ArchivePath:   "/extracted-release-path/jobs/cpi.tgz",
 								Templates: map[string]string{
 									"cpi.erb":     "bin/cpi",
 									"cpi.yml.erb": "config/cpi.yml",
@@ -157,6 +160,38 @@ properties: {}
 			})
 		})
 
+		Context("when the path is a release directory", func() {
+			BeforeEach(func() {
+				fakeDevReleaseBuilder.ReleaseDirs["/fake/release-dir"] = true
+				fakeDevReleaseBuilder.BuildTarballPath = "/fake/dev-release.tgz"
+
+				fakeFS.TempDirDirs = []string{"/extracted-release-path"}
+				fakeFS.WriteFileString("/extracted-release-path/release.MF", `---
+name: fake-release-name
+version: 0+dev.fake
+`)
+			})
+
+			It("extracts the dev release built from the directory", func() {
+				release, err := releaseExtractor.Extract("/fake/release-dir")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(release.Name()).To(Equal("fake-release-name"))
+				Expect(release.Version()).To(Equal("0+dev.fake"))
+
+				Expect(fakeDevReleaseBuilder.BuildReleaseDirPath).To(Equal("/fake/release-dir"))
+				Expect(fakeExtractor.DecompressedFiles()).To(ContainElement("/extracted-release-path//fake/dev-release.tgz"))
+			})
+
+			It("returns an error when building the dev release fails", func() {
+				fakeDevReleaseBuilder.BuildErr = bosherr.Error("fake-build-error")
+
+				_, err := releaseExtractor.Extract("/fake/release-dir")
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("Building dev release from '/fake/release-dir'"))
+				Expect(err.Error()).To(ContainSubstring("fake-build-error"))
+			})
+		})
+
 		Context("when an extracted release path cannot be created", func() {
 			BeforeEach(func() {
 				fakeFS.TempDirError = bosherr.Error("fake-tmp-dir-error")
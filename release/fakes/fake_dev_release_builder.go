@@ -0,0 +1,24 @@
+package fakes
+
+type FakeDevReleaseBuilder struct {
+	ReleaseDirs map[string]bool
+
+	BuildReleaseDirPath string
+	BuildTarballPath    string
+	BuildErr            error
+}
+
+func NewFakeDevReleaseBuilder() *FakeDevReleaseBuilder {
+	return &FakeDevReleaseBuilder{
+		ReleaseDirs: map[string]bool{},
+	}
+}
+
+func (f *FakeDevReleaseBuilder) IsReleaseDir(path string) bool {
+	return f.ReleaseDirs[path]
+}
+
+func (f *FakeDevReleaseBuilder) Build(releaseDirPath string) (string, error) {
+	f.BuildReleaseDirPath = releaseDirPath
+	return f.BuildTarballPath, f.BuildErr
+}
@@ -0,0 +1,28 @@
+package fakes
+
+import (
+	"os"
+)
+
+type FakeFileModeReader struct {
+	Modes map[string]os.FileMode
+	Errs  map[string]error
+}
+
+func NewFakeFileModeReader() *FakeFileModeReader {
+	return &FakeFileModeReader{
+		Modes: map[string]os.FileMode{},
+		Errs:  map[string]error{},
+	}
+}
+
+// Mode returns 0644 for the files without a mode
+func (r *FakeFileModeReader) Mode(path string) (os.FileMode, error) {
+	if err := r.Errs[path]; err != nil {
+		return 0, err
+	}
+	if mode, found := r.Modes[path]; found {
+		return mode, nil
+	}
+	return 0644, nil
+}
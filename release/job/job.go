@@ -10,15 +10,36 @@ type Job struct {
 	Fingerprint   string
 	SHA1          string
 	ExtractedPath string
+	ArchivePath   string
 	Templates     map[string]string
 	PackageNames  []string
 	Packages      []*birelpkg.Package
 	Properties    map[string]PropertyDefinition
+	Provides      []ProvidedLink
+	Consumes      []ConsumedLink
 }
 
 type PropertyDefinition struct {
 	Description string
+	Type        string
 	Default     biproperty.Property
+	// Secret properties have their values masked when the rendered templates are shown
+	Secret bool
+}
+
+// ProvidedLink is a link the job makes available to the other jobs of a deployment job,
+// exposing the values of the listed job properties.
+type ProvidedLink struct {
+	Name       string
+	Type       string
+	Properties []string
+}
+
+// ConsumedLink is a link of a specific type that the job requires (unless optional) from another job.
+type ConsumedLink struct {
+	Name     string
+	Type     string
+	Optional bool
 }
 
 func (j Job) FindTemplateByValue(value string) (string, bool) {
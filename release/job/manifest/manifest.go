@@ -5,9 +5,25 @@ type Manifest struct {
 	Templates  map[string]string             `yaml:"templates"`
 	Packages   []string                      `yaml:"packages"`
 	Properties map[string]PropertyDefinition `yaml:"properties"`
+	Provides   []ProvidedLinkDefinition      `yaml:"provides"`
+	Consumes   []ConsumedLinkDefinition      `yaml:"consumes"`
 }
 
 type PropertyDefinition struct {
 	Description string      `yaml:"description"`
+	Type        string      `yaml:"type"`
 	Default     interface{} `yaml:"default"`
+	Secret      bool        `yaml:"secret"`
+}
+
+type ProvidedLinkDefinition struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Properties []string `yaml:"properties"`
+}
+
+type ConsumedLinkDefinition struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Optional bool   `yaml:"optional"`
 }
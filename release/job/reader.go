@@ -71,10 +71,28 @@ func (r *reader) Read() (Job, error) {
 		}
 		jobProperties[propertyName] = PropertyDefinition{
 			Description: rawPropertyDef.Description,
+			Type:        rawPropertyDef.Type,
 			Default:     defaultValue,
+			Secret:      rawPropertyDef.Secret,
 		}
 	}
 	job.Properties = jobProperties
 
+	for _, providedLink := range jobManifest.Provides {
+		job.Provides = append(job.Provides, ProvidedLink{
+			Name:       providedLink.Name,
+			Type:       providedLink.Type,
+			Properties: providedLink.Properties,
+		})
+	}
+
+	for _, consumedLink := range jobManifest.Consumes {
+		job.Consumes = append(job.Consumes, ConsumedLink{
+			Name:     consumedLink.Name,
+			Type:     consumedLink.Type,
+			Optional: consumedLink.Optional,
+		})
+	}
+
 	return job, nil
 }
@@ -38,7 +38,11 @@ packages:
 properties:
   fake-property:
     description: "Fake description"
+    type: string
     default: "fake-default"
+  fake-secret-property:
+    description: "Fake secret description"
+    secret: true
 `,
 				)
 			})
@@ -55,14 +59,53 @@ properties:
 						Properties: map[string]PropertyDefinition{
 							"fake-property": PropertyDefinition{
 								Description: "Fake description",
+								Type:        "string",
 								Default:     biproperty.Property("fake-default"),
 							},
+							"fake-secret-property": PropertyDefinition{
+								Description: "Fake secret description",
+								Secret:      true,
+							},
 						},
 					},
 				))
 			})
 		})
 
+		Context("when the job manifest has links", func() {
+			BeforeEach(func() {
+				fakeFs.WriteFileString(
+					"/extracted/job/job.MF",
+					`---
+name: fake-job
+provides:
+- name: fake-provided-link
+  type: fake-link-type
+  properties:
+  - fake-property
+consumes:
+- name: fake-consumed-link
+  type: fake-other-link-type
+- name: fake-optional-link
+  type: fake-optional-link-type
+  optional: true
+`,
+				)
+			})
+
+			It("returns a job with the provided and consumed links", func() {
+				job, err := reader.Read()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(job.Provides).To(Equal([]ProvidedLink{
+					{Name: "fake-provided-link", Type: "fake-link-type", Properties: []string{"fake-property"}},
+				}))
+				Expect(job.Consumes).To(Equal([]ConsumedLink{
+					{Name: "fake-consumed-link", Type: "fake-other-link-type"},
+					{Name: "fake-optional-link", Type: "fake-optional-link-type", Optional: true},
+				}))
+			})
+		})
+
 		Context("when the job manifest is invalid", func() {
 			It("returns an error when the job manifest is missing", func() {
 				_, err := reader.Read()
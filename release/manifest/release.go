@@ -7,19 +7,31 @@ type Manifest struct {
 	CommitHash         string `yaml:"commit_hash"`
 	UncommittedChanges bool   `yaml:"uncommitted_changes"`
 
-	Jobs     []JobRef     `yaml:"jobs"`
-	Packages []PackageRef `yaml:"packages"`
+	Jobs             []JobRef             `yaml:"jobs"`
+	Packages         []PackageRef         `yaml:"packages"`
+	CompiledPackages []CompiledPackageRef `yaml:"compiled_packages"`
 }
 
 type JobRef struct {
 	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
 	Fingerprint string `yaml:"fingerprint"`
 	SHA1        string `yaml:"sha1"`
 }
 
 type PackageRef struct {
 	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
 	Fingerprint  string   `yaml:"fingerprint"`
 	SHA1         string   `yaml:"sha1"`
 	Dependencies []string `yaml:"dependencies"`
 }
+
+type CompiledPackageRef struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Fingerprint  string   `yaml:"fingerprint"`
+	SHA1         string   `yaml:"sha1"`
+	Stemcell     string   `yaml:"stemcell"`
+	Dependencies []string `yaml:"dependencies"`
+}
@@ -5,9 +5,10 @@ import (
 )
 
 type ReleaseRef struct {
-	Name string
-	URL  string
-	SHA1 string
+	Name    string
+	Version string
+	URL     string
+	SHA1    string
 }
 
 func (r ReleaseRef) GetURL() string {
@@ -7,8 +7,29 @@ type Package struct {
 	Dependencies  []*Package
 	ExtractedPath string
 	ArchivePath   string
+
+	// Compiled is the archive of the package pre-compiled by a compiled release (nil for source-only packages)
+	Compiled *CompiledArchive
+}
+
+// CompiledArchive is a package archive that was compiled ahead of time against a specific stemcell.
+type CompiledArchive struct {
+	// Stemcell is the '<operating system>/<version>' of the stemcell the package was compiled against
+	Stemcell    string
+	SHA1        string
+	ArchivePath string
 }
 
 func (p Package) String() string {
 	return p.Name
 }
+
+// HasSource returns false for packages that only exist as compiled archives
+func (p Package) HasSource() bool {
+	return p.Compiled == nil || p.ArchivePath != ""
+}
+
+// IsCompiledFor returns true if the package has a compiled archive for the specified stemcell ('<os>/<version>')
+func (p Package) IsCompiledFor(stemcell string) bool {
+	return p.Compiled != nil && stemcell != "" && p.Compiled.Stemcell == stemcell
+}
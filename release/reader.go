@@ -66,7 +66,7 @@ func (r *reader) Read() (Release, error) {
 
 func (r *reader) newReleaseFromManifest(releaseManifest birelmanifest.Manifest) (Release, error) {
 	errors := []error{}
-	packages, err := r.newPackagesFromManifestPackages(releaseManifest.Packages)
+	packages, err := r.newPackagesFromManifestPackages(releaseManifest.Packages, releaseManifest.CompiledPackages)
 	if err != nil {
 		errors = append(errors, bosherr.WrapError(err, "Constructing packages from manifest"))
 	}
@@ -115,6 +115,7 @@ func (r *reader) newJobsFromManifestJobs(packages []*birelpkg.Package, manifestJ
 
 		job.Fingerprint = manifestJob.Fingerprint
 		job.SHA1 = manifestJob.SHA1
+		job.ArchivePath = jobArchivePath
 		for _, pkgName := range job.PackageNames {
 			pkg, found := r.findPackageByName(packages, pkgName)
 			if !found {
@@ -142,7 +143,7 @@ func (r *reader) findPackageByName(packages []*birelpkg.Package, pkgName string)
 	return nil, false
 }
 
-func (r *reader) newPackagesFromManifestPackages(manifestPackages []birelmanifest.PackageRef) ([]*birelpkg.Package, error) {
+func (r *reader) newPackagesFromManifestPackages(manifestPackages []birelmanifest.PackageRef, manifestCompiledPackages []birelmanifest.CompiledPackageRef) ([]*birelpkg.Package, error) {
 	packages := []*birelpkg.Package{}
 	errors := []error{}
 	packageRepo := &birelpkg.PackageRepo{}
@@ -176,6 +177,32 @@ func (r *reader) newPackagesFromManifestPackages(manifestPackages []birelmanifes
 		packages = append(packages, pkg)
 	}
 
+	for _, manifestCompiledPackage := range manifestCompiledPackages {
+		pkg := packageRepo.FindOrCreatePackage(manifestCompiledPackage.Name)
+
+		// compiled packages are handed to the agent as-is, so they are not extracted
+		pkg.Compiled = &birelpkg.CompiledArchive{
+			Stemcell:    manifestCompiledPackage.Stemcell,
+			SHA1:        manifestCompiledPackage.SHA1,
+			ArchivePath: path.Join(r.extractedReleasePath, "compiled_packages", manifestCompiledPackage.Name+".tgz"),
+		}
+
+		if pkg.HasSource() {
+			if pkg.Fingerprint != manifestCompiledPackage.Fingerprint {
+				errors = append(errors, bosherr.Errorf("Compiled package '%s' fingerprint '%s' does not match source package fingerprint '%s'", pkg.Name, manifestCompiledPackage.Fingerprint, pkg.Fingerprint))
+			}
+			continue
+		}
+
+		pkg.Fingerprint = manifestCompiledPackage.Fingerprint
+		pkg.Dependencies = []*birelpkg.Package{}
+		for _, manifestPackageName := range manifestCompiledPackage.Dependencies {
+			pkg.Dependencies = append(pkg.Dependencies, packageRepo.FindOrCreatePackage(manifestPackageName))
+		}
+
+		packages = append(packages, pkg)
+	}
+
 	if len(errors) > 0 {
 		return []*birelpkg.Package{}, bosherr.NewMultiError(errors...)
 	}
@@ -92,6 +92,7 @@ packages:
 									Fingerprint:   "fake-job-fingerprint",
 									SHA1:          "fake-job-sha",
 									ExtractedPath: "/extracted/release/extracted_jobs/fake-job",
+									ArchivePath:   "/extracted/release/jobs/fake-job.tgz",
 									Templates:     map[string]string{"some_template": "some_file"},
 									PackageNames:  []string{"fake-package"},
 									Packages:      []*birelpkg.Package{expectedPackage},
@@ -165,6 +166,62 @@ packages:
 				})
 			})
 
+			Context("when the release manifest contains compiled packages", func() {
+				BeforeEach(func() {
+					fakeFs.WriteFileString(
+						"/extracted/release/release.MF",
+						`---
+name: fake-release
+version: fake-version
+
+jobs:
+- name: fake-job
+  version: fake-job-version
+  fingerprint: fake-job-fingerprint
+  sha1: fake-job-sha
+
+compiled_packages:
+- name: fake-package
+  version: fake-package-version
+  fingerprint: fake-package-fingerprint
+  sha1: fake-compiled-package-sha
+  stemcell: ubuntu-trusty/2690
+  dependencies: []
+`,
+					)
+					fakeFs.WriteFileString(
+						"/extracted/release/extracted_jobs/fake-job/job.MF",
+						`---
+name: fake-job
+templates: {}
+packages:
+- fake-package
+`,
+					)
+				})
+
+				It("returns a release with the compiled packages", func() {
+					release, err := reader.Read()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(release.Packages()).To(Equal([]*birelpkg.Package{
+						&birelpkg.Package{
+							Name:         "fake-package",
+							Fingerprint:  "fake-package-fingerprint",
+							Dependencies: []*birelpkg.Package{},
+							Compiled: &birelpkg.CompiledArchive{
+								Stemcell:    "ubuntu-trusty/2690",
+								SHA1:        "fake-compiled-package-sha",
+								ArchivePath: "/extracted/release/compiled_packages/fake-package.tgz",
+							},
+						},
+					}))
+					Expect(release.Packages()[0].HasSource()).To(BeFalse())
+					Expect(release.Packages()[0].IsCompiledFor("ubuntu-trusty/2690")).To(BeTrue())
+					Expect(release.Packages()[0].IsCompiledFor("ubuntu-trusty/3012")).To(BeFalse())
+				})
+			})
+
 			Context("when the CPI release manifest is invalid", func() {
 				BeforeEach(func() {
 					fakeFs.WriteFileString("/extracted/release/release.MF", "{")
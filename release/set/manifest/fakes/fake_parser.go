@@ -1,20 +1,26 @@
 package fakes
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	birelsetmanifest "github.com/cloudfoundry/bosh-init/release/set/manifest"
 )
 
 type FakeParser struct {
-	ParsePath     string
-	ParseManifest birelsetmanifest.Manifest
-	ParseErr      error
+	ParsePath      string
+	ParseVariables bivars.Variables
+	ParseOps       bipatch.Ops
+	ParseManifest  birelsetmanifest.Manifest
+	ParseErr       error
 }
 
 func NewFakeParser() *FakeParser {
 	return &FakeParser{}
 }
 
-func (p *FakeParser) Parse(path string) (birelsetmanifest.Manifest, error) {
+func (p *FakeParser) Parse(path string, variables bivars.Variables, ops bipatch.Ops) (birelsetmanifest.Manifest, error) {
 	p.ParsePath = path
+	p.ParseVariables = variables
+	p.ParseOps = ops
 	return p.ParseManifest, p.ParseErr
 }
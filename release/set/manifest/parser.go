@@ -1,6 +1,8 @@
 package manifest
 
 import (
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -9,7 +11,7 @@ import (
 )
 
 type Parser interface {
-	Parse(path string) (Manifest, error)
+	Parse(path string, variables bivars.Variables, ops bipatch.Ops) (Manifest, error)
 }
 
 type parser struct {
@@ -32,12 +34,22 @@ func NewParser(fs boshsys.FileSystem, logger boshlog.Logger, validator Validator
 	}
 }
 
-func (p *parser) Parse(path string) (Manifest, error) {
+func (p *parser) Parse(path string, variables bivars.Variables, ops bipatch.Ops) (Manifest, error) {
 	contents, err := p.fs.ReadFile(path)
 	if err != nil {
 		return Manifest{}, bosherr.WrapErrorf(err, "Reading file %s", path)
 	}
 
+	contents, err = ops.Apply(contents)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Applying ops to %s", path)
+	}
+
+	contents, err = bivars.Interpolate(contents, variables)
+	if err != nil {
+		return Manifest{}, bosherr.WrapErrorf(err, "Interpolating variables in %s", path)
+	}
+
 	comboManifest := manifest{}
 	err = yaml.Unmarshal(contents, &comboManifest)
 	if err != nil {
@@ -3,6 +3,8 @@ package manifest_test
 import (
 	"errors"
 
+	bipatch "github.com/cloudfoundry/bosh-init/common/patch"
+	bivars "github.com/cloudfoundry/bosh-init/common/vars"
 	birelmanifest "github.com/cloudfoundry/bosh-init/release/manifest"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
@@ -49,7 +51,7 @@ name: unknown-keys-are-ignored
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 		})
 	})
@@ -60,13 +62,13 @@ name: unknown-keys-are-ignored
 		})
 
 		It("returns an error", func() {
-			_, err := parser.Parse(comboManifestPath)
+			_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 			Expect(err).To(HaveOccurred())
 		})
 	})
 
 	It("parses release set manifest from combo manifest file", func() {
-		deploymentManifest, err := parser.Parse(comboManifestPath)
+		deploymentManifest, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(deploymentManifest).To(Equal(manifest.Manifest{
@@ -85,12 +87,38 @@ name: unknown-keys-are-ignored
 		}))
 	})
 
+	It("interpolates the variables of the combo manifest file", func() {
+		fakeFs.WriteFileString(comboManifestPath, `
+---
+releases:
+- name: fake-release-name
+  url: ((release_url))
+  sha1: ((release_sha1))
+`)
+
+		deploymentManifest, err := parser.Parse(comboManifestPath, bivars.Variables{
+			"release_url":  "file://fake-release.tgz",
+			"release_sha1": "fake-sha1",
+		}, bipatch.Ops{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(deploymentManifest).To(Equal(manifest.Manifest{
+			Releases: []birelmanifest.ReleaseRef{
+				{
+					Name: "fake-release-name",
+					URL:  "file://fake-release.tgz",
+					SHA1: "fake-sha1",
+				},
+			},
+		}))
+	})
+
 	It("handles errors validating the release set manifest", func() {
 		fakeValidator.SetValidateBehavior([]fakes.ValidateOutput{
 			{Err: errors.New("couldn't validate that")},
 		})
 
-		_, err := parser.Parse(comboManifestPath)
+		_, err := parser.Parse(comboManifestPath, bivars.Variables{}, bipatch.Ops{})
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("Validating release set manifest: couldn't validate that"))
 	})
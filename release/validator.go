@@ -126,9 +126,23 @@ func (v *validator) validateReleasePackages(release Release) error {
 			errs = append(errs, fmt.Errorf("Package '%s' fingerprint is missing", pkg.Name))
 		}
 
-		if pkg.SHA1 == "" {
+		if pkg.HasSource() && pkg.SHA1 == "" {
 			errs = append(errs, fmt.Errorf("Package '%s' sha1 is missing", pkg.Name))
 		}
+
+		if pkg.Compiled != nil {
+			if pkg.Compiled.Stemcell == "" {
+				errs = append(errs, fmt.Errorf("Compiled package '%s' stemcell is missing", pkg.Name))
+			}
+
+			if pkg.Compiled.SHA1 == "" {
+				errs = append(errs, fmt.Errorf("Compiled package '%s' sha1 is missing", pkg.Name))
+			}
+
+			if !v.fs.FileExists(pkg.Compiled.ArchivePath) {
+				errs = append(errs, fmt.Errorf("Compiled package '%s' is missing archive '%s'", pkg.Name, pkg.Compiled.ArchivePath))
+			}
+		}
 	}
 
 	if len(errs) > 0 {
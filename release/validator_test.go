@@ -193,4 +193,45 @@ var _ = Describe("Validator", func() {
 			Expect(err.Error()).To(ContainSubstring("Job 'fake-job-2' requires 'fake-package-2' which is not in the release"))
 		})
 	})
+
+	Context("when packages are compiled", func() {
+		It("returns errors with each compiled package that is invalid", func() {
+			fakeFs.WriteFileString("/some/release/path/compiled_packages/fake-package.tgz", "")
+			release := NewRelease(
+				"fake-release-name",
+				"fake-release-version",
+				[]bireljob.Job{},
+				[]*birelpkg.Package{
+					{
+						Name:        "fake-package",
+						Fingerprint: "fake-fingerprint",
+						Compiled: &birelpkg.CompiledArchive{
+							Stemcell:    "ubuntu-trusty/2690",
+							SHA1:        "fake-compiled-sha",
+							ArchivePath: "/some/release/path/compiled_packages/fake-package.tgz",
+						},
+					},
+					{
+						Name:        "fake-package-2",
+						Fingerprint: "fake-fingerprint-2",
+						Compiled: &birelpkg.CompiledArchive{
+							ArchivePath: "/some/release/path/compiled_packages/fake-package-2.tgz",
+						},
+					},
+				},
+				"/some/release/path",
+				fakeFs,
+			)
+			validator := NewValidator(fakeFs)
+
+			err := validator.Validate(release)
+			Expect(err).To(HaveOccurred())
+
+			Expect(err.Error()).ToNot(ContainSubstring("'fake-package' "))
+			Expect(err.Error()).ToNot(ContainSubstring("Package 'fake-package-2' sha1 is missing"))
+			Expect(err.Error()).To(ContainSubstring("Compiled package 'fake-package-2' stemcell is missing"))
+			Expect(err.Error()).To(ContainSubstring("Compiled package 'fake-package-2' sha1 is missing"))
+			Expect(err.Error()).To(ContainSubstring("Compiled package 'fake-package-2' is missing archive '/some/release/path/compiled_packages/fake-package-2.tgz'"))
+		})
+	})
 })
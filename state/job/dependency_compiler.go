@@ -20,31 +20,49 @@ type CompiledPackageRef struct {
 }
 
 type DependencyCompiler interface {
-	Compile(releaseJobs []bireljob.Job, stage biui.Stage) ([]CompiledPackageRef, error)
+	Compile(releaseJobs []bireljob.Job, stemcell bistatepkg.Stemcell, stage biui.Stage) ([]CompiledPackageRef, error)
 }
 
 type dependencyCompiler struct {
 	packageCompiler bistatepkg.Compiler
+	packageImporter bistatepkg.Importer
+	packageRepo     bistatepkg.CompiledPackageRepo
 	logger          boshlog.Logger
 	logTag          string
 }
 
-func NewDependencyCompiler(packageCompiler bistatepkg.Compiler, logger boshlog.Logger) DependencyCompiler {
+// NewDependencyCompiler returns a DependencyCompiler.
+// The packageImporter is optional: when nil, pre-compiled packages are compiled from source.
+// The packageRepo is the repo the compiler & importer save the compiled packages to, to write their records at once.
+func NewDependencyCompiler(
+	packageCompiler bistatepkg.Compiler,
+	packageImporter bistatepkg.Importer,
+	packageRepo bistatepkg.CompiledPackageRepo,
+	logger boshlog.Logger,
+) DependencyCompiler {
 	return &dependencyCompiler{
 		packageCompiler: packageCompiler,
+		packageImporter: packageImporter,
+		packageRepo:     packageRepo,
 		logger:          logger,
 		logTag:          "dependencyCompiler",
 	}
 }
 
-// Compile resolves and compiles all transitive dependencies of multiple release jobs
-func (c *dependencyCompiler) Compile(releaseJobs []bireljob.Job, stage biui.Stage) ([]CompiledPackageRef, error) {
+// Compile resolves and compiles all transitive dependencies of multiple release jobs.
+// Packages that were pre-compiled against the OS & version of the specified stemcell are used as-is.
+// Use an empty stemcell to always compile from source.
+func (c *dependencyCompiler) Compile(releaseJobs []bireljob.Job, stemcell bistatepkg.Stemcell, stage biui.Stage) ([]CompiledPackageRef, error) {
 	compileOrderReleasePackages, err := c.resolveJobCompilationDependencies(releaseJobs)
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Resolving job package dependencies")
 	}
 
-	compiledPackageRefs, err := c.compilePackages(compileOrderReleasePackages, stage)
+	var compiledPackageRefs []CompiledPackageRef
+	err = c.packageRepo.Batch(func() error {
+		compiledPackageRefs, err = c.compilePackages(compileOrderReleasePackages, stemcell, stage)
+		return err
+	})
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Compiling job package dependencies")
 	}
@@ -94,36 +112,58 @@ func (c *dependencyCompiler) resolvePackageDependencies(releasePackage *birelpkg
 	}
 }
 
-// compilePackages compiles the specified packages, in the order specified, uploads them to the Blobstore, and returns the blob references
-func (c *dependencyCompiler) compilePackages(requiredPackages []*birelpkg.Package, stage biui.Stage) ([]CompiledPackageRef, error) {
+// compilePackages compiles the specified packages, in the order specified, uploads them to the Blobstore, and returns the blob references.
+// Packages with a compiled archive matching the stemcell are uploaded without being compiled.
+func (c *dependencyCompiler) compilePackages(requiredPackages []*birelpkg.Package, stemcell bistatepkg.Stemcell, stage biui.Stage) ([]CompiledPackageRef, error) {
 	packageRefs := make([]CompiledPackageRef, 0, len(requiredPackages))
 
 	for _, pkg := range requiredPackages {
-		stepName := fmt.Sprintf("Compiling package '%s/%s'", pkg.Name, pkg.Fingerprint)
-		err := stage.Perform(stepName, func() error {
-			compiledPackageRecord, err := c.packageCompiler.Compile(pkg)
-			if err != nil {
+		var compiledPackageRecord bistatepkg.CompiledPackageRecord
+
+		if c.packageImporter != nil && pkg.IsCompiledFor(stemcell.OsAndVersion()) {
+			stepName := fmt.Sprintf("Using compiled package '%s/%s'", pkg.Name, pkg.Fingerprint)
+			err := stage.Perform(stepName, func() error {
+				var err error
+				compiledPackageRecord, err = c.packageImporter.Import(pkg, stemcell)
 				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if !pkg.HasSource() {
+				return nil, c.noSourceError(pkg, stemcell.OsAndVersion())
 			}
 
-			packageRef := CompiledPackageRef{
-				Name:        pkg.Name,
-				Version:     pkg.Fingerprint,
-				BlobstoreID: compiledPackageRecord.BlobID,
-				SHA1:        compiledPackageRecord.BlobSHA1,
+			stepName := fmt.Sprintf("Compiling package '%s/%s'", pkg.Name, pkg.Fingerprint)
+			err := stage.Perform(stepName, func() error {
+				var err error
+				compiledPackageRecord, err = c.packageCompiler.Compile(pkg, stemcell)
+				return err
+			})
+			if err != nil {
+				return nil, err
 			}
-			packageRefs = append(packageRefs, packageRef)
+		}
 
-			return nil
+		packageRefs = append(packageRefs, CompiledPackageRef{
+			Name:        pkg.Name,
+			Version:     pkg.Fingerprint,
+			BlobstoreID: compiledPackageRecord.BlobID,
+			SHA1:        compiledPackageRecord.BlobSHA1,
 		})
-		if err != nil {
-			return nil, err
-		}
 	}
 
 	return packageRefs, nil
 }
 
+func (c *dependencyCompiler) noSourceError(pkg *birelpkg.Package, stemcell string) error {
+	if stemcell == "" {
+		return bosherr.Errorf("Package '%s/%s' is only available compiled for stemcell '%s' and cannot be compiled from source", pkg.Name, pkg.Fingerprint, pkg.Compiled.Stemcell)
+	}
+	return bosherr.Errorf("Package '%s/%s' is only available compiled for stemcell '%s', but stemcell '%s' is being deployed", pkg.Name, pkg.Fingerprint, pkg.Compiled.Stemcell, stemcell)
+}
+
 func (c *dependencyCompiler) pkgKey(pkg *birelpkg.Package) string {
 	return pkg.Name
 }
@@ -8,6 +8,7 @@ import (
 	"code.google.com/p/gomock/gomock"
 	mock_state_package "github.com/cloudfoundry/bosh-init/state/pkg/mocks"
 
+	biindex "github.com/cloudfoundry/bosh-init/index"
 	bireljob "github.com/cloudfoundry/bosh-init/release/job"
 	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
 	bistatepkg "github.com/cloudfoundry/bosh-init/state/pkg"
@@ -29,11 +30,14 @@ var _ = Describe("DependencyCompiler", func() {
 
 	var (
 		mockPackageCompiler *mock_state_package.MockCompiler
+		mockPackageImporter *mock_state_package.MockImporter
+		packageRepo         bistatepkg.CompiledPackageRepo
 		logger              boshlog.Logger
 
 		dependencyCompiler DependencyCompiler
 
 		releaseJobs []bireljob.Job
+		stemcell    bistatepkg.Stemcell
 		fakeStage   *fakebiui.FakeStage
 
 		releasePackage1 *birelpkg.Package
@@ -47,18 +51,27 @@ var _ = Describe("DependencyCompiler", func() {
 
 	BeforeEach(func() {
 		mockPackageCompiler = mock_state_package.NewMockCompiler(mockCtrl)
+		mockPackageImporter = mock_state_package.NewMockImporter(mockCtrl)
+		packageRepo = bistatepkg.NewCompiledPackageRepo(biindex.NewInMemoryIndex())
 
 		logger = boshlog.NewLogger(boshlog.LevelNone)
-		dependencyCompiler = NewDependencyCompiler(mockPackageCompiler, logger)
+		dependencyCompiler = NewDependencyCompiler(mockPackageCompiler, mockPackageImporter, packageRepo, logger)
 
 		fakeStage = fakebiui.NewFakeStage()
 
+		stemcell = bistatepkg.Stemcell{
+			Name:    "fake-stemcell-name",
+			Version: "fake-stemcell-version",
+			OS:      "fake-stemcell-os",
+		}
+
 		releasePackage1 = &birelpkg.Package{
 			Name:          "fake-release-package-name-1",
 			Fingerprint:   "fake-release-package-fingerprint-1",
 			SHA1:          "fake-release-package-sha1-1",
 			Dependencies:  []*birelpkg.Package{},
 			ExtractedPath: "/extracted-release-path/extracted_packages/fake-release-package-name-1",
+			ArchivePath:   "/extracted-release-path/packages/fake-release-package-name-1.tgz",
 		}
 
 		releasePackage2 = &birelpkg.Package{
@@ -67,6 +80,7 @@ var _ = Describe("DependencyCompiler", func() {
 			SHA1:          "fake-release-package-sha1-2",
 			Dependencies:  []*birelpkg.Package{releasePackage1},
 			ExtractedPath: "/extracted-release-path/extracted_packages/fake-release-package-name-2",
+			ArchivePath:   "/extracted-release-path/packages/fake-release-package-name-2.tgz",
 		}
 
 		releaseJob = bireljob.Job{
@@ -90,13 +104,13 @@ var _ = Describe("DependencyCompiler", func() {
 			BlobID:   "fake-compiled-package-blobstore-id-1",
 			BlobSHA1: "fake-compiled-package-sha1-1",
 		}
-		expectCompilePkg1 = mockPackageCompiler.EXPECT().Compile(releasePackage1).Return(compiledPackageRecord1, nil).AnyTimes()
+		expectCompilePkg1 = mockPackageCompiler.EXPECT().Compile(releasePackage1, stemcell).Return(compiledPackageRecord1, nil).AnyTimes()
 
 		compiledPackageRecord2 := bistatepkg.CompiledPackageRecord{
 			BlobID:   "fake-compiled-package-blobstore-id-2",
 			BlobSHA1: "fake-compiled-package-sha1-2",
 		}
-		expectCompilePkg2 = mockPackageCompiler.EXPECT().Compile(releasePackage2).Return(compiledPackageRecord2, nil).AnyTimes()
+		expectCompilePkg2 = mockPackageCompiler.EXPECT().Compile(releasePackage2, stemcell).Return(compiledPackageRecord2, nil).AnyTimes()
 	})
 
 	It("compiles all the job dependencies (packages) such that no package is compiled before its dependencies", func() {
@@ -105,12 +119,12 @@ var _ = Describe("DependencyCompiler", func() {
 			expectCompilePkg2.Times(1),
 		)
 
-		_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+		_, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
 	It("returns references to the compiled packages", func() {
-		compiledPackageRefs, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+		compiledPackageRefs, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(compiledPackageRefs).To(Equal([]CompiledPackageRef{
@@ -130,7 +144,7 @@ var _ = Describe("DependencyCompiler", func() {
 	})
 
 	It("logs compile stages", func() {
-		_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+		_, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
 		Expect(err).ToNot(HaveOccurred())
 
 		Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
@@ -156,7 +170,7 @@ var _ = Describe("DependencyCompiler", func() {
 				expectCompilePkg2.Times(1),
 			)
 
-			_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+			_, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
@@ -187,7 +201,7 @@ var _ = Describe("DependencyCompiler", func() {
 				BlobID:   "fake-compiled-package-blobstore-id-3",
 				BlobSHA1: "fake-compiled-package-sha1-3",
 			}
-			expectCompilePkg3 = mockPackageCompiler.EXPECT().Compile(releasePackage3).Return(compiledPackageRecord3, nil).AnyTimes()
+			expectCompilePkg3 = mockPackageCompiler.EXPECT().Compile(releasePackage3, stemcell).Return(compiledPackageRecord3, nil).AnyTimes()
 		})
 
 		It("only compiles each package once", func() {
@@ -195,8 +209,105 @@ var _ = Describe("DependencyCompiler", func() {
 			expectCompilePkg2.After(expectCompilePkg1)
 			expectCompilePkg3.After(expectCompilePkg1)
 
-			_, err := dependencyCompiler.Compile(releaseJobs, fakeStage)
+			_, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
 			Expect(err).ToNot(HaveOccurred())
 		})
 	})
+
+	Context("when a package has been compiled for the deployed stemcell", func() {
+		var expectImportPkg1 *gomock.Call
+
+		BeforeEach(func() {
+			releasePackage1.Compiled = &birelpkg.CompiledArchive{
+				Stemcell:    "fake-stemcell-os/fake-stemcell-version",
+				SHA1:        "fake-compiled-archive-sha1-1",
+				ArchivePath: "/extracted-release-path/compiled_packages/fake-release-package-name-1.tgz",
+			}
+		})
+
+		JustBeforeEach(func() {
+			compiledPackageRecord1 := bistatepkg.CompiledPackageRecord{
+				BlobID:   "fake-imported-package-blobstore-id-1",
+				BlobSHA1: "fake-compiled-archive-sha1-1",
+			}
+			expectImportPkg1 = mockPackageImporter.EXPECT().Import(releasePackage1, stemcell).Return(compiledPackageRecord1, nil).AnyTimes()
+		})
+
+		It("uses the compiled package instead of compiling it", func() {
+			expectCompilePkg1.Times(0)
+			gomock.InOrder(
+				expectImportPkg1.Times(1),
+				expectCompilePkg2.Times(1),
+			)
+
+			compiledPackageRefs, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(compiledPackageRefs[0]).To(Equal(CompiledPackageRef{
+				Name:        "fake-release-package-name-1",
+				Version:     "fake-release-package-fingerprint-1",
+				BlobstoreID: "fake-imported-package-blobstore-id-1",
+				SHA1:        "fake-compiled-archive-sha1-1",
+			}))
+
+			Expect(fakeStage.PerformCalls).To(Equal([]*fakebiui.PerformCall{
+				{Name: "Using compiled package 'fake-release-package-name-1/fake-release-package-fingerprint-1'"},
+				{Name: "Compiling package 'fake-release-package-name-2/fake-release-package-fingerprint-2'"},
+			}))
+		})
+
+		Context("when there is no package importer", func() {
+			BeforeEach(func() {
+				dependencyCompiler = NewDependencyCompiler(mockPackageCompiler, nil, packageRepo, logger)
+			})
+
+			It("compiles the package from source", func() {
+				expectImportPkg1.Times(0)
+				gomock.InOrder(
+					expectCompilePkg1.Times(1),
+					expectCompilePkg2.Times(1),
+				)
+
+				_, err := dependencyCompiler.Compile(releaseJobs, stemcell, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when a different stemcell is being deployed", func() {
+			var otherStemcell bistatepkg.Stemcell
+
+			BeforeEach(func() {
+				otherStemcell = bistatepkg.Stemcell{
+					Name:    "fake-stemcell-name",
+					Version: "other-stemcell-version",
+					OS:      "fake-stemcell-os",
+				}
+			})
+
+			It("compiles the package from source", func() {
+				expectImportPkg1.Times(0)
+				gomock.InOrder(
+					mockPackageCompiler.EXPECT().Compile(releasePackage1, otherStemcell).Return(bistatepkg.CompiledPackageRecord{}, nil),
+					mockPackageCompiler.EXPECT().Compile(releasePackage2, otherStemcell).Return(bistatepkg.CompiledPackageRecord{}, nil),
+				)
+
+				_, err := dependencyCompiler.Compile(releaseJobs, otherStemcell, fakeStage)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			Context("when the package does not have source", func() {
+				BeforeEach(func() {
+					releasePackage1.ExtractedPath = ""
+					releasePackage1.ArchivePath = ""
+				})
+
+				It("returns an error", func() {
+					expectCompilePkg1.Times(0)
+
+					_, err := dependencyCompiler.Compile(releaseJobs, otherStemcell, fakeStage)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("Package 'fake-release-package-name-1/fake-release-package-fingerprint-1' is only available compiled for stemcell 'fake-stemcell-os/fake-stemcell-version', but stemcell 'fake-stemcell-os/other-stemcell-version' is being deployed"))
+				})
+			})
+		})
+	})
 })
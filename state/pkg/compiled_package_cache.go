@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+
+	bicrypto "github.com/cloudfoundry/bosh-init/crypto"
+	biindex "github.com/cloudfoundry/bosh-init/index"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// CachedCompiledPackage is a compiled package archive stored on the local machine
+type CachedCompiledPackage struct {
+	ArchivePath string
+	SHA1        string
+}
+
+// CompiledPackageCache stores compiled package archives on the local machine,
+// so that they can be re-used by other deployments using the same stemcell.
+type CompiledPackageCache interface {
+	// Find returns the cached compiled package, evicting its archive when it does not match its sha1
+	Find(birelpkg.Package, Stemcell) (CachedCompiledPackage, bool, error)
+	// Save copies the compiled package archive into the cache
+	Save(pkg birelpkg.Package, stemcell Stemcell, archivePath string, sha1 string) error
+}
+
+type compiledPackageCacheRecord struct {
+	SHA1 string
+}
+
+type compiledPackageCache struct {
+	basePath       string
+	index          biindex.Index
+	sha1Calculator bicrypto.SHA1Calculator
+	fs             boshsys.FileSystem
+	logger         boshlog.Logger
+	logTag         string
+}
+
+func NewCompiledPackageCache(
+	basePath string,
+	index biindex.Index,
+	sha1Calculator bicrypto.SHA1Calculator,
+	fs boshsys.FileSystem,
+	logger boshlog.Logger,
+) CompiledPackageCache {
+	return &compiledPackageCache{
+		basePath:       basePath,
+		index:          index,
+		sha1Calculator: sha1Calculator,
+		fs:             fs,
+		logger:         logger,
+		logTag:         "compiledPackageCache",
+	}
+}
+
+func (c *compiledPackageCache) Find(pkg birelpkg.Package, stemcell Stemcell) (CachedCompiledPackage, bool, error) {
+	var record compiledPackageCacheRecord
+
+	err := c.index.Find(newCompiledPackageKey(pkg, stemcell), &record)
+	if err != nil {
+		if err == biindex.ErrNotFound {
+			return CachedCompiledPackage{}, false, nil
+		}
+
+		return CachedCompiledPackage{}, false, bosherr.WrapError(err, "Finding cached compiled package")
+	}
+
+	// the archive may have been removed by hand to clear the cache
+	archivePath := c.archivePath(record.SHA1)
+	if !c.fs.FileExists(archivePath) {
+		return CachedCompiledPackage{}, false, nil
+	}
+
+	// the archive is shared by all the deployments using the cache, so it is removed when it has been corrupted,
+	// for the package to be compiled & cached again
+	archiveSHA1, err := c.sha1Calculator.Calculate(archivePath)
+	if err != nil {
+		return CachedCompiledPackage{}, false, bosherr.WrapErrorf(err, "Calculating sha1 of cached compiled package archive '%s'", archivePath)
+	}
+	if archiveSHA1 != record.SHA1 {
+		c.logger.Warn(c.logTag, "Evicting cached compiled package archive '%s' with sha1 '%s' instead of '%s'", archivePath, archiveSHA1, record.SHA1)
+		err = c.fs.RemoveAll(archivePath)
+		if err != nil {
+			return CachedCompiledPackage{}, false, bosherr.WrapErrorf(err, "Removing cached compiled package archive '%s'", archivePath)
+		}
+		return CachedCompiledPackage{}, false, nil
+	}
+
+	return CachedCompiledPackage{ArchivePath: archivePath, SHA1: record.SHA1}, true, nil
+}
+
+func (c *compiledPackageCache) Save(pkg birelpkg.Package, stemcell Stemcell, archivePath string, sha1 string) error {
+	err := c.fs.MkdirAll(c.basePath, os.ModePerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating compiled package cache dir '%s'", c.basePath)
+	}
+
+	err = c.fs.CopyFile(archivePath, c.archivePath(sha1))
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying compiled package archive '%s' into cache", archivePath)
+	}
+
+	err = c.index.Save(newCompiledPackageKey(pkg, stemcell), compiledPackageCacheRecord{SHA1: sha1})
+	if err != nil {
+		return bosherr.WrapError(err, "Saving cached compiled package")
+	}
+
+	return nil
+}
+
+// archivePath returns the cached archive path, which is keyed by content so identical archives are stored once
+func (c *compiledPackageCache) archivePath(sha1 string) string {
+	return filepath.Join(c.basePath, sha1+".tgz")
+}
@@ -0,0 +1,120 @@
+package pkg_test
+
+import (
+	"errors"
+
+	fakebicrypto "github.com/cloudfoundry/bosh-init/crypto/fakes"
+	biindex "github.com/cloudfoundry/bosh-init/index"
+	birelpkg "github.com/cloudfoundry/bosh-init/release/pkg"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/state/pkg"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakesys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("CompiledPackageCache", func() {
+	var (
+		fakeFS               *fakesys.FakeFileSystem
+		fakeSHA1Calculator   *fakebicrypto.FakeSha1Calculator
+		compiledPackageCache CompiledPackageCache
+
+		pkg      birelpkg.Package
+		stemcell Stemcell
+	)
+
+	BeforeEach(func() {
+		fakeFS = fakesys.NewFakeFileSystem()
+		index := biindex.NewFileIndex("/cache/index.json", fakeFS)
+		fakeSHA1Calculator = fakebicrypto.NewFakeSha1Calculator()
+		fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+			"/cache/fake-sha1.tgz": {Sha1: "fake-sha1"},
+		})
+		compiledPackageCache = NewCompiledPackageCache("/cache", index, fakeSHA1Calculator, fakeFS, boshlog.NewLogger(boshlog.LevelNone))
+
+		pkg = birelpkg.Package{
+			Name:        "fake-package-name",
+			Fingerprint: "fake-package-fingerprint",
+		}
+		stemcell = Stemcell{
+			Name:    "fake-stemcell-name",
+			Version: "fake-stemcell-version",
+			OS:      "fake-stemcell-os",
+		}
+
+		fakeFS.WriteFileString("/compiled-package.tgz", "fake-compiled-package-content")
+	})
+
+	It("stores a copy of the compiled package archive", func() {
+		err := compiledPackageCache.Save(pkg, stemcell, "/compiled-package.tgz", "fake-sha1")
+		Expect(err).ToNot(HaveOccurred())
+
+		cachedPackage, found, err := compiledPackageCache.Find(pkg, stemcell)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+		Expect(cachedPackage).To(Equal(CachedCompiledPackage{
+			ArchivePath: "/cache/fake-sha1.tgz",
+			SHA1:        "fake-sha1",
+		}))
+		Expect(fakeFS.ReadFileString("/cache/fake-sha1.tgz")).To(Equal("fake-compiled-package-content"))
+	})
+
+	It("returns false when the package was cached for a different stemcell", func() {
+		err := compiledPackageCache.Save(pkg, stemcell, "/compiled-package.tgz", "fake-sha1")
+		Expect(err).ToNot(HaveOccurred())
+
+		stemcell.Version = "new-fake-stemcell-version"
+
+		_, found, err := compiledPackageCache.Find(pkg, stemcell)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("returns false when the cached archive has been removed", func() {
+		err := compiledPackageCache.Save(pkg, stemcell, "/compiled-package.tgz", "fake-sha1")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = fakeFS.RemoveAll("/cache/fake-sha1.tgz")
+		Expect(err).ToNot(HaveOccurred())
+
+		_, found, err := compiledPackageCache.Find(pkg, stemcell)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+	})
+
+	It("returns false and evicts the cached archive when it does not match its sha1", func() {
+		err := compiledPackageCache.Save(pkg, stemcell, "/compiled-package.tgz", "fake-sha1")
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+			"/cache/fake-sha1.tgz": {Sha1: "fake-corrupted-sha1"},
+		})
+
+		_, found, err := compiledPackageCache.Find(pkg, stemcell)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeFalse())
+		Expect(fakeFS.FileExists("/cache/fake-sha1.tgz")).To(BeFalse())
+	})
+
+	It("returns an error when the sha1 of the cached archive cannot be calculated", func() {
+		err := compiledPackageCache.Save(pkg, stemcell, "/compiled-package.tgz", "fake-sha1")
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeSHA1Calculator.SetCalculateBehavior(map[string]fakebicrypto.CalculateInput{
+			"/cache/fake-sha1.tgz": {Err: errors.New("fake-calculate-error")},
+		})
+
+		_, _, err = compiledPackageCache.Find(pkg, stemcell)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-calculate-error"))
+	})
+
+	It("returns an error when the archive cannot be copied", func() {
+		fakeFS.CopyFileError = errors.New("fake-copy-error")
+
+		err := compiledPackageCache.Save(pkg, stemcell, "/compiled-package.tgz", "fake-sha1")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("fake-copy-error"))
+	})
+})
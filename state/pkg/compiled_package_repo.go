@@ -16,20 +16,27 @@ type CompiledPackageRecord struct {
 }
 
 type CompiledPackageRepo interface {
-	Save(birelpkg.Package, CompiledPackageRecord) error
-	Find(birelpkg.Package) (CompiledPackageRecord, bool, error)
+	Save(birelpkg.Package, Stemcell, CompiledPackageRecord) error
+	Find(birelpkg.Package, Stemcell) (CompiledPackageRecord, bool, error)
+
+	// Batch runs the function, writing the records it saves at once, when the index supports batched saves
+	Batch(fn func() error) error
 }
 
 type compiledPackageRepo struct {
 	index biindex.Index
 }
 
+type batchIndex interface {
+	Batch(fn func() error) error
+}
+
 func NewCompiledPackageRepo(index biindex.Index) CompiledPackageRepo {
 	return &compiledPackageRepo{index: index}
 }
 
-func (cpr *compiledPackageRepo) Save(pkg birelpkg.Package, record CompiledPackageRecord) error {
-	err := cpr.index.Save(cpr.pkgKey(pkg), record)
+func (cpr *compiledPackageRepo) Save(pkg birelpkg.Package, stemcell Stemcell, record CompiledPackageRecord) error {
+	err := cpr.index.Save(newCompiledPackageKey(pkg, stemcell), record)
 
 	if err != nil {
 		return bosherr.WrapError(err, "Saving compiled package")
@@ -38,10 +45,10 @@ func (cpr *compiledPackageRepo) Save(pkg birelpkg.Package, record CompiledPackag
 	return nil
 }
 
-func (cpr *compiledPackageRepo) Find(pkg birelpkg.Package) (CompiledPackageRecord, bool, error) {
+func (cpr *compiledPackageRepo) Find(pkg birelpkg.Package, stemcell Stemcell) (CompiledPackageRecord, bool, error) {
 	var record CompiledPackageRecord
 
-	err := cpr.index.Find(cpr.pkgKey(pkg), &record)
+	err := cpr.index.Find(newCompiledPackageKey(pkg, stemcell), &record)
 	if err != nil {
 		if err == biindex.ErrNotFound {
 			return record, false, nil
@@ -53,23 +60,35 @@ func (cpr *compiledPackageRepo) Find(pkg birelpkg.Package) (CompiledPackageRecor
 	return record, true, nil
 }
 
+func (cpr *compiledPackageRepo) Batch(fn func() error) error {
+	if index, ok := cpr.index.(batchIndex); ok {
+		return index.Batch(fn)
+	}
+	return fn()
+}
+
 type packageToCompiledPackageKey struct {
 	PackageName string
 	// Fingerprint of a package captures the sorted names of its dependencies
 	// (but not the dependencies' fingerprints)
 	PackageFingerprint string
 	DependencyKey      string
+	// Packages compiled for one stemcell may not work on another (e.g. different OS libraries)
+	StemcellName    string
+	StemcellVersion string
 }
 
-func (cpr compiledPackageRepo) pkgKey(pkg birelpkg.Package) packageToCompiledPackageKey {
+func newCompiledPackageKey(pkg birelpkg.Package, stemcell Stemcell) packageToCompiledPackageKey {
 	return packageToCompiledPackageKey{
 		PackageName:        pkg.Name,
 		PackageFingerprint: pkg.Fingerprint,
-		DependencyKey:      cpr.convertToDependencyKey(ResolveDependencies(&pkg)),
+		DependencyKey:      convertToDependencyKey(ResolveDependencies(&pkg)),
+		StemcellName:       stemcell.Name,
+		StemcellVersion:    stemcell.Version,
 	}
 }
 
-func (cpr compiledPackageRepo) convertToDependencyKey(packages []*birelpkg.Package) string {
+func convertToDependencyKey(packages []*birelpkg.Package) string {
 	dependencyKeys := []string{}
 	for _, pkg := range packages {
 		dependencyKeys = append(dependencyKeys, fmt.Sprintf("%s:%s", pkg.Name, pkg.Fingerprint))
@@ -30,10 +30,16 @@ var _ = Describe("CompiledPackageRepo", func() {
 			record     CompiledPackageRecord
 			dependency birelpkg.Package
 			pkg        birelpkg.Package
+			stemcell   Stemcell
 		)
 
 		BeforeEach(func() {
 			record = CompiledPackageRecord{}
+			stemcell = Stemcell{
+				Name:    "fake-stemcell-name",
+				Version: "fake-stemcell-version",
+				OS:      "fake-stemcell-os",
+			}
 			dependency = birelpkg.Package{
 				Name:        "fake-dependency-package",
 				Fingerprint: "fake-dependency-fingerprint",
@@ -46,10 +52,10 @@ var _ = Describe("CompiledPackageRepo", func() {
 		})
 
 		It("saves the compiled package to the index", func() {
-			err := compiledPackageRepo.Save(pkg, record)
+			err := compiledPackageRepo.Save(pkg, stemcell, record)
 			Expect(err).ToNot(HaveOccurred())
 
-			result, found, err := compiledPackageRepo.Find(pkg)
+			result, found, err := compiledPackageRepo.Find(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeTrue())
 			Expect(result).To(Equal(record))
@@ -59,22 +65,41 @@ var _ = Describe("CompiledPackageRepo", func() {
 			pkg := birelpkg.Package{
 				Name: "fake-package-name",
 			}
-			_, found, err := compiledPackageRepo.Find(pkg)
+			_, found, err := compiledPackageRepo.Find(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeFalse())
 		})
 
 		It("returns false if package dependencies have changed after saving", func() {
-			err := compiledPackageRepo.Save(pkg, record)
+			err := compiledPackageRepo.Save(pkg, stemcell, record)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, found, err := compiledPackageRepo.Find(pkg)
+			_, found, err := compiledPackageRepo.Find(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeTrue())
 
 			dependency.Fingerprint = "new-fake-dependency-fingerprint"
 
-			_, found, err = compiledPackageRepo.Find(pkg)
+			_, found, err = compiledPackageRepo.Find(pkg, stemcell)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns false if the stemcell is different", func() {
+			err := compiledPackageRepo.Save(pkg, stemcell, record)
+			Expect(err).ToNot(HaveOccurred())
+
+			otherStemcell := Stemcell{
+				Name:    "fake-stemcell-name",
+				Version: "new-fake-stemcell-version",
+				OS:      "fake-stemcell-os",
+			}
+
+			_, found, err := compiledPackageRepo.Find(pkg, otherStemcell)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+
+			_, found, err = compiledPackageRepo.Find(pkg, Stemcell{})
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeFalse())
 		})
@@ -91,12 +116,12 @@ var _ = Describe("CompiledPackageRepo", func() {
 
 			pkg.Dependencies = []*birelpkg.Package{&dependency1, &dependency2}
 
-			err := compiledPackageRepo.Save(pkg, record)
+			err := compiledPackageRepo.Save(pkg, stemcell, record)
 			Expect(err).ToNot(HaveOccurred())
 
 			pkg.Dependencies = []*birelpkg.Package{&dependency2, &dependency1}
 
-			result, found, err := compiledPackageRepo.Find(pkg)
+			result, found, err := compiledPackageRepo.Find(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeTrue())
 			Expect(result).To(Equal(record))
@@ -109,16 +134,16 @@ var _ = Describe("CompiledPackageRepo", func() {
 			}
 			dependency.Dependencies = []*birelpkg.Package{&transitive}
 
-			err := compiledPackageRepo.Save(pkg, record)
+			err := compiledPackageRepo.Save(pkg, stemcell, record)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, found, err := compiledPackageRepo.Find(pkg)
+			_, found, err := compiledPackageRepo.Find(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeTrue())
 
 			transitive.Fingerprint = "new-fake-dependency-fingerprint"
 
-			_, found, err = compiledPackageRepo.Find(pkg)
+			_, found, err = compiledPackageRepo.Find(pkg, stemcell)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(found).To(BeFalse())
 		})
@@ -135,7 +160,7 @@ var _ = Describe("CompiledPackageRepo", func() {
 					Name: "fake-package-name",
 				}
 
-				err := compiledPackageRepo.Save(pkg, record)
+				err := compiledPackageRepo.Save(pkg, stemcell, record)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Saving compiled package"))
 			})
@@ -143,13 +168,65 @@ var _ = Describe("CompiledPackageRepo", func() {
 
 		Context("when reading from index fails", func() {
 			It("returns error", func() {
-				err := compiledPackageRepo.Save(pkg, record)
+				err := compiledPackageRepo.Save(pkg, stemcell, record)
 				fakeFS.ReadFileError = errors.New("fake-error")
 
-				_, _, err = compiledPackageRepo.Find(pkg)
+				_, _, err = compiledPackageRepo.Find(pkg, Stemcell{Name: "other-fake-stemcell-name"})
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("Finding compiled package"))
 			})
 		})
 	})
+
+	Describe("Batch", func() {
+		var (
+			stemcell Stemcell
+			pkg1     birelpkg.Package
+			pkg2     birelpkg.Package
+		)
+
+		BeforeEach(func() {
+			stemcell = Stemcell{Name: "fake-stemcell-name", Version: "fake-stemcell-version", OS: "fake-stemcell-os"}
+			pkg1 = birelpkg.Package{Name: "fake-package-name-1", Fingerprint: "fake-package-fingerprint-1"}
+			pkg2 = birelpkg.Package{Name: "fake-package-name-2", Fingerprint: "fake-package-fingerprint-2"}
+		})
+
+		It("writes the compiled packages saved in the batch to the index at once", func() {
+			err := compiledPackageRepo.Batch(func() error {
+				err := compiledPackageRepo.Save(pkg1, stemcell, CompiledPackageRecord{BlobID: "fake-blob-id-1"})
+				Expect(err).ToNot(HaveOccurred())
+				err = compiledPackageRepo.Save(pkg2, stemcell, CompiledPackageRecord{BlobID: "fake-blob-id-2"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeFS.FileExists("/index_file")).To(BeFalse())
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeFS.RenameOldPaths).To(HaveLen(1))
+
+			otherRepo := NewCompiledPackageRepo(biindex.NewFileIndex("/index_file", fakeFS))
+			record, found, err := otherRepo.Find(pkg2, stemcell)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(record.BlobID).To(Equal("fake-blob-id-2"))
+		})
+
+		It("runs the function when the index does not batch saves", func() {
+			compiledPackageRepo = NewCompiledPackageRepo(biindex.NewInMemoryIndex())
+
+			err := compiledPackageRepo.Batch(func() error {
+				return compiledPackageRepo.Save(pkg1, stemcell, CompiledPackageRecord{BlobID: "fake-blob-id-1"})
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found, err := compiledPackageRepo.Find(pkg1, stemcell)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+		})
+
+		It("returns the error of the function", func() {
+			err := compiledPackageRepo.Batch(func() error { return errors.New("fake-batch-error") })
+			Expect(err).To(MatchError("fake-batch-error"))
+		})
+	})
 })
@@ -5,5 +5,10 @@ import (
 )
 
 type Compiler interface {
-	Compile(*birelpkg.Package) (CompiledPackageRecord, error)
+	Compile(*birelpkg.Package, Stemcell) (CompiledPackageRecord, error)
+}
+
+type Importer interface {
+	// Import records the package's pre-compiled archive as its compiled package, without compiling it
+	Import(*birelpkg.Package, Stemcell) (CompiledPackageRecord, error)
 }
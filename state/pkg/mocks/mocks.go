@@ -1,5 +1,5 @@
 // Automatically generated by MockGen. DO NOT EDIT!
-// Source: github.com/cloudfoundry/bosh-init/state/pkg (interfaces: Compiler,CompiledPackageRepo)
+// Source: github.com/cloudfoundry/bosh-init/state/pkg (interfaces: Compiler,Importer,CompiledPackageRepo)
 
 package mocks
 
@@ -30,15 +30,47 @@ func (_m *MockCompiler) EXPECT() *_MockCompilerRecorder {
 	return _m.recorder
 }
 
-func (_m *MockCompiler) Compile(_param0 *pkg.Package) (pkg0.CompiledPackageRecord, error) {
-	ret := _m.ctrl.Call(_m, "Compile", _param0)
+func (_m *MockCompiler) Compile(_param0 *pkg.Package, _param1 pkg0.Stemcell) (pkg0.CompiledPackageRecord, error) {
+	ret := _m.ctrl.Call(_m, "Compile", _param0, _param1)
 	ret0, _ := ret[0].(pkg0.CompiledPackageRecord)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockCompilerRecorder) Compile(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Compile", arg0)
+func (_mr *_MockCompilerRecorder) Compile(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Compile", arg0, arg1)
+}
+
+// Mock of Importer interface
+type MockImporter struct {
+	ctrl     *gomock.Controller
+	recorder *_MockImporterRecorder
+}
+
+// Recorder for MockImporter (not exported)
+type _MockImporterRecorder struct {
+	mock *MockImporter
+}
+
+func NewMockImporter(ctrl *gomock.Controller) *MockImporter {
+	mock := &MockImporter{ctrl: ctrl}
+	mock.recorder = &_MockImporterRecorder{mock}
+	return mock
+}
+
+func (_m *MockImporter) EXPECT() *_MockImporterRecorder {
+	return _m.recorder
+}
+
+func (_m *MockImporter) Import(_param0 *pkg.Package, _param1 pkg0.Stemcell) (pkg0.CompiledPackageRecord, error) {
+	ret := _m.ctrl.Call(_m, "Import", _param0, _param1)
+	ret0, _ := ret[0].(pkg0.CompiledPackageRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockImporterRecorder) Import(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Import", arg0, arg1)
 }
 
 // Mock of CompiledPackageRepo interface
@@ -62,24 +94,34 @@ func (_m *MockCompiledPackageRepo) EXPECT() *_MockCompiledPackageRepoRecorder {
 	return _m.recorder
 }
 
-func (_m *MockCompiledPackageRepo) Find(_param0 pkg.Package) (pkg0.CompiledPackageRecord, bool, error) {
-	ret := _m.ctrl.Call(_m, "Find", _param0)
+func (_m *MockCompiledPackageRepo) Batch(_param0 func() error) error {
+	ret := _m.ctrl.Call(_m, "Batch", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockCompiledPackageRepoRecorder) Batch(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Batch", arg0)
+}
+
+func (_m *MockCompiledPackageRepo) Find(_param0 pkg.Package, _param1 pkg0.Stemcell) (pkg0.CompiledPackageRecord, bool, error) {
+	ret := _m.ctrl.Call(_m, "Find", _param0, _param1)
 	ret0, _ := ret[0].(pkg0.CompiledPackageRecord)
 	ret1, _ := ret[1].(bool)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-func (_mr *_MockCompiledPackageRepoRecorder) Find(arg0 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Find", arg0)
+func (_mr *_MockCompiledPackageRepoRecorder) Find(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Find", arg0, arg1)
 }
 
-func (_m *MockCompiledPackageRepo) Save(_param0 pkg.Package, _param1 pkg0.CompiledPackageRecord) error {
-	ret := _m.ctrl.Call(_m, "Save", _param0, _param1)
+func (_m *MockCompiledPackageRepo) Save(_param0 pkg.Package, _param1 pkg0.Stemcell, _param2 pkg0.CompiledPackageRecord) error {
+	ret := _m.ctrl.Call(_m, "Save", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-func (_mr *_MockCompiledPackageRepoRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Save", arg0, arg1)
+func (_mr *_MockCompiledPackageRepoRecorder) Save(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Save", arg0, arg1, arg2)
 }
@@ -0,0 +1,21 @@
+package pkg
+
+import (
+	"fmt"
+)
+
+// Stemcell identifies the stemcell that packages are compiled against.
+// The zero value is used for packages compiled on the local machine.
+type Stemcell struct {
+	Name    string
+	Version string
+	OS      string
+}
+
+// OsAndVersion returns the '<os>/<version>' used by compiled releases to identify a stemcell
+func (s Stemcell) OsAndVersion() string {
+	if s.OS == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", s.OS, s.Version)
+}
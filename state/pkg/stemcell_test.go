@@ -0,0 +1,21 @@
+package pkg_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cloudfoundry/bosh-init/state/pkg"
+)
+
+var _ = Describe("Stemcell", func() {
+	Describe("OsAndVersion", func() {
+		It("returns the os and version", func() {
+			stemcell := Stemcell{Name: "fake-stemcell-name", Version: "2690", OS: "ubuntu-trusty"}
+			Expect(stemcell.OsAndVersion()).To(Equal("ubuntu-trusty/2690"))
+		})
+
+		It("returns empty for the local machine", func() {
+			Expect(Stemcell{}.OsAndVersion()).To(Equal(""))
+		})
+	})
+})
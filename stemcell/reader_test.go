@@ -28,6 +28,7 @@ var _ = Describe("Reader", func() {
 ---
 name: fake-stemcell-name
 version: '2690'
+operating_system: ubuntu-trusty
 cloud_properties:
   infrastructure: aws
   ami:
@@ -50,6 +51,7 @@ cloud_properties:
 			Manifest{
 				Name:      "fake-stemcell-name",
 				Version:   "2690",
+				OS:        "ubuntu-trusty",
 				ImagePath: "fake-extracted-path/image",
 				CloudProperties: biproperty.Map{
 					"infrastructure": "aws",
@@ -62,6 +64,19 @@ cloud_properties:
 			fs,
 		)
 		Expect(stemcell).To(Equal(expectedStemcell))
+		Expect(stemcell.OsAndVersion()).To(Equal("ubuntu-trusty/2690"))
+	})
+
+	Context("when the stemcell manifest does not specify an operating system", func() {
+		BeforeEach(func() {
+			fs.WriteFileString("fake-extracted-path/stemcell.MF", "---\nname: fake-stemcell-name\nversion: '2690'\n")
+		})
+
+		It("does not have an operating system and version", func() {
+			stemcell, err := stemcellReader.Read("fake-stemcell-path", "fake-extracted-path")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stemcell.OsAndVersion()).To(Equal(""))
+		})
 	})
 
 	Context("when extracting stemcell fails", func() {
@@ -9,6 +9,7 @@ import (
 
 type ExtractedStemcell interface {
 	Manifest() Manifest
+	OsAndVersion() string
 	Delete() error
 	fmt.Stringer
 }
@@ -33,6 +34,14 @@ func NewExtractedStemcell(
 
 func (s *extractedStemcell) Manifest() Manifest { return s.manifest }
 
+// OsAndVersion returns the '<operating system>/<version>' identifier used by compiled releases
+func (s *extractedStemcell) OsAndVersion() string {
+	if s.manifest.OS == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", s.manifest.OS, s.manifest.Version)
+}
+
 func (s *extractedStemcell) Delete() error {
 	return s.fs.RemoveAll(s.extractedPath)
 }
@@ -45,6 +54,7 @@ type Manifest struct {
 	ImagePath       string
 	Name            string
 	Version         string
+	OS              string
 	SHA1            string
 	CloudProperties biproperty.Map
 }
@@ -12,6 +12,7 @@ import (
 
 type jobEvaluationContext struct {
 	releaseJob       bireljob.Job
+	links            map[string]Link
 	jobProperties    biproperty.Map
 	globalProperties biproperty.Map
 	deploymentName   string
@@ -33,6 +34,9 @@ type RootContext struct {
 	GlobalProperties  biproperty.Map `json:"global_properties"`  // values from manifest's top-level properties
 	ClusterProperties biproperty.Map `json:"cluster_properties"` // values from manifest's jobs[].properties
 	DefaultProperties biproperty.Map `json:"default_properties"` // values from release's job's spec
+
+	// Usually is accessed with <%= link("name").p("property") %>
+	Links map[string]Link `json:"links"`
 }
 
 type jobContext struct {
@@ -47,13 +51,19 @@ type networkContext struct {
 
 func NewJobEvaluationContext(
 	releaseJob bireljob.Job,
+	links map[string]Link,
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
 	logger boshlog.Logger,
 ) bierbrenderer.TemplateEvaluationContext {
+	if links == nil {
+		links = map[string]Link{}
+	}
+
 	return jobEvaluationContext{
 		releaseJob:       releaseJob,
+		links:            links,
 		jobProperties:    jobProperties,
 		globalProperties: globalProperties,
 		deploymentName:   deploymentName,
@@ -73,6 +83,7 @@ func (ec jobEvaluationContext) MarshalJSON() ([]byte, error) {
 		GlobalProperties:  ec.globalProperties,
 		ClusterProperties: ec.jobProperties,
 		DefaultProperties: defaultProperties,
+		Links:             ec.links,
 	}
 
 	ec.logger.Debug(ec.logTag, "Marshalling context %#v", context)
@@ -22,6 +22,7 @@ var _ = Describe("JobEvaluationContext", func() {
 
 		releaseJob        bireljob.Job
 		clusterProperties biproperty.Map
+		links             map[string]Link
 		globalProperties  biproperty.Map
 	)
 	BeforeEach(func() {
@@ -47,6 +48,17 @@ var _ = Describe("JobEvaluationContext", func() {
 				"fake-global-property2": "value-from-global-properties",
 			},
 		}
+
+		links = map[string]Link{
+			"fake-link-name": {
+				Name:      "fake-link-name",
+				Type:      "fake-link-type",
+				Instances: []LinkInstance{{Name: "fake-provider-job-name", Index: 0}},
+				Properties: biproperty.Map{
+					"fake-link-property": "fake-link-property-value",
+				},
+			},
+		}
 	})
 
 	JustBeforeEach(func() {
@@ -54,6 +66,7 @@ var _ = Describe("JobEvaluationContext", func() {
 
 		jobEvaluationContext := NewJobEvaluationContext(
 			releaseJob,
+			links,
 			clusterProperties,
 			globalProperties,
 			"fake-deployment-name",
@@ -71,6 +84,10 @@ var _ = Describe("JobEvaluationContext", func() {
 		Expect(generatedContext.NetworkContexts["default"].IP).To(Equal(""))
 	})
 
+	It("it has the links consumed by the job", func() {
+		Expect(generatedContext.Links).To(Equal(links))
+	})
+
 	var erbRenderer erbrenderer.ERBRenderer
 	getValueFor := func(key string) string {
 		logger := boshlog.NewLogger(boshlog.LevelNone)
@@ -94,6 +111,7 @@ var _ = Describe("JobEvaluationContext", func() {
 
 		jobEvaluationContext := NewJobEvaluationContext(
 			releaseJob,
+			links,
 			clusterProperties,
 			globalProperties,
 			"fake-deployment-name",
@@ -10,16 +10,19 @@ import (
 type JobListRenderer interface {
 	Render(
 		releaseJobs []bireljob.Job,
+		linkOverrides map[string]LinkOverrides,
 		jobProperties biproperty.Map,
 		globalProperties biproperty.Map,
 		deploymentName string,
+		address string,
 	) (RenderedJobList, error)
 }
 
 type jobListRenderer struct {
-	jobRenderer JobRenderer
-	logger      boshlog.Logger
-	logTag      string
+	jobRenderer  JobRenderer
+	linkResolver LinkResolver
+	logger       boshlog.Logger
+	logTag       string
 }
 
 func NewJobListRenderer(
@@ -27,24 +30,32 @@ func NewJobListRenderer(
 	logger boshlog.Logger,
 ) JobListRenderer {
 	return &jobListRenderer{
-		jobRenderer: jobRenderer,
-		logger:      logger,
-		logTag:      "jobListRenderer",
+		jobRenderer:  jobRenderer,
+		linkResolver: NewLinkResolver(logger),
+		logger:       logger,
+		logTag:       "jobListRenderer",
 	}
 }
 
 func (r *jobListRenderer) Render(
 	releaseJobs []bireljob.Job,
+	linkOverrides map[string]LinkOverrides,
 	jobProperties biproperty.Map,
 	globalProperties biproperty.Map,
 	deploymentName string,
+	address string,
 ) (RenderedJobList, error) {
 	r.logger.Debug(r.logTag, "Rendering job list: deploymentName='%s' jobProperties=%#v globalProperties=%#v", deploymentName, jobProperties, globalProperties)
 	renderedJobList := NewRenderedJobList()
 
+	links, err := r.linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, address)
+	if err != nil {
+		return renderedJobList, bosherr.WrapError(err, "Resolving job links")
+	}
+
 	// render all the jobs' templates
 	for _, releaseJob := range releaseJobs {
-		renderedJob, err := r.jobRenderer.Render(releaseJob, jobProperties, globalProperties, deploymentName)
+		renderedJob, err := r.jobRenderer.Render(releaseJob, links[releaseJob.Name], jobProperties, globalProperties, deploymentName)
 		if err != nil {
 			defer renderedJobList.DeleteSilently()
 			return renderedJobList, bosherr.WrapErrorf(err, "Rendering templates for job '%s/%s'", releaseJob.Name, releaseJob.Fingerprint)
@@ -31,6 +31,7 @@ var _ = Describe("JobListRenderer", func() {
 		mockJobRenderer *mock_template.MockJobRenderer
 
 		releaseJobs      []bireljob.Job
+		linkOverrides    map[string]LinkOverrides
 		jobProperties    biproperty.Map
 		globalProperties biproperty.Map
 		deploymentName   string
@@ -53,6 +54,8 @@ var _ = Describe("JobListRenderer", func() {
 			{Name: "fake-release-job-name-1"},
 		}
 
+		linkOverrides = map[string]LinkOverrides{}
+
 		jobProperties = biproperty.Map{
 			"fake-key": "fake-job-value",
 		}
@@ -72,13 +75,13 @@ var _ = Describe("JobListRenderer", func() {
 	})
 
 	JustBeforeEach(func() {
-		mockJobRenderer.EXPECT().Render(releaseJobs[0], jobProperties, globalProperties, deploymentName).Return(renderedJobs[0], nil)
-		expectRender1 = mockJobRenderer.EXPECT().Render(releaseJobs[1], jobProperties, globalProperties, deploymentName).Return(renderedJobs[1], nil)
+		mockJobRenderer.EXPECT().Render(releaseJobs[0], map[string]Link{}, jobProperties, globalProperties, deploymentName).Return(renderedJobs[0], nil)
+		expectRender1 = mockJobRenderer.EXPECT().Render(releaseJobs[1], map[string]Link{}, jobProperties, globalProperties, deploymentName).Return(renderedJobs[1], nil)
 	})
 
 	Describe("Render", func() {
 		It("returns a new RenderedJobList with all the RenderedJobs", func() {
-			renderedJobList, err := jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+			renderedJobList, err := jobListRenderer.Render(releaseJobs, linkOverrides, jobProperties, globalProperties, deploymentName, "fake-address")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(renderedJobList.All()).To(Equal([]RenderedJob{
 				renderedJobs[0],
@@ -94,7 +97,7 @@ var _ = Describe("JobListRenderer", func() {
 			It("returns an error and cleans up any sucessfully rendered jobs", func() {
 				renderedJobs[0].EXPECT().DeleteSilently()
 
-				_, err := jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+				_, err := jobListRenderer.Render(releaseJobs, linkOverrides, jobProperties, globalProperties, deploymentName, "fake-address")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-render-error"))
 			})
@@ -22,6 +22,7 @@ var _ = Describe("JobRenderer", func() {
 		jobRenderer      JobRenderer
 		fakeERBRenderer  *fakebirender.FakeERBRenderer
 		job              bireljob.Job
+		links            map[string]Link
 		context          bierbrenderer.TemplateEvaluationContext
 		fs               *fakesys.FakeFileSystem
 		jobProperties    biproperty.Map
@@ -41,6 +42,8 @@ var _ = Describe("JobRenderer", func() {
 			"fake-property-key": "fake-global-property-value",
 		}
 
+		links = map[string]Link{}
+
 		job = bireljob.Job{
 			Templates: map[string]string{
 				"director.yml.erb": "config/director.yml",
@@ -50,7 +53,7 @@ var _ = Describe("JobRenderer", func() {
 
 		logger := boshlog.NewLogger(boshlog.LevelNone)
 
-		context = NewJobEvaluationContext(job, jobProperties, globalProperties, "fake-deployment-name", logger)
+		context = NewJobEvaluationContext(job, links, jobProperties, globalProperties, "fake-deployment-name", logger)
 
 		fakeERBRenderer = fakebirender.NewFakeERBRender()
 
@@ -81,7 +84,7 @@ var _ = Describe("JobRenderer", func() {
 
 	Describe("Render", func() {
 		It("renders job templates", func() {
-			renderedjob, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name")
+			renderedjob, err := jobRenderer.Render(job, links, jobProperties, globalProperties, "fake-deployment-name")
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeERBRenderer.RenderInputs).To(Equal([]fakebirender.RenderInput{
@@ -109,7 +112,7 @@ var _ = Describe("JobRenderer", func() {
 			})
 
 			It("returns an error", func() {
-				_, err := jobRenderer.Render(job, jobProperties, globalProperties, "fake-deployment-name")
+				_, err := jobRenderer.Render(job, links, jobProperties, globalProperties, "fake-deployment-name")
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-template-render-error"))
 			})
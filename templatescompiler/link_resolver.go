@@ -0,0 +1,218 @@
+package templatescompiler
+
+import (
+	"sort"
+	"strings"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+// LinkOverrides are the link settings of a release job in the deployment manifest
+type LinkOverrides struct {
+	// Consumes maps consumed link names to the names of the provided links to use
+	Consumes map[string]string
+	// Provides maps provided link names to the names they are provided as
+	Provides map[string]string
+}
+
+// Link is a link provided by one job and consumed by another, as exposed to ERB templates with link(name)
+type Link struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Instances  []LinkInstance `json:"instances"`
+	Properties biproperty.Map `json:"properties"`
+}
+
+type LinkInstance struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+	// Address is the IP of the default network of the instance of the providing job
+	Address string `json:"address"`
+}
+
+type LinkResolver interface {
+	// Resolve returns the links consumed by each of the release jobs (by job name and link name),
+	// using the links provided by the same release jobs, which all run on the instance with the given address.
+	Resolve(
+		releaseJobs []bireljob.Job,
+		linkOverrides map[string]LinkOverrides,
+		jobProperties biproperty.Map,
+		globalProperties biproperty.Map,
+		address string,
+	) (map[string]map[string]Link, error)
+}
+
+type linkResolver struct {
+	logger boshlog.Logger
+	logTag string
+}
+
+type providedLink struct {
+	jobName string
+	link    Link
+}
+
+func NewLinkResolver(logger boshlog.Logger) LinkResolver {
+	return &linkResolver{
+		logger: logger,
+		logTag: "linkResolver",
+	}
+}
+
+func (r *linkResolver) Resolve(
+	releaseJobs []bireljob.Job,
+	linkOverrides map[string]LinkOverrides,
+	jobProperties biproperty.Map,
+	globalProperties biproperty.Map,
+	address string,
+) (map[string]map[string]Link, error) {
+	errs := []error{}
+
+	providedLinks := []providedLink{}
+	for _, releaseJob := range releaseJobs {
+		overrides := linkOverrides[releaseJob.Name]
+		for overriddenName := range overrides.Provides {
+			if !r.providesLink(releaseJob, overriddenName) {
+				errs = append(errs, bosherr.Errorf("Job '%s' does not provide link '%s'", releaseJob.Name, overriddenName))
+			}
+		}
+
+		for _, provided := range releaseJob.Provides {
+			name := provided.Name
+			if alias, found := overrides.Provides[provided.Name]; found {
+				name = alias
+			}
+
+			providedLinks = append(providedLinks, providedLink{
+				jobName: releaseJob.Name,
+				link: Link{
+					Name:       name,
+					Type:       provided.Type,
+					Instances:  []LinkInstance{{Name: releaseJob.Name, Index: 0, Address: address}},
+					Properties: r.linkProperties(releaseJob, provided.Properties, jobProperties, globalProperties),
+				},
+			})
+		}
+	}
+
+	resolvedLinks := map[string]map[string]Link{}
+	for _, releaseJob := range releaseJobs {
+		overrides := linkOverrides[releaseJob.Name]
+		for overriddenName := range overrides.Consumes {
+			if !r.consumesLink(releaseJob, overriddenName) {
+				errs = append(errs, bosherr.Errorf("Job '%s' does not consume link '%s'", releaseJob.Name, overriddenName))
+			}
+		}
+
+		jobLinks := map[string]Link{}
+		for _, consumed := range releaseJob.Consumes {
+			from, hasFrom := overrides.Consumes[consumed.Name]
+
+			candidates := []providedLink{}
+			for _, provided := range providedLinks {
+				if provided.link.Type != consumed.Type {
+					continue
+				}
+				if hasFrom && provided.link.Name != from {
+					continue
+				}
+				candidates = append(candidates, provided)
+			}
+
+			switch {
+			case len(candidates) == 1:
+				jobLinks[consumed.Name] = candidates[0].link
+			case len(candidates) == 0 && hasFrom:
+				errs = append(errs, bosherr.Errorf("Link '%s' of type '%s' consumed by job '%s' from '%s' is not provided by any job", consumed.Name, consumed.Type, releaseJob.Name, from))
+			case len(candidates) == 0 && !consumed.Optional:
+				errs = append(errs, bosherr.Errorf("Link '%s' of type '%s' consumed by job '%s' is not provided by any job", consumed.Name, consumed.Type, releaseJob.Name))
+			case len(candidates) > 1:
+				providers := []string{}
+				for _, candidate := range candidates {
+					providers = append(providers, candidate.jobName+"."+candidate.link.Name)
+				}
+				sort.Strings(providers)
+				errs = append(errs, bosherr.Errorf("Link '%s' of type '%s' consumed by job '%s' is provided by multiple jobs (%s). Choose one with 'consumes.%s.from'", consumed.Name, consumed.Type, releaseJob.Name, strings.Join(providers, ", "), consumed.Name))
+			}
+		}
+		resolvedLinks[releaseJob.Name] = jobLinks
+	}
+
+	if len(errs) > 0 {
+		return nil, bosherr.NewMultiError(errs...)
+	}
+
+	r.logger.Debug(r.logTag, "Resolved links: %#v", resolvedLinks)
+
+	return resolvedLinks, nil
+}
+
+// linkProperties returns the values of the named properties, the same way they are looked up with p(name) in ERB templates:
+// job properties override global properties, which override the defaults of the providing release job.
+func (r *linkResolver) linkProperties(releaseJob bireljob.Job, propertyNames []string, jobProperties, globalProperties biproperty.Map) biproperty.Map {
+	properties := biproperty.Map{}
+	for _, propertyName := range propertyNames {
+		keys := strings.Split(propertyName, ".")
+
+		value, found := lookupProperty(jobProperties, keys)
+		if !found {
+			value, found = lookupProperty(globalProperties, keys)
+		}
+		if !found {
+			value = releaseJob.Properties[propertyName].Default
+		}
+
+		setProperty(properties, keys, value)
+	}
+	return properties
+}
+
+func (r *linkResolver) providesLink(releaseJob bireljob.Job, name string) bool {
+	for _, provided := range releaseJob.Provides {
+		if provided.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *linkResolver) consumesLink(releaseJob bireljob.Job, name string) bool {
+	for _, consumed := range releaseJob.Consumes {
+		if consumed.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupProperty(properties biproperty.Map, keys []string) (biproperty.Property, bool) {
+	var value biproperty.Property = properties
+	for _, key := range keys {
+		propertyMap, ok := value.(biproperty.Map)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = propertyMap[key]
+		if !ok || value == nil {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func setProperty(properties biproperty.Map, keys []string, value biproperty.Property) {
+	propertyMap := properties
+	for _, key := range keys[:len(keys)-1] {
+		nestedMap, ok := propertyMap[key].(biproperty.Map)
+		if !ok {
+			nestedMap = biproperty.Map{}
+			propertyMap[key] = nestedMap
+		}
+		propertyMap = nestedMap
+	}
+	propertyMap[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,191 @@
+package templatescompiler_test
+
+import (
+	. "github.com/cloudfoundry/bosh-init/templatescompiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bireljob "github.com/cloudfoundry/bosh-init/release/job"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+)
+
+var _ = Describe("LinkResolver", func() {
+	var (
+		linkResolver LinkResolver
+
+		releaseJobs      []bireljob.Job
+		linkOverrides    map[string]LinkOverrides
+		jobProperties    biproperty.Map
+		globalProperties biproperty.Map
+	)
+
+	BeforeEach(func() {
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		linkResolver = NewLinkResolver(logger)
+
+		releaseJobs = []bireljob.Job{
+			{
+				Name: "director",
+				Consumes: []bireljob.ConsumedLink{
+					{Name: "db", Type: "postgres"},
+				},
+			},
+			{
+				Name: "postgres",
+				Provides: []bireljob.ProvidedLink{
+					{Name: "db", Type: "postgres", Properties: []string{"postgres.port", "postgres.user"}},
+				},
+				Properties: map[string]bireljob.PropertyDefinition{
+					"postgres.port": {Default: 5432},
+					"postgres.user": {Default: "fake-default-user"},
+				},
+			},
+		}
+
+		linkOverrides = map[string]LinkOverrides{}
+
+		jobProperties = biproperty.Map{
+			"postgres": biproperty.Map{
+				"user": "fake-job-user",
+			},
+		}
+
+		globalProperties = biproperty.Map{
+			"postgres": biproperty.Map{
+				"user": "fake-global-user",
+				"port": 5433,
+			},
+		}
+	})
+
+	It("resolves consumed links by type", func() {
+		links, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(links).To(Equal(map[string]map[string]Link{
+			"director": {
+				"db": {
+					Name:      "db",
+					Type:      "postgres",
+					Instances: []LinkInstance{{Name: "postgres", Index: 0, Address: "fake-address"}},
+					Properties: biproperty.Map{
+						"postgres": biproperty.Map{
+							"port": 5433,
+							"user": "fake-job-user",
+						},
+					},
+				},
+			},
+			"postgres": {},
+		}))
+	})
+
+	It("uses the release job defaults for link properties that are not set", func() {
+		globalProperties = biproperty.Map{}
+
+		links, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(links["director"]["db"].Properties).To(Equal(biproperty.Map{
+			"postgres": biproperty.Map{
+				"port": 5432,
+				"user": "fake-job-user",
+			},
+		}))
+	})
+
+	Context("when the consumed link is not provided", func() {
+		BeforeEach(func() {
+			releaseJobs = releaseJobs[:1]
+		})
+
+		It("returns an error", func() {
+			_, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Link 'db' of type 'postgres' consumed by job 'director' is not provided by any job"))
+		})
+
+		It("skips optional links", func() {
+			releaseJobs[0].Consumes[0].Optional = true
+
+			links, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(links["director"]).To(BeEmpty())
+		})
+	})
+
+	Context("when multiple jobs provide a link of the consumed type", func() {
+		BeforeEach(func() {
+			releaseJobs = append(releaseJobs, bireljob.Job{
+				Name: "other-postgres",
+				Provides: []bireljob.ProvidedLink{
+					{Name: "other-db", Type: "postgres"},
+				},
+			})
+		})
+
+		It("returns an error", func() {
+			_, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Link 'db' of type 'postgres' consumed by job 'director' is provided by multiple jobs (other-postgres.other-db, postgres.db). Choose one with 'consumes.db.from'"))
+		})
+
+		It("uses the link chosen with from", func() {
+			linkOverrides["director"] = LinkOverrides{
+				Consumes: map[string]string{"db": "other-db"},
+			}
+
+			links, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(links["director"]["db"].Name).To(Equal("other-db"))
+			Expect(links["director"]["db"].Instances).To(Equal([]LinkInstance{{Name: "other-postgres", Index: 0, Address: "fake-address"}}))
+		})
+
+		It("uses the link provided with the alias chosen with from", func() {
+			linkOverrides["postgres"] = LinkOverrides{
+				Provides: map[string]string{"db": "main-db"},
+			}
+			linkOverrides["director"] = LinkOverrides{
+				Consumes: map[string]string{"db": "main-db"},
+			}
+
+			links, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(links["director"]["db"].Name).To(Equal("main-db"))
+			Expect(links["director"]["db"].Instances).To(Equal([]LinkInstance{{Name: "postgres", Index: 0, Address: "fake-address"}}))
+		})
+	})
+
+	Context("when from does not match any provided link", func() {
+		BeforeEach(func() {
+			linkOverrides["director"] = LinkOverrides{
+				Consumes: map[string]string{"db": "missing-db"},
+			}
+		})
+
+		It("returns an error", func() {
+			_, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Link 'db' of type 'postgres' consumed by job 'director' from 'missing-db' is not provided by any job"))
+		})
+	})
+
+	Context("when the overrides refer to links the jobs do not have", func() {
+		BeforeEach(func() {
+			linkOverrides["director"] = LinkOverrides{
+				Consumes: map[string]string{"cache": "redis"},
+			}
+			linkOverrides["postgres"] = LinkOverrides{
+				Provides: map[string]string{"cache": "redis"},
+			}
+		})
+
+		It("returns an error", func() {
+			_, err := linkResolver.Resolve(releaseJobs, linkOverrides, jobProperties, globalProperties, "fake-address")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Job 'postgres' does not provide link 'cache'"))
+			Expect(err.Error()).To(ContainSubstring("Job 'director' does not consume link 'cache'"))
+		})
+	})
+})
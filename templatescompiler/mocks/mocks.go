@@ -1,5 +1,5 @@
 // Automatically generated by MockGen. DO NOT EDIT!
-// Source: github.com/cloudfoundry/bosh-init/templatescompiler (interfaces: JobRenderer,JobListRenderer,RenderedJob,RenderedJobList,RenderedJobListArchive,RenderedJobListCompressor)
+// Source: github.com/cloudfoundry/bosh-init/templatescompiler (interfaces: JobRenderer,JobListRenderer,RenderedJob,RenderedJobList,RenderedJobListArchive,RenderedJobListCompressor,RenderedJobListArchiveRepo,RenderedJobListDiffer)
 
 package mocks
 
@@ -31,15 +31,15 @@ func (_m *MockJobRenderer) EXPECT() *_MockJobRendererRecorder {
 	return _m.recorder
 }
 
-func (_m *MockJobRenderer) Render(_param0 job.Job, _param1 property.Map, _param2 property.Map, _param3 string) (templatescompiler.RenderedJob, error) {
-	ret := _m.ctrl.Call(_m, "Render", _param0, _param1, _param2, _param3)
+func (_m *MockJobRenderer) Render(_param0 job.Job, _param1 map[string]templatescompiler.Link, _param2 property.Map, _param3 property.Map, _param4 string) (templatescompiler.RenderedJob, error) {
+	ret := _m.ctrl.Call(_m, "Render", _param0, _param1, _param2, _param3, _param4)
 	ret0, _ := ret[0].(templatescompiler.RenderedJob)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockJobRendererRecorder) Render(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Render", arg0, arg1, arg2, arg3)
+func (_mr *_MockJobRendererRecorder) Render(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Render", arg0, arg1, arg2, arg3, arg4)
 }
 
 // Mock of JobListRenderer interface
@@ -63,15 +63,15 @@ func (_m *MockJobListRenderer) EXPECT() *_MockJobListRendererRecorder {
 	return _m.recorder
 }
 
-func (_m *MockJobListRenderer) Render(_param0 []job.Job, _param1 property.Map, _param2 property.Map, _param3 string) (templatescompiler.RenderedJobList, error) {
-	ret := _m.ctrl.Call(_m, "Render", _param0, _param1, _param2, _param3)
+func (_m *MockJobListRenderer) Render(_param0 []job.Job, _param1 map[string]templatescompiler.LinkOverrides, _param2 property.Map, _param3 property.Map, _param4 string, _param5 string) (templatescompiler.RenderedJobList, error) {
+	ret := _m.ctrl.Call(_m, "Render", _param0, _param1, _param2, _param3, _param4, _param5)
 	ret0, _ := ret[0].(templatescompiler.RenderedJobList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-func (_mr *_MockJobListRendererRecorder) Render(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
-	return _mr.mock.ctrl.RecordCall(_mr.mock, "Render", arg0, arg1, arg2, arg3)
+func (_mr *_MockJobListRendererRecorder) Render(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Render", arg0, arg1, arg2, arg3, arg4, arg5)
 }
 
 // Mock of RenderedJob interface
@@ -300,3 +300,97 @@ func (_m *MockRenderedJobListCompressor) Compress(_param0 templatescompiler.Rend
 func (_mr *_MockRenderedJobListCompressorRecorder) Compress(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Compress", arg0)
 }
+
+// Mock of RenderedJobListArchiveRepo interface
+type MockRenderedJobListArchiveRepo struct {
+	ctrl     *gomock.Controller
+	recorder *_MockRenderedJobListArchiveRepoRecorder
+}
+
+// Recorder for MockRenderedJobListArchiveRepo (not exported)
+type _MockRenderedJobListArchiveRepoRecorder struct {
+	mock *MockRenderedJobListArchiveRepo
+}
+
+func NewMockRenderedJobListArchiveRepo(ctrl *gomock.Controller) *MockRenderedJobListArchiveRepo {
+	mock := &MockRenderedJobListArchiveRepo{ctrl: ctrl}
+	mock.recorder = &_MockRenderedJobListArchiveRepoRecorder{mock}
+	return mock
+}
+
+func (_m *MockRenderedJobListArchiveRepo) EXPECT() *_MockRenderedJobListArchiveRepoRecorder {
+	return _m.recorder
+}
+
+func (_m *MockRenderedJobListArchiveRepo) CommitPending() error {
+	ret := _m.ctrl.Call(_m, "CommitPending")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockRenderedJobListArchiveRepoRecorder) CommitPending() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "CommitPending")
+}
+
+func (_m *MockRenderedJobListArchiveRepo) FindLast() (templatescompiler.AppliedRenderedJobListArchive, bool) {
+	ret := _m.ctrl.Call(_m, "FindLast")
+	ret0, _ := ret[0].(templatescompiler.AppliedRenderedJobListArchive)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+func (_mr *_MockRenderedJobListArchiveRepoRecorder) FindLast() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FindLast")
+}
+
+func (_m *MockRenderedJobListArchiveRepo) SavePending(_param0 templatescompiler.RenderedJobListArchive, _param1 []string) error {
+	ret := _m.ctrl.Call(_m, "SavePending", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockRenderedJobListArchiveRepoRecorder) SavePending(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SavePending", arg0, arg1)
+}
+
+// Mock of RenderedJobListDiffer interface
+type MockRenderedJobListDiffer struct {
+	ctrl     *gomock.Controller
+	recorder *_MockRenderedJobListDifferRecorder
+}
+
+// Recorder for MockRenderedJobListDiffer (not exported)
+type _MockRenderedJobListDifferRecorder struct {
+	mock *MockRenderedJobListDiffer
+}
+
+func NewMockRenderedJobListDiffer(ctrl *gomock.Controller) *MockRenderedJobListDiffer {
+	mock := &MockRenderedJobListDiffer{ctrl: ctrl}
+	mock.recorder = &_MockRenderedJobListDifferRecorder{mock}
+	return mock
+}
+
+func (_m *MockRenderedJobListDiffer) EXPECT() *_MockRenderedJobListDifferRecorder {
+	return _m.recorder
+}
+
+func (_m *MockRenderedJobListDiffer) Diff(_param0 templatescompiler.AppliedRenderedJobListArchive, _param1 []templatescompiler.RenderedJob, _param2 []string) (string, error) {
+	ret := _m.ctrl.Call(_m, "Diff", _param0, _param1, _param2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockRenderedJobListDifferRecorder) Diff(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Diff", arg0, arg1, arg2)
+}
+
+func (_m *MockRenderedJobListDiffer) SecretValues(_param0 []templatescompiler.RenderedJob, _param1 property.Map, _param2 property.Map) []string {
+	ret := _m.ctrl.Call(_m, "SecretValues", _param0, _param1, _param2)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+func (_mr *_MockRenderedJobListDifferRecorder) SecretValues(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SecretValues", arg0, arg1, arg2)
+}
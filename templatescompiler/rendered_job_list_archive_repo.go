@@ -0,0 +1,177 @@
+package templatescompiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// AppliedRenderedJobListArchive is an archive of the rendered job templates applied to a deployment,
+// with a JSON file of the digests of the secret values the templates were rendered with, to mask them when comparing.
+// The secret values themselves are not kept.
+type AppliedRenderedJobListArchive struct {
+	Path             string
+	SecretValuesPath string
+}
+
+// RenderedJobListArchiveRepo keeps copies of the rendered job templates applied to a deployment,
+// so that they can be compared with newly rendered templates.
+type RenderedJobListArchiveRepo interface {
+	// SavePending keeps a copy of an archive that is about to be applied & of its secret values,
+	// replacing the previous pending archive.
+	SavePending(archive RenderedJobListArchive, secretValues []string) error
+
+	// CommitPending records the pending archive as the last applied archive,
+	// deleting the oldest applied archives beyond the retention.
+	CommitPending() error
+
+	// FindLast returns the last applied archive.
+	FindLast() (archive AppliedRenderedJobListArchive, found bool)
+}
+
+type renderedJobListArchiveRepo struct {
+	path      string
+	retention int
+	fs        boshsys.FileSystem
+	logger    boshlog.Logger
+	logTag    string
+}
+
+// NewRenderedJobListArchiveRepo returns a repo that stores the archives of a deployment in the dir at path.
+// The retention is the number of applied archives kept.
+func NewRenderedJobListArchiveRepo(path string, retention int, fs boshsys.FileSystem, logger boshlog.Logger) RenderedJobListArchiveRepo {
+	return &renderedJobListArchiveRepo{
+		path:      path,
+		retention: retention,
+		fs:        fs,
+		logger:    logger,
+		logTag:    "renderedJobListArchiveRepo",
+	}
+}
+
+func (r *renderedJobListArchiveRepo) SavePending(archive RenderedJobListArchive, secretValues []string) error {
+	err := r.fs.MkdirAll(r.path, os.ModePerm)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Creating rendered job list archive dir '%s'", r.path)
+	}
+
+	pending := r.pendingArchive()
+	r.logger.Debug(r.logTag, "Saving pending rendered job list archive '%s' to '%s'", archive.Path(), pending.Path)
+
+	err = r.fs.CopyFile(archive.Path(), pending.Path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying rendered job list archive '%s' to '%s'", archive.Path(), pending.Path)
+	}
+
+	secretValueDigests, err := NewSecretValueDigests(secretValues)
+	if err != nil {
+		return err
+	}
+
+	secretValuesBytes, err := json.Marshal(secretValueDigests)
+	if err != nil {
+		return bosherr.WrapError(err, "Marshalling secret value digests")
+	}
+
+	err = r.fs.WriteFile(pending.SecretValuesPath, secretValuesBytes)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Writing secret values file '%s'", pending.SecretValuesPath)
+	}
+
+	return nil
+}
+
+func (r *renderedJobListArchiveRepo) CommitPending() error {
+	pending := r.pendingArchive()
+	if !r.exists(pending) {
+		return bosherr.Error("No pending rendered job list archive")
+	}
+
+	// shift the applied archives, from the oldest to the newest, dropping the ones beyond the retention
+	for i := r.retention - 1; i >= 0; i-- {
+		applied := r.appliedArchive(i)
+		if !r.fs.FileExists(applied.Path) {
+			continue
+		}
+
+		if i == r.retention-1 {
+			err := r.remove(applied)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := r.rename(applied, r.appliedArchive(i+1))
+		if err != nil {
+			return err
+		}
+	}
+
+	last := r.appliedArchive(0)
+	r.logger.Debug(r.logTag, "Recording pending rendered job list archive '%s' as applied '%s'", pending.Path, last.Path)
+
+	return r.rename(pending, last)
+}
+
+func (r *renderedJobListArchiveRepo) FindLast() (AppliedRenderedJobListArchive, bool) {
+	last := r.appliedArchive(0)
+	if !r.exists(last) {
+		return AppliedRenderedJobListArchive{}, false
+	}
+	return last, true
+}
+
+// exists returns whether both files of the archive exist, as archives saved without secret values cannot be masked
+func (r *renderedJobListArchiveRepo) exists(archive AppliedRenderedJobListArchive) bool {
+	return r.fs.FileExists(archive.Path) && r.fs.FileExists(archive.SecretValuesPath)
+}
+
+func (r *renderedJobListArchiveRepo) remove(archive AppliedRenderedJobListArchive) error {
+	for _, path := range []string{archive.Path, archive.SecretValuesPath} {
+		err := r.fs.RemoveAll(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Deleting rendered job list archive file '%s'", path)
+		}
+	}
+	return nil
+}
+
+func (r *renderedJobListArchiveRepo) rename(archive, newArchive AppliedRenderedJobListArchive) error {
+	err := r.fs.Rename(archive.Path, newArchive.Path)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming rendered job list archive '%s' to '%s'", archive.Path, newArchive.Path)
+	}
+
+	// archives applied by earlier versions have no secret values file
+	if !r.fs.FileExists(archive.SecretValuesPath) {
+		return nil
+	}
+
+	err = r.fs.Rename(archive.SecretValuesPath, newArchive.SecretValuesPath)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Renaming secret values file '%s' to '%s'", archive.SecretValuesPath, newArchive.SecretValuesPath)
+	}
+
+	return nil
+}
+
+func (r *renderedJobListArchiveRepo) pendingArchive() AppliedRenderedJobListArchive {
+	return AppliedRenderedJobListArchive{
+		Path:             filepath.Join(r.path, "pending.tgz"),
+		SecretValuesPath: filepath.Join(r.path, "pending-secrets.json"),
+	}
+}
+
+// appliedArchive returns the paths of an applied archive, by age (0 is the last applied)
+func (r *renderedJobListArchiveRepo) appliedArchive(age int) AppliedRenderedJobListArchive {
+	return AppliedRenderedJobListArchive{
+		Path:             filepath.Join(r.path, fmt.Sprintf("applied-%d.tgz", age)),
+		SecretValuesPath: filepath.Join(r.path, fmt.Sprintf("applied-%d-secrets.json", age)),
+	}
+}
@@ -0,0 +1,155 @@
+package templatescompiler_test
+
+import (
+	"encoding/json"
+
+	. "github.com/cloudfoundry/bosh-init/templatescompiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.google.com/p/gomock/gomock"
+	mock_template "github.com/cloudfoundry/bosh-init/templatescompiler/mocks"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	fakeboshsys "github.com/cloudfoundry/bosh-utils/system/fakes"
+)
+
+var _ = Describe("RenderedJobListArchiveRepo", func() {
+	var (
+		mockCtrl *gomock.Controller
+		fs       *fakeboshsys.FakeFileSystem
+
+		mockArchive *mock_template.MockRenderedJobListArchive
+
+		archiveRepo RenderedJobListArchiveRepo
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+
+		fs = fakeboshsys.NewFakeFileSystem()
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+
+		mockArchive = mock_template.NewMockRenderedJobListArchive(mockCtrl)
+		mockArchive.EXPECT().Path().Return("/fake-archive-path").AnyTimes()
+		fs.WriteFileString("/fake-archive-path", "fake-archive-content")
+
+		archiveRepo = NewRenderedJobListArchiveRepo("/fake-path", 2, fs, logger)
+	})
+
+	var readSecretValueDigests = func(path string) SecretValueDigests {
+		contents, err := fs.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		var secretValueDigests SecretValueDigests
+		Expect(json.Unmarshal(contents, &secretValueDigests)).To(Succeed())
+		return secretValueDigests
+	}
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("SavePending", func() {
+		It("copies the archive & writes the digests of its secret values into the dir of the deployment", func() {
+			err := archiveRepo.SavePending(mockArchive, []string{"fake-secret-value"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.ReadFileString("/fake-path/pending.tgz")).To(Equal("fake-archive-content"))
+			Expect(readSecretValueDigests("/fake-path/pending-secrets.json").Mask("x fake-secret-value y")).To(Equal("x <redacted> y"))
+		})
+
+		It("does not write the secret values themselves", func() {
+			err := archiveRepo.SavePending(mockArchive, []string{"fake-secret-value"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fs.ReadFileString("/fake-path/pending-secrets.json")).ToNot(ContainSubstring("fake-secret-value"))
+		})
+
+		It("does not record the archive as applied", func() {
+			err := archiveRepo.SavePending(mockArchive, []string{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, found := archiveRepo.FindLast()
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns an error when copying fails", func() {
+			fs.CopyFileError = bosherr.Error("fake-copy-error")
+
+			err := archiveRepo.SavePending(mockArchive, []string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-copy-error"))
+		})
+
+		It("returns an error when writing the secret values fails", func() {
+			fs.WriteFileError = bosherr.Error("fake-write-error")
+
+			err := archiveRepo.SavePending(mockArchive, []string{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("fake-write-error"))
+		})
+	})
+
+	Describe("CommitPending", func() {
+		var commit = func(content string) {
+			fs.WriteFileString("/fake-archive-path", content)
+
+			err := archiveRepo.SavePending(mockArchive, []string{content + "-secret"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = archiveRepo.CommitPending()
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		It("records the pending archive as the last applied archive", func() {
+			commit("fake-archive-content-1")
+
+			archive, found := archiveRepo.FindLast()
+			Expect(found).To(BeTrue())
+			Expect(archive).To(Equal(AppliedRenderedJobListArchive{
+				Path:             "/fake-path/applied-0.tgz",
+				SecretValuesPath: "/fake-path/applied-0-secrets.json",
+			}))
+			Expect(fs.ReadFileString(archive.Path)).To(Equal("fake-archive-content-1"))
+			Expect(readSecretValueDigests(archive.SecretValuesPath).Mask("fake-archive-content-1-secret")).To(Equal("<redacted>"))
+			Expect(fs.FileExists("/fake-path/pending.tgz")).To(BeFalse())
+			Expect(fs.FileExists("/fake-path/pending-secrets.json")).To(BeFalse())
+		})
+
+		It("keeps the previously applied archives up to the retention", func() {
+			commit("fake-archive-content-1")
+			commit("fake-archive-content-2")
+			commit("fake-archive-content-3")
+
+			Expect(fs.ReadFileString("/fake-path/applied-0.tgz")).To(Equal("fake-archive-content-3"))
+			Expect(readSecretValueDigests("/fake-path/applied-0-secrets.json").Mask("fake-archive-content-3-secret")).To(Equal("<redacted>"))
+			Expect(fs.ReadFileString("/fake-path/applied-1.tgz")).To(Equal("fake-archive-content-2"))
+			Expect(readSecretValueDigests("/fake-path/applied-1-secrets.json").Mask("fake-archive-content-2-secret")).To(Equal("<redacted>"))
+			Expect(fs.FileExists("/fake-path/applied-2.tgz")).To(BeFalse())
+			Expect(fs.FileExists("/fake-path/applied-2-secrets.json")).To(BeFalse())
+		})
+
+		It("returns an error when there is no pending archive", func() {
+			err := archiveRepo.CommitPending()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("No pending rendered job list archive"))
+		})
+	})
+
+	Describe("FindLast", func() {
+		It("returns false when no archive was applied", func() {
+			_, found := archiveRepo.FindLast()
+			Expect(found).To(BeFalse())
+		})
+
+		It("returns false when the applied archive has no secret values file, as its secrets could not be masked", func() {
+			fs.WriteFileString("/fake-path/applied-0.tgz", "fake-archive-content")
+
+			_, found := archiveRepo.FindLast()
+			Expect(found).To(BeFalse())
+		})
+	})
+})
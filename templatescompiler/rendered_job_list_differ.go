@@ -0,0 +1,253 @@
+package templatescompiler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	bidiff "github.com/cloudfoundry/bosh-init/common/diff"
+	biproperty "github.com/cloudfoundry/bosh-init/common/property"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshcmd "github.com/cloudfoundry/bosh-utils/fileutil"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshsys "github.com/cloudfoundry/bosh-utils/system"
+)
+
+// secretMask replaces the values of secret properties in diffs
+const secretMask = "<redacted>"
+
+type RenderedJobListDiffer interface {
+	// Diff returns the unified diff of the rendered template files of each job, from the files in the last applied archive
+	// to the files of the rendered jobs. It returns an empty string if the files are the same.
+	// The secret values, both the ones the archive was rendered with and the given ones, are masked.
+	Diff(lastArchive AppliedRenderedJobListArchive, renderedJobs []RenderedJob, secretValues []string) (string, error)
+
+	// SecretValues returns the values of the properties that the jobs declare as secret,
+	// using the job & global properties the jobs are rendered with.
+	SecretValues(renderedJobs []RenderedJob, jobProperties, globalProperties biproperty.Map) []string
+}
+
+type renderedJobListDiffer struct {
+	fs         boshsys.FileSystem
+	compressor boshcmd.Compressor
+	logger     boshlog.Logger
+	logTag     string
+}
+
+func NewRenderedJobListDiffer(fs boshsys.FileSystem, compressor boshcmd.Compressor, logger boshlog.Logger) RenderedJobListDiffer {
+	return &renderedJobListDiffer{
+		fs:         fs,
+		compressor: compressor,
+		logger:     logger,
+		logTag:     "renderedJobListDiffer",
+	}
+}
+
+func (d *renderedJobListDiffer) Diff(lastArchive AppliedRenderedJobListArchive, renderedJobs []RenderedJob, secretValues []string) (string, error) {
+	// mask the secrets the last archive was rendered with too, so that changed secrets are not shown either
+	lastSecretValueDigests, err := d.readSecretValueDigests(lastArchive.SecretValuesPath)
+	if err != nil {
+		return "", err
+	}
+
+	extractedPath, err := d.fs.TempDir("rendered-job-list-archive")
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating temporary directory")
+	}
+	defer func() {
+		err := d.fs.RemoveAll(extractedPath)
+		if err != nil {
+			d.logger.Error(d.logTag, "Failed to delete extracted rendered job list archive: %s", err.Error())
+		}
+	}()
+
+	err = d.compressor.DecompressFileToDir(lastArchive.Path, extractedPath, boshcmd.CompressorOptions{})
+	if err != nil {
+		return "", bosherr.WrapErrorf(err, "Extracting rendered job list archive '%s'", lastArchive.Path)
+	}
+
+	var buffer bytes.Buffer
+	for _, renderedJob := range renderedJobs {
+		jobName := renderedJob.Job().Name
+
+		oldFiles, err := d.readFiles(filepath.Join(extractedPath, jobName))
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Reading previously rendered templates of job '%s'", jobName)
+		}
+
+		newFiles, err := d.readFiles(renderedJob.Path())
+		if err != nil {
+			return "", bosherr.WrapErrorf(err, "Reading rendered templates of job '%s'", jobName)
+		}
+
+		for _, relativePath := range d.sortedPaths(oldFiles, newFiles) {
+			oldName := filepath.Join("a", jobName, relativePath)
+			oldContent, found := oldFiles[relativePath]
+			if !found {
+				oldName = "/dev/null"
+			}
+
+			newName := filepath.Join("b", jobName, relativePath)
+			newContent, found := newFiles[relativePath]
+			if !found {
+				newName = "/dev/null"
+			}
+
+			buffer.WriteString(d.maskSecrets(bidiff.Unified(oldName, newName, oldContent, newContent), secretValues, lastSecretValueDigests))
+		}
+	}
+
+	return buffer.String(), nil
+}
+
+func (d *renderedJobListDiffer) SecretValues(renderedJobs []RenderedJob, jobProperties, globalProperties biproperty.Map) []string {
+	secretValues := []string{}
+	for _, renderedJob := range renderedJobs {
+		for propertyName, definition := range renderedJob.Job().Properties {
+			if !definition.Secret {
+				continue
+			}
+
+			keys := strings.Split(propertyName, ".")
+			value, found := lookupProperty(jobProperties, keys)
+			if !found {
+				value, found = lookupProperty(globalProperties, keys)
+			}
+			if !found {
+				value = definition.Default
+			}
+
+			secretValues = append(secretValues, d.secretValues(value)...)
+		}
+	}
+	return secretValues
+}
+
+func (d *renderedJobListDiffer) readSecretValueDigests(path string) (SecretValueDigests, error) {
+	var secretValueDigests SecretValueDigests
+
+	contents, err := d.fs.ReadFile(path)
+	if err != nil {
+		return secretValueDigests, bosherr.WrapErrorf(err, "Reading secret values file '%s'", path)
+	}
+
+	err = json.Unmarshal(contents, &secretValueDigests)
+	if err != nil {
+		return secretValueDigests, bosherr.WrapErrorf(err, "Unmarshalling secret values file '%s'", path)
+	}
+	return secretValueDigests, nil
+}
+
+// maskSecrets masks the secret values & the values of the digests in the changed & context lines of a file diff,
+// leaving its header & hunk lines intact.
+// Every occurrence of the values is masked, even within longer words, except for values that are too short to be told from other text.
+func (d *renderedJobListDiffer) maskSecrets(fileDiff string, secretValues []string, secretValueDigests SecretValueDigests) string {
+	if fileDiff == "" {
+		return fileDiff
+	}
+
+	// mask the longest values first, so that values containing other values are masked entirely
+	secretValues = append([]string{}, secretValues...)
+	sort.Sort(sort.Reverse(byLength(secretValues)))
+
+	lines := strings.Split(fileDiff, "\n")
+	for i, line := range lines {
+		// the first two lines are the old & new file names
+		if i < 2 || strings.HasPrefix(line, "@@") || line == "" {
+			continue
+		}
+
+		content := line[1:]
+		for _, secretValue := range secretValues {
+			if len(secretValue) >= minSecretValueLength {
+				content = strings.Replace(content, secretValue, secretMask, -1)
+			}
+		}
+		lines[i] = line[:1] + secretValueDigests.Mask(content)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// secretValues returns the non-empty lines of the scalar values of the property, since diffs are line based
+func (d *renderedJobListDiffer) secretValues(value biproperty.Property) []string {
+	values := []string{}
+	switch typedValue := value.(type) {
+	case nil:
+	case biproperty.Map:
+		for _, nestedValue := range typedValue {
+			values = append(values, d.secretValues(nestedValue)...)
+		}
+	case biproperty.List:
+		for _, nestedValue := range typedValue {
+			values = append(values, d.secretValues(nestedValue)...)
+		}
+	default:
+		for _, line := range strings.Split(fmt.Sprintf("%v", typedValue), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				values = append(values, line)
+			}
+		}
+	}
+	return values
+}
+
+// readFiles returns the contents of the files in the dir (recursively), by relative path
+func (d *renderedJobListDiffer) readFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	if !d.fs.FileExists(dir) {
+		return files, nil
+	}
+
+	err := d.fs.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := d.fs.ReadFileString(path)
+		if err != nil {
+			return bosherr.WrapErrorf(err, "Reading file '%s'", path)
+		}
+
+		files[relativePath] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (d *renderedJobListDiffer) sortedPaths(fileMaps ...map[string]string) []string {
+	paths := []string{}
+	seen := map[string]struct{}{}
+	for _, files := range fileMaps {
+		for path := range files {
+			if _, found := seen[path]; !found {
+				seen[path] = struct{}{}
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+type byLength []string
+
+func (s byLength) Len() int           { return len(s) }
+func (s byLength) Less(i, j int) bool { return len(s[i]) < len(s[j]) }
+func (s byLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,96 @@
+package templatescompiler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// minSecretValueLength is the length under which secret values are not masked,
+// as short values (like 'true' or '1') would mask unrelated text.
+const minSecretValueLength = 6
+
+// SecretValueDigests are the salted digests of the secret values that rendered job templates were rendered with.
+// They are enough to mask the values in the templates, without keeping the values themselves.
+type SecretValueDigests struct {
+	Salt    string              `json:"salt"`
+	Digests []SecretValueDigest `json:"digests"`
+}
+
+type SecretValueDigest struct {
+	Length int    `json:"length"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewSecretValueDigests returns the digests of the secret values that are long enough to be masked, with a random salt
+func NewSecretValueDigests(secretValues []string) (SecretValueDigests, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return SecretValueDigests{}, bosherr.WrapError(err, "Generating secret values salt")
+	}
+
+	digests := SecretValueDigests{Salt: hex.EncodeToString(salt), Digests: []SecretValueDigest{}}
+	seen := map[string]struct{}{}
+	for _, secretValue := range secretValues {
+		if len(secretValue) < minSecretValueLength {
+			continue
+		}
+
+		digest := digests.digest(secretValue)
+		if _, found := seen[digest]; found {
+			continue
+		}
+		seen[digest] = struct{}{}
+
+		digests.Digests = append(digests.Digests, SecretValueDigest{Length: len(secretValue), SHA256: digest})
+	}
+	return digests, nil
+}
+
+// Mask replaces every occurrence of the secret values in the text, the longest values first
+func (d SecretValueDigests) Mask(text string) string {
+	digestsByLength := map[int]map[string]struct{}{}
+	lengths := []int{}
+	for _, digest := range d.Digests {
+		if _, found := digestsByLength[digest.Length]; !found {
+			digestsByLength[digest.Length] = map[string]struct{}{}
+			lengths = append(lengths, digest.Length)
+		}
+		digestsByLength[digest.Length][digest.SHA256] = struct{}{}
+	}
+	if len(lengths) == 0 {
+		return text
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(lengths)))
+
+	var buffer bytes.Buffer
+	for i := 0; i < len(text); {
+		masked := false
+		for _, length := range lengths {
+			if i+length > len(text) {
+				continue
+			}
+			if _, found := digestsByLength[length][d.digest(text[i:i+length])]; found {
+				buffer.WriteString(secretMask)
+				i += length
+				masked = true
+				break
+			}
+		}
+		if !masked {
+			buffer.WriteByte(text[i])
+			i++
+		}
+	}
+	return buffer.String()
+}
+
+func (d SecretValueDigests) digest(value string) string {
+	sum := sha256.Sum256([]byte(d.Salt + value))
+	return hex.EncodeToString(sum[:])
+}
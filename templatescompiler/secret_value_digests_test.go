@@ -0,0 +1,46 @@
+package templatescompiler_test
+
+import (
+	"encoding/json"
+
+	. "github.com/cloudfoundry/bosh-init/templatescompiler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SecretValueDigests", func() {
+	It("masks every occurrence of the secret values, the longest values first", func() {
+		digests, err := NewSecretValueDigests([]string{"fake-password", "fake-password-2"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(digests.Mask("a=fake-password-2 b=user-fake-password. c=fake-password@host")).To(Equal("a=<redacted> b=user-<redacted>. c=<redacted>@host"))
+	})
+
+	It("does not keep secret values too short to be told from other text", func() {
+		digests, err := NewSecretValueDigests([]string{"true", "1", "fake-password", "fake-password"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(digests.Digests).To(HaveLen(1))
+		Expect(digests.Mask("enabled: true, port: 1")).To(Equal("enabled: true, port: 1"))
+	})
+
+	It("does not contain the secret values", func() {
+		digests, err := NewSecretValueDigests([]string{"fake-password"})
+		Expect(err).ToNot(HaveOccurred())
+
+		digestsBytes, err := json.Marshal(digests)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(digestsBytes)).ToNot(ContainSubstring("fake-password"))
+	})
+
+	It("salts the digests differently each time", func() {
+		digests1, err := NewSecretValueDigests([]string{"fake-password"})
+		Expect(err).ToNot(HaveOccurred())
+		digests2, err := NewSecretValueDigests([]string{"fake-password"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(digests1.Digests[0].SHA256).ToNot(Equal(digests2.Digests[0].SHA256))
+		Expect(digests2.Mask("fake-password")).To(Equal("<redacted>"))
+	})
+})
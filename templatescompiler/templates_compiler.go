@@ -47,7 +47,7 @@ func (tc templatesCompiler) Compile(releaseJobs []bireljob.Job, deploymentName s
 	globalProperties := biproperty.Map{}
 
 	return stage.Perform("Rendering job templates", func() error {
-		renderedJobList, err := tc.jobListRenderer.Render(releaseJobs, jobProperties, globalProperties, deploymentName)
+		renderedJobList, err := tc.jobListRenderer.Render(releaseJobs, map[string]LinkOverrides{}, jobProperties, globalProperties, deploymentName, "")
 		if err != nil {
 			return err
 		}
@@ -115,7 +115,7 @@ var _ = Describe("TemplatesCompiler", func() {
 		renderedJobList := NewRenderedJobList()
 		renderedJobList.Add(renderedJob)
 
-		expectJobRender = mockJobListRenderer.EXPECT().Render(jobs, jobProperties, globalProperties, deploymentName).Do(func(_, _, _, _ interface{}) {
+		expectJobRender = mockJobListRenderer.EXPECT().Render(jobs, map[string]LinkOverrides{}, jobProperties, globalProperties, deploymentName, "").Do(func(_, _, _, _, _, _ interface{}) {
 			err := fs.MkdirAll(renderedPath, os.ModePerm)
 			Expect(err).ToNot(HaveOccurred())
 			err = fs.WriteFileString(renderedTemplatePath, "fake-bin/cpi-content")
@@ -322,7 +322,7 @@ var _ = Describe("TemplatesCompiler", func() {
 					},
 				}
 
-				mockJobListRenderer.EXPECT().Render(jobs, jobProperties, globalProperties, deploymentName).Return(nil, renderError)
+				mockJobListRenderer.EXPECT().Render(jobs, map[string]LinkOverrides{}, jobProperties, globalProperties, deploymentName, "").Return(nil, renderError)
 
 				record := TemplateRecord{
 					BlobID:   "fake-blob-id",
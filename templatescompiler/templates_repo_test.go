@@ -89,6 +89,7 @@ var _ = Describe("TemplatesRepo", func() {
 				err := templatesRepo.Save(job, record)
 				fakeFS.ReadFileError = errors.New("fake-read-error")
 
+				job.Fingerprint = "other-fake-job-fingerprint"
 				_, _, err = templatesRepo.Find(job)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(ContainSubstring("fake-read-error"))
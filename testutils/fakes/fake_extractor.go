@@ -37,7 +37,11 @@ type FakeMultiResponseExtractor struct {
 }
 
 func NewFakeMultiResponseExtractor() *FakeMultiResponseExtractor {
-	return &FakeMultiResponseExtractor{decompressBehavior: map[decompressInput]decompressError{}}
+	return &FakeMultiResponseExtractor{
+		decompressBehavior: map[decompressInput]decompressError{},
+		compressBehavior:   map[compressInput]compressOutput{},
+		cleanUpBehavior:    map[cleanUpInput]cleanUpOutput{},
+	}
 }
 
 func (e *FakeMultiResponseExtractor) DecompressFileToDir(srcFile, destDir string, options boshcmd.CompressorOptions) error {